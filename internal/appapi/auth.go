@@ -22,20 +22,20 @@ func AuthMiddleware(cfg *Config, skipPaths map[string]bool, next http.Handler) h
 
 		if exAppID == "" || authHeader == "" {
 			slog.Warn("missing auth headers", "path", r.URL.Path, "ex_app_id", exAppID)
-			http.Error(w, `{"error": "missing authentication headers"}`, http.StatusUnauthorized)
+			http.Error(w, `{"error": "missing authentication headers", "code": "unauthorized"}`, http.StatusUnauthorized)
 			return
 		}
 
 		if exAppID != cfg.AppID {
 			slog.Warn("invalid EX-APP-ID", "got", exAppID, "expected", cfg.AppID)
-			http.Error(w, `{"error": "invalid EX-APP-ID"}`, http.StatusUnauthorized)
+			http.Error(w, `{"error": "invalid EX-APP-ID", "code": "unauthorized"}`, http.StatusUnauthorized)
 			return
 		}
 
 		username, secret := decodeAuthHeader(authHeader)
 		if secret != cfg.AppSecret {
 			slog.Warn("invalid app secret", "username", username)
-			http.Error(w, `{"error": "invalid app secret"}`, http.StatusUnauthorized)
+			http.Error(w, `{"error": "invalid app secret", "code": "unauthorized"}`, http.StatusUnauthorized)
 			return
 		}
 
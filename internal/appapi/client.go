@@ -6,15 +6,16 @@ package appapi
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
 )
 
 type Client struct {
@@ -24,9 +25,12 @@ type Client struct {
 
 func NewClient(cfg *Config) *Client {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
-	skipCert := os.Getenv("SKIP_CERT_VERIFY")
-	if skipCert == "true" || skipCert == "1" {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	transport.Proxy = cfg.ProxyFunc()
+	transport.MaxIdleConns = cfg.HTTPMaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.HTTPMaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.HTTPIdleConnTimeout
+	if tlsConfig := cfg.NextcloudTLSConfig(); tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
 	}
 
 	return &Client{
@@ -38,42 +42,8 @@ func NewClient(cfg *Config) *Client {
 	}
 }
 
-func (c *Client) OCSGet(path, userID string) (json.RawMessage, error) {
-	url := c.cfg.NextcloudURL + path
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, http.NoBody)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	c.setHeaders(req, userID)
-	req.Header.Set("OCS-APIRequest", "true")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("executing request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		slog.Warn("OCS request failed", "url", url, "status", resp.StatusCode, "body", string(body))
-		return nil, fmt.Errorf("OCS request failed with status %d", resp.StatusCode)
-	}
-
-	var ocsResp struct {
-		OCS struct {
-			Data json.RawMessage `json:"data"`
-		} `json:"ocs"`
-	}
-	if err := json.Unmarshal(body, &ocsResp); err != nil {
-		return nil, fmt.Errorf("parsing OCS response: %w", err)
-	}
-
-	return ocsResp.OCS.Data, nil
+func (c *Client) OCSGet(ctx context.Context, path, userID string) (json.RawMessage, error) {
+	return c.ocsRequest(ctx, "GET", path, userID, nil, true)
 }
 
 func (c *Client) setHeaders(req *http.Request, userID string) {
@@ -83,80 +53,119 @@ func (c *Client) setHeaders(req *http.Request, userID string) {
 	req.Header.Set("Accept", "application/json")
 }
 
-func (c *Client) OCSPost(path, userID string, body any) (json.RawMessage, error) {
-	jsonBody, err := json.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("marshaling body: %w", err)
-	}
-
-	url := c.cfg.NextcloudURL + path
-	req, err := http.NewRequestWithContext(context.Background(), "POST", url, bytes.NewReader(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	c.setHeaders(req, userID)
-	req.Header.Set("OCS-APIRequest", "true")
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("executing request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
-	}
+// OCSPost is never retried, as the request may have been processed before
+// the failure was observed. Use OCSPostRetryable for requests that are safe
+// to repeat.
+func (c *Client) OCSPost(ctx context.Context, path, userID string, body any) (json.RawMessage, error) {
+	return c.ocsRequest(ctx, "POST", path, userID, body, false)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		slog.Warn("OCS POST request failed", "url", url, "status", resp.StatusCode, "body", string(respBody))
-		return nil, fmt.Errorf("OCS POST request failed with status %d", resp.StatusCode)
-	}
+// OCSPostRetryable is OCSPost with the retry policy applied, for POSTs
+// that have no side effects when repeated.
+func (c *Client) OCSPostRetryable(ctx context.Context, path, userID string, body any) (json.RawMessage, error) {
+	return c.ocsRequest(ctx, "POST", path, userID, body, true)
+}
 
-	var ocsResp struct {
-		OCS struct {
-			Data json.RawMessage `json:"data"`
-		} `json:"ocs"`
-	}
-	if err := json.Unmarshal(respBody, &ocsResp); err != nil {
-		return nil, fmt.Errorf("parsing OCS response: %w", err)
-	}
+func (c *Client) OCSDelete(ctx context.Context, path, userID string) (json.RawMessage, error) {
+	return c.ocsRequest(ctx, "DELETE", path, userID, nil, true)
+}
 
-	return ocsResp.OCS.Data, nil
+func (c *Client) OCSPut(ctx context.Context, path, userID string, body any) (json.RawMessage, error) {
+	return c.ocsRequest(ctx, "PUT", path, userID, body, true)
 }
 
-func (c *Client) OCSPut(path, userID string, body any) (json.RawMessage, error) {
-	jsonBody, err := json.Marshal(body)
-	if err != nil {
-		return nil, fmt.Errorf("marshaling body: %w", err)
+// ocsRequest performs an OCS request and unwraps ocs.data. With retry set,
+// connection errors and 502/503/504 responses are retried with exponential
+// backoff, honoring Retry-After, up to OCSRetryMaxAttempts attempts.
+func (c *Client) ocsRequest(
+	ctx context.Context,
+	method, path, userID string,
+	body any,
+	retry bool,
+) (json.RawMessage, error) {
+	var jsonBody []byte
+	if body != nil {
+		var err error
+		if jsonBody, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("marshaling body: %w", err)
+		}
+	}
+
+	attempts := 1
+	if retry {
+		attempts = max(c.cfg.OCSRetryMaxAttempts, 1)
+	}
+
+	delay := c.cfg.OCSRetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		data, retryAfter, err := c.doOCS(ctx, method, path, userID, jsonBody)
+		if err == nil || retryAfter < 0 || attempt >= attempts || ctx.Err() != nil {
+			return data, err
+		}
+
+		wait := max(delay, retryAfter)
+		slog.Warn("OCS request failed, retrying",
+			"method", method,
+			"path", path,
+			"error", err,
+			"attempt", attempt,
+			"wait", wait,
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("executing request: %w", ctx.Err())
+		case <-timer.C:
+		}
+		delay *= 2
 	}
+}
 
+// doOCS performs a single attempt. retryAfter is negative when the error is
+// not retryable, otherwise the minimum wait requested by the server (or 0).
+func (c *Client) doOCS(
+	ctx context.Context,
+	method, path, userID string,
+	jsonBody []byte,
+) (data json.RawMessage, retryAfter time.Duration, err error) {
 	url := c.cfg.NextcloudURL + path
-	req, err := http.NewRequestWithContext(context.Background(), "PUT", url, bytes.NewReader(jsonBody))
+
+	var reqBody io.Reader = http.NoBody
+	if jsonBody != nil {
+		reqBody = bytes.NewReader(jsonBody)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, -1, fmt.Errorf("creating request: %w", err)
 	}
 
 	c.setHeaders(req, userID)
 	req.Header.Set("OCS-APIRequest", "true")
-	req.Header.Set("Content-Type", "application/json")
+	if jsonBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("executing request: %w", err)
+		return nil, 0, fmt.Errorf("executing request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+		return nil, 0, fmt.Errorf("reading response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		slog.Warn("OCS PUT request failed", "url", url, "status", resp.StatusCode, "body", string(respBody))
-		return nil, fmt.Errorf("OCS PUT request failed with status %d", resp.StatusCode)
+		slog.Warn("OCS request failed", "method", method, "url", url, "status", resp.StatusCode, "body", string(respBody))
+		err := fmt.Errorf("OCS %s request failed with status %d", method, resp.StatusCode)
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return nil, parseRetryAfter(resp.Header.Get("Retry-After")), err
+		}
+		return nil, -1, err
 	}
 
 	var ocsResp struct {
@@ -165,17 +174,32 @@ func (c *Client) OCSPut(path, userID string, body any) (json.RawMessage, error)
 		} `json:"ocs"`
 	}
 	if err := json.Unmarshal(respBody, &ocsResp); err != nil {
-		return nil, fmt.Errorf("parsing OCS response: %w", err)
+		return nil, -1, fmt.Errorf("parsing OCS response: %w", err)
 	}
 
-	return ocsResp.OCS.Data, nil
+	return ocsResp.OCS.Data, 0, nil
+}
+
+// parseRetryAfter accepts both forms of the header (delay in seconds or an
+// HTTP date) and caps the result at constants.OCSRetryMaxDelay.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(v); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = time.Until(t)
+	}
+	return min(max(d, 0), constants.OCSRetryMaxDelay)
 }
 
 // SetInitStatus reports init progress (0-100) back to AppAPI.
 // 100 means init complete and triggers auto-enable.
-func (c *Client) SetInitStatus(progress int) error {
+func (c *Client) SetInitStatus(ctx context.Context, progress int) error {
 	path := fmt.Sprintf("/ocs/v1.php/apps/app_api/apps/status/%s", c.cfg.AppID)
-	_, err := c.OCSPut(path, "", map[string]any{
+	_, err := c.OCSPut(ctx, path, "", map[string]any{
 		"progress": progress,
 		"error":    "",
 	})
@@ -186,6 +210,21 @@ func (c *Client) SetInitStatus(progress int) error {
 	return nil
 }
 
+// SetInitError reports a failed init with its reason back to AppAPI, which
+// shows it to the admin.
+func (c *Client) SetInitError(ctx context.Context, reason string) error {
+	path := fmt.Sprintf("/ocs/v1.php/apps/app_api/apps/status/%s", c.cfg.AppID)
+	_, err := c.OCSPut(ctx, path, "", map[string]any{
+		"progress": -1,
+		"error":    reason,
+	})
+	if err != nil {
+		return fmt.Errorf("setting init error: %w", err)
+	}
+	slog.Info("init failure reported", "reason", reason)
+	return nil
+}
+
 func encodeAuth(username, secret string) string {
 	return base64.StdEncoding.EncodeToString([]byte(username + ":" + secret))
 }
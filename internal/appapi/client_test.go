@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package appapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOCS answers the requests with statuses in turn, the last one for
+// all remaining requests, and the OCS data "ok" on 200.
+type fakeOCS struct {
+	mu       sync.Mutex
+	statuses []int
+	requests int
+}
+
+func (f *fakeOCS) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	f.mu.Lock()
+	status := f.statuses[min(f.requests, len(f.statuses)-1)]
+	f.requests++
+	f.mu.Unlock()
+	if status != http.StatusOK {
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(status)
+		return
+	}
+	_, _ = w.Write([]byte(`{"ocs":{"data":"ok"}}`))
+}
+
+func newFakeOCSClient(t *testing.T, statuses ...int) (*fakeOCS, *Client) {
+	f := &fakeOCS{statuses: statuses}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, NewClient(&Config{
+		NextcloudURL:        srv.URL,
+		OCSRetryMaxAttempts: 3,
+		OCSRetryBaseDelay:   time.Millisecond,
+	})
+}
+
+func TestOCSRetry(t *testing.T) {
+	type request func(c *Client) error
+	get := func(c *Client) error {
+		_, err := c.OCSGet(context.Background(), "/ocs", "")
+		return err
+	}
+	post := func(c *Client) error {
+		_, err := c.OCSPost(context.Background(), "/ocs", "", map[string]string{})
+		return err
+	}
+	postRetryable := func(c *Client) error {
+		_, err := c.OCSPostRetryable(context.Background(), "/ocs", "", map[string]string{})
+		return err
+	}
+
+	tests := []struct {
+		name         string
+		request      request
+		statuses     []int
+		wantErr      bool
+		wantRequests int
+	}{
+		{"get recovers", get, []int{503, 502, 200}, false, 3},
+		{"get gives up", get, []int{504}, true, 3},
+		{"get fails fast", get, []int{404, 200}, true, 1},
+		{"post not retried", post, []int{503, 200}, true, 1},
+		{"safe post recovers", postRetryable, []int{503, 200}, false, 2},
+		{"safe post fails fast", postRetryable, []int{400, 200}, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ocs, client := newFakeOCSClient(t, tt.statuses...)
+			if err := tt.request(client); (err != nil) != tt.wantErr {
+				t.Errorf("error %v, want error %v", err, tt.wantErr)
+			}
+			if ocs.requests != tt.wantRequests {
+				t.Errorf("%d requests, want %d", ocs.requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestClientUsesProxy(t *testing.T) {
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"ocs":{"data":"ok"}}`))
+	}))
+	t.Cleanup(proxy.Close)
+
+	t.Setenv("LT_PROXY_URL", proxy.URL)
+	cfg := &Config{NextcloudURL: "http://cloud.invalid", OCSRetryMaxAttempts: 1}
+	if err := cfg.loadProxy(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(cfg).OCSGet(context.Background(), "/ocs/v2.php/cloud/capabilities", ""); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(proxied) != 1 || proxied[0] != "http://cloud.invalid/ocs/v2.php/cloud/capabilities" {
+		t.Errorf("proxy got %q, want the capabilities request", proxied)
+	}
+}
+
+func TestClientTransportPool(t *testing.T) {
+	c := NewClient(&Config{
+		HTTPMaxIdleConns:        50,
+		HTTPMaxIdleConnsPerHost: 16,
+		HTTPIdleConnTimeout:     45 * time.Second,
+	})
+	transport, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport %T, want *http.Transport", c.httpClient.Transport)
+	}
+	if transport.MaxIdleConns != 50 || transport.MaxIdleConnsPerHost != 16 || transport.IdleConnTimeout != 45*time.Second {
+		t.Errorf("pool of %d idle connections, %d per host, idle timeout %v, want 50, 16, 45s",
+			transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+	if transport == http.DefaultTransport {
+		t.Error("client tunes the shared default transport")
+	}
+}
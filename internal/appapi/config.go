@@ -4,20 +4,270 @@
 package appapi
 
 import (
+	"crypto/x509"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
 )
 
+// Config is the configuration loaded from the environment. Fields holding
+// secrets are tagged for Redacted: redact:"secret" fields are
+// replaced, redact:"url" fields have the password of the URL masked and
+// redact:"query" fields also its query.
 type Config struct {
 	AppID          string
-	AppSecret      string
+	AppSecret      string `redact:"secret"`
 	AppVersion     string
 	AppPort        string
-	NextcloudURL   string
-	HPBUrl         string
-	InternalSecret string
+	NextcloudURL   string `redact:"url"`
+	HPBUrl         string `redact:"url"`
+	InternalSecret string `redact:"secret"`
+
+	// HPSharedKey is set by AppAPI's HaRP proxy, the app then listens on a
+	// unix socket instead of AppPort.
+	HPSharedKey string `redact:"secret"`
+
+	// PersistentStorage is where models and recorded transcripts are kept.
+	PersistentStorage string
+
+	// LogLevel is the minimum level logged, see constants.LogLevelTrace.
+	LogLevel slog.Level
+
+	// ModelsBaseURL, ModelsRepo and ModelsRevision locate the models on
+	// huggingface.co or a mirror exposing the same API layout.
+	ModelsBaseURL  string `redact:"url"`
+	ModelsRepo     string
+	ModelsRevision string
+
+	// CABundle is a PEM file of additional trusted CAs for the Nextcloud
+	// and HPB connections. Certificate verification of each can be
+	// skipped separately, which is insecure.
+	CABundle                string
+	NextcloudSkipCertVerify bool
+	HPBSkipCertVerify       bool
+	rootCAs                 *x509.CertPool // system roots plus CABundle, nil without it
+
+	// ProxyURL is the proxy of all outbound connections. Without it the
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY variables apply.
+	ProxyURL string `redact:"url"`
+	proxyURL *url.URL
+
+	// HTTPMaxIdleConns, HTTPMaxIdleConnsPerHost and HTTPIdleConnTimeout
+	// size the pool of keep-alive connections to Nextcloud, so frequent
+	// OCS requests reuse connections instead of new TLS handshakes.
+	HTTPMaxIdleConns        int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeout     time.Duration
+
+	// PartialTranslation also translates the stable prefix of partial
+	// transcripts, at most once per PartialTranslationDebounce per speaker.
+	PartialTranslation         bool
+	PartialTranslationDebounce time.Duration
+
+	// MergeFinalsGap joins the finals of a speaker following each other
+	// within it into one. 0 disables merging.
+	MergeFinalsGap time.Duration
+
+	// TranscriptHistorySize final transcripts no older than
+	// TranscriptHistoryMaxAge are replayed to newly added targets.
+	// A size of 0 disables the history.
+	TranscriptHistorySize   int
+	TranscriptHistoryMaxAge time.Duration
+
+	// TranslationCacheSize entries per target language are cached for
+	// TranslationCacheTTL (0 keeps them until evicted). A size of 0
+	// disables the cache.
+	TranslationCacheSize int
+	TranslationCacheTTL  time.Duration
+
+	// TranslationBackend names the translation.Backend translating
+	// segments, "ocp" for Nextcloud's task processing.
+	TranslationBackend string
+
+	// TranslationBatchWindow is how long segments are collected before
+	// being translated together. 0 translates every segment on its own.
+	TranslationBatchWindow time.Duration
+
+	// TranslationPlaceholder sends the targets a placeholder partial while
+	// a final takes longer than TranslationPlaceholderDelay to translate.
+	TranslationPlaceholder bool
+
+	// TranslationFallback sends the original transcripts to translation
+	// targets whose translator could not be set up or failed
+	// TranslationFailuresBeforeFallback times in a row, until it
+	// translates again.
+	TranslationFallback bool
+
+	// LifecycleWebhookURL receives a POST when the transcription of a
+	// call starts and ends. Empty disables it.
+	LifecycleWebhookURL string `redact:"query"`
+
+	// TranscriptWebhookURL receives a POST for each final transcript,
+	// signed with TranscriptWebhookSecret if set. Empty disables it.
+	TranscriptWebhookURL    string `redact:"query"`
+	TranscriptWebhookSecret string `redact:"secret"`
+
+	// ASRBackend names the asr.Backend transcribing the audio, "vosk" for
+	// the bundled Vosk models.
+	ASRBackend string
+	// ASREndpoint is where backends such as "remote" reach their ASR worker,
+	// unix:///path/to/socket or tcp://host:port.
+	ASREndpoint string
+
+	// RecreateRecognizerOnForceFinalize resets the Vosk recognizer after a
+	// forced finalize, and frees and recreates it every
+	// RecognizerRecreateInterval of audio to return its C memory.
+	RecreateRecognizerOnForceFinalize bool
+
+	// TranslationPollInitialInterval is the first wait before polling an
+	// OCP translation task, TranslationPollDeadline the overall limit.
+	TranslationPollInitialInterval time.Duration
+	TranslationPollDeadline        time.Duration
+
+	// RenegotiateOnDecoderFailure re-requests a speaker's offer when no
+	// Opus decoder could be created for the track.
+	RenegotiateOnDecoderFailure bool
+
+	// ICERelayOnly restricts peer connections to TURN relay candidates, for
+	// networks where only the TURN server is reachable.
+	ICERelayOnly bool
+
+	// JitterBufferPackets is how many out-of-order RTP packets of a
+	// speaker are held while waiting for a missing one. 0 disables
+	// reordering; late packets are dropped either way.
+	JitterBufferPackets int
+
+	// AudioBufferFrames is the depth of a room's decoded audio queue.
+	// Audio is dropped, and counted, when transcription falls behind it.
+	AudioBufferFrames int
+
+	// ShedLoad stops partial transcripts while the service is overloaded.
+	ShedLoad bool
+
+	// DrainTimeout bounds how long a room may take to send its last
+	// transcripts and translations when drained before closing.
+	DrainTimeout time.Duration
+
+	// SignalingAPIVersion is the Talk signaling API version (e.g. "v3").
+	// SignalingBackendURL is the backend URL sent to the HPB in hello
+	// messages, derived from it unless LT_SIGNALING_BACKEND_PATH is set.
+	SignalingAPIVersion string
+	SignalingBackendURL string `redact:"url"`
+
+	// OCSRetryMaxAttempts bounds the attempts of retryable OCS requests,
+	// the wait between them doubles starting at OCSRetryBaseDelay.
+	OCSRetryMaxAttempts int
+	OCSRetryBaseDelay   time.Duration
+
+	// Translation language allow/deny lists, see translation.LangPolicy.
+	TranslationOriginAllow []string
+	TranslationOriginDeny  []string
+	TranslationTargetAllow []string
+	TranslationTargetDeny  []string
+
+	// SpeakerNames adds the participant display name to transcripts.
+	SpeakerNames bool
+
+	// Redact masks profanity and personal data in the transcripts of new
+	// rooms, see transcript.Redactor; rooms can toggle it. RedactWords nil
+	// keeps the default word list, RedactPatterns add to the default
+	// patterns and every match is replaced by RedactMask.
+	Redact         bool
+	RedactWords    []string
+	RedactPatterns []string
+	RedactMask     string
+
+	// Punctuation restores capitalization and punctuation of the finals of
+	// new rooms, see transcript.Punctuator; rooms can toggle it.
+	// PunctuationProvider is "heuristic" or "ocp" for a text2text task,
+	// which falls back to the heuristic after PunctuationTimeout.
+	Punctuation         bool
+	PunctuationProvider string
+	PunctuationTimeout  time.Duration
+
+	// MaxTranslationTargetLangs caps the distinct target languages per
+	// room. 0 means no limit.
+	MaxTranslationTargetLangs int
+
+	// MaxRooms caps the calls transcribed at the same time. 0 means no
+	// limit.
+	MaxRooms int
+
+	// MaxRecognizersPerRoom and MaxRecognizers bound the Vosk recognizers
+	// per call and in total, evicting the least recently active speaker's.
+	// 0 means no limit.
+	MaxRecognizersPerRoom int
+	MaxRecognizers        int
+
+	// MaxTranslationTasksPerRoom and MaxTranslationTasks bound the
+	// translation tasks in flight per call and in total. Finals wait for
+	// a slot, partials are dropped. 0 means no limit.
+	MaxTranslationTasksPerRoom int
+	MaxTranslationTasks        int
+
+	// LanguageDetectionCandidates are the languages speakers are detected
+	// among when a room enables language detection, at most
+	// MaxLanguageDetectionCandidates. Empty disables detection.
+	LanguageDetectionCandidates []string
+
+	// HPBSettingsRefreshInterval is how often the STUN/TURN settings are
+	// fetched again from Talk, as TURN credentials expire. 0 disables it.
+	HPBSettingsRefreshInterval time.Duration
+
+	// ModelsRefreshInterval is how long the list of installed models is
+	// cached before the persistent storage is scanned again.
+	ModelsRefreshInterval time.Duration
+
+	// MaxIdleModels is how many Vosk models without recognizers stay loaded
+	// for reuse. 0 frees a model with its last recognizer.
+	MaxIdleModels int
+
+	// WordTimings enables Vosk word timing for exact segment times.
+	WordTimings bool
+
+	// MaxAlternatives adds up to this many hypotheses with their confidence
+	// to final transcripts, see signaling.Transcript.Alternatives. 0
+	// disables them.
+	MaxAlternatives int
+
+	// StopTokenMaxConfidence: segments that are only a stop token of the
+	// language are dropped below this mean word confidence, and kept when
+	// their confidence is unknown.
+	StopTokenMaxConfidence float64
+
+	// MinFinalWords and MinFinalChars suppress final transcripts with fewer
+	// words or letters, 0 disables them. In languages written without
+	// spaces every letter counts as a word.
+	MinFinalWords int
+	MinFinalChars int
+
+	// MixedAudio transcribes the downmixed audio of all speakers with one
+	// recognizer per room instead of one per speaker.
+	MixedAudio bool
+
+	// ServerReadTimeout, ServerWriteTimeout and ServerIdleTimeout bound
+	// the requests of AppAPI and Nextcloud to this app. The transcript
+	// stream and draining a call are exempt from ServerWriteTimeout.
+	ServerReadTimeout  time.Duration
+	ServerWriteTimeout time.Duration
+	ServerIdleTimeout  time.Duration
+
+	// LogUnsafe logs room tokens and session IDs in full instead of
+	// redacted, see RedactLogAttr.
+	LogUnsafe bool
 }
 
+var apiVersionRe = regexp.MustCompile(`^v[0-9]+$`)
+
 func LoadConfig() (*Config, error) {
 	cfg := &Config{
 		AppID:          os.Getenv("APP_ID"),
@@ -27,6 +277,7 @@ func LoadConfig() (*Config, error) {
 		NextcloudURL:   os.Getenv("NEXTCLOUD_URL"),
 		HPBUrl:         os.Getenv("LT_HPB_URL"),
 		InternalSecret: os.Getenv("LT_INTERNAL_SECRET"),
+		HPSharedKey:    os.Getenv("HP_SHARED_KEY"),
 	}
 
 	if cfg.AppID == "" {
@@ -38,17 +289,568 @@ func LoadConfig() (*Config, error) {
 	if cfg.AppPort == "" {
 		cfg.AppPort = "23000"
 	}
+	if port, err := strconv.Atoi(cfg.AppPort); err != nil || port < 1 || port > 65535 {
+		return nil, fmt.Errorf("APP_PORT must be a port number between 1 and 65535, got %q", cfg.AppPort)
+	}
 	if cfg.AppVersion == "" {
 		cfg.AppVersion = "0.0.1"
 	}
 
+	cfg.PartialTranslation = envBool("LT_PARTIAL_TRANSLATION", false)
+	debounce, err := envMillis("LT_PARTIAL_TRANSLATION_DEBOUNCE_MS", constants.PartialTranslationDebounce)
+	if err != nil {
+		return nil, err
+	}
+	cfg.PartialTranslationDebounce = debounce
+
+	if cfg.MergeFinalsGap, err = envMillis("LT_MERGE_FINALS_GAP_MS", 0); err != nil {
+		return nil, err
+	}
+
+	if cfg.TranscriptHistorySize, err = envInt("LT_TRANSCRIPT_HISTORY_SIZE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.TranscriptHistoryMaxAge, err = envSeconds("LT_TRANSCRIPT_HISTORY_MAX_AGE_SECONDS",
+		constants.TranscriptHistoryMaxAge); err != nil {
+		return nil, err
+	}
+
+	if cfg.TranslationCacheSize, err = envInt("LT_TRANSLATION_CACHE_SIZE",
+		constants.TranslationCacheSize); err != nil {
+		return nil, err
+	}
+	if cfg.TranslationCacheTTL, err = envSeconds("LT_TRANSLATION_CACHE_TTL_SECONDS",
+		constants.TranslationCacheTTL); err != nil {
+		return nil, err
+	}
+	cfg.TranslationBackend = envOr("LT_TRANSLATION_BACKEND", constants.TranslationBackend)
+	if cfg.TranslationBatchWindow, err = envMillis("LT_TRANSLATION_BATCH_WINDOW_MS",
+		constants.TranslationBatchWindow); err != nil {
+		return nil, err
+	}
+	cfg.TranslationPlaceholder = envBool("LT_TRANSLATION_PLACEHOLDER", false)
+	cfg.TranslationFallback = envBool("LT_TRANSLATION_FALLBACK_TO_ORIGINAL", false)
+	if err := cfg.loadLifecycleWebhook(); err != nil {
+		return nil, err
+	}
+	if err := cfg.loadTranscriptWebhook(); err != nil {
+		return nil, err
+	}
+
+	cfg.ASRBackend = envOr("LT_ASR_BACKEND", constants.ASRBackend)
+	if err := cfg.loadASREndpoint(); err != nil {
+		return nil, err
+	}
+	cfg.RecreateRecognizerOnForceFinalize = envBool("LT_RECREATE_RECOGNIZER_ON_FORCE_FINALIZE", true)
+
+	if cfg.TranslationPollInitialInterval, err = envMillis("LT_TRANSLATION_POLL_INITIAL_MS",
+		constants.OCPPollInitialInterval); err != nil {
+		return nil, err
+	}
+	if cfg.TranslationPollInitialInterval == 0 {
+		return nil, fmt.Errorf("LT_TRANSLATION_POLL_INITIAL_MS must be positive")
+	}
+	if cfg.TranslationPollDeadline, err = envSeconds("LT_TRANSLATION_POLL_DEADLINE_SECONDS",
+		constants.OCPPollDeadline); err != nil {
+		return nil, err
+	}
+	if cfg.TranslationPollDeadline == 0 {
+		return nil, fmt.Errorf("LT_TRANSLATION_POLL_DEADLINE_SECONDS must be positive")
+	}
+
+	cfg.RenegotiateOnDecoderFailure = envBool("LT_RENEGOTIATE_ON_DECODER_FAILURE", true)
+	cfg.ICERelayOnly = envBool("LT_ICE_RELAY_ONLY", false)
+
+	if cfg.JitterBufferPackets, err = envInt("LT_JITTER_BUFFER_PACKETS",
+		constants.JitterBufferPackets); err != nil {
+		return nil, err
+	}
+	if cfg.AudioBufferFrames, err = envInt("LT_AUDIO_BUFFER_FRAMES",
+		constants.AudioBufferFrames); err != nil {
+		return nil, err
+	}
+	if cfg.AudioBufferFrames == 0 {
+		return nil, fmt.Errorf("LT_AUDIO_BUFFER_FRAMES must be positive")
+	}
+
+	cfg.ShedLoad = envBool("LT_SHED_LOAD", false)
+
+	if cfg.DrainTimeout, err = envSeconds("LT_DRAIN_TIMEOUT_SECONDS", constants.DrainTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.DrainTimeout == 0 {
+		return nil, fmt.Errorf("LT_DRAIN_TIMEOUT_SECONDS must be positive")
+	}
+
+	if cfg.OCSRetryMaxAttempts, err = envInt("LT_OCS_RETRY_MAX_ATTEMPTS",
+		constants.OCSRetryMaxAttempts); err != nil {
+		return nil, err
+	}
+	if cfg.OCSRetryBaseDelay, err = envMillis("LT_OCS_RETRY_BASE_DELAY_MS",
+		constants.OCSRetryBaseDelay); err != nil {
+		return nil, err
+	}
+
+	cfg.TranslationOriginAllow = envList("LT_TRANSLATION_ORIGIN_LANGS_ALLOW")
+	cfg.TranslationOriginDeny = envList("LT_TRANSLATION_ORIGIN_LANGS_DENY")
+	cfg.TranslationTargetAllow = envList("LT_TRANSLATION_TARGET_LANGS_ALLOW")
+	cfg.TranslationTargetDeny = envList("LT_TRANSLATION_TARGET_LANGS_DENY")
+
+	cfg.SpeakerNames = envBool("LT_SPEAKER_NAMES", false)
+
+	if err := cfg.loadRedaction(); err != nil {
+		return nil, err
+	}
+
+	cfg.Punctuation = envBool("LT_PUNCTUATION", false)
+	cfg.PunctuationProvider = envOr("LT_PUNCTUATION_PROVIDER", constants.PunctuationProvider)
+	if cfg.PunctuationProvider != "heuristic" && cfg.PunctuationProvider != "ocp" {
+		return nil, fmt.Errorf("invalid LT_PUNCTUATION_PROVIDER %q: must be heuristic or ocp", cfg.PunctuationProvider)
+	}
+	if cfg.PunctuationTimeout, err = envMillis("LT_PUNCTUATION_TIMEOUT_MS",
+		constants.PunctuationTimeout); err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxTranslationTargetLangs, err = envInt("LT_MAX_TRANSLATION_TARGET_LANGS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRooms, err = envInt("LT_MAX_ROOMS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRecognizersPerRoom, err = envInt("LT_MAX_RECOGNIZERS_PER_ROOM", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRecognizers, err = envInt("LT_MAX_RECOGNIZERS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxTranslationTasksPerRoom, err = envInt("LT_MAX_TRANSLATION_TASKS_PER_ROOM",
+		constants.MaxTranslationTasksPerRoom); err != nil {
+		return nil, err
+	}
+	if cfg.MaxTranslationTasks, err = envInt("LT_MAX_TRANSLATION_TASKS", 0); err != nil {
+		return nil, err
+	}
+	cfg.LanguageDetectionCandidates = envList("LT_LANGUAGE_DETECTION_CANDIDATES")
+	if len(cfg.LanguageDetectionCandidates) > constants.MaxLanguageDetectionCandidates {
+		return nil, fmt.Errorf("LT_LANGUAGE_DETECTION_CANDIDATES lists %d languages, at most %d are allowed",
+			len(cfg.LanguageDetectionCandidates), constants.MaxLanguageDetectionCandidates)
+	}
+
+	if cfg.HPBSettingsRefreshInterval, err = envSeconds("LT_HPB_SETTINGS_REFRESH_SECONDS",
+		constants.HPBSettingsRefreshInterval); err != nil {
+		return nil, err
+	}
+
+	if cfg.ModelsRefreshInterval, err = envSeconds("LT_MODELS_REFRESH_SECONDS",
+		constants.ModelsRefreshInterval); err != nil {
+		return nil, err
+	}
+	if cfg.MaxIdleModels, err = envInt("LT_MAX_IDLE_MODELS", constants.MaxIdleModels); err != nil {
+		return nil, err
+	}
+	if cfg.MaxIdleModels < 0 {
+		return nil, fmt.Errorf("LT_MAX_IDLE_MODELS must not be negative")
+	}
+
+	cfg.WordTimings = envBool("LT_WORD_TIMINGS", false)
+	if cfg.MaxAlternatives, err = envInt("LT_MAX_ALTERNATIVES", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxAlternatives > constants.MaxAlternatives {
+		return nil, fmt.Errorf("LT_MAX_ALTERNATIVES must be at most %d", constants.MaxAlternatives)
+	}
+
+	if cfg.MinFinalWords, err = envInt("LT_MIN_FINAL_WORDS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MinFinalChars, err = envInt("LT_MIN_FINAL_CHARS", 0); err != nil {
+		return nil, err
+	}
+
+	cfg.MixedAudio = envBool("LT_MIXED_AUDIO", false)
+
+	if cfg.StopTokenMaxConfidence, err = envFraction("LT_STOP_TOKEN_MAX_CONFIDENCE",
+		constants.StopTokenMaxConfidence); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.loadURLs(); err != nil {
+		return nil, err
+	}
+	if err := cfg.loadSignalingBackend(); err != nil {
+		return nil, err
+	}
+	if err := cfg.loadTLS(); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPMaxIdleConns, err = envInt("LT_HTTP_MAX_IDLE_CONNS",
+		constants.HTTPMaxIdleConns); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPMaxIdleConnsPerHost, err = envInt("LT_HTTP_MAX_IDLE_CONNS_PER_HOST",
+		constants.HTTPMaxIdleConnsPerHost); err != nil {
+		return nil, err
+	}
+	if cfg.HTTPIdleConnTimeout, err = envSeconds("LT_HTTP_IDLE_CONN_TIMEOUT_SECONDS",
+		constants.HTTPIdleConnTimeout); err != nil {
+		return nil, err
+	}
+	if err := cfg.loadProxy(); err != nil {
+		return nil, err
+	}
+	cfg.LogUnsafe = envBool("LT_LOG_UNSAFE", false)
+	switch level := strings.ToLower(os.Getenv("LT_LOG_LEVEL")); level {
+	case "", "info":
+		cfg.LogLevel = slog.LevelInfo
+	case "debug":
+		cfg.LogLevel = slog.LevelDebug
+	case "trace":
+		cfg.LogLevel = constants.LogLevelTrace
+	default:
+		return nil, fmt.Errorf("LT_LOG_LEVEL must be info, debug or trace, got %q", level)
+	}
+
+	if cfg.PersistentStorage = os.Getenv("APP_PERSISTENT_STORAGE"); cfg.PersistentStorage == "" {
+		cfg.PersistentStorage = constants.PersistentStorage
+	}
+	if err := cfg.loadModelSource(); err != nil {
+		return nil, err
+	}
+
+	for _, t := range []struct {
+		key      string
+		dst      *time.Duration
+		fallback time.Duration
+	}{
+		{"LT_SERVER_READ_TIMEOUT_SECONDS", &cfg.ServerReadTimeout, constants.ServerReadTimeout},
+		{"LT_SERVER_WRITE_TIMEOUT_SECONDS", &cfg.ServerWriteTimeout, constants.ServerWriteTimeout},
+		{"LT_SERVER_IDLE_TIMEOUT_SECONDS", &cfg.ServerIdleTimeout, constants.ServerIdleTimeout},
+	} {
+		if *t.dst, err = envSeconds(t.key, t.fallback); err != nil {
+			return nil, err
+		}
+		if *t.dst == 0 {
+			return nil, fmt.Errorf("%s must be positive", t.key)
+		}
+	}
+
 	return cfg, nil
 }
 
-func PersistentStorage() string {
-	path := os.Getenv("APP_PERSISTENT_STORAGE")
+// SpreedSignalingPath returns the OCS path of a Talk signaling endpoint
+// (e.g. "settings" or "backend") for the configured API version.
+func (c *Config) SpreedSignalingPath(endpoint string) string {
+	return fmt.Sprintf("/ocs/v2.php/apps/spreed/api/%s/signaling/%s", c.SignalingAPIVersion, endpoint)
+}
+
+func (c *Config) loadSignalingBackend() error {
+	c.SignalingAPIVersion = os.Getenv("LT_SIGNALING_API_VERSION")
+	if c.SignalingAPIVersion == "" {
+		c.SignalingAPIVersion = constants.SignalingAPIVersion
+	}
+	if !apiVersionRe.MatchString(c.SignalingAPIVersion) {
+		return fmt.Errorf("invalid LT_SIGNALING_API_VERSION %q: must look like \"v3\"", c.SignalingAPIVersion)
+	}
+
+	path := os.Getenv("LT_SIGNALING_BACKEND_PATH")
 	if path == "" {
-		return "/nc_app_live_transcription_data"
+		path = c.SpreedSignalingPath("backend")
+	} else if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("invalid LT_SIGNALING_BACKEND_PATH %q: must start with '/'", path)
+	}
+	c.SignalingBackendURL = strings.TrimRight(c.NextcloudURL, "/") + path
+
+	// NEXTCLOUD_URL is optional for local development without HPB
+	if c.NextcloudURL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.SignalingBackendURL)
+	if err != nil {
+		return fmt.Errorf("invalid signaling backend URL %q: %w", c.SignalingBackendURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid signaling backend URL %q: must be an absolute http(s) URL", c.SignalingBackendURL)
+	}
+	return nil
+}
+
+func envBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	return v == "true" || v == "1"
+}
+
+// envList parses a comma-separated list, ignoring empty items.
+func envList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// envInt parses a non-negative integer.
+func envInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+// envFraction parses a number between 0 and 1.
+func envFraction(key string, fallback float64) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > 1 {
+		return 0, fmt.Errorf("%s must be a number between 0 and 1, got %q", key, v)
+	}
+	return f, nil
+}
+
+// envSeconds parses a non-negative duration given in seconds.
+func envSeconds(key string, fallback time.Duration) (time.Duration, error) {
+	if os.Getenv(key) == "" {
+		return fallback, nil
+	}
+	n, err := envInt(key, 0)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// envMillis parses a non-negative duration given in milliseconds.
+func envMillis(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	ms, err := strconv.Atoi(v)
+	if err != nil || ms < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer (milliseconds), got %q", key, v)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+const redacted = "***"
+
+// Redacted returns a copy of the config that is safe to show to operators,
+// with the fields tagged redact masked.
+func (c *Config) Redacted() Config {
+	r := *c
+	v := reflect.ValueOf(&r).Elem()
+	for i := range v.NumField() {
+		mode := v.Type().Field(i).Tag.Get("redact")
+		if f := v.Field(i); mode != "" && f.String() != "" {
+			f.SetString(redactValue(mode, f.String()))
+		}
+	}
+	return r
+}
+
+func redactValue(mode, value string) string {
+	if mode == "secret" {
+		return redacted
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		// Not shown as is, it may hold credentials url.Parse cannot find
+		return redacted
+	}
+	if u.User != nil {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	// Webhooks often authenticate with a token in the query
+	if mode == "query" && u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+	return u.String()
+}
+
+// loadProxy validates LT_PROXY_URL.
+func (c *Config) loadProxy() error {
+	if c.ProxyURL = os.Getenv("LT_PROXY_URL"); c.ProxyURL == "" {
+		c.proxyURL = nil
+		return nil
+	}
+	u, err := url.Parse(c.ProxyURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid LT_PROXY_URL: must be an absolute URL like http://proxy:3128")
+	}
+	c.proxyURL = u
+	return nil
+}
+
+// ProxyFunc returns the proxy selection for outbound requests: ProxyURL if
+// set, otherwise the one of the standard proxy environment variables.
+func (c *Config) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	if c.proxyURL != nil {
+		return http.ProxyURL(c.proxyURL)
+	}
+	return http.ProxyFromEnvironment
+}
+
+// loadURLs validates NEXTCLOUD_URL and LT_HPB_URL, so a typo fails at
+// startup instead of on the first connection. Both are optional for local
+// development without HPB.
+func (c *Config) loadURLs() error {
+	if c.NextcloudURL != "" {
+		u, err := url.Parse(c.NextcloudURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid NEXTCLOUD_URL %q: must be an absolute http(s) URL "+
+				"like https://cloud.example.com", c.NextcloudURL)
+		}
+		if u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("invalid NEXTCLOUD_URL %q: must not have a query or fragment", c.NextcloudURL)
+		}
+		c.NextcloudURL = strings.TrimRight(c.NextcloudURL, "/")
+	}
+	if c.HPBUrl != "" {
+		if _, err := HPBWebSocketURL(c.HPBUrl); err != nil {
+			return err
+		}
+		c.HPBUrl = strings.TrimRight(c.HPBUrl, "/")
+	}
+	return nil
+}
+
+// HPBWebSocketURL turns the configured HPB URL into the websocket URL of its
+// signaling endpoint: http(s) becomes ws(s) and /spreed is appended unless
+// present.
+func HPBWebSocketURL(hpbURL string) (string, error) {
+	wsURL := strings.TrimRight(hpbURL, "/")
+	if rest, ok := strings.CutPrefix(wsURL, "http://"); ok {
+		wsURL = "ws://" + rest
+	} else if rest, ok := strings.CutPrefix(wsURL, "https://"); ok {
+		wsURL = "wss://" + rest
+	}
+	if !strings.HasSuffix(wsURL, "/spreed") {
+		wsURL += "/spreed"
+	}
+
+	u, err := url.Parse(wsURL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return "", fmt.Errorf("invalid LT_HPB_URL %q: must be an absolute http(s) or ws(s) URL "+
+			"like wss://cloud.example.com/standalone-signaling/spreed", hpbURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid LT_HPB_URL %q: must not have a query or fragment", hpbURL)
+	}
+	return wsURL, nil
+}
+
+// loadModelSource reads LT_MODELS_BASE_URL, LT_MODELS_REPO and
+// LT_MODELS_REVISION, falling back to the upstream HF repository.
+func (c *Config) loadModelSource() error {
+	c.ModelsBaseURL = strings.TrimRight(envOr("LT_MODELS_BASE_URL", constants.ModelsBaseURL), "/")
+	c.ModelsRepo = strings.Trim(envOr("LT_MODELS_REPO", constants.ModelsRepo), "/")
+	c.ModelsRevision = envOr("LT_MODELS_REVISION", constants.ModelsRevision)
+
+	u, err := url.Parse(c.ModelsBaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid LT_MODELS_BASE_URL %q: %w", c.ModelsBaseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid LT_MODELS_BASE_URL %q: must be an absolute http(s) URL", c.ModelsBaseURL)
+	}
+	if c.ModelsRepo == "" {
+		return fmt.Errorf("invalid LT_MODELS_REPO: must not be empty")
+	}
+	if strings.Contains(c.ModelsRevision, "/") {
+		return fmt.Errorf("invalid LT_MODELS_REVISION %q: must not contain '/'", c.ModelsRevision)
+	}
+	return nil
+}
+
+// loadRedaction reads the LT_REDACT variables. An empty LT_REDACT_WORDS
+// masks no words; LT_REDACT_PATTERNS are separated by whitespace, which
+// patterns match with \s.
+func (c *Config) loadRedaction() error {
+	c.Redact = envBool("LT_REDACT", false)
+	if _, ok := os.LookupEnv("LT_REDACT_WORDS"); ok {
+		c.RedactWords = append([]string{}, envList("LT_REDACT_WORDS")...)
+	}
+	c.RedactPatterns = strings.Fields(os.Getenv("LT_REDACT_PATTERNS"))
+	for _, p := range c.RedactPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("invalid LT_REDACT_PATTERNS entry %q: %w", p, err)
+		}
+	}
+	c.RedactMask = envOr("LT_REDACT_MASK", constants.RedactMask)
+	return nil
+}
+
+// loadLifecycleWebhook reads LT_LIFECYCLE_WEBHOOK_URL.
+func (c *Config) loadLifecycleWebhook() error {
+	c.LifecycleWebhookURL = os.Getenv("LT_LIFECYCLE_WEBHOOK_URL")
+	return checkWebhookURL("LT_LIFECYCLE_WEBHOOK_URL", c.LifecycleWebhookURL)
+}
+
+// loadTranscriptWebhook reads LT_TRANSCRIPT_WEBHOOK_URL and its secret.
+func (c *Config) loadTranscriptWebhook() error {
+	c.TranscriptWebhookURL = os.Getenv("LT_TRANSCRIPT_WEBHOOK_URL")
+	c.TranscriptWebhookSecret = os.Getenv("LT_TRANSCRIPT_WEBHOOK_SECRET")
+	if c.TranscriptWebhookSecret != "" && c.TranscriptWebhookURL == "" {
+		return fmt.Errorf("LT_TRANSCRIPT_WEBHOOK_SECRET requires LT_TRANSCRIPT_WEBHOOK_URL")
+	}
+	return checkWebhookURL("LT_TRANSCRIPT_WEBHOOK_URL", c.TranscriptWebhookURL)
+}
+
+// checkWebhookURL validates an optional webhook URL.
+func checkWebhookURL(key, rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid %s %q: must be an absolute http(s) URL", key, rawURL)
+	}
+	return nil
+}
+
+// loadASREndpoint reads LT_ASR_ENDPOINT. Whether the backend needs one is
+// checked against its capabilities on startup.
+func (c *Config) loadASREndpoint() error {
+	c.ASREndpoint = os.Getenv("LT_ASR_ENDPOINT")
+	if c.ASREndpoint == "" {
+		return nil
+	}
+	_, _, err := ASRDialAddress(c.ASREndpoint)
+	return err
+}
+
+// ASRDialAddress splits an ASR worker endpoint into the network and address
+// to dial: unix:///run/asr.sock or tcp://asr:9000.
+func ASRDialAddress(endpoint string) (network, address string, err error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid LT_ASR_ENDPOINT %q: %w", endpoint, err)
+	}
+	switch {
+	case u.Scheme == "unix" && u.Host == "" && u.Path != "":
+		return "unix", u.Path, nil
+	case u.Scheme == "tcp" && u.Host != "" && u.Port() != "" && (u.Path == "" || u.Path == "/"):
+		return "tcp", u.Host, nil
+	}
+	return "", "", fmt.Errorf("invalid LT_ASR_ENDPOINT %q: must be unix:///path/to/socket or tcp://host:port", endpoint)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
 	}
-	return path
+	return fallback
 }
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package appapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+)
+
+// sensitiveLogKeys are log attributes identifying a call or a participant.
+// Anyone reading retained logs could use them to join or follow a call.
+var sensitiveLogKeys = map[string]bool{
+	"room_token":         true,
+	"session_id":         true,
+	"resume_id":          true,
+	"nc_session_id":      true,
+	"speaker_session_id": true,
+	"speaker_sid":        true,
+	"offer_sid":          true,
+}
+
+// RedactLogAttr is a slog ReplaceAttr function replacing the values of
+// sensitive attributes by a short hash. The same value always gives the same
+// hash, so log lines of a call can still be correlated.
+func RedactLogAttr(_ []string, a slog.Attr) slog.Attr {
+	if !sensitiveLogKeys[a.Key] || a.Value.Kind() != slog.KindString {
+		return a
+	}
+	if v := a.Value.String(); v != "" {
+		a.Value = slog.StringValue(RedactLogValue(v))
+	}
+	return a
+}
+
+// RedactLogValue returns a short, stable stand-in for a sensitive value.
+func RedactLogValue(v string) string {
+	sum := sha256.Sum256([]byte(v))
+	return "#" + hex.EncodeToString(sum[:4])
+}
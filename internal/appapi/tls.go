@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package appapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// loadTLS reads how the Nextcloud and HPB certificates are verified.
+// LT_CA_BUNDLE adds the certificates of an internal CA to the system roots.
+// Skipping verification is a separate opt-in per endpoint; SKIP_CERT_VERIFY
+// still disables it for both unless overridden.
+func (c *Config) loadTLS() error {
+	skip := envBool("SKIP_CERT_VERIFY", false)
+	c.NextcloudSkipCertVerify = envBool("LT_NEXTCLOUD_SKIP_CERT_VERIFY", skip)
+	c.HPBSkipCertVerify = envBool("LT_HPB_SKIP_CERT_VERIFY", skip)
+	if c.NextcloudSkipCertVerify {
+		slog.Warn("TLS certificate verification is DISABLED for Nextcloud, connections can be intercepted; " +
+			"prefer LT_CA_BUNDLE to trust an internal CA")
+	}
+	if c.HPBSkipCertVerify {
+		slog.Warn("TLS certificate verification is DISABLED for the HPB, connections can be intercepted; " +
+			"prefer LT_CA_BUNDLE to trust an internal CA")
+	}
+
+	c.CABundle = os.Getenv("LT_CA_BUNDLE")
+	if c.CABundle == "" {
+		return nil
+	}
+	pem, err := os.ReadFile(c.CABundle)
+	if err != nil {
+		return fmt.Errorf("invalid LT_CA_BUNDLE: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("invalid LT_CA_BUNDLE %q: no PEM certificate found", c.CABundle)
+	}
+	c.rootCAs = pool
+	return nil
+}
+
+// NextcloudTLSConfig returns the TLS config for requests to Nextcloud, or
+// nil for the defaults.
+func (c *Config) NextcloudTLSConfig() *tls.Config {
+	return c.tlsConfig(c.NextcloudSkipCertVerify)
+}
+
+// HPBTLSConfig returns the TLS config for the HPB connection, or nil for
+// the defaults.
+func (c *Config) HPBTLSConfig() *tls.Config {
+	return c.tlsConfig(c.HPBSkipCertVerify)
+}
+
+func (c *Config) tlsConfig(skipVerify bool) *tls.Config {
+	if skipVerify {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+	if c.rootCAs == nil {
+		return nil
+	}
+	return &tls.Config{RootCAs: c.rootCAs}
+}
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+// Package asr defines the speech recognition backend a room's audio is
+// transcribed with. Vosk is the default, "remote" streams the audio to an
+// external ASR worker; other engines such as Whisper plug in through
+// RegisterBackend.
+package asr
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+)
+
+// SampleRate is the rate of the PCM fed to a Transcriber.
+const SampleRate = 16000
+
+// Transcriber transcribes the speakers of one room. Audio is fed as 16 kHz
+// mono 16-bit little-endian PCM per session; partial and final transcripts
+// are sent to the channel the transcriber was created with.
+type Transcriber interface {
+	Feed(sessionID string, pcm []byte) error
+	// Remove finalizes and forgets a session whose audio ended.
+	Remove(sessionID string)
+	// FlushAll finalizes every running utterance.
+	FlushAll()
+	// CloseAll flushes and releases all resources; the transcriber is not
+	// used afterwards.
+	CloseAll()
+
+	SetLanguage(language string) error
+	// SetSpeakerLanguage overrides the room language for one session, ""
+	// reverts to it.
+	SetSpeakerLanguage(sessionID, language string) error
+	// SetLanguageDetection enables detecting each speaker's language among
+	// the candidates, none disables it.
+	SetLanguageDetection(candidates []string)
+	// SetGrammar constrains recognition to the phrases: other speech is
+	// not transcribed. None returns to free recognition. Backends unable
+	// to apply a grammar recognize freely.
+	SetGrammar(phrases []string)
+	SetForceFinalizeChunks(n int)
+	SetPartials(enabled bool)
+
+	// DroppedFinals counts final transcripts lost to a full channel.
+	DroppedFinals() int64
+	Stats() (recognizers int, language string)
+}
+
+// Backend creates the transcriber of a room with the language languageID.
+type Backend func(cfg *appapi.Config, languageID string, transcriptCh chan signaling.Transcript) (Transcriber, error)
+
+// Capabilities describe what a backend needs of the deployment.
+type Capabilities struct {
+	// LocalModels is set for backends transcribing with the models this
+	// app installs, which readiness and language changes then check for.
+	LocalModels bool
+	// Endpoint is set for backends reaching an ASR worker at
+	// LT_ASR_ENDPOINT.
+	Endpoint bool
+}
+
+type registeredBackend struct {
+	create Backend
+	caps   Capabilities
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]registeredBackend{
+		"remote": {create: newRemoteBackend, caps: Capabilities{Endpoint: true}},
+	}
+)
+
+// RegisterBackend makes a speech recognition backend selectable with
+// LT_ASR_BACKEND. It is meant to be called from an init function.
+func RegisterBackend(name string, backend Backend, caps Capabilities) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = registeredBackend{create: backend, caps: caps}
+}
+
+// NeedsLocalModels reports whether the named backend transcribes with the
+// installed models.
+func NeedsLocalModels(name string) bool {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return backends[name].caps.LocalModels
+}
+
+// NeedsEndpoint reports whether the named backend needs LT_ASR_ENDPOINT.
+func NeedsEndpoint(name string) bool {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return backends[name].caps.Endpoint
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// HasBackend reports whether a backend of that name is registered.
+func HasBackend(name string) bool {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	_, ok := backends[name]
+	return ok
+}
+
+// NewTranscriber creates a transcriber with the backend selected in cfg.
+func NewTranscriber(cfg *appapi.Config, languageID string, transcriptCh chan signaling.Transcript) (Transcriber, error) {
+	backendsMu.RLock()
+	backend, ok := backends[cfg.ASRBackend]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown ASR backend %q", cfg.ASRBackend)
+	}
+	return backend.create(cfg, languageID, transcriptCh)
+}
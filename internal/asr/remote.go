@@ -0,0 +1,535 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package asr
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+)
+
+// The remote backend streams a room's audio to an external ASR worker and
+// reads its transcripts back, keeping the recognizers and their memory out
+// of this process. Every room opens its own connection to LT_ASR_ENDPOINT.
+//
+// Both directions exchange frames of a 1-byte type, a 4-byte big-endian
+// payload length of at most ASRMaxFrameSize and the payload:
+//
+//	frameControl     JSON remoteControl, both directions
+//	frameAudio       client → worker: 2-byte big-endian session ID length,
+//	                 session ID, 16 kHz mono s16le PCM
+//	frameTranscript  worker → client: JSON remoteTranscript
+//
+// The client starts every connection with a "start" control carrying the
+// room language and sample rate, followed by the room's current settings
+// ("partials", "force_finalize_chunks", "detect", "grammar",
+// "speaker_language").
+// Later changes are sent as they happen. "end" tells the worker a
+// session's audio ended, so it finalizes and forgets it, and "flush" with
+// an increasing "id" asks it to finalize every utterance and answer
+// "flushed" with the same id. The worker may send "error" controls, which
+// are logged.
+//
+// A lost connection is redialed with exponential backoff; the settings are
+// replayed on the new connection and audio is dropped until it is up.
+const (
+	frameControl    byte = 1
+	frameAudio      byte = 2
+	frameTranscript byte = 3
+)
+
+var errWorkerDisconnected = errors.New("not connected to the ASR worker")
+
+type remoteControl struct {
+	Type       string   `json:"type"`
+	Language   string   `json:"language,omitempty"`
+	SessionID  string   `json:"session_id,omitempty"`
+	SampleRate int      `json:"sample_rate,omitempty"`
+	Candidates []string `json:"candidates,omitempty"`
+	Phrases    []string `json:"phrases,omitempty"`
+	Chunks     int      `json:"chunks,omitempty"`
+	Enabled    *bool    `json:"enabled,omitempty"`
+	ID         uint64   `json:"id,omitempty"`      // of "flush" and "flushed"
+	Message    string   `json:"message,omitempty"` // of "error"
+}
+
+type remoteTranscript struct {
+	SessionID string `json:"session_id"`
+	Language  string `json:"language,omitempty"` // the speaker's if empty
+	Message   string `json:"message"`
+	Final     bool   `json:"final"`
+	StartMs   int64  `json:"start_ms,omitempty"`
+	EndMs     int64  `json:"end_ms,omitempty"`
+
+	Alternatives []signaling.Alternative `json:"alternatives,omitempty"` // of finals
+}
+
+type remoteTranscriber struct {
+	network      string
+	address      string
+	transcriptCh chan signaling.Transcript
+	logger       *slog.Logger
+
+	// Guards the connection, so frames are written whole and settings
+	// reach the worker in the order they were made. Taken before mu.
+	writeMu   sync.Mutex
+	conn      net.Conn
+	flushSeq  uint64
+	flushed   chan struct{} // signalled on every "flushed"
+	lastFlush atomic.Uint64 // id of the last "flushed"
+
+	// Guards the settings replayed on reconnect. Never held during I/O,
+	// so delivering transcripts does not wait for a slow write.
+	mu           sync.Mutex
+	language     string
+	speakerLangs map[string]string
+	candidates   []string
+	grammar      []string
+	chunks       int
+	partials     bool
+	sessions     map[string]struct{}
+
+	droppedFinals atomic.Int64
+	droppedAudio  atomic.Int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newRemoteBackend(cfg *appapi.Config, languageID string, transcriptCh chan signaling.Transcript) (Transcriber, error) {
+	if cfg.ASREndpoint == "" {
+		return nil, fmt.Errorf("the remote ASR backend needs LT_ASR_ENDPOINT")
+	}
+	network, address, err := appapi.ASRDialAddress(cfg.ASREndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rt := &remoteTranscriber{
+		network:      network,
+		address:      address,
+		transcriptCh: transcriptCh,
+		flushed:      make(chan struct{}, 1),
+		logger:       slog.With("component", "remote_asr", "endpoint", cfg.ASREndpoint),
+		language:     languageID,
+		speakerLangs: make(map[string]string),
+		partials:     true,
+		sessions:     make(map[string]struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+
+	// A worker that is down when the room starts fails the request, one
+	// going away later is reconnected to
+	conn, err := rt.connect(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	go rt.run(conn)
+	return rt, nil
+}
+
+// connect dials the worker and sends the start control and the settings.
+func (rt *remoteTranscriber) connect(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: constants.ASRDialTimeout}
+	conn, err := dialer.DialContext(ctx, rt.network, rt.address)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the ASR worker: %w", err)
+	}
+
+	rt.writeMu.Lock()
+	defer rt.writeMu.Unlock()
+
+	rt.conn = conn
+	if err := rt.replayLocked(); err != nil {
+		rt.conn = nil
+		conn.Close()
+		return nil, fmt.Errorf("starting the ASR worker session: %w", err)
+	}
+	rt.logger.Info("connected to ASR worker", "language", rt.language)
+	return conn, nil
+}
+
+// replayLocked sends the start control followed by every setting differing
+// from the worker's defaults. Must be called with rt.writeMu held.
+func (rt *remoteTranscriber) replayLocked() error {
+	rt.mu.Lock()
+	msgs := []remoteControl{{Type: "start", Language: rt.language, SampleRate: SampleRate}}
+	if partials := rt.partials; !partials {
+		msgs = append(msgs, remoteControl{Type: "partials", Enabled: &partials})
+	}
+	if rt.chunks > 0 {
+		msgs = append(msgs, remoteControl{Type: "force_finalize_chunks", Chunks: rt.chunks})
+	}
+	if len(rt.candidates) > 0 {
+		msgs = append(msgs, remoteControl{Type: "detect", Candidates: rt.candidates})
+	}
+	if len(rt.grammar) > 0 {
+		msgs = append(msgs, remoteControl{Type: "grammar", Phrases: rt.grammar})
+	}
+	for sid, lang := range rt.speakerLangs {
+		msgs = append(msgs, remoteControl{Type: "speaker_language", SessionID: sid, Language: lang})
+	}
+	rt.mu.Unlock()
+
+	for _, msg := range msgs {
+		if err := rt.controlLocked(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run reads from the connection until it fails and reconnects, until the
+// transcriber is closed.
+func (rt *remoteTranscriber) run(conn net.Conn) {
+	defer close(rt.done)
+
+	delay := constants.ReconnectBaseDelay
+	for {
+		if conn != nil {
+			err := rt.readLoop(conn)
+			rt.disconnect(conn)
+			if rt.ctx.Err() != nil {
+				return
+			}
+			rt.logger.Warn("ASR worker connection lost", "error", err)
+			delay = constants.ReconnectBaseDelay
+		}
+
+		select {
+		case <-rt.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		var err error
+		if conn, err = rt.connect(rt.ctx); err != nil {
+			delay = min(delay*2, constants.ASRReconnectMaxDelay)
+			rt.logger.Warn("reconnecting to ASR worker failed", "error", err, "retry_in", delay)
+			conn = nil
+		}
+	}
+}
+
+func (rt *remoteTranscriber) disconnect(conn net.Conn) {
+	rt.writeMu.Lock()
+	if rt.conn == conn {
+		rt.conn = nil
+	}
+	rt.writeMu.Unlock()
+	conn.Close()
+}
+
+func (rt *remoteTranscriber) readLoop(conn net.Conn) error {
+	for {
+		typ, payload, err := readFrame(conn)
+		if err != nil {
+			return err
+		}
+
+		switch typ {
+		case frameTranscript:
+			var t remoteTranscript
+			if err := json.Unmarshal(payload, &t); err != nil {
+				return fmt.Errorf("decoding transcript: %w", err)
+			}
+			rt.deliver(t)
+		case frameControl:
+			var msg remoteControl
+			if err := json.Unmarshal(payload, &msg); err != nil {
+				return fmt.Errorf("decoding control: %w", err)
+			}
+			switch msg.Type {
+			case "flushed":
+				rt.lastFlush.Store(msg.ID)
+				select {
+				case rt.flushed <- struct{}{}:
+				default:
+				}
+			case "error":
+				rt.logger.Warn("ASR worker reported an error", "error", msg.Message)
+			default:
+				rt.logger.Debug("ignoring unknown control from ASR worker", "type", msg.Type)
+			}
+		default:
+			return fmt.Errorf("unexpected frame type %d", typ)
+		}
+	}
+}
+
+// deliver queues a transcript of the worker with the same policy as the
+// Vosk recognizers: partials are dropped when the queue is full, finals
+// wait up to FinalTranscriptQueueTimeout before they are dropped and
+// counted.
+func (rt *remoteTranscriber) deliver(rtr remoteTranscript) {
+	rt.mu.Lock()
+	partials := rt.partials
+	language := rtr.Language
+	if language == "" {
+		language = rt.languageForLocked(rtr.SessionID)
+	}
+	rt.mu.Unlock()
+
+	if !rtr.Final && !partials {
+		return
+	}
+	t := signaling.Transcript{
+		Final:            rtr.Final,
+		LangID:           language,
+		Message:          rtr.Message,
+		SpeakerSessionID: rtr.SessionID,
+		StartMs:          rtr.StartMs,
+		EndMs:            rtr.EndMs,
+		Alternatives:     rtr.Alternatives,
+	}
+
+	select {
+	case rt.transcriptCh <- t:
+		return
+	default:
+	}
+	if !t.Final {
+		rt.logger.Debug("transcript channel full, dropping partial")
+		return
+	}
+
+	timer := time.NewTimer(constants.FinalTranscriptQueueTimeout)
+	defer timer.Stop()
+	select {
+	case rt.transcriptCh <- t:
+	case <-timer.C:
+		dropped := rt.droppedFinals.Add(1)
+		rt.logger.Warn("transcript channel full, dropped final transcript", "dropped_finals", dropped)
+	}
+}
+
+func (rt *remoteTranscriber) languageForLocked(sessionID string) string {
+	if lang, ok := rt.speakerLangs[sessionID]; ok {
+		return lang
+	}
+	return rt.language
+}
+
+// writeLocked sends one frame. A failed write closes the connection, so the
+// read loop ends and reconnects. Must be called with rt.writeMu held.
+func (rt *remoteTranscriber) writeLocked(typ byte, payload []byte) error {
+	if rt.conn == nil {
+		return errWorkerDisconnected
+	}
+	if len(payload) > constants.ASRMaxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds the maximum of %d", len(payload), constants.ASRMaxFrameSize)
+	}
+
+	frame := make([]byte, 5+len(payload))
+	frame[0] = typ
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+
+	rt.conn.SetWriteDeadline(time.Now().Add(constants.ASRWriteTimeout))
+	if _, err := rt.conn.Write(frame); err != nil {
+		rt.conn.Close()
+		rt.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (rt *remoteTranscriber) controlLocked(msg remoteControl) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return rt.writeLocked(frameControl, payload)
+}
+
+// update records a setting with set, under rt.mu, and sends it. While
+// disconnected the setting is sent with the others on reconnect.
+func (rt *remoteTranscriber) update(msg remoteControl, set func()) {
+	rt.writeMu.Lock()
+	defer rt.writeMu.Unlock()
+
+	rt.mu.Lock()
+	set()
+	rt.mu.Unlock()
+	if err := rt.controlLocked(msg); err != nil && !errors.Is(err, errWorkerDisconnected) {
+		rt.logger.Warn("failed to update ASR worker", "type", msg.Type, "error", err)
+	}
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > constants.ASRMaxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the maximum of %d", size, constants.ASRMaxFrameSize)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+func (rt *remoteTranscriber) Feed(sessionID string, pcm []byte) error {
+	if len(sessionID) > 0xffff {
+		return fmt.Errorf("session ID of %d bytes is too long", len(sessionID))
+	}
+	payload := make([]byte, 2+len(sessionID)+len(pcm))
+	binary.BigEndian.PutUint16(payload, uint16(len(sessionID)))
+	copy(payload[2:], sessionID)
+	copy(payload[2+len(sessionID):], pcm)
+
+	rt.mu.Lock()
+	rt.sessions[sessionID] = struct{}{}
+	rt.mu.Unlock()
+
+	rt.writeMu.Lock()
+	err := rt.writeLocked(frameAudio, payload)
+	rt.writeMu.Unlock()
+	if errors.Is(err, errWorkerDisconnected) {
+		// The connection already failed once and is being redialed
+		if dropped := rt.droppedAudio.Add(1); dropped%constants.AudioDropWarnEvery == 1 {
+			rt.logger.Warn("ASR worker unavailable, dropping audio", "dropped_chunks", dropped)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("sending audio to the ASR worker: %w", err)
+	}
+	return nil
+}
+
+// Remove tells the worker a session's audio ended, so it finalizes and
+// forgets it, and forgets the session.
+func (rt *remoteTranscriber) Remove(sessionID string) {
+	rt.mu.Lock()
+	_, ok := rt.sessions[sessionID]
+	delete(rt.sessions, sessionID)
+	rt.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	rt.writeMu.Lock()
+	defer rt.writeMu.Unlock()
+	if err := rt.controlLocked(remoteControl{Type: "end", SessionID: sessionID}); err != nil &&
+		!errors.Is(err, errWorkerDisconnected) {
+		rt.logger.Warn("failed to end session on ASR worker", "error", err)
+	}
+}
+
+// FlushAll asks the worker to finalize every utterance and waits up to
+// ASRFlushTimeout for it to confirm this flush; answers to earlier ones
+// do not count.
+func (rt *remoteTranscriber) FlushAll() {
+	rt.writeMu.Lock()
+	rt.flushSeq++
+	id := rt.flushSeq
+	err := rt.controlLocked(remoteControl{Type: "flush", ID: id})
+	rt.writeMu.Unlock()
+	if err != nil {
+		rt.logger.Warn("failed to flush ASR worker", "error", err)
+		return
+	}
+
+	timer := time.NewTimer(constants.ASRFlushTimeout)
+	defer timer.Stop()
+	for rt.lastFlush.Load() < id {
+		select {
+		case <-rt.flushed:
+		case <-timer.C:
+			rt.logger.Warn("ASR worker did not confirm the flush", "timeout", constants.ASRFlushTimeout)
+			return
+		case <-rt.ctx.Done():
+			return
+		}
+	}
+}
+
+func (rt *remoteTranscriber) CloseAll() {
+	rt.cancel()
+
+	rt.writeMu.Lock()
+	if rt.conn != nil {
+		rt.conn.Close()
+		rt.conn = nil
+	}
+	rt.writeMu.Unlock()
+
+	rt.mu.Lock()
+	clear(rt.sessions)
+	rt.mu.Unlock()
+
+	<-rt.done
+	rt.logger.Debug("remote transcriber closed")
+}
+
+func (rt *remoteTranscriber) SetLanguage(language string) error {
+	rt.mu.Lock()
+	same := language == rt.language
+	rt.mu.Unlock()
+	if same {
+		return nil
+	}
+	rt.update(remoteControl{Type: "language", Language: language}, func() { rt.language = language })
+	return nil
+}
+
+func (rt *remoteTranscriber) SetSpeakerLanguage(sessionID, language string) error {
+	rt.update(remoteControl{Type: "speaker_language", SessionID: sessionID, Language: language}, func() {
+		if language == "" {
+			delete(rt.speakerLangs, sessionID)
+		} else {
+			rt.speakerLangs[sessionID] = language
+		}
+	})
+	return nil
+}
+
+func (rt *remoteTranscriber) SetLanguageDetection(candidates []string) {
+	rt.update(remoteControl{Type: "detect", Candidates: candidates}, func() { rt.candidates = candidates })
+}
+
+func (rt *remoteTranscriber) SetGrammar(phrases []string) {
+	rt.update(remoteControl{Type: "grammar", Phrases: phrases}, func() { rt.grammar = phrases })
+}
+
+func (rt *remoteTranscriber) SetForceFinalizeChunks(n int) {
+	rt.update(remoteControl{Type: "force_finalize_chunks", Chunks: n}, func() { rt.chunks = n })
+}
+
+func (rt *remoteTranscriber) SetPartials(enabled bool) {
+	rt.update(remoteControl{Type: "partials", Enabled: &enabled}, func() { rt.partials = enabled })
+}
+
+func (rt *remoteTranscriber) DroppedFinals() int64 {
+	return rt.droppedFinals.Load()
+}
+
+// Stats returns the number of sessions whose audio was streamed to the
+// worker and the room language.
+func (rt *remoteTranscriber) Stats() (recognizers int, language string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return len(rt.sessions), rt.language
+}
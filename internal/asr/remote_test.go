@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package asr
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+)
+
+// fakeWorker is the worker side of one connection.
+type fakeWorker struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func (w fakeWorker) read() (byte, []byte) {
+	w.t.Helper()
+	w.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	typ, payload, err := readFrame(w.conn)
+	if err != nil {
+		w.t.Fatalf("reading frame: %v", err)
+	}
+	return typ, payload
+}
+
+func (w fakeWorker) readControl() remoteControl {
+	w.t.Helper()
+	typ, payload := w.read()
+	if typ != frameControl {
+		w.t.Fatalf("frame type %d, want a control", typ)
+	}
+	var msg remoteControl
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		w.t.Fatal(err)
+	}
+	return msg
+}
+
+func (w fakeWorker) write(typ byte, v any) {
+	w.t.Helper()
+	payload, err := json.Marshal(v)
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	frame := binary.BigEndian.AppendUint32([]byte{typ}, uint32(len(payload)))
+	if _, err := w.conn.Write(append(frame, payload...)); err != nil {
+		w.t.Fatalf("writing frame: %v", err)
+	}
+}
+
+func startRemote(t *testing.T) (*remoteTranscriber, chan signaling.Transcript, net.Listener) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	transcripts := make(chan signaling.Transcript, 10)
+	tr, err := newRemoteBackend(&appapi.Config{ASREndpoint: "tcp://" + ln.Addr().String()}, "en", transcripts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := tr.(*remoteTranscriber)
+	t.Cleanup(rt.CloseAll)
+	return rt, transcripts, ln
+}
+
+func accept(t *testing.T, ln net.Listener) fakeWorker {
+	t.Helper()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return fakeWorker{t: t, conn: conn}
+}
+
+func TestRemoteProtocol(t *testing.T) {
+	rt, transcripts, ln := startRemote(t)
+	w := accept(t, ln)
+
+	if msg := w.readControl(); msg.Type != "start" || msg.Language != "en" || msg.SampleRate != SampleRate {
+		t.Fatalf("first control = %+v, want start in en at %d Hz", msg, SampleRate)
+	}
+
+	// Audio frames carry the session ID
+	if err := rt.Feed("s1", []byte{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	typ, payload := w.read()
+	if typ != frameAudio {
+		t.Fatalf("frame type %d, want audio", typ)
+	}
+	n := binary.BigEndian.Uint16(payload)
+	if sid, pcm := string(payload[2:2+n]), payload[2+n:]; sid != "s1" || len(pcm) != 4 {
+		t.Fatalf("audio of %q with %d bytes, want s1 with 4", sid, len(pcm))
+	}
+
+	// Transcripts are delivered in the speaker's language
+	w.write(frameTranscript, remoteTranscript{SessionID: "s1", Message: "hello", Final: true})
+	select {
+	case tr := <-transcripts:
+		if tr.Message != "hello" || !tr.Final || tr.LangID != "en" || tr.SpeakerSessionID != "s1" {
+			t.Fatalf("transcript = %+v", tr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no transcript delivered")
+	}
+
+	// A late answer to an earlier flush does not confirm the current one
+	flushed := make(chan struct{})
+	go func() {
+		rt.FlushAll()
+		close(flushed)
+	}()
+	msg := w.readControl()
+	if msg.Type != "flush" || msg.ID == 0 {
+		t.Fatalf("control = %+v, want a flush with an id", msg)
+	}
+	w.write(frameControl, remoteControl{Type: "flushed", ID: msg.ID - 1})
+	select {
+	case <-flushed:
+		t.Fatal("flush confirmed by the answer to an earlier one")
+	case <-time.After(100 * time.Millisecond):
+	}
+	w.write(frameControl, remoteControl{Type: "flushed", ID: msg.ID})
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("flush not confirmed")
+	}
+
+	// Ended sessions are ended on the worker and forgotten
+	rt.Remove("s1")
+	if msg := w.readControl(); msg.Type != "end" || msg.SessionID != "s1" {
+		t.Fatalf("control = %+v, want end of s1", msg)
+	}
+	if n, _ := rt.Stats(); n != 0 {
+		t.Errorf("%d sessions after Remove, want 0", n)
+	}
+}
+
+func TestRemoteReplaysSettingsOnReconnect(t *testing.T) {
+	rt, _, ln := startRemote(t)
+	w := accept(t, ln)
+	w.readControl() // start
+
+	rt.SetPartials(false)
+	if msg := w.readControl(); msg.Type != "partials" || msg.Enabled == nil || *msg.Enabled {
+		t.Fatalf("control = %+v, want partials disabled", msg)
+	}
+	if err := rt.SetSpeakerLanguage("s1", "de"); err != nil {
+		t.Fatal(err)
+	}
+	w.readControl()
+
+	// The worker goes away: the new connection starts over with the settings
+	w.conn.Close()
+	w = accept(t, ln)
+	want := map[string]bool{"partials": false, "speaker_language": false}
+	if msg := w.readControl(); msg.Type != "start" {
+		t.Fatalf("first control = %+v, want start", msg)
+	}
+	for range want {
+		msg := w.readControl()
+		if _, ok := want[msg.Type]; !ok {
+			t.Fatalf("unexpected control %+v", msg)
+		}
+		want[msg.Type] = true
+	}
+	for typ, seen := range want {
+		if !seen {
+			t.Errorf("%s not replayed", typ)
+		}
+	}
+}
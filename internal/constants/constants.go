@@ -3,7 +3,14 @@
 
 package constants
 
-import "time"
+import (
+	"log/slog"
+	"time"
+)
+
+// LogLevelTrace is below debug and enabled with LT_LOG_LEVEL=trace. It logs
+// every signaling message exchanged with the HPB.
+const LogLevelTrace = slog.LevelDebug - 4
 
 const (
 	MsgReceiveTimeout         = 10 * time.Second
@@ -22,4 +29,159 @@ const (
 	CacheTranslationTaskTypes = 15 * time.Minute
 	MaxTranscriptSendTimeout  = 30 * time.Second
 	MaxTranslationSendTimeout = 60 * time.Second
+
+	PartialTranslationDebounce = 2 * time.Second
+	TranscriptHistoryMaxAge    = 60 * time.Second
+	TranslationCacheSize       = 256
+	TranslationCacheTTL        = time.Hour
+	TranslationBatchWindow     = 0 // disabled, every final is translated at once
+	TranslationBackend         = "ocp"
+	TranslationMaxBatchSize    = 16
+	OCPPollInitialInterval     = time.Second
+	OCPPollMaxInterval         = 5 * time.Second
+	OCPPollSlowAfter           = 15 * time.Minute
+	OCPPollSlowInterval        = 10 * time.Second
+	OCPPollDeadline            = 30 * time.Minute
+	OCPTaskSchedRetryDelay     = 2 * time.Second
+	OpusDecoderRetries         = 3
+	OpusDecoderRetryDelay      = 100 * time.Millisecond
+	MaxDecoderRenegotiations   = 3
+	SignalingAPIVersion        = "v3"
+
+	TranslationProviderCheckTTL     = 2 * time.Minute
+	TranslationProviderCheckTimeout = 5 * time.Second
+	OCSRetryMaxAttempts             = 3
+	OCSRetryBaseDelay               = 500 * time.Millisecond
+	OCSRetryMaxDelay                = 30 * time.Second
+
+	SpeakerNameCacheTTL        = 5 * time.Minute
+	SpeakerNameRefreshInterval = 10 * time.Second
+	SpeakerNameFetchTimeout    = 10 * time.Second
+	GuestDisplayName           = "Guest"
+	TranscriptRetention        = 30 * 24 * time.Hour
+	ModelsRefreshInterval      = time.Minute
+	MaxIdleModels              = 0
+	StopTokenMaxConfidence     = 0.7
+	ASRBackend                 = "vosk"
+
+	MaxReconnectTries  = 5
+	ReconnectBaseDelay = time.Second
+
+	// The client pings the HPB this often; a connection with no traffic,
+	// not even a pong, for SignalingReadTimeout is considered dead and
+	// reconnected, while a bye ends the room
+	SignalingPingInterval = 30 * time.Second
+	SignalingReadTimeout  = 75 * time.Second
+
+	ConnectRetryDelay     = 2 * time.Second
+	RateLimitedRetryDelay = 10 * time.Second
+
+	// The HPB may still hold the session of a crashed instance for a while
+	DuplicateSessionRetries    = 3
+	DuplicateSessionRetryDelay = 3 * time.Second
+
+	MaxICERestarts  = 5
+	ICERestartDelay = time.Second
+
+	CloseWriteTimeout   = 2 * time.Second
+	MaxConcealedPackets = 5
+	JitterBufferPackets = 5
+	AudioBufferFrames   = 100
+	AudioDropWarnEvery  = 50
+	AudioLevelSmoothing = 0.05 // weight of a frame in the speaker's average RMS
+
+	LoadCheckInterval     = 5 * time.Second
+	OverloadDroppedFrames = 10 // 200ms of audio per check
+	OverloadChecks        = 3
+	OverloadRecoverChecks = 6
+
+	FinalTranscriptQueueTimeout = 2 * time.Second
+
+	DrainTimeout      = 10 * time.Second
+	DrainPollInterval = 50 * time.Millisecond
+
+	LanguageDetectionWindow        = 3 * time.Second
+	LanguageDetectionMaxWindow     = 10 * time.Second
+	MaxLanguageDetectionCandidates = 4
+
+	HPBSettingsRetryInterval   = 30 * time.Second
+	HPBSettingsRefreshInterval = time.Hour
+
+	HTTPMaxIdleConns        = 100
+	HTTPMaxIdleConnsPerHost = 32 // translation polling hits the one Nextcloud host
+	HTTPIdleConnTimeout     = 90 * time.Second
+
+	ServerReadTimeout  = 30 * time.Second
+	ServerWriteTimeout = 30 * time.Second
+	ServerIdleTimeout  = 120 * time.Second
+
+	PersistentStorage = "/nc_app_live_transcription_data"
+
+	ModelsBaseURL  = "https://huggingface.co"
+	ModelsRepo     = "Nextcloud-AI/vosk-models"
+	ModelsRevision = "06f2f156dcd79092400891afb6cf8101e54f6ba2"
+
+	FeedBufferSize   = 64
+	FeedWriteTimeout = 10 * time.Second
+	FeedPingInterval = 30 * time.Second
+	FeedPongTimeout  = 60 * time.Second
+
+	// Transcripts requested by a session that never shows up in the call
+	// are given up after this long
+	DeferredTargetTTL = 2 * time.Minute
+
+	ASRDialTimeout       = 5 * time.Second
+	ASRWriteTimeout      = 2 * time.Second
+	ASRFlushTimeout      = 2 * time.Second
+	ASRReconnectMaxDelay = 30 * time.Second
+	ASRMaxFrameSize      = 1 << 20
+
+	RedactMask = "***"
+
+	MaxGrammarPhrases     = 1000
+	MaxGrammarPhraseBytes = 200
+
+	MaxAlternatives = 10
+
+	PunctuationProvider = "heuristic"
+	PunctuationTimeout  = 1500 * time.Millisecond
+	PunctuationMinWords = 3 // shorter finals are punctuated heuristically
+
+	// Finals of continuous speech are merged for at most this long
+	MaxMergedFinalDuration = 20 * time.Second
+
+	MaxTranslationTasksPerRoom = 4
+	// Finals waiting for a translation task slot, per limit, beyond which
+	// they are dropped like partials
+	MaxTranslationTaskWaiters = 16
+
+	// Language neutral, clients can show a text of their own for
+	// translationPending messages
+	TranslationPlaceholder      = "…"
+	TranslationPlaceholderDelay = time.Second
+
+	TranslationFailureNoticeInterval    = 30 * time.Second
+	TranslationFailureNoticeMaxInterval = 10 * time.Minute
+	TranslationFailuresBeforeFallback   = 3
+
+	WebhookTimeout        = 10 * time.Second
+	WebhookQueueSize      = 1000
+	WebhookMaxAttempts    = 5
+	WebhookRetryBaseDelay = time.Second
+	WebhookRetryMaxDelay  = 30 * time.Second
+	WebhookDropWarnEvery  = 100
+
+	TranscriptWebhookQueueSize = 10000
+
+	OCPTaskDeleteTimeout = 10 * time.Second
+
+	// Audio a Vosk recognizer is reset for, rather than recreated, after
+	// forced finals with RecreateRecognizerOnForceFinalize
+	RecognizerRecreateInterval = 10 * time.Minute
+	MallocTrimInterval         = 30 * time.Second
+
+	// A recognizer is only evicted for another speaker once it was fed no
+	// audio for this long, so speakers taking turns at the recognizer
+	// limit do not cut each other off mid-utterance
+	RecognizerEvictMinIdle = 5 * time.Second
 )
@@ -4,14 +4,26 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"os"
+	"slices"
 	"sync/atomic"
+	"time"
 
 	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
 	"github.com/nextcloud/go_live_transcription/internal/languages"
 	"github.com/nextcloud/go_live_transcription/internal/service"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+	"github.com/nextcloud/go_live_transcription/internal/transcript"
+	"github.com/nextcloud/go_live_transcription/internal/transcript/export"
+	"github.com/nextcloud/go_live_transcription/internal/translation"
 	"github.com/nextcloud/go_live_transcription/internal/vosk"
 )
 
@@ -20,6 +32,10 @@ type Handler struct {
 	Client  *appapi.Client
 	Service *service.Application
 	Enabled atomic.Bool
+
+	// downloading gates init and model downloads so that only one of them
+	// writes into the persistent storage at a time.
+	downloading atomic.Bool
 }
 
 func NewHandler(cfg *appapi.Config, client *appapi.Client, svc *service.Application) *Handler {
@@ -38,10 +54,37 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	}
 }
 
+// writeError answers with an ErrorResponse carrying one of the Code constants.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{Error: message, Code: code})
+}
+
 func (h *Handler) Heartbeat(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, StatusResponse{Status: "ok"})
 }
 
+// Ready is the readiness probe: 503 with the blocking reasons until a call
+// could be transcribed. Heartbeat stays a pure liveness check.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	ready := h.Service.Readiness(r.Context())
+	status := http.StatusOK
+	if !ready.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, ready)
+}
+
+// Health reports per-capability readiness. It answers 503 only when nothing
+// can be transcribed; a missing translation provider is a degraded state.
+func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
+	health := h.Service.Health(r.Context())
+	status := http.StatusOK
+	if health.Status == service.HealthUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, health)
+}
+
 func (h *Handler) SetEnabled(w http.ResponseWriter, r *http.Request) {
 	enabledParam := r.URL.Query().Get("enabled")
 	enabled := enabledParam == "1" || enabledParam == "true"
@@ -55,27 +98,52 @@ func (h *Handler) GetEnabled(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, EnabledResponse{Enabled: h.Enabled.Load()})
 }
 
+// requireEnabled rejects requests while the app is disabled in AppAPI.
+func (h *Handler) requireEnabled(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.Enabled.Load() {
+			writeError(w, http.StatusServiceUnavailable, CodeAppDisabled, "The app is disabled.")
+			return
+		}
+		next(w, r)
+	}
+}
+
 func (h *Handler) Init(w http.ResponseWriter, r *http.Request) {
 	slog.Info("init called")
+	if !h.downloading.CompareAndSwap(false, true) {
+		slog.Info("init or model download already in progress, ignoring")
+		writeJSON(w, http.StatusOK, StatusResponse{Status: "in_progress"})
+		return
+	}
 	writeJSON(w, http.StatusOK, struct{}{})
 
 	// Download models and report init completion in background
+	ctx := context.WithoutCancel(r.Context())
 	go func() {
-		storageDir := appapi.PersistentStorage()
-		if err := vosk.DownloadModels(h.Client, storageDir); err != nil {
+		defer h.downloading.Store(false)
+
+		storageDir := h.Config.PersistentStorage
+		if err := vosk.DownloadModels(ctx, h.Client, storageDir); err != nil {
 			slog.Error("model download failed", "error", err)
-			if statusErr := h.Client.SetInitStatus(-1); statusErr != nil {
+			if statusErr := h.Client.SetInitError(ctx, err.Error()); statusErr != nil {
 				slog.Error("failed to report init failure", "error", statusErr)
 			}
 			return
 		}
 
-		if err := h.Client.SetInitStatus(100); err != nil {
+		if err := h.Client.SetInitStatus(ctx, 100); err != nil {
 			slog.Error("failed to report init status", "error", err)
 		}
 	}()
 }
 
+// GetInitStatus reports the progress of the model download started by init,
+// including the file being downloaded and the reason of a failure.
+func (h *Handler) GetInitStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, vosk.CurrentInitStatus())
+}
+
 func (h *Handler) GetLanguages(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, languages.VoskSupportedLanguageMap)
 }
@@ -89,7 +157,7 @@ func (h *Handler) GetCapabilities(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	translationLangs := h.Service.GetTranslationLanguagesForCapabilities()
+	translationLangs := h.Service.GetTranslationLanguagesForCapabilities(r.Context())
 	if translationLangs != nil {
 		features = append(features, "live_translation")
 		appCaps["live_translation"] = map[string]any{
@@ -104,10 +172,12 @@ func (h *Handler) GetCapabilities(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+const modelNotInstalledMsg = "The model for this language is not installed, download it first."
+
 func (h *Handler) TranscribeCall(w http.ResponseWriter, r *http.Request) {
 	var req TranscribeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
 		return
 	}
 
@@ -120,58 +190,328 @@ func (h *Handler) TranscribeCall(w http.ResponseWriter, r *http.Request) {
 		langID = "en"
 	}
 
-	if err := h.Service.TranscriptReq(r.Context(), req.RoomToken, req.NcSessionID, langID, enable); err != nil {
+	var tuning service.RoomTuning
+	if t := req.Tuning; t != nil {
+		tuning.ForceFinalizeChunks = t.ForceFinalizeChunks
+		if t.MinPartialIntervalMs != nil {
+			d := time.Duration(*t.MinPartialIntervalMs) * time.Millisecond
+			tuning.MinPartialInterval = &d
+		}
+	}
+
+	userID := r.Header.Get("X-Auth-Username")
+	targets, err := h.Service.TranscriptReq(r.Context(), req.RoomToken, req.NcSessionID, userID, langID,
+		enable, req.Record, tuning)
+	if err != nil {
 		slog.Error("transcribe request failed", "error", err, "room_token", req.RoomToken)
-		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: err.Error()})
+		status, code := http.StatusServiceUnavailable, CodeTranscriptionUnavailable
+		switch {
+		case errors.Is(err, service.ErrInvalidTuning):
+			status, code = http.StatusBadRequest, CodeInvalidTuning
+		case errors.Is(err, vosk.ErrModelUnsupported):
+			status, code = http.StatusBadRequest, CodeLanguageUnsupported
+		case errors.Is(err, vosk.ErrModelNotFound):
+			writeError(w, http.StatusConflict, CodeModelNotInstalled, modelNotInstalledMsg)
+			return
+		case errors.Is(err, signaling.ErrDuplicateSession):
+			status, code = http.StatusConflict, CodeDuplicateSession
+		case errors.Is(err, signaling.ErrRateLimited):
+			status, code = http.StatusTooManyRequests, CodeRateLimited
+		case errors.Is(err, service.ErrTooManyRooms):
+			code = CodeTooManyRooms
+		}
+		writeError(w, status, code, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, MessageResponse{Message: "Transcription request processed successfully."})
+	writeJSON(w, http.StatusOK, TranscribeResponse{
+		Message: "Transcription request processed successfully.",
+		Targets: targets,
+	})
 }
 
 func (h *Handler) LeaveCall(w http.ResponseWriter, r *http.Request) {
 	var req LeaveCallRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	closed := h.Service.LeaveCall(req.RoomToken)
+	writeJSON(w, http.StatusOK, LeaveCallResponse{Message: "Leave call request processed.", Closed: closed})
+}
+
+// DrainCall sends the room's last transcripts and translations, then
+// leaves the call.
+func (h *Handler) DrainCall(w http.ResponseWriter, r *http.Request) {
+	var req LeaveCallRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	// Draining may take up to DrainTimeout, longer than the server allows
+	// for writing the response
+	deadline := time.Now().Add(h.Config.DrainTimeout + h.Config.ServerWriteTimeout)
+	_ = http.NewResponseController(w).SetWriteDeadline(deadline)
+
+	closed := h.Service.DrainAndLeave(r.Context(), req.RoomToken)
+	writeJSON(w, http.StatusOK, LeaveCallResponse{Message: "Drain call request processed.", Closed: closed})
+}
+
+// openTranscript opens the recorded transcript of the room in the request
+// path. On failure the error response has been written and nil is returned.
+func (h *Handler) openTranscript(w http.ResponseWriter, r *http.Request) *os.File {
+	path, err := h.Service.TranscriptFile(r.PathValue("roomToken"))
+	switch {
+	case errors.Is(err, transcript.ErrInvalidRoomToken):
+		writeError(w, http.StatusBadRequest, CodeInvalidRoomToken, "Invalid room token.")
+		return nil
+	case errors.Is(err, os.ErrNotExist):
+		writeError(w, http.StatusNotFound, CodeTranscriptNotFound, "No transcript recorded for this call.")
+		return nil
+	case err != nil:
+		slog.Error("get transcript failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to read the transcript.")
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		slog.Error("open transcript failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to read the transcript.")
+		return nil
+	}
+	return f
+}
+
+func (h *Handler) GetTranscript(w http.ResponseWriter, r *http.Request) {
+	f := h.openTranscript(w, r)
+	if f == nil {
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		slog.Error("stat transcript failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to read the transcript.")
 		return
 	}
 
-	h.Service.LeaveCall(req.RoomToken)
-	writeJSON(w, http.StatusOK, MessageResponse{Message: "Leave call request processed."})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func (h *Handler) GetTranscriptVTT(w http.ResponseWriter, r *http.Request) {
+	h.exportTranscript(w, r, "text/vtt; charset=utf-8", export.WriteVTT)
+}
+
+func (h *Handler) GetTranscriptSRT(w http.ResponseWriter, r *http.Request) {
+	h.exportTranscript(w, r, "application/x-subrip; charset=utf-8", export.WriteSRT)
+}
+
+func (h *Handler) exportTranscript(
+	w http.ResponseWriter,
+	r *http.Request,
+	contentType string,
+	write func(io.Writer, []transcript.RecordedSegment) error,
+) {
+	f := h.openTranscript(w, r)
+	if f == nil {
+		return
+	}
+	defer f.Close()
+
+	segments, err := export.ReadSegments(f)
+	if err != nil {
+		slog.Error("parse transcript failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to read the transcript.")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if err := write(w, segments); err != nil {
+		slog.Error("failed to write transcript export", "error", err)
+	}
 }
 
 func (h *Handler) SetCallLanguage(w http.ResponseWriter, r *http.Request) {
 	var req RoomLanguageSetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
 		return
 	}
 
 	if req.LangID == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid or unsupported language ID provided."})
+		writeError(w, http.StatusBadRequest, CodeLanguageUnsupported, "Invalid or unsupported language ID provided.")
 		return
 	}
 	if _, ok := languages.VoskSupportedLanguageMap[req.LangID]; !ok {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid or unsupported language ID provided."})
+		writeError(w, http.StatusBadRequest, CodeLanguageUnsupported, "Invalid or unsupported language ID provided.")
 		return
 	}
 
 	if err := h.Service.SetCallLanguage(req.RoomToken, req.LangID); err != nil {
+		if errors.Is(err, vosk.ErrModelNotFound) {
+			writeError(w, http.StatusConflict, CodeModelNotInstalled, modelNotInstalledMsg)
+			return
+		}
 		slog.Error("set call language failed", "error", err)
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "Failed to set language for the call"})
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to set language for the call")
 		return
 	}
 
 	writeJSON(w, http.StatusOK, MessageResponse{Message: "Language set successfully for the call"})
 }
 
+func (h *Handler) SetSpeakerLanguage(w http.ResponseWriter, r *http.Request) {
+	var req SpeakerLanguageSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	if req.NcSessionID == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "ncSessionId is required")
+		return
+	}
+	if _, ok := languages.VoskSupportedLanguageMap[req.LangID]; req.LangID != "" && !ok {
+		writeError(w, http.StatusBadRequest, CodeLanguageUnsupported, "Invalid or unsupported language ID provided.")
+		return
+	}
+
+	if err := h.Service.SetSpeakerLanguage(req.RoomToken, req.NcSessionID, req.LangID); err != nil {
+		if errors.Is(err, vosk.ErrModelNotFound) {
+			writeError(w, http.StatusConflict, CodeModelNotInstalled, modelNotInstalledMsg)
+			return
+		}
+		if errors.Is(err, service.ErrSpeakerNotInCall) {
+			writeError(w, http.StatusNotFound, CodeParticipantNotFound, "The participant is not in the call.")
+			return
+		}
+		if errors.Is(err, service.ErrMixedAudio) {
+			writeError(w, http.StatusConflict, CodeMixedAudio,
+				"Per-participant languages are not available in mixed audio mode.")
+			return
+		}
+		slog.Error("set speaker language failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to set language for the participant")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Language set successfully for the participant"})
+}
+
+func (h *Handler) SetLanguageDetection(w http.ResponseWriter, r *http.Request) {
+	var req LanguageDetectionSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	if err := h.Service.SetLanguageDetection(req.RoomToken, req.Enabled); err != nil {
+		if errors.Is(err, service.ErrDetectionNotConfigured) {
+			writeError(w, http.StatusConflict, CodeDetectionNotConfigured, err.Error())
+			return
+		}
+		slog.Error("set language detection failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to set language detection for the call")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Language detection set successfully for the call"})
+}
+
+func (h *Handler) SetGrammar(w http.ResponseWriter, r *http.Request) {
+	var req GrammarSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	if len(req.Phrases) > constants.MaxGrammarPhrases {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest,
+			fmt.Sprintf("at most %d phrases are allowed", constants.MaxGrammarPhrases))
+		return
+	}
+	for _, phrase := range req.Phrases {
+		if len(phrase) > constants.MaxGrammarPhraseBytes {
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest,
+				fmt.Sprintf("phrases must not exceed %d bytes", constants.MaxGrammarPhraseBytes))
+			return
+		}
+	}
+
+	err := h.Service.SetGrammar(req.RoomToken, req.Phrases)
+	switch {
+	case errors.Is(err, service.ErrRoomNotFound):
+		writeError(w, http.StatusNotFound, CodeRoomNotFound, "No active transcription for this call.")
+		return
+	case err != nil:
+		slog.Error("set grammar failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to set the grammar for the call")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Grammar set successfully for the call"})
+}
+
+func (h *Handler) SetPunctuation(w http.ResponseWriter, r *http.Request) {
+	var req PunctuationSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	err := h.Service.SetPunctuation(req.RoomToken, req.Enabled)
+	switch {
+	case errors.Is(err, service.ErrRoomNotFound):
+		writeError(w, http.StatusNotFound, CodeRoomNotFound, "No active transcription for this call.")
+		return
+	case err != nil:
+		slog.Error("set punctuation failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to set punctuation for the call")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Punctuation set successfully for the call"})
+}
+
+func (h *Handler) SetRedaction(w http.ResponseWriter, r *http.Request) {
+	var req RedactionSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	err := h.Service.SetRedaction(req.RoomToken, req.Enabled)
+	switch {
+	case errors.Is(err, service.ErrRoomNotFound):
+		writeError(w, http.StatusNotFound, CodeRoomNotFound, "No active transcription for this call.")
+		return
+	case err != nil:
+		slog.Error("set redaction failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to set redaction for the call")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Redaction set successfully for the call"})
+}
+
 func (h *Handler) GetTranslationLanguages(w http.ResponseWriter, r *http.Request) {
 	roomToken := r.URL.Query().Get("roomToken")
-	langs, err := h.Service.GetTranslationLanguages(roomToken)
+	langs, err := h.Service.GetTranslationLanguages(r.Context(), roomToken)
+	if errors.Is(err, translation.ErrProviderMalformed) {
+		slog.Error("get translation languages failed", "error", err)
+		writeError(w, http.StatusBadGateway, CodeTranslationProviderError,
+			"The translation provider returned unexpected data.")
+		return
+	}
 	if err != nil {
 		slog.Error("get translation languages failed", "error", err)
-		writeJSON(w, http.StatusInternalServerError,
-			ErrorResponse{Error: "An error occurred while fetching translation languages."})
+		writeError(w, http.StatusInternalServerError, CodeInternal,
+			"An error occurred while fetching translation languages.")
 		return
 	}
 	writeJSON(w, http.StatusOK, langs)
@@ -180,14 +520,24 @@ func (h *Handler) GetTranslationLanguages(w http.ResponseWriter, r *http.Request
 func (h *Handler) SetTargetLanguage(w http.ResponseWriter, r *http.Request) {
 	var req TargetLanguageSetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid request body"})
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
 		return
 	}
 
-	if err := h.Service.SetTargetLanguage(req.RoomToken, req.NcSessionID, req.LangID); err != nil {
+	if err := h.Service.SetTargetLanguage(r.Context(), req.RoomToken, req.NcSessionID, req.LangID); err != nil {
+		if errors.Is(err, translation.ErrTranslateLangNotAllowed) {
+			writeError(w, http.StatusBadRequest, CodeTranslationNotOffered,
+				"Translation into or from this language is not offered.")
+			return
+		}
+		if errors.Is(err, translation.ErrTooManyTargetLangs) {
+			writeError(w, http.StatusConflict, CodeTooManyTargetLanguages,
+				"The maximum number of translation languages for this call has been reached.")
+			return
+		}
 		slog.Error("set target language failed", "error", err)
-		writeJSON(w, http.StatusInternalServerError,
-			ErrorResponse{Error: "Failed to set the target translation language for the participant."})
+		writeError(w, http.StatusInternalServerError, CodeInternal,
+			"Failed to set the target translation language for the participant.")
 		return
 	}
 
@@ -195,17 +545,171 @@ func (h *Handler) SetTargetLanguage(w http.ResponseWriter, r *http.Request) {
 		MessageResponse{Message: "Target translation language set successfully for the participant."})
 }
 
+func (h *Handler) SetDefaultTargetLanguage(w http.ResponseWriter, r *http.Request) {
+	var req DefaultTargetLanguageSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	if err := h.Service.SetDefaultTargetLanguage(r.Context(), req.RoomToken, req.LangID); err != nil {
+		if errors.Is(err, translation.ErrTranslateLangNotAllowed) || errors.Is(err, translation.ErrTranslateLangPair) {
+			writeError(w, http.StatusBadRequest, CodeTranslationNotOffered,
+				"Translation into this language is not offered.")
+			return
+		}
+		slog.Error("set default target language failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal,
+			"Failed to set the default target translation language for the call.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK,
+		MessageResponse{Message: "Default target translation language set successfully for the call."})
+}
+
+func (h *Handler) ListModels(w http.ResponseWriter, r *http.Request) {
+	installed := vosk.GetModelManager().ListAvailableModels()
+	slices.Sort(installed)
+
+	available := make([]string, 0, len(languages.ModelsList))
+	for langID := range languages.ModelsList {
+		available = append(available, langID)
+	}
+	slices.Sort(available)
+
+	writeJSON(w, http.StatusOK, ModelsResponse{Installed: installed, Available: available})
+}
+
+// RefreshHPBSettings fetches the STUN/TURN settings from Talk again, e.g.
+// after an admin changed them. New peer connections use the new settings.
+func (h *Handler) RefreshHPBSettings(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.RefreshHPBSettings(r.Context()); err != nil {
+		slog.Error("refresh HPB settings failed", "error", err)
+		writeError(w, http.StatusBadGateway, CodeHPBUnavailable, "Failed to fetch the signaling settings from Nextcloud")
+		return
+	}
+	writeJSON(w, http.StatusOK, MessageResponse{Message: "Signaling settings refreshed"})
+}
+
+// RefreshModels drops the cached list of installed models, e.g. after
+// models were copied into the persistent storage by hand.
+func (h *Handler) RefreshModels(w http.ResponseWriter, r *http.Request) {
+	vosk.GetModelManager().InvalidateAvailableModels()
+	h.ListModels(w, r)
+}
+
+func (h *Handler) DownloadModel(w http.ResponseWriter, r *http.Request) {
+	var req ModelDownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	if _, ok := languages.ModelsList[req.LangID]; !ok {
+		writeError(w, http.StatusBadRequest, CodeLanguageUnsupported, "Invalid or unsupported language ID provided.")
+		return
+	}
+
+	if !h.downloading.CompareAndSwap(false, true) {
+		writeError(w, http.StatusConflict, CodeDownloadInProgress, "Another model download is already in progress.")
+		return
+	}
+
+	// Models can be several GB, download in background
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		defer h.downloading.Store(false)
+		if err := vosk.DownloadModel(ctx, h.Config.PersistentStorage, req.LangID); err != nil {
+			slog.Error("model download failed", "error", err, "lang_id", req.LangID)
+		}
+	}()
+
+	writeJSON(w, http.StatusAccepted, MessageResponse{Message: "Model download started."})
+}
+
+func (h *Handler) DeleteModel(w http.ResponseWriter, r *http.Request) {
+	langID := r.PathValue("langId")
+
+	// Holding the gate keeps a download from starting during the delete
+	if !h.downloading.CompareAndSwap(false, true) {
+		writeError(w, http.StatusConflict, CodeDownloadInProgress, "A model download is in progress, try again later.")
+		return
+	}
+	defer h.downloading.Store(false)
+
+	reclaimed, err := vosk.GetModelManager().DeleteModel(langID)
+	switch {
+	case errors.Is(err, vosk.ErrModelUnsupported):
+		writeError(w, http.StatusBadRequest, CodeLanguageUnsupported, "Invalid or unsupported language ID provided.")
+		return
+	case errors.Is(err, vosk.ErrModelNotFound):
+		writeError(w, http.StatusNotFound, CodeModelNotInstalled, "Model is not installed.")
+		return
+	case errors.Is(err, vosk.ErrModelInUse):
+		writeError(w, http.StatusConflict, CodeModelInUse, "Model is currently in use by an active call.")
+		return
+	case err != nil:
+		slog.Error("model delete failed", "error", err, "lang_id", langID)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to delete the model.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ModelDeleteResponse{LangID: langID, ReclaimedBytes: reclaimed})
+}
+
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.Service.Stats())
+}
+
+// GetDebugConfig returns the configuration the process actually loaded,
+// with defaults applied and secrets redacted.
+func (h *Handler) GetDebugConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := h.Config.Redacted()
+	resp := DebugConfigResponse{
+		Config:            cfg,
+		PersistentStorage: h.Config.PersistentStorage,
+	}
+	if cfg.HPBUrl != "" {
+		resp.HPBWebSocketURL, _ = appapi.HPBWebSocketURL(cfg.HPBUrl)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /heartbeat", h.Heartbeat)
+	mux.HandleFunc("GET /ready", h.Ready)
 	mux.HandleFunc("PUT /enabled", h.SetEnabled)
 	mux.HandleFunc("GET /enabled", h.GetEnabled)
 	mux.HandleFunc("POST /init", h.Init)
+	mux.HandleFunc("GET /api/v1/init/status", h.GetInitStatus)
 	mux.HandleFunc("GET /capabilities", h.GetCapabilities)
 
 	mux.HandleFunc("GET /api/v1/languages", h.GetLanguages)
-	mux.HandleFunc("POST /api/v1/call/transcribe", h.TranscribeCall)
+	// Leaving and draining stay available so calls running when the app
+	// got disabled can still be torn down
+	mux.HandleFunc("POST /api/v1/call/transcribe", h.requireEnabled(h.TranscribeCall))
 	mux.HandleFunc("POST /api/v1/call/leave", h.LeaveCall)
-	mux.HandleFunc("POST /api/v1/call/set-language", h.SetCallLanguage)
-	mux.HandleFunc("GET /api/v1/translation/languages", h.GetTranslationLanguages)
-	mux.HandleFunc("POST /api/v1/translation/set-target-language", h.SetTargetLanguage)
+	mux.HandleFunc("POST /api/v1/call/drain", h.DrainCall)
+	mux.HandleFunc("GET /api/v1/call/{roomToken}/transcript", h.requireEnabled(h.GetTranscript))
+	mux.HandleFunc("GET /api/v1/call/{roomToken}/transcript.vtt", h.requireEnabled(h.GetTranscriptVTT))
+	mux.HandleFunc("GET /api/v1/call/{roomToken}/transcript.srt", h.requireEnabled(h.GetTranscriptSRT))
+	mux.HandleFunc("GET /api/v1/call/{roomToken}/stream", h.requireEnabled(h.StreamTranscript))
+	mux.HandleFunc("POST /api/v1/call/set-language", h.requireEnabled(h.SetCallLanguage))
+	mux.HandleFunc("POST /api/v1/call/set-speaker-language", h.requireEnabled(h.SetSpeakerLanguage))
+	mux.HandleFunc("POST /api/v1/call/set-language-detection", h.requireEnabled(h.SetLanguageDetection))
+	mux.HandleFunc("POST /api/v1/call/set-redaction", h.requireEnabled(h.SetRedaction))
+	mux.HandleFunc("POST /api/v1/call/set-punctuation", h.requireEnabled(h.SetPunctuation))
+	mux.HandleFunc("POST /api/v1/call/set-grammar", h.requireEnabled(h.SetGrammar))
+	mux.HandleFunc("GET /api/v1/translation/languages", h.requireEnabled(h.GetTranslationLanguages))
+	mux.HandleFunc("POST /api/v1/translation/set-target-language", h.requireEnabled(h.SetTargetLanguage))
+	mux.HandleFunc("POST /api/v1/call/set-default-target-language", h.requireEnabled(h.SetDefaultTargetLanguage))
+	mux.HandleFunc("GET /api/v1/health", h.Health)
+	mux.HandleFunc("GET /api/v1/stats", h.GetStats)
+	mux.HandleFunc("GET /api/v1/debug/config", h.GetDebugConfig)
+	mux.HandleFunc("POST /api/v1/hpb/refresh", h.RefreshHPBSettings)
+	mux.HandleFunc("GET /api/v1/models", h.ListModels)
+	mux.HandleFunc("POST /api/v1/models/download", h.DownloadModel)
+	mux.HandleFunc("POST /api/v1/models/refresh", h.RefreshModels)
+	mux.HandleFunc("DELETE /api/v1/models/{langId}", h.DeleteModel)
 }
@@ -0,0 +1,246 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/languages"
+	"github.com/nextcloud/go_live_transcription/internal/service"
+	"github.com/nextcloud/go_live_transcription/internal/vosk"
+)
+
+// serve answers a request with the handler's routes.
+func serve(h *Handler, method, target string) *httptest.ResponseRecorder {
+	return serveJSON(h, method, target, "")
+}
+
+// serveJSON answers a request with a JSON body with the handler's routes.
+func serveJSON(h *Handler, method, target, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+// errorCode returns the code of an ErrorResponse.
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Code
+}
+
+// withModelStorage installs the models in a temporary directory, with an
+// empty model of the language.
+func withModelStorage(t *testing.T, langID string) string {
+	t.Helper()
+	dir := t.TempDir()
+	mm := vosk.GetModelManager()
+	mm.SetStorageDir(dir)
+	t.Cleanup(func() { mm.SetStorageDir(constants.PersistentStorage) })
+	modelDir := filepath.Join(dir, languages.ModelsList[langID])
+	if err := os.MkdirAll(modelDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mm.InvalidateAvailableModels()
+	return modelDir
+}
+
+func TestDeleteModelBlockedByDownload(t *testing.T) {
+	modelDir := withModelStorage(t, "en")
+	h := NewHandler(&appapi.Config{}, nil, nil)
+
+	h.downloading.Store(true) // a download or init runs
+	w := serve(h, http.MethodDelete, "/api/v1/models/en")
+	if w.Code != http.StatusConflict || errorCode(t, w) != CodeDownloadInProgress {
+		t.Fatalf("delete during a download: %d %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(modelDir); err != nil {
+		t.Errorf("model deleted during a download: %v", err)
+	}
+	if !h.downloading.Load() {
+		t.Error("the rejected delete released the download's gate")
+	}
+
+	h.downloading.Store(false)
+	if w := serve(h, http.MethodDelete, "/api/v1/models/en"); w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(modelDir); !os.IsNotExist(err) {
+		t.Errorf("model not deleted: %v", err)
+	}
+	if h.downloading.Load() {
+		t.Error("the delete kept the gate held")
+	}
+}
+
+func TestConcurrentInitDownloadsOnce(t *testing.T) {
+	var listings atomic.Int32
+	release := make(chan struct{})
+	reported := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/ocs/") {
+			reported <- struct{}{}
+			json.NewEncoder(w).Encode(map[string]any{"ocs": map[string]any{"data": nil}})
+			return
+		}
+		// The repository listing blocks until released, keeping init running
+		listings.Add(1)
+		<-release
+		json.NewEncoder(w).Encode([]any{})
+	}))
+	t.Cleanup(srv.Close)
+	vosk.SetModelSource(srv.URL, "repo", "main")
+	t.Cleanup(func() {
+		vosk.SetModelSource(constants.ModelsBaseURL, constants.ModelsRepo, constants.ModelsRevision)
+	})
+
+	cfg := &appapi.Config{NextcloudURL: srv.URL, AppID: "live_transcription", PersistentStorage: t.TempDir()}
+	h := NewHandler(cfg, appapi.NewClient(cfg), nil)
+
+	const inits = 10
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(inits)
+	for range inits {
+		go func() {
+			defer wg.Done()
+			w := serve(h, http.MethodPost, "/init")
+			var resp StatusResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Status != "in_progress" {
+				started.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if started.Load() != 1 {
+		t.Fatalf("%d inits started a download, want 1", started.Load())
+	}
+
+	close(release)
+	select {
+	case <-reported:
+	case <-time.After(5 * time.Second):
+		t.Fatal("init completion not reported")
+	}
+	if n := listings.Load(); n != 1 {
+		t.Errorf("repository listed %d times, want once", n)
+	}
+
+	// Once done, init can run again
+	for h.downloading.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	w := serve(h, http.MethodPost, "/init")
+	var resp StatusResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Status == "in_progress" {
+		t.Error("init after completion reported in progress")
+	}
+	<-reported
+}
+
+// newTestHandler returns an enabled handler of a service without rooms,
+// transcribing with the models installed by withModelStorage.
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	cfg := &appapi.Config{ASRBackend: "vosk", PersistentStorage: t.TempDir()}
+	h := NewHandler(cfg, nil, service.NewApplication(cfg, nil))
+	h.Enabled.Store(true)
+	return h
+}
+
+func TestLanguageModelInstalled(t *testing.T) {
+	withModelStorage(t, "en")
+	h := newTestHandler(t)
+
+	tests := []struct {
+		name, target, body string
+		wantStatus         int
+		wantCode           string // "" for success
+	}{
+		{"installed call language", "/api/v1/call/set-language",
+			`{"roomToken":"room","langId":"en"}`, http.StatusOK, ""},
+		{"missing call language", "/api/v1/call/set-language",
+			`{"roomToken":"room","langId":"de"}`, http.StatusConflict, CodeModelNotInstalled},
+		{"unsupported call language", "/api/v1/call/set-language",
+			`{"roomToken":"room","langId":"xx"}`, http.StatusBadRequest, CodeLanguageUnsupported},
+		{"call in a missing language", "/api/v1/call/transcribe",
+			`{"roomToken":"room","ncSessionId":"nc","langId":"de"}`, http.StatusConflict, CodeModelNotInstalled},
+		{"call in an unsupported language", "/api/v1/call/transcribe",
+			`{"roomToken":"room","ncSessionId":"nc","langId":"xx"}`, http.StatusBadRequest, CodeLanguageUnsupported},
+	}
+	for _, tt := range tests {
+		w := serveJSON(h, http.MethodPost, tt.target, tt.body)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.wantStatus, w.Body)
+			continue
+		}
+		if tt.wantCode != "" {
+			if code := errorCode(t, w); code != tt.wantCode {
+				t.Errorf("%s: code %q, want %q", tt.name, code, tt.wantCode)
+			}
+		}
+	}
+}
+
+func TestDisabledAppRejectsRequests(t *testing.T) {
+	withModelStorage(t, "en")
+	h := newTestHandler(t)
+	if w := serve(h, http.MethodPut, "/enabled?enabled=0"); w.Code != http.StatusOK || h.Enabled.Load() {
+		t.Fatalf("disabling answered %d, enabled %v", w.Code, h.Enabled.Load())
+	}
+
+	rejected := []struct{ method, target, body string }{
+		{http.MethodPost, "/api/v1/call/transcribe", `{"roomToken":"room","ncSessionId":"nc","langId":"en"}`},
+		{http.MethodPost, "/api/v1/call/set-language", `{"roomToken":"room","langId":"en"}`},
+		{http.MethodGet, "/api/v1/call/room/transcript", ""},
+		{http.MethodGet, "/api/v1/call/room/stream", ""},
+		{http.MethodGet, "/api/v1/translation/languages", ""},
+		{http.MethodPost, "/api/v1/translation/set-target-language", `{"roomToken":"room","ncSessionId":"nc","langId":"de"}`},
+	}
+	for _, tt := range rejected {
+		w := serveJSON(h, tt.method, tt.target, tt.body)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s: status %d while disabled, want %d", tt.method, tt.target, w.Code, http.StatusServiceUnavailable)
+			continue
+		}
+		if code := errorCode(t, w); code != CodeAppDisabled {
+			t.Errorf("%s %s: code %q, want %q", tt.method, tt.target, code, CodeAppDisabled)
+		}
+	}
+
+	// Lifecycle routes stay reachable, and calls can still be torn down
+	for _, tt := range []struct{ method, target, body string }{
+		{http.MethodGet, "/heartbeat", ""},
+		{http.MethodGet, "/enabled", ""},
+		{http.MethodGet, "/api/v1/init/status", ""},
+		{http.MethodPost, "/api/v1/call/leave", `{"roomToken":"room"}`},
+	} {
+		if w := serveJSON(h, tt.method, tt.target, tt.body); w.Code != http.StatusOK {
+			t.Errorf("%s %s: status %d while disabled, want %d", tt.method, tt.target, w.Code, http.StatusOK)
+		}
+	}
+
+	serve(h, http.MethodPut, "/enabled?enabled=1")
+	if w := serveJSON(h, http.MethodPost, "/api/v1/call/set-language", `{"roomToken":"room","langId":"en"}`); w.Code != http.StatusOK {
+		t.Errorf("set-language after enabling: status %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/service"
+)
+
+var streamUpgrader = websocket.Upgrader{}
+
+// StreamTranscript pushes the transcripts and translations of a call over a
+// WebSocket as JSON, for integrations that consume them without joining the
+// call. Partials may be skipped for slow clients; a client that cannot keep
+// up with finals is disconnected. The socket closes when the call ends.
+func (h *Handler) StreamTranscript(w http.ResponseWriter, r *http.Request) {
+	roomToken := r.PathValue("roomToken")
+	sub, err := h.Service.SubscribeTranscripts(roomToken)
+	if err != nil {
+		if errors.Is(err, service.ErrRoomNotFound) {
+			writeError(w, http.StatusNotFound, CodeRoomNotFound, "No active transcription for this call.")
+			return
+		}
+		slog.Error("subscribe transcripts failed", "error", err)
+		writeError(w, http.StatusInternalServerError, CodeInternal, "Failed to subscribe to the transcripts.")
+		return
+	}
+	defer sub.Unsubscribe()
+
+	// The stream lives as long as the call, the deadlines of the server
+	// would cut it off; the loop below sets its own
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already answered the client
+		slog.Debug("transcript stream upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+	logger := slog.With("component", "transcript_stream", "room_token", roomToken)
+	logger.Info("transcript stream client connected")
+
+	// Clients only send pongs and the close frame; reading is needed to
+	// process them and notice the client going away
+	_ = conn.SetReadDeadline(time.Now().Add(constants.FeedPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(constants.FeedPongTimeout))
+	})
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(constants.FeedPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-gone:
+			logger.Info("transcript stream client disconnected")
+			return
+		case <-ping.C:
+			deadline := time.Now().Add(constants.FeedWriteTimeout)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+		case ev, ok := <-sub.C:
+			if !ok {
+				// The call ended or the client fell behind
+				deadline := time.Now().Add(constants.FeedWriteTimeout)
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), deadline)
+				logger.Info("transcript stream closed")
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(constants.FeedWriteTimeout))
+			if err := conn.WriteJSON(ev); err != nil {
+				logger.Debug("transcript stream write failed", "error", err)
+				return
+			}
+		}
+	}
+}
@@ -3,12 +3,29 @@
 
 package handlers
 
+import "github.com/nextcloud/go_live_transcription/internal/appapi"
+
 type TranscribeRequest struct {
 	RoomToken               string  `json:"roomToken"`
 	NcSessionID             string  `json:"ncSessionId"`
 	Enable                  *bool   `json:"enable,omitempty"`
 	LangID                  string  `json:"langId,omitempty"`
 	TranslationTargetLangID *string `json:"translationTargetLangId,omitempty"`
+	Record                  bool    `json:"record,omitempty"`
+	// Tuning overrides segmentation parameters for the room. Only the
+	// participant who started the call can set it; others' is ignored.
+	Tuning *TranscribeTuning `json:"tuning,omitempty"`
+}
+
+// TranscribeTuning holds per-room overrides of the global defaults; absent
+// fields keep the current value.
+type TranscribeTuning struct {
+	// ForceFinalizeChunks: 20ms audio chunks after which a running
+	// utterance is finalized, 50-3000 (default 500).
+	ForceFinalizeChunks *int `json:"forceFinalizeChunks,omitempty"`
+	// MinPartialIntervalMs: minimum milliseconds between partial
+	// transcripts of a speaker, 0-5000 (default 300).
+	MinPartialIntervalMs *int `json:"minPartialIntervalMs,omitempty"`
 }
 
 type RoomLanguageSetRequest struct {
@@ -16,20 +33,103 @@ type RoomLanguageSetRequest struct {
 	LangID    string `json:"langId"`
 }
 
+// SpeakerLanguageSetRequest assigns a participant their own transcription
+// language; an empty LangID reverts to the call language.
+type SpeakerLanguageSetRequest struct {
+	RoomToken   string `json:"roomToken"`
+	NcSessionID string `json:"ncSessionId"`
+	LangID      string `json:"langId"`
+}
+
+type LanguageDetectionSetRequest struct {
+	RoomToken string `json:"roomToken"`
+	Enabled   bool   `json:"enabled"`
+}
+
+type PunctuationSetRequest struct {
+	RoomToken string `json:"roomToken"`
+	Enabled   bool   `json:"enabled"`
+}
+
+type RedactionSetRequest struct {
+	RoomToken string `json:"roomToken"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// GrammarSetRequest sets the phrases a call's recognition is constrained
+// to; an empty list returns to free recognition.
+type GrammarSetRequest struct {
+	RoomToken string   `json:"roomToken"`
+	Phrases   []string `json:"phrases"`
+}
+
 type TargetLanguageSetRequest struct {
 	RoomToken   string  `json:"roomToken"`
 	NcSessionID string  `json:"ncSessionId"`
 	LangID      *string `json:"langId,omitempty"`
 }
 
+type DefaultTargetLanguageSetRequest struct {
+	RoomToken string  `json:"roomToken"`
+	LangID    *string `json:"langId,omitempty"`
+}
+
+// TranscribeResponse reports the participants of the call receiving or
+// awaiting transcripts after the request, 0 once none are left.
+type TranscribeResponse struct {
+	Message string `json:"message"`
+	Targets int    `json:"targets"`
+}
+
 type LeaveCallRequest struct {
 	RoomToken string `json:"roomToken"`
 }
 
+type LeaveCallResponse struct {
+	Message string `json:"message"`
+	Closed  bool   `json:"closed"`
+}
+
+// DebugConfigResponse is the effective configuration, secrets redacted,
+// with values derived from it.
+type DebugConfigResponse struct {
+	Config            appapi.Config `json:"config"`
+	HPBWebSocketURL   string        `json:"hpb_websocket_url,omitempty"`
+	PersistentStorage string        `json:"persistent_storage"`
+}
+
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error string `json:"error"`          // human readable, may change
+	Code  string `json:"code,omitempty"` // one of the Code constants, stable
 }
 
+// Error codes of ErrorResponse, so clients can tell failures apart without
+// parsing the message.
+const (
+	CodeAppDisabled              = "app_disabled"
+	CodeInvalidRequest           = "invalid_request"
+	CodeInvalidRoomToken         = "invalid_room_token"
+	CodeInvalidTuning            = "invalid_tuning"
+	CodeLanguageUnsupported      = "language_unsupported"
+	CodeModelNotInstalled        = "model_not_installed"
+	CodeModelInUse               = "model_in_use"
+	CodeDownloadInProgress       = "download_in_progress"
+	CodeRoomNotFound             = "room_not_found"
+	CodeTranscriptNotFound       = "transcript_not_found"
+	CodeParticipantNotFound      = "participant_not_found"
+	CodeMixedAudio               = "mixed_audio_mode"
+	CodeDetectionNotConfigured   = "language_detection_not_configured"
+	CodeDuplicateSession         = "duplicate_session"
+	CodeRateLimited              = "rate_limited"
+	CodeTooManyRooms             = "too_many_rooms"
+	CodeTranscriptionUnavailable = "transcription_unavailable"
+	CodeHPBUnavailable           = "hpb_unavailable"
+	CodeTranslationNotOffered    = "translation_not_offered"
+	CodeTooManyTargetLanguages   = "too_many_target_languages"
+	CodeTranslationProviderError = "translation_provider_error"
+	CodeInternal                 = "internal_error"
+)
+
 type MessageResponse struct {
 	Message string `json:"message"`
 }
@@ -41,3 +141,17 @@ type StatusResponse struct {
 type EnabledResponse struct {
 	Enabled bool `json:"enabled"`
 }
+
+type ModelDownloadRequest struct {
+	LangID string `json:"langId"`
+}
+
+type ModelsResponse struct {
+	Installed []string `json:"installed"`
+	Available []string `json:"available"`
+}
+
+type ModelDeleteResponse struct {
+	LangID         string `json:"langId"`
+	ReclaimedBytes int64  `json:"reclaimedBytes"`
+}
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package languages
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Separator returns the word separator of the language: "" for languages
+// written without spaces between words (e.g. Chinese, Japanese), a space
+// for all others, including unknown ones.
+func Separator(langID string) string {
+	if lm, ok := LanguageMap[langID]; ok {
+		return lm.Metadata.Separator
+	}
+	return " "
+}
+
+// JoinWords normalizes the whitespace-separated words of text into the
+// language's spelling. Vosk separates words with spaces in every language,
+// which is wrong for languages without a separator.
+func JoinWords(langID, text string) string {
+	return strings.Join(strings.Fields(text), Separator(langID))
+}
+
+// WordCount counts the words of text. In languages without a separator
+// every letter counts as a word, as their characters are mostly words or
+// syllables on their own.
+func WordCount(langID, text string) int {
+	if Separator(langID) != "" {
+		return len(strings.Fields(text))
+	}
+	return LetterCount(text)
+}
+
+// LetterCount counts the letters and digits of text, ignoring spaces and
+// punctuation.
+func LetterCount(text string) int {
+	n := 0
+	for _, r := range text {
+		if unicode.In(r, unicode.L, unicode.N) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package languages
+
+import "testing"
+
+func TestSeparator(t *testing.T) {
+	for lang, want := range map[string]string{
+		"en":      " ",
+		"ar":      " ",
+		"ja":      "",
+		"zh":      "",
+		"unknown": " ",
+	} {
+		if got := Separator(lang); got != want {
+			t.Errorf("Separator(%s) = %q, want %q", lang, got, want)
+		}
+	}
+}
+
+func TestJoinWords(t *testing.T) {
+	tests := []struct {
+		lang, text, want string
+	}{
+		{"en", "hello   world", "hello world"},
+		{"en", " \thello world\n", "hello world"},
+		{"zh", "你好 世界", "你好世界"},
+		{"ja", " こんにちは  世界 ", "こんにちは世界"},
+		{"unknown", "a  b", "a b"},
+		{"zh", "", ""},
+	}
+	for _, tt := range tests {
+		if got := JoinWords(tt.lang, tt.text); got != tt.want {
+			t.Errorf("JoinWords(%s, %q) = %q, want %q", tt.lang, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWordCount(t *testing.T) {
+	tests := []struct {
+		lang, text string
+		want       int
+	}{
+		{"en", "hello world", 2},
+		{"en", "  uh  ", 1},
+		{"en", "", 0},
+		// Without a separator every letter counts, punctuation does not
+		{"zh", "你好世界", 4},
+		{"zh", "你好，世界。", 4},
+		{"ja", "嗯", 1},
+		{"ja", "2026年", 5},
+	}
+	for _, tt := range tests {
+		if got := WordCount(tt.lang, tt.text); got != tt.want {
+			t.Errorf("WordCount(%s, %q) = %d, want %d", tt.lang, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLetterCount(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"uh", 2},
+		{"it's 42!", 5},
+		{"  ...  ", 0},
+		{"日本語", 3},
+	}
+	for _, tt := range tests {
+		if got := LetterCount(tt.text); got != tt.want {
+			t.Errorf("LetterCount(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package languages
+
+import "strings"
+
+// StopTokens lists segments a Vosk model is known to hallucinate from noise
+// or silence, per language. Languages without known artifacts have no entry.
+var StopTokens = map[string][]string{
+	"en": {"the"},
+}
+
+// IsStopSegment reports whether the whole of text is one of the stop tokens
+// of the language. Matching ignores case and surrounding whitespace.
+func IsStopSegment(langID, text string) bool {
+	text = strings.TrimSpace(text)
+	for _, token := range StopTokens[langID] {
+		if strings.EqualFold(text, token) {
+			return true
+		}
+	}
+	return false
+}
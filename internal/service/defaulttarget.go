@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// defaultTarget is the per-room default translation language. Participants
+// that enable transcription get it as their target unless they chose a
+// target language themselves (or explicitly none); explicit choices win.
+type defaultTarget struct {
+	langID    string
+	explicit  map[string]struct{} // NC session IDs that chose their own target
+	defaulted map[string]struct{} // NC session IDs translated into langID
+}
+
+func newDefaultTarget() *defaultTarget {
+	return &defaultTarget{
+		explicit:  make(map[string]struct{}),
+		defaulted: make(map[string]struct{}),
+	}
+}
+
+// change switches to the default langID, "" for none, and returns the
+// participants among targets to translate into it and those to stop
+// translating for. Participants with a choice of their own are left alone.
+// The caller marks applied ones with defaulted.
+func (d *defaultTarget) change(langID string, targets []string) (apply, revert []string) {
+	d.langID = langID
+	if langID == "" {
+		for ncSessionID := range d.defaulted {
+			revert = append(revert, ncSessionID)
+		}
+		clear(d.defaulted)
+		return nil, revert
+	}
+
+	for ncSessionID := range d.defaulted {
+		apply = append(apply, ncSessionID)
+	}
+	for _, ncSessionID := range targets {
+		if d.applies(ncSessionID) {
+			apply = append(apply, ncSessionID)
+		}
+	}
+	return apply, nil
+}
+
+// applies reports whether a participant not yet on the default gets it.
+func (d *defaultTarget) applies(ncSessionID string) bool {
+	if d.langID == "" {
+		return false
+	}
+	_, explicit := d.explicit[ncSessionID]
+	_, defaulted := d.defaulted[ncSessionID]
+	return !explicit && !defaulted
+}
+
+// SetDefaultTargetLanguage sets or, with a nil or empty langID, clears the
+// room's default target language. It applies to the call's current targets
+// without a choice of their own; clearing it stops the translations it
+// started.
+func (app *Application) SetDefaultTargetLanguage(ctx context.Context, roomToken string, langID *string) error {
+	app.mu.Lock()
+	rs, ok := app.rooms[roomToken]
+	app.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no active transcription session for room %s", roomToken)
+	}
+
+	newLang := ""
+	if langID != nil {
+		newLang = *langID
+	}
+
+	rs.targetMu.Lock()
+	defer rs.targetMu.Unlock()
+
+	if newLang != "" {
+		// Reject unusable languages before touching any participant
+		if _, err := rs.meta.IsTargetLangSupported(ctx, newLang); err != nil {
+			return fmt.Errorf("failed to set default target language: %w", err)
+		}
+	}
+
+	apply, revert := rs.defaults.change(newLang, rs.client.TargetNcSessionIDs())
+	for _, ncSessionID := range revert {
+		rs.meta.RemoveTranslator(ncSessionID)
+	}
+	for _, ncSessionID := range apply {
+		if err := rs.meta.AddTranslator(ctx, newLang, ncSessionID); err != nil {
+			slog.Warn("failed to apply default target language",
+				"error", err,
+				"room_token", roomToken,
+				"nc_session_id", ncSessionID,
+			)
+			continue
+		}
+		rs.defaults.defaulted[ncSessionID] = struct{}{}
+	}
+
+	slog.Info("set default target language", "room_token", roomToken, "lang_id", newLang)
+	return nil
+}
+
+// applyDefaultTarget gives a participant the room's default target language
+// unless they made their own choice.
+func (app *Application) applyDefaultTarget(ctx context.Context, rs *roomState, roomToken, ncSessionID string) {
+	rs.targetMu.Lock()
+	defer rs.targetMu.Unlock()
+
+	if !rs.defaults.applies(ncSessionID) {
+		return
+	}
+
+	if err := rs.meta.AddTranslator(ctx, rs.defaults.langID, ncSessionID); err != nil {
+		slog.Warn("failed to apply default target language",
+			"error", err,
+			"room_token", roomToken,
+			"nc_session_id", ncSessionID,
+			"lang_id", rs.defaults.langID,
+		)
+		return
+	}
+	rs.defaults.defaulted[ncSessionID] = struct{}{}
+}
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDefaultTargetChange(t *testing.T) {
+	d := newDefaultTarget()
+	d.explicit["chose"] = struct{}{}
+
+	// Setting a default applies it to current targets without a choice
+	apply, revert := d.change("de", []string{"a", "b", "chose"})
+	slices.Sort(apply)
+	if !slices.Equal(apply, []string{"a", "b"}) || len(revert) != 0 {
+		t.Fatalf("set: apply %v revert %v, want apply [a b]", apply, revert)
+	}
+	for _, sid := range apply {
+		d.defaulted[sid] = struct{}{}
+	}
+
+	// Newcomers get it, participants with a choice or already on it do not
+	for sid, want := range map[string]bool{"c": true, "a": false, "chose": false} {
+		if got := d.applies(sid); got != want {
+			t.Errorf("applies(%q) = %v, want %v", sid, got, want)
+		}
+	}
+
+	// Changing it moves the defaulted participants along
+	apply, revert = d.change("fr", []string{"a", "b", "chose"})
+	slices.Sort(apply)
+	if !slices.Equal(apply, []string{"a", "b"}) || len(revert) != 0 {
+		t.Fatalf("change: apply %v revert %v, want apply [a b]", apply, revert)
+	}
+
+	// Clearing it stops only the translations it started
+	apply, revert = d.change("", []string{"a", "b", "chose"})
+	slices.Sort(revert)
+	if len(apply) != 0 || !slices.Equal(revert, []string{"a", "b"}) {
+		t.Fatalf("clear: apply %v revert %v, want revert [a b]", apply, revert)
+	}
+	if len(d.defaulted) != 0 || d.applies("c") {
+		t.Error("default still applied after clearing it")
+	}
+}
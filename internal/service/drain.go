@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// drain lets a room finish what it has heard before it is closed: audio
+// stops, the recognizers finalize their current utterances, and the
+// resulting transcripts and translations are sent. It returns when the
+// pipeline is idle or ctx is done, whichever comes first.
+func (rs *roomState) drain(ctx context.Context, roomToken string) {
+	start := time.Now()
+	rs.client.StopAudio()
+	if err := rs.audioWorker.Drain(ctx); err != nil {
+		slog.Warn("room drain timed out flushing recognizers", "room_token", roomToken)
+		return
+	}
+
+	// A segment can be between two stages for a moment, so the pipeline
+	// only counts as idle when it is seen idle twice in a row.
+	ticker := time.NewTicker(constants.DrainPollInterval)
+	defer ticker.Stop()
+	idleSeen := 0
+	for idleSeen < 2 {
+		if rs.idle() {
+			idleSeen++
+		} else {
+			idleSeen = 0
+		}
+		select {
+		case <-ctx.Done():
+			slog.Warn("room drain timed out, closing anyway", "room_token", roomToken)
+			return
+		case <-ticker.C:
+		}
+	}
+	slog.Info("room drained", "room_token", roomToken, "duration", time.Since(start))
+}
+
+func (rs *roomState) idle() bool {
+	return rs.sender.Idle() && (rs.meta == nil || rs.meta.Idle()) && rs.transSender.Idle()
+}
+
+// DrainAndLeave drains a room, then closes it like LeaveCall.
+func (app *Application) DrainAndLeave(ctx context.Context, roomToken string) bool {
+	app.mu.Lock()
+	rs, ok := app.rooms[roomToken]
+	app.mu.Unlock()
+
+	if !ok || rs.client.IsDefunct() {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, app.cfg.DrainTimeout)
+	defer cancel()
+	rs.drain(ctx, roomToken)
+	return app.LeaveCall(roomToken)
+}
+
+// Drain drains all rooms in parallel, for a graceful shutdown. Rooms are
+// closed afterwards by Shutdown.
+func (app *Application) Drain(ctx context.Context) {
+	app.mu.Lock()
+	rooms := make(map[string]*roomState, len(app.rooms))
+	for token, rs := range app.rooms {
+		if !rs.client.IsDefunct() {
+			rooms[token] = rs
+		}
+	}
+	app.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, app.cfg.DrainTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for token, rs := range rooms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rs.drain(ctx, token)
+		}()
+	}
+	wg.Wait()
+}
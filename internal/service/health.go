@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/asr"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/translation"
+	"github.com/nextcloud/go_live_transcription/internal/vosk"
+)
+
+const (
+	HealthOK          = "ok"
+	HealthDegraded    = "degraded"    // translation unavailable or transcription overloaded
+	HealthUnavailable = "unavailable" // no models, nothing can be transcribed
+)
+
+type Health struct {
+	Status          string `json:"status"`
+	Transcription   bool   `json:"transcription"`
+	Translation     bool   `json:"translation"`
+	InstalledModels int    `json:"installed_models"`
+	Overloaded      bool   `json:"overloaded"`
+}
+
+// Health reports per-capability readiness. Transcription is ready when at
+// least one model is installed or the ASR backend brings its own models,
+// translation when an OCP translation provider is registered. The provider
+// check is cached as it costs an OCS round trip.
+func (app *Application) Health(ctx context.Context) Health {
+	installed := len(vosk.GetModelManager().ListAvailableModels())
+	transcriptionReady := installed > 0 || !asr.NeedsLocalModels(app.cfg.ASRBackend)
+	return newHealth(installed, transcriptionReady, app.translationProviderAvailable(ctx), app.Overloaded())
+}
+
+func newHealth(installedModels int, transcriptionReady, translationReady, overloaded bool) Health {
+	h := Health{
+		Transcription:   transcriptionReady,
+		Translation:     translationReady,
+		InstalledModels: installedModels,
+		Overloaded:      overloaded,
+	}
+	switch {
+	case !h.Transcription:
+		h.Status = HealthUnavailable
+	case !h.Translation || h.Overloaded:
+		h.Status = HealthDegraded
+	default:
+		h.Status = HealthOK
+	}
+	return h
+}
+
+func (app *Application) translationProviderAvailable(ctx context.Context) bool {
+	app.providerMu.Lock()
+	defer app.providerMu.Unlock()
+
+	if time.Since(app.providerChecked) < constants.TranslationProviderCheckTTL {
+		return app.providerOK
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, constants.TranslationProviderCheckTimeout)
+	defer cancel()
+
+	tmp := translation.NewTranslator(app.client, app.cfg, "health-check", "en", "en")
+	_, err := tmp.GetTranslationLanguages(ctx)
+	if err != nil {
+		slog.Debug("translation provider check failed", "error", err)
+	}
+
+	app.providerOK = err == nil
+	app.providerChecked = time.Now()
+	return app.providerOK
+}
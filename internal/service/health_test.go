@@ -0,0 +1,32 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import "testing"
+
+func TestNewHealth(t *testing.T) {
+	for _, tt := range []struct {
+		name                                   string
+		models                                 int
+		transcription, translation, overloaded bool
+		want                                   string
+	}{
+		{"all ready", 3, true, true, false, HealthOK},
+		{"remote backend without models", 0, true, true, false, HealthOK},
+		{"no translation", 3, true, false, false, HealthDegraded},
+		{"overloaded", 3, true, true, true, HealthDegraded},
+		{"overloaded without translation", 3, true, false, true, HealthDegraded},
+		{"no transcription", 0, false, true, false, HealthUnavailable},
+		{"nothing ready", 0, false, false, true, HealthUnavailable},
+	} {
+		h := newHealth(tt.models, tt.transcription, tt.translation, tt.overloaded)
+		if h.Status != tt.want {
+			t.Errorf("%s: status %q, want %q", tt.name, h.Status, tt.want)
+		}
+		if h.InstalledModels != tt.models || h.Transcription != tt.transcription ||
+			h.Translation != tt.translation || h.Overloaded != tt.overloaded {
+			t.Errorf("%s: health %+v does not report its inputs", tt.name, h)
+		}
+	}
+}
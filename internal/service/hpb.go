@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RefreshHPBSettings fetches the signaling settings from Talk again and hands
+// them to all rooms. Rotated TURN credentials and changed STUN/TURN servers
+// thus apply to new peer connections; established ones are not disturbed.
+func (app *Application) RefreshHPBSettings(ctx context.Context) error {
+	settings, err := app.fetchHPBSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Stored under mu, as new rooms take the current settings when they
+	// are added and would otherwise miss the snapshot below
+	app.mu.Lock()
+	app.hpbSettings.Store(settings)
+	rooms := make([]*roomState, 0, len(app.rooms))
+	for _, rs := range app.rooms {
+		rooms = append(rooms, rs)
+	}
+	app.mu.Unlock()
+
+	for _, rs := range rooms {
+		rs.client.SetHPBSettings(settings)
+	}
+	return nil
+}
+
+// RunHPBSettingsRefresh refreshes the HPB settings every interval until ctx
+// is done. A zero interval disables the refresh.
+func (app *Application) RunHPBSettingsRefresh(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := app.RefreshHPBSettings(ctx); err != nil {
+				// Keep the previous settings, their credentials may still be valid
+				slog.Warn("failed to refresh HPB settings", "error", err)
+			}
+		}
+	}
+}
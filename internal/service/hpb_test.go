@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/languages"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+	"github.com/nextcloud/go_live_transcription/internal/vosk"
+)
+
+// TestRefreshHPBSettingsWhileRoomsStart checks, best run with -race, that
+// every room started during refreshes ends up with the latest settings.
+func TestRefreshHPBSettingsWhileRoomsStart(t *testing.T) {
+	mm := vosk.GetModelManager()
+	dir := t.TempDir()
+	mm.SetStorageDir(dir)
+	t.Cleanup(func() { mm.SetStorageDir(constants.PersistentStorage) })
+	if err := os.MkdirAll(filepath.Join(dir, languages.ModelsList["en"]), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	connect := connectClient
+	connectClient = func(context.Context, *signaling.SpreedClient) (signaling.SigConnectResult, error) {
+		return signaling.SigConnectSuccess, nil
+	}
+	t.Cleanup(func() { connectClient = connect })
+
+	// Every fetch returns a new server, so stale settings are told apart
+	var fetches atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"ocs":{"data":{"server":"wss://hpb%d.example"}}}`, fetches.Add(1))
+	}))
+	t.Cleanup(srv.Close)
+	cfg := &appapi.Config{
+		NextcloudURL:        srv.URL,
+		HPBUrl:              "https://hpb.example",
+		InternalSecret:      "secret",
+		SignalingAPIVersion: "v3",
+		ASRBackend:          "vosk",
+		OCSRetryMaxAttempts: 1,
+	}
+	app := NewApplication(cfg, appapi.NewClient(cfg))
+
+	const rooms, refreshes = 8, 20
+	var wg sync.WaitGroup
+	wg.Add(rooms + 1)
+	go func() {
+		defer wg.Done()
+		for range refreshes {
+			if err := app.RefreshHPBSettings(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for i := range rooms {
+		go func() {
+			defer wg.Done()
+			token := fmt.Sprintf("room%d", i)
+			if _, err := app.TranscriptReq(context.Background(), token, "nc", "alice", "en", true, false, RoomTuning{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	latest := app.HPBSettings()
+	if want := fmt.Sprintf("wss://hpb%d.example", fetches.Load()); latest.Server != want {
+		t.Errorf("settings of server %s, want the last fetched %s", latest.Server, want)
+	}
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	if len(app.rooms) != rooms {
+		t.Fatalf("%d rooms started, want %d", len(app.rooms), rooms)
+	}
+	for token, rs := range app.rooms {
+		if got := rs.client.HPBSettings(); got != latest {
+			t.Errorf("%s has the settings of server %s, want %s", token, got.Server, latest.Server)
+		}
+		rs.client.Close()
+	}
+}
@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/transcript"
+	"github.com/nextcloud/go_live_transcription/internal/webhook"
+)
+
+// Lifecycle event types.
+const (
+	EventCallStarted = "call_started"
+	EventCallEnded   = "call_ended"
+)
+
+// LifecycleEvent tells integrators when the transcription of a call began
+// and ended, for session accounting and auditing. It is posted to the
+// lifecycle webhook; call_ended also ends the room's transcript stream.
+type LifecycleEvent struct {
+	Type      string    `json:"type"`
+	RoomToken string    `json:"roomToken"`
+	Time      time.Time `json:"time"`
+	StartedAt time.Time `json:"startedAt"`
+	// Reason is why transcription ended, one of the signaling.CloseReason
+	// constants. call_ended only.
+	Reason string `json:"reason,omitempty"`
+}
+
+// callStarted records that transcription of a room began once connected.
+func (app *Application) callStarted(roomToken string, rs *roomState) {
+	app.mu.Lock()
+	rs.startedAt = time.Now()
+	app.mu.Unlock()
+
+	if app.lifecycle != nil {
+		app.lifecycle.Post(LifecycleEvent{
+			Type:      EventCallStarted,
+			RoomToken: roomToken,
+			Time:      rs.startedAt,
+			StartedAt: rs.startedAt,
+		})
+	}
+}
+
+// callEndedLocked announces the end of a room that had started, before it
+// is torn down. Must be called with app.mu held.
+func (app *Application) callEndedLocked(roomToken string, rs *roomState, reason string) {
+	if rs.startedAt.IsZero() {
+		return
+	}
+	now := time.Now()
+	slog.Info("transcription ended", "room_token", roomToken, "reason", reason,
+		"duration", now.Sub(rs.startedAt).Round(time.Second))
+
+	rs.feed.Publish(transcript.FeedEvent{Type: EventCallEnded, Final: true, Reason: reason})
+	if app.lifecycle != nil {
+		app.lifecycle.Post(LifecycleEvent{
+			Type:      EventCallEnded,
+			RoomToken: roomToken,
+			Time:      now,
+			StartedAt: rs.startedAt,
+			Reason:    reason,
+		})
+	}
+}
+
+// RunWebhooks delivers the webhook events until ctx is done.
+func (app *Application) RunWebhooks(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, p := range []*webhook.Poster{app.lifecycle, app.transcripts} {
+		if p != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.Run(ctx)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// FlushWebhooks waits for the queued webhook events to be delivered, such
+// as the call_ended events of Shutdown and the last transcripts, or until ctx is done.
+func (app *Application) FlushWebhooks(ctx context.Context) {
+	for _, p := range []*webhook.Poster{app.lifecycle, app.transcripts} {
+		if p != nil {
+			p.Flush(ctx)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// loadMonitor tracks whether the recognizers keep up with the incoming
+// audio. Dropped audio frames are the overload signal, as audio is only
+// dropped when transcription falls behind. Only accessed under app.loadMu.
+type loadMonitor struct {
+	overloaded  bool
+	busy        int              // consecutive checks over the threshold
+	idle        int              // consecutive checks under it
+	lastDropped map[string]int64 // room token → dropped frames at the last check
+}
+
+// Overloaded reports whether the service is under sustained overload.
+func (app *Application) Overloaded() bool {
+	app.loadMu.Lock()
+	defer app.loadMu.Unlock()
+	return app.load.overloaded
+}
+
+// RunLoadMonitor checks the load every LoadCheckInterval until ctx is done.
+// The service counts as overloaded once OverloadChecks checks in a row saw
+// at least OverloadDroppedFrames dropped audio frames, and recovers after
+// OverloadRecoverChecks checks in a row below that. Targets are told about
+// both transitions. With load shedding enabled, rooms stop computing
+// partial transcripts while overloaded; finals are unaffected.
+func (app *Application) RunLoadMonitor(ctx context.Context) {
+	ticker := time.NewTicker(constants.LoadCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.checkLoad()
+		}
+	}
+}
+
+func (app *Application) checkLoad() {
+	app.mu.Lock()
+	rooms := make(map[string]*roomState, len(app.rooms))
+	for token, rs := range app.rooms {
+		rooms[token] = rs
+	}
+	app.mu.Unlock()
+
+	app.loadMu.Lock()
+	lm := &app.load
+	var dropped int64
+	seen := make(map[string]int64, len(rooms))
+	for token, rs := range rooms {
+		total := roomDroppedAudio(rs)
+		dropped += total - lm.lastDropped[token]
+		seen[token] = total
+	}
+	lm.lastDropped = seen
+
+	if dropped >= constants.OverloadDroppedFrames {
+		lm.busy++
+		lm.idle = 0
+	} else {
+		lm.idle++
+		lm.busy = 0
+	}
+
+	changed := false
+	switch {
+	case !lm.overloaded && lm.busy >= constants.OverloadChecks:
+		lm.overloaded, changed = true, true
+		slog.Warn("transcription overloaded, audio is being dropped",
+			"dropped_frames", dropped, "shed_load", app.cfg.ShedLoad)
+	case lm.overloaded && lm.idle >= constants.OverloadRecoverChecks:
+		lm.overloaded, changed = false, true
+		slog.Info("transcription load recovered")
+	}
+	overloaded := lm.overloaded
+	app.loadMu.Unlock()
+
+	if !changed {
+		return
+	}
+	for _, rs := range rooms {
+		app.applyLoad(rs, overloaded)
+		rs.client.SendLoadStatus(overloaded)
+	}
+}
+
+// roomDroppedAudio returns the audio frames a room dropped so far, replaced
+// in tests.
+var roomDroppedAudio = func(rs *roomState) int64 {
+	return rs.client.Stats().DroppedAudio
+}
+
+// applyLoad sheds or restores the room's partial transcripts, if load
+// shedding is enabled.
+func (app *Application) applyLoad(rs *roomState, overloaded bool) {
+	if app.cfg.ShedLoad {
+		rs.audioWorker.SetPartials(!overloaded)
+	}
+}
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"testing"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/asr"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+	"github.com/nextcloud/go_live_transcription/internal/vosk"
+)
+
+// partialsTranscriber records whether partials are enabled.
+type partialsTranscriber struct {
+	asr.Transcriber
+	partials bool
+}
+
+func (pt *partialsTranscriber) SetPartials(enabled bool) { pt.partials = enabled }
+
+func TestLoadOverloadAndRecovery(t *testing.T) {
+	orig := roomDroppedAudio
+	t.Cleanup(func() { roomDroppedAudio = orig })
+	for _, shedLoad := range []bool{true, false} {
+		cfg := &appapi.Config{ShedLoad: shedLoad}
+		app := NewApplication(cfg, nil)
+		client := signaling.NewSpreedClient("room", &signaling.HPBSettings{}, "en", cfg, nil)
+		pt := &partialsTranscriber{partials: true}
+		app.rooms["room"] = &roomState{client: client, audioWorker: vosk.NewAudioWorker(client, pt, false)}
+
+		var dropped int64
+		roomDroppedAudio = func(*roomState) int64 { return dropped }
+		check := func(newDrops int64) {
+			dropped += newDrops
+			app.checkLoad()
+		}
+
+		for range constants.OverloadChecks - 1 {
+			check(constants.OverloadDroppedFrames)
+		}
+		// A check below the threshold starts the count over
+		check(constants.OverloadDroppedFrames - 1)
+		for range constants.OverloadChecks - 1 {
+			check(constants.OverloadDroppedFrames)
+		}
+		if app.Overloaded() {
+			t.Fatalf("shed load %v: overloaded before %d busy checks in a row", shedLoad, constants.OverloadChecks)
+		}
+		check(constants.OverloadDroppedFrames)
+		if !app.Overloaded() || pt.partials == shedLoad {
+			t.Fatalf("shed load %v: overloaded %v, partials %v after sustained drops",
+				shedLoad, app.Overloaded(), pt.partials)
+		}
+
+		// Drops are counted since the last check, so a high total is idle
+		for range constants.OverloadRecoverChecks - 1 {
+			check(0)
+		}
+		if !app.Overloaded() {
+			t.Fatalf("shed load %v: recovered before %d idle checks in a row", shedLoad, constants.OverloadRecoverChecks)
+		}
+		check(0)
+		if app.Overloaded() || !pt.partials {
+			t.Errorf("shed load %v: overloaded %v, partials %v after recovering",
+				shedLoad, app.Overloaded(), pt.partials)
+		}
+	}
+}
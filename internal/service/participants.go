@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+type participant struct {
+	ActorType   string   `json:"actorType"`
+	ActorID     string   `json:"actorId"`
+	DisplayName string   `json:"displayName"`
+	SessionIDs  []string `json:"sessionIds"`
+}
+
+// talkCapabilities is the part of the Nextcloud capabilities naming the
+// Talk features.
+type talkCapabilities struct {
+	Capabilities struct {
+		Spreed *struct {
+			Features []string `json:"features"`
+		} `json:"spreed"`
+	} `json:"capabilities"`
+}
+
+// roomAPIVersion returns the Talk room API version, "v4" if Talk has the
+// conversation-v4 feature, otherwise "v3". It is fetched once.
+func (app *Application) roomAPIVersion(ctx context.Context, userID string) (string, error) {
+	app.talkAPIMu.Lock()
+	defer app.talkAPIMu.Unlock()
+	if app.talkAPIVersion != "" {
+		return app.talkAPIVersion, nil
+	}
+
+	data, err := app.client.OCSGet(ctx, "/ocs/v2.php/cloud/capabilities", userID)
+	if err != nil {
+		return "", fmt.Errorf("fetching capabilities: %w", err)
+	}
+	var caps talkCapabilities
+	if err := json.Unmarshal(data, &caps); err != nil {
+		return "", fmt.Errorf("parsing capabilities: %w", err)
+	}
+	if caps.Capabilities.Spreed == nil {
+		return "", errors.New("talk capabilities missing")
+	}
+	app.talkAPIVersion = "v3"
+	if slices.Contains(caps.Capabilities.Spreed.Features, "conversation-v4") {
+		app.talkAPIVersion = "v4"
+	}
+	return app.talkAPIVersion, nil
+}
+
+// fetchSpeakerNames returns the display names of the room's participants
+// keyed by Nextcloud session ID, fetched as the participant userID, which
+// is nil until one asks for transcripts. Guests without a name get a generic
+// one.
+func (app *Application) fetchSpeakerNames(ctx context.Context, roomToken string, userID *string) (map[string]string, error) {
+	if userID == nil {
+		return nil, errors.New("no participant to fetch the participants as")
+	}
+	version, err := app.roomAPIVersion(ctx, *userID)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/ocs/v2.php/apps/spreed/api/%s/room/%s/participants", version, url.PathEscape(roomToken))
+	data, err := app.client.OCSGet(ctx, path, *userID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching participants: %w", err)
+	}
+
+	var participants []participant
+	if err := json.Unmarshal(data, &participants); err != nil {
+		return nil, fmt.Errorf("parsing participants: %w", err)
+	}
+
+	names := make(map[string]string)
+	for _, p := range participants {
+		name := p.DisplayName
+		if name == "" {
+			if p.ActorType != "guests" {
+				name = p.ActorID
+			} else {
+				name = constants.GuestDisplayName
+			}
+		}
+		for _, sid := range p.SessionIDs {
+			// Talk reports "0" for participants without an active session
+			if sid != "" && sid != "0" {
+				names[sid] = name
+			}
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// fakeTalk serves the capabilities with features and the participants of
+// room "room" to alice only, as Talk shows them only to participants.
+type fakeTalk struct {
+	features string
+
+	mu    sync.Mutex
+	paths []string
+}
+
+func (f *fakeTalk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.paths = append(f.paths, r.URL.Path)
+	f.mu.Unlock()
+
+	auth, _ := base64.StdEncoding.DecodeString(r.Header.Get("AUTHORIZATION-APP-API"))
+	user, _, _ := strings.Cut(string(auth), ":")
+	switch {
+	case r.URL.Path == "/ocs/v2.php/cloud/capabilities":
+		fmt.Fprintf(w, `{"ocs":{"data":{"capabilities":{"spreed":{"features":[%s]}}}}}`, f.features)
+	case strings.HasSuffix(r.URL.Path, "/room/room/participants") && user == "alice":
+		fmt.Fprint(w, `{"ocs":{"data":[
+			{"actorType":"users","actorId":"alice","displayName":"Alice","sessionIds":["nc1"]},
+			{"actorType":"users","actorId":"bob","sessionIds":["nc2","nc3"]},
+			{"actorType":"guests","actorId":"g","sessionIds":["nc4"]},
+			{"actorType":"users","actorId":"carol","displayName":"Carol","sessionIds":["0"]}]}}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestFetchSpeakerNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		features string
+		wantPath string
+	}{
+		{"room API v4", `"audio","conversation-v4"`, "/ocs/v2.php/apps/spreed/api/v4/room/room/participants"},
+		{"room API v3", `"audio"`, "/ocs/v2.php/apps/spreed/api/v3/room/room/participants"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			talk := &fakeTalk{features: tt.features}
+			srv := httptest.NewServer(talk)
+			t.Cleanup(srv.Close)
+			cfg := &appapi.Config{NextcloudURL: srv.URL, OCSRetryMaxAttempts: 1}
+			app := NewApplication(cfg, appapi.NewClient(cfg))
+
+			user := "alice"
+			names, err := app.fetchSpeakerNames(context.Background(), "room", &user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{"nc1": "Alice", "nc2": "bob", "nc3": "bob", "nc4": constants.GuestDisplayName}
+			if !maps.Equal(names, want) {
+				t.Errorf("names %v, want %v", names, want)
+			}
+			if _, err := app.fetchSpeakerNames(context.Background(), "room", &user); err != nil {
+				t.Fatal(err)
+			}
+			wantPaths := []string{"/ocs/v2.php/cloud/capabilities", tt.wantPath, tt.wantPath}
+			if fmt.Sprint(talk.paths) != fmt.Sprint(wantPaths) {
+				t.Errorf("requested %v, want the capabilities once then %v", talk.paths, wantPaths)
+			}
+
+			other := "mallory"
+			if _, err := app.fetchSpeakerNames(context.Background(), "room", &other); err == nil {
+				t.Error("fetched the participants as a non-participant")
+			}
+			if _, err := app.fetchSpeakerNames(context.Background(), "room", nil); err == nil {
+				t.Error("fetched the participants without a participant")
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/asr"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/vosk"
+)
+
+type Readiness struct {
+	Ready   bool     `json:"ready"`
+	Reasons []string `json:"reasons,omitempty"` // what blocks readiness
+}
+
+// Readiness reports whether a call could be transcribed right now: a model
+// is installed, unless the ASR backend brings its own, and the HPB
+// settings are loaded. Missing settings are fetched again at most every
+// HPBSettingsRetryInterval, as they are otherwise only fetched on startup
+// and the first call.
+func (app *Application) Readiness(ctx context.Context) Readiness {
+	var reasons []string
+	if asr.NeedsLocalModels(app.cfg.ASRBackend) && len(vosk.GetModelManager().ListAvailableModels()) == 0 {
+		reasons = append(reasons, "no speech recognition model is installed")
+	}
+	if !app.hpbSettingsLoaded(ctx) {
+		reasons = append(reasons, "the signaling (HPB) settings could not be loaded from Nextcloud")
+	}
+	return Readiness{Ready: len(reasons) == 0, Reasons: reasons}
+}
+
+func (app *Application) hpbSettingsLoaded(ctx context.Context) bool {
+	if app.HPBSettings() != nil {
+		return true
+	}
+
+	app.hpbFetchMu.Lock()
+	defer app.hpbFetchMu.Unlock()
+	if time.Since(app.hpbFetched) < constants.HPBSettingsRetryInterval {
+		return false
+	}
+	app.hpbFetched = time.Now()
+
+	if _, err := app.ensureHPBSettings(ctx); err != nil {
+		slog.Warn("HPB settings still unavailable", "error", err)
+		return false
+	}
+	return true
+}
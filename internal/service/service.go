@@ -6,17 +6,31 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/asr"
 	"github.com/nextcloud/go_live_transcription/internal/constants"
 	"github.com/nextcloud/go_live_transcription/internal/signaling"
 	"github.com/nextcloud/go_live_transcription/internal/transcript"
 	"github.com/nextcloud/go_live_transcription/internal/translation"
 	"github.com/nextcloud/go_live_transcription/internal/vosk"
+	"github.com/nextcloud/go_live_transcription/internal/webhook"
+)
+
+var (
+	ErrTooManyRooms     = errors.New("too many concurrent calls")
+	ErrSpeakerNotInCall = errors.New("speaker is not in the call")
+	ErrMixedAudio       = errors.New("speakers are transcribed together in mixed audio mode")
+	ErrRoomNotFound     = errors.New("no active transcription session for the room")
+
+	ErrDetectionNotConfigured = errors.New("no language detection candidates configured")
 )
 
 type roomState struct {
@@ -25,30 +39,131 @@ type roomState struct {
 	audioWorker *vosk.AudioWorker
 	meta        *translation.MetaTranslator
 	transSender *translation.TranslatedSender
+	feed        *transcript.Feed
+	recorder    *transcript.Recorder // nil unless the call is recorded
 	cancel      context.CancelFunc
+	startedAt   time.Time // when connected, zero before; guarded by app.mu
+	// namesUser is the participant the speaker names are fetched as, the
+	// latest to enable transcripts, as the participant list is only shown
+	// to participants
+	namesUser atomic.Pointer[string]
+	// tuningOwner is the participant who started the call, the only one
+	// whose tuning applies, so one participant cannot retune the room for
+	// everyone. Guarded by app.mu.
+	tuningOwner string
+
+	targetMu sync.Mutex // serializes target language changes, guards defaults
+	defaults *defaultTarget
+}
+
+// startRecording is a no-op if the room is already recorded. Once the room
+// is registered, callers hold app.mu.
+func (rs *roomState) startRecording(storageDir, roomToken string) error {
+	if rs.recorder != nil {
+		return nil
+	}
+	rec, err := transcript.OpenRecorder(storageDir, roomToken)
+	if err != nil {
+		return fmt.Errorf("start recording: %w", err)
+	}
+	rs.recorder = rec
+	rs.sender.SetRecorder(rec)
+	slog.Info("recording transcript", "room_token", roomToken)
+	return nil
+}
+
+// setRedactor makes both senders of the room redact with r, nil stops it.
+func (rs *roomState) setRedactor(r *transcript.Redactor) {
+	rs.sender.SetRedactor(r)
+	rs.transSender.SetRedactor(r)
+}
+
+// setNamesUser fetches the speaker names as the user from now on, unless
+// the request came without one.
+func (rs *roomState) setNamesUser(userID string) {
+	if userID != "" {
+		rs.namesUser.Store(&userID)
+	}
+}
+
+func (rs *roomState) stopRecording() {
+	if rs.recorder == nil {
+		return
+	}
+	rs.sender.SetRecorder(nil)
+	if err := rs.recorder.Close(); err != nil {
+		slog.Error("failed to close transcript recorder", "error", err)
+	}
+	rs.recorder = nil
 }
 
 type Application struct {
 	mu          sync.Mutex
 	cfg         *appapi.Config
 	client      *appapi.Client
-	hpbSettings *signaling.HPBSettings
+	hpbSettings atomic.Pointer[signaling.HPBSettings] // nil until fetched, see HPBSettings
 	rooms       map[string]*roomState
+	langPolicy  *translation.LangPolicy
+	redactor    *transcript.Redactor // shared by the rooms redacting transcripts
+	punctuator  transcript.Punctuator
+	lifecycle   *webhook.Poster // nil without a lifecycle webhook
+	transcripts *webhook.Poster // nil without a transcript webhook
+
+	providerMu      sync.Mutex
+	providerChecked time.Time
+	providerOK      bool
+
+	hpbFetchMu sync.Mutex
+	hpbFetched time.Time // last fetch attempt of the readiness check
+
+	talkAPIMu      sync.Mutex
+	talkAPIVersion string // Talk room API version, "" until fetched
+
+	loadMu sync.Mutex
+	load   loadMonitor
 }
 
 func NewApplication(cfg *appapi.Config, client *appapi.Client) *Application {
 	app := &Application{
-		cfg:    cfg,
-		client: client,
-		rooms:  make(map[string]*roomState),
+		cfg:        cfg,
+		client:     client,
+		rooms:      make(map[string]*roomState),
+		langPolicy: translation.NewLangPolicy(cfg),
+	}
+
+	words := cfg.RedactWords
+	if words == nil {
+		words = transcript.DefaultRedactWords
+	}
+	redactor, err := transcript.NewRedactor(words, cfg.RedactPatterns, cfg.RedactMask)
+	if err != nil {
+		// LoadConfig has compiled the patterns already
+		slog.Error("transcript redaction unavailable", "error", err)
+	}
+	app.redactor = redactor
+
+	if cfg.LifecycleWebhookURL != "" {
+		app.lifecycle = webhook.NewPoster(cfg.LifecycleWebhookURL, constants.WebhookQueueSize, cfg.ProxyFunc())
+	}
+	if cfg.TranscriptWebhookURL != "" {
+		app.transcripts = webhook.NewPoster(cfg.TranscriptWebhookURL, constants.TranscriptWebhookQueueSize, cfg.ProxyFunc())
+		if cfg.TranscriptWebhookSecret != "" {
+			app.transcripts.SetSecret(cfg.TranscriptWebhookSecret)
+		}
+	}
+
+	if cfg.PunctuationProvider == "ocp" {
+		app.punctuator = translation.NewOCPPunctuator(client, cfg.PunctuationTimeout)
+	} else {
+		app.punctuator = transcript.HeuristicPunctuator{}
 	}
 
 	if cfg.HPBUrl != "" && cfg.InternalSecret != "" {
-		hpbSettings, err := app.fetchHPBSettings()
+		hpbSettings, err := app.fetchHPBSettings(context.Background())
 		if err != nil {
 			slog.Warn("failed to fetch HPB settings on startup, will retry on first call", "error", err)
 		} else {
-			app.hpbSettings = hpbSettings
+			app.hpbSettings.Store(hpbSettings)
 		}
 	} else {
 		slog.Info("HPB not configured (LT_HPB_URL/LT_INTERNAL_SECRET not set)")
@@ -58,8 +173,8 @@ func NewApplication(cfg *appapi.Config, client *appapi.Client) *Application {
 	return app
 }
 
-func (app *Application) fetchHPBSettings() (*signaling.HPBSettings, error) {
-	data, err := app.client.OCSGet("/ocs/v2.php/apps/spreed/api/v3/signaling/settings", "admin")
+func (app *Application) fetchHPBSettings(ctx context.Context) (*signaling.HPBSettings, error) {
+	data, err := app.client.OCSGet(ctx, app.cfg.SpreedSignalingPath("settings"), "admin")
 	if err != nil {
 		return nil, fmt.Errorf("fetching signaling settings: %w", err)
 	}
@@ -77,7 +192,44 @@ func (app *Application) fetchHPBSettings() (*signaling.HPBSettings, error) {
 	return &settings, nil
 }
 
-func (app *Application) TranscriptReq(ctx context.Context, roomToken, ncSessionID, langID string, enable bool) error {
+// ensureHPBSettings returns the HPB settings, fetching them if they could
+// not be fetched before.
+func (app *Application) ensureHPBSettings(ctx context.Context) (*signaling.HPBSettings, error) {
+	if settings := app.HPBSettings(); settings != nil {
+		return settings, nil
+	}
+
+	settings, err := app.fetchHPBSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// A concurrent fetch or refresh may have stored settings meanwhile
+	if !app.hpbSettings.CompareAndSwap(nil, settings) {
+		return app.HPBSettings(), nil
+	}
+	return settings, nil
+}
+
+// HPBSettings returns the current signaling settings, or nil if they could
+// not be fetched yet. They are replaced as a whole on refresh and must not
+// be modified.
+func (app *Application) HPBSettings() *signaling.HPBSettings {
+	return app.hpbSettings.Load()
+}
+
+// TranscriptReq enables or disables transcripts for a participant, joining
+// the call for the first one. Repeating either is harmless. It returns the
+// number of participants receiving or awaiting transcripts.
+func (app *Application) TranscriptReq(
+	ctx context.Context,
+	roomToken, ncSessionID, userID, langID string,
+	enable, record bool,
+	tuning RoomTuning,
+) (int, error) {
+	if err := tuning.Validate(); err != nil {
+		return 0, err
+	}
+
 	app.mu.Lock()
 
 	if rs, ok := app.rooms[roomToken]; ok {
@@ -87,54 +239,97 @@ func (app *Application) TranscriptReq(ctx context.Context, roomToken, ncSessionI
 				app.mu.Unlock()
 				slog.Info("client defunct, deferring restart", "room_token", roomToken)
 				time.Sleep(5 * time.Second)
-				return app.TranscriptReq(ctx, roomToken, ncSessionID, langID, enable)
+				return app.TranscriptReq(ctx, roomToken, ncSessionID, userID, langID, enable, record, tuning)
 			}
 			app.mu.Unlock()
-			return nil
+			return 0, nil
 		}
 
+		var count int
 		if enable {
-			rs.client.AddTarget(ncSessionID)
+			if record {
+				if err := rs.startRecording(app.cfg.PersistentStorage, roomToken); err != nil {
+					app.mu.Unlock()
+					return 0, err
+				}
+			}
+			rs.applyTuning(ncSessionID, tuning)
+			rs.setNamesUser(userID)
 		} else {
-			rs.client.RemoveTarget(ncSessionID)
+			count = rs.client.RemoveTarget(ncSessionID)
 		}
 		app.mu.Unlock()
-		return nil
+
+		if enable {
+			// The default target comes first, so a participant translated
+			// into it gets no history in the original language
+			app.applyDefaultTarget(ctx, rs, roomToken, ncSessionID)
+			count = rs.client.AddTarget(ncSessionID)
+		}
+		return count, nil
 	}
 	app.mu.Unlock()
 
 	if !enable {
 		slog.Info("no active call, ignoring disable request", "room_token", roomToken)
-		return nil
+		return 0, nil
+	}
+
+	// Checked again once the room is set up, as other calls may start
+	// meanwhile
+	app.mu.Lock()
+	err := app.checkRoomLimitLocked(roomToken)
+	app.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	// Fail early and clearly instead of on the first speaker's audio
+	if err := app.checkModel(langID); err != nil {
+		return 0, err
 	}
 
 	// New call — ensure HPB settings
-	if app.hpbSettings == nil {
-		settings, err := app.fetchHPBSettings()
-		if err != nil {
-			return fmt.Errorf("HPB settings unavailable: %w", err)
-		}
-		app.hpbSettings = settings
+	settings, err := app.ensureHPBSettings(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("HPB settings unavailable: %w", err)
 	}
 
 	client := signaling.NewSpreedClient(
 		roomToken,
-		app.hpbSettings,
+		settings,
 		langID,
 		app.cfg,
 		app.leaveCallCb,
 	)
 
-	transcriberMgr := vosk.NewTranscriberManager(langID, 16000, client.TranscriptCh)
-	audioWorker := vosk.NewAudioWorker(client, transcriberMgr)
+	transcriber, err := asr.NewTranscriber(app.cfg, langID, client.TranscriptCh)
+	if err != nil {
+		return 0, err
+	}
+	audioWorker := vosk.NewAudioWorker(client, transcriber, app.cfg.MixedAudio)
 
 	translateIn := make(chan transcript.TranslateInputOutput, 100)
 	translateOut := make(chan transcript.TranslateInputOutput, 100)
-	meta := translation.NewMetaTranslator(app.client, roomToken, langID, translateIn, translateOut)
+	meta := translation.NewMetaTranslator(app.client, app.cfg, roomToken, langID, translateIn, translateOut)
+	client.SetReplaySkip(meta.IsTranslationTarget)
 	sender := transcript.NewSender(client, client.TranscriptCh, translateIn, meta)
+	if app.cfg.PartialTranslation {
+		sender.EnablePartialTranslation(app.cfg.PartialTranslationDebounce)
+	}
+	if app.cfg.MergeFinalsGap > 0 {
+		sender.EnableFinalMerging(app.cfg.MergeFinalsGap)
+	}
 	transSender := translation.NewTranslatedSender(client, translateOut)
+	feed := transcript.NewFeed()
+	sender.SetFeed(feed)
+	if app.transcripts != nil {
+		sender.SetWebhook(app.transcripts, roomToken)
+	}
+	transSender.SetFeed(feed)
 
 	roomCtx, roomCancel := context.WithCancel(context.Background())
+	context.AfterFunc(roomCtx, feed.Close)
 
 	rs := &roomState{
 		client:      client,
@@ -142,11 +337,52 @@ func (app *Application) TranscriptReq(ctx context.Context, roomToken, ncSessionI
 		audioWorker: audioWorker,
 		meta:        meta,
 		transSender: transSender,
+		feed:        feed,
 		cancel:      roomCancel,
+		defaults:    newDefaultTarget(),
+		tuningOwner: ncSessionID,
+	}
+	rs.applyTuning(ncSessionID, tuning)
+	rs.setNamesUser(userID)
+	if app.cfg.SpeakerNames {
+		client.SetSpeakerNameResolver(func(ctx context.Context, roomToken string) (map[string]string, error) {
+			return app.fetchSpeakerNames(ctx, roomToken, rs.namesUser.Load())
+		})
+	}
+	if app.cfg.Redact {
+		rs.setRedactor(app.redactor)
+	}
+	if app.cfg.Punctuation {
+		sender.SetPunctuator(app.punctuator)
+	}
+	if app.Overloaded() {
+		app.applyLoad(rs, true)
+	}
+
+	// Until the audio worker runs, which closes the transcriber when the
+	// room ends, a discarded room must close it itself
+	discard := func() {
+		roomCancel()
+		transcriber.CloseAll()
 	}
 
 	app.mu.Lock()
+	if err := app.checkRoomLimitLocked(roomToken); err != nil {
+		app.mu.Unlock()
+		discard()
+		return 0, err
+	}
+	// Settings refreshed since the client was created were not handed to it
+	client.SetHPBSettings(app.HPBSettings())
 	app.rooms[roomToken] = rs
+	if record {
+		if err := rs.startRecording(app.cfg.PersistentStorage, roomToken); err != nil {
+			delete(app.rooms, roomToken)
+			app.mu.Unlock()
+			discard()
+			return 0, err
+		}
+	}
 	app.mu.Unlock()
 
 	go sender.Run(roomCtx)
@@ -155,41 +391,99 @@ func (app *Application) TranscriptReq(ctx context.Context, roomToken, ncSessionI
 
 	var lastErr error
 	for i := 0; i < constants.MaxConnectTries; i++ {
-		result, err := client.Connect(roomCtx, signaling.NoReconnect)
+		result, err := connectClient(roomCtx, client)
 		switch result {
 		case signaling.SigConnectSuccess:
-			client.AddTarget(ncSessionID)
+			count := client.AddTarget(ncSessionID)
 			slog.Info("connected to signaling server", "room_token", roomToken)
-			return nil
+			app.callStarted(roomToken, rs)
+			return count, nil
 		case signaling.SigConnectFailure:
-			client.Close()
-			roomCancel()
-			app.mu.Lock()
-			delete(app.rooms, roomToken)
-			app.mu.Unlock()
-			return fmt.Errorf("connection failed: %w", err)
+			app.abandonRoom(roomToken, rs)
+			return 0, fmt.Errorf("connection failed: %w", err)
 		case signaling.SigConnectRetry:
 			lastErr = err
-			time.Sleep(2 * time.Second)
+			delay := constants.ConnectRetryDelay
+			if errors.Is(err, signaling.ErrRateLimited) {
+				delay = constants.RateLimitedRetryDelay
+			}
+			time.Sleep(delay)
 		}
 	}
 
-	return fmt.Errorf("failed to connect after %d attempts: %w", constants.MaxConnectTries, lastErr)
+	app.abandonRoom(roomToken, rs)
+	return 0, fmt.Errorf("failed to connect after %d attempts: %w", constants.MaxConnectTries, lastErr)
+}
+
+// connectClient connects a new room's client to the HPB, replaced in tests.
+var connectClient = func(ctx context.Context, client *signaling.SpreedClient) (signaling.SigConnectResult, error) {
+	return client.Connect(ctx, signaling.NoReconnect)
+}
+
+// checkRoomLimitLocked fails with ErrTooManyRooms if a new room would exceed
+// MaxRooms. Must be called with app.mu held.
+func (app *Application) checkRoomLimitLocked(roomToken string) error {
+	if limit := app.cfg.MaxRooms; limit > 0 && len(app.rooms) >= limit {
+		slog.Warn("room limit reached, rejecting call", "room_token", roomToken, "max_rooms", limit)
+		return fmt.Errorf("%w: limit of %d concurrent calls reached", ErrTooManyRooms, limit)
+	}
+	return nil
+}
+
+// abandonRoom tears down a room whose client never connected, so it does
+// not count against MaxRooms.
+func (app *Application) abandonRoom(roomToken string, rs *roomState) {
+	rs.client.Close()
+	rs.cancel()
+	app.mu.Lock()
+	rs.stopRecording()
+	if app.rooms[roomToken] == rs {
+		delete(app.rooms, roomToken)
+	}
+	app.mu.Unlock()
 }
 
-func (app *Application) LeaveCall(roomToken string) {
+// LeaveCall closes the signaling client of a room. It reports true only if
+// this call closed an active client, so of concurrent calls only one does.
+func (app *Application) LeaveCall(roomToken string) bool {
 	app.mu.Lock()
 	rs, ok := app.rooms[roomToken]
 	app.mu.Unlock()
 
 	if !ok {
-		return
+		return false
 	}
 
-	rs.client.Close()
+	return rs.client.CloseWithReason(signaling.CloseReasonLeft)
+}
+
+// TranscriptFile returns the path of a room's recorded transcript, or an
+// error wrapping os.ErrNotExist if the room was never recorded.
+func (app *Application) TranscriptFile(roomToken string) (string, error) {
+	path, err := transcript.TranscriptPath(app.cfg.PersistentStorage, roomToken)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// checkModel fails for a language whose Vosk model is not installed, with
+// an ASR backend using the installed models.
+func (app *Application) checkModel(langID string) error {
+	if !asr.NeedsLocalModels(app.cfg.ASRBackend) {
+		return nil
+	}
+	return vosk.GetModelManager().CheckInstalled(langID)
 }
 
 func (app *Application) SetCallLanguage(roomToken, langID string) error {
+	if err := app.checkModel(langID); err != nil {
+		return err
+	}
+
 	app.mu.Lock()
 	rs, ok := app.rooms[roomToken]
 	app.mu.Unlock()
@@ -199,11 +493,13 @@ func (app *Application) SetCallLanguage(roomToken, langID string) error {
 		return nil
 	}
 
-	rs.client.SetRoomLangID(langID)
+	// Switch the recognizers first: they flush their pending utterances as
+	// finals tagged with the old language, which must still be the room's.
 	if err := rs.audioWorker.SetLanguage(langID); err != nil {
 		slog.Error("failed to switch transcription language", "error", err, "room_token", roomToken, "lang_id", langID)
 		return fmt.Errorf("failed to switch transcription language: %w", err)
 	}
+	rs.client.SetRoomLangID(langID)
 
 	if rs.meta != nil {
 		rs.meta.SetRoomLangID(langID)
@@ -213,13 +509,149 @@ func (app *Application) SetCallLanguage(roomToken, langID string) error {
 	return nil
 }
 
-func (app *Application) GetTranslationLanguages(roomToken string) (any, error) {
+// SubscribeTranscripts subscribes to the transcripts and translations of an
+// active room as they are sent. The subscription closes when the room ends.
+func (app *Application) SubscribeTranscripts(roomToken string) (*transcript.Subscription, error) {
+	app.mu.Lock()
+	rs, ok := app.rooms[roomToken]
+	app.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, roomToken)
+	}
+	sub := rs.feed.Subscribe()
+	if sub == nil {
+		return nil, fmt.Errorf("%w: %s", ErrRoomNotFound, roomToken)
+	}
+	return sub, nil
+}
+
+// SetSpeakerLanguage transcribes one participant in a language other than
+// the call's, for calls where people speak different languages. An empty
+// langID makes the participant follow the call language again.
+func (app *Application) SetSpeakerLanguage(roomToken, ncSessionID, langID string) error {
+	if app.cfg.MixedAudio {
+		return ErrMixedAudio
+	}
+
+	app.mu.Lock()
+	rs, ok := app.rooms[roomToken]
+	app.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no active transcription session for room %s", roomToken)
+	}
+
+	hpbSid := rs.client.ResolveNcSessionID(ncSessionID)
+	if hpbSid == "" {
+		return fmt.Errorf("%w: %s", ErrSpeakerNotInCall, ncSessionID)
+	}
+	if err := rs.audioWorker.SetSpeakerLanguage(hpbSid, langID); err != nil {
+		return fmt.Errorf("failed to switch speaker language: %w", err)
+	}
+
+	slog.Info("set speaker language", "room_token", roomToken, "nc_session_id", ncSessionID, "lang_id", langID)
+	return nil
+}
+
+// SetGrammar constrains the room's recognition to the phrases, such as
+// the commands or terms of a structured meeting; speech outside them is
+// not transcribed. With Vosk this needs a model with a runtime graph,
+// usually the small ones; others recognize freely.
+func (app *Application) SetGrammar(roomToken string, phrases []string) error {
+	app.mu.Lock()
+	rs, ok := app.rooms[roomToken]
+	app.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrRoomNotFound, roomToken)
+	}
+	rs.audioWorker.SetGrammar(phrases)
+
+	slog.Info("set grammar", "room_token", roomToken, "phrases", len(phrases))
+	return nil
+}
+
+// SetPunctuation enables or disables restoring capitalization and
+// punctuation of the room's finals.
+func (app *Application) SetPunctuation(roomToken string, enabled bool) error {
+	app.mu.Lock()
+	rs, ok := app.rooms[roomToken]
+	app.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrRoomNotFound, roomToken)
+	}
+
+	var punctuator transcript.Punctuator
+	if enabled {
+		punctuator = app.punctuator
+	}
+	rs.sender.SetPunctuator(punctuator)
+
+	slog.Info("set punctuation", "room_token", roomToken, "enabled", enabled)
+	return nil
+}
+
+// SetRedaction enables or disables masking profanity and personal data in
+// the room's transcripts and translations. The text is redacted before it
+// is recorded or translated.
+func (app *Application) SetRedaction(roomToken string, enabled bool) error {
+	app.mu.Lock()
+	rs, ok := app.rooms[roomToken]
+	app.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrRoomNotFound, roomToken)
+	}
+	if enabled && app.redactor == nil {
+		return fmt.Errorf("transcript redaction is unavailable")
+	}
+
+	var redactor *transcript.Redactor
+	if enabled {
+		redactor = app.redactor
+	}
+	rs.setRedactor(redactor)
+
+	slog.Info("set redaction", "room_token", roomToken, "enabled", enabled)
+	return nil
+}
+
+// SetLanguageDetection enables or disables detecting the language of each
+// new speaker of the room among the configured candidate languages.
+// Speakers without a match follow the call language.
+func (app *Application) SetLanguageDetection(roomToken string, enabled bool) error {
+	app.mu.Lock()
+	rs, ok := app.rooms[roomToken]
+	app.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no active transcription session for room %s", roomToken)
+	}
+
+	var candidates []string
+	if enabled {
+		if candidates = app.cfg.LanguageDetectionCandidates; len(candidates) == 0 {
+			return ErrDetectionNotConfigured
+		}
+	}
+	rs.audioWorker.SetLanguageDetection(candidates)
+
+	slog.Info("set language detection", "room_token", roomToken, "enabled", enabled, "candidates", candidates)
+	return nil
+}
+
+func (app *Application) GetTranslationLanguages(ctx context.Context, roomToken string) (any, error) {
 	app.mu.Lock()
 	rs, ok := app.rooms[roomToken]
 	app.mu.Unlock()
 
 	if ok && rs.meta != nil {
-		langs, err := rs.meta.GetTranslationLanguages()
+		langs, err := rs.meta.GetTranslationLanguages(ctx)
+		if errors.Is(err, translation.ErrProviderMalformed) {
+			return nil, err
+		}
 		if err != nil {
 			slog.Warn("failed to get translation languages from meta translator", "error", err)
 		} else {
@@ -227,8 +659,11 @@ func (app *Application) GetTranslationLanguages(roomToken string) (any, error) {
 		}
 	}
 
-	tmp := translation.NewOCPTranslator(app.client, "en", "en", "languages-dummy")
-	langs, err := tmp.GetTranslationLanguages()
+	tmp := translation.NewTranslator(app.client, app.cfg, "languages-dummy", "en", "en")
+	langs, err := tmp.GetTranslationLanguages(ctx)
+	if errors.Is(err, translation.ErrProviderMalformed) {
+		return nil, err
+	}
 	if err != nil {
 		slog.Info("get translation languages", "room_token", roomToken)
 		return map[string]any{
@@ -236,19 +671,19 @@ func (app *Application) GetTranslationLanguages(roomToken string) (any, error) {
 			"target_languages": map[string]any{},
 		}, nil
 	}
-	return langs, nil
+	return app.langPolicy.Filter(langs), nil
 }
 
-func (app *Application) GetTranslationLanguagesForCapabilities() *translation.SupportedTranslationLanguages {
-	tmp := translation.NewOCPTranslator(app.client, "en", "en", "languages-dummy")
-	langs, err := tmp.GetTranslationLanguages()
+func (app *Application) GetTranslationLanguagesForCapabilities(ctx context.Context) *translation.SupportedTranslationLanguages {
+	tmp := translation.NewTranslator(app.client, app.cfg, "languages-dummy", "en", "en")
+	langs, err := tmp.GetTranslationLanguages(ctx)
 	if err != nil {
 		return nil
 	}
-	return langs
+	return app.langPolicy.Filter(langs)
 }
 
-func (app *Application) SetTargetLanguage(roomToken, ncSessionID string, langID *string) error {
+func (app *Application) SetTargetLanguage(ctx context.Context, roomToken, ncSessionID string, langID *string) error {
 	app.mu.Lock()
 	rs, ok := app.rooms[roomToken]
 	app.mu.Unlock()
@@ -258,13 +693,19 @@ func (app *Application) SetTargetLanguage(roomToken, ncSessionID string, langID
 		return fmt.Errorf("no active transcription session for room %s", roomToken)
 	}
 
+	// An explicit choice, including none, overrides the room default
+	rs.targetMu.Lock()
+	defer rs.targetMu.Unlock()
+	rs.defaults.explicit[ncSessionID] = struct{}{}
+	delete(rs.defaults.defaulted, ncSessionID)
+
 	if langID == nil || *langID == "" {
 		rs.meta.RemoveTranslator(ncSessionID)
 		slog.Info("removed target language", "room_token", roomToken, "nc_session_id", ncSessionID)
 		return nil
 	}
 
-	if err := rs.meta.AddTranslator(*langID, ncSessionID); err != nil {
+	if err := rs.meta.AddTranslator(ctx, *langID, ncSessionID); err != nil {
 		return fmt.Errorf("failed to set target language: %w", err)
 	}
 
@@ -282,12 +723,14 @@ func (app *Application) leaveCallCb(roomToken string) {
 
 	if rs, ok := app.rooms[roomToken]; ok {
 		if rs.client.IsDefunct() {
+			app.callEndedLocked(roomToken, rs, rs.client.CloseReason())
 			if rs.cancel != nil {
 				rs.cancel()
 			}
 			if rs.meta != nil {
 				rs.meta.Shutdown()
 			}
+			rs.stopRecording()
 			delete(app.rooms, roomToken)
 			slog.Info("cleaned up defunct client", "room_token", roomToken)
 		}
@@ -299,13 +742,15 @@ func (app *Application) Shutdown() {
 	defer app.mu.Unlock()
 
 	for token, rs := range app.rooms {
-		rs.client.Close()
+		rs.client.CloseWithReason(signaling.CloseReasonShutdown)
+		app.callEndedLocked(token, rs, signaling.CloseReasonShutdown)
 		if rs.cancel != nil {
 			rs.cancel()
 		}
 		if rs.meta != nil {
 			rs.meta.Shutdown()
 		}
+		rs.stopRecording()
 		delete(app.rooms, token)
 	}
 	slog.Info("application shutdown complete")
@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+)
+
+func TestRoomLimitRejectsNextCall(t *testing.T) {
+	app := NewApplication(&appapi.Config{MaxRooms: 2, ASRBackend: "remote"}, nil)
+	app.rooms["room1"] = &roomState{}
+	app.rooms["room2"] = &roomState{}
+
+	_, err := app.TranscriptReq(context.Background(), "room3", "nc", "alice", "en", true, false, RoomTuning{})
+	if !errors.Is(err, ErrTooManyRooms) {
+		t.Fatalf("third call: error %v, want ErrTooManyRooms", err)
+	}
+	if len(app.rooms) != 2 {
+		t.Errorf("%d rooms after the rejection, want 2", len(app.rooms))
+	}
+
+	// Disabling transcription in an unknown room is no call to reject
+	if _, err := app.TranscriptReq(context.Background(), "room3", "nc", "alice", "en", false, false, RoomTuning{}); err != nil {
+		t.Errorf("disable in a new room: %v", err)
+	}
+}
+
+func TestConcurrentLeaveCall(t *testing.T) {
+	cfg := &appapi.Config{}
+	app := NewApplication(cfg, nil)
+	client := signaling.NewSpreedClient("room", &signaling.HPBSettings{}, "en", cfg, nil)
+	app.rooms["room"] = &roomState{client: client}
+
+	var wg sync.WaitGroup
+	var left atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if app.LeaveCall("room") {
+				left.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if left.Load() != 1 {
+		t.Errorf("%d calls left the room, want 1", left.Load())
+	}
+	if !client.IsDefunct() {
+		t.Error("client not closed")
+	}
+	if app.LeaveCall("room") {
+		t.Error("left a closed room again")
+	}
+}
@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+	"github.com/nextcloud/go_live_transcription/internal/vosk"
+	"github.com/nextcloud/go_live_transcription/internal/webhook"
+)
+
+type RoomStats struct {
+	signaling.ClientStats
+	Recognizers   int    `json:"recognizers"`
+	Language      string `json:"language"`
+	Translating   bool   `json:"translating"`
+	DroppedFinals int64  `json:"dropped_finals"`
+
+	Speakers map[string]SpeakerStats `json:"speakers"` // key: speaker session ID
+}
+
+// SpeakerStats are the audio statistics of a speaker with an audio track.
+type SpeakerStats struct {
+	signaling.SpeakerAudioStats
+	FedBytes int64 `json:"fed_bytes"`
+}
+
+type ProcessStats struct {
+	Goroutines   int            `json:"goroutines"`
+	RSSBytes     uint64         `json:"rss_bytes"`
+	GoHeapBytes  uint64         `json:"go_heap_bytes"`
+	GoTotalBytes uint64         `json:"go_total_bytes"`
+	CPUSeconds   float64        `json:"cpu_seconds"`
+	LoadedModels map[string]int `json:"loaded_models"` // language → ref count
+	Recognizers  int64          `json:"recognizers"`
+}
+
+// WebhookStats are the delivery statistics of a webhook.
+type WebhookStats struct {
+	Pending int64 `json:"pending"`
+	Dropped int64 `json:"dropped"` // queue full or delivery failed
+}
+
+type Stats struct {
+	Rooms      map[string]RoomStats    `json:"rooms"`
+	Process    ProcessStats            `json:"process"`
+	Overloaded bool                    `json:"overloaded"`
+	Webhooks   map[string]WebhookStats `json:"webhooks,omitempty"` // key: lifecycle, transcript
+}
+
+// Stats returns a snapshot of per-room resource usage plus process totals.
+// The Vosk models live in C memory and are only visible in the RSS.
+func (app *Application) Stats() Stats {
+	app.mu.Lock()
+	rooms := make(map[string]*roomState, len(app.rooms))
+	for token, rs := range app.rooms {
+		rooms[token] = rs
+	}
+	app.mu.Unlock()
+
+	stats := Stats{
+		Rooms:      make(map[string]RoomStats, len(rooms)),
+		Process:    processStats(),
+		Overloaded: app.Overloaded(),
+		Webhooks:   app.webhookStats(),
+	}
+	for token, rs := range rooms {
+		recognizers, language := rs.audioWorker.Stats()
+		speakers := make(map[string]SpeakerStats)
+		for sid, audio := range rs.client.SpeakerStats() {
+			speakers[sid] = SpeakerStats{SpeakerAudioStats: audio, FedBytes: rs.audioWorker.FedBytes(sid)}
+		}
+		stats.Rooms[token] = RoomStats{
+			ClientStats:   rs.client.Stats(),
+			Recognizers:   recognizers,
+			Language:      language,
+			Translating:   rs.meta != nil && rs.meta.IsTranslating(),
+			DroppedFinals: rs.audioWorker.DroppedFinals(),
+			Speakers:      speakers,
+		}
+	}
+	return stats
+}
+
+func (app *Application) webhookStats() map[string]WebhookStats {
+	posters := map[string]*webhook.Poster{"lifecycle": app.lifecycle, "transcript": app.transcripts}
+	stats := make(map[string]WebhookStats)
+	for name, p := range posters {
+		if p != nil {
+			stats[name] = WebhookStats{Pending: p.Pending(), Dropped: p.Dropped()}
+		}
+	}
+	return stats
+}
+
+func processStats() ProcessStats {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/heap/objects:bytes"},
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/cpu/classes/total:cpu-seconds"},
+	}
+	metrics.Read(samples)
+
+	ps := ProcessStats{
+		Goroutines:   runtime.NumGoroutine(),
+		RSSBytes:     readRSS(),
+		LoadedModels: vosk.GetModelManager().LoadedModels(),
+		Recognizers:  vosk.LiveRecognizers(),
+	}
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		ps.GoHeapBytes = samples[0].Value.Uint64()
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		ps.GoTotalBytes = samples[1].Value.Uint64()
+	}
+	if samples[2].Value.Kind() == metrics.KindFloat64 {
+		ps.CPUSeconds = samples[2].Value.Float64()
+	}
+	return ps
+}
+
+// readRSS returns the resident set size from /proc, or 0 where unavailable.
+func readRSS() uint64 {
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pages * uint64(os.Getpagesize())
+}
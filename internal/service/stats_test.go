@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/asr"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+	"github.com/nextcloud/go_live_transcription/internal/vosk"
+)
+
+// statsTranscriber reports fixed recognizer counters.
+type statsTranscriber struct {
+	asr.Transcriber
+	recognizers   int
+	language      string
+	droppedFinals int64
+}
+
+func (st *statsTranscriber) Stats() (int, string) { return st.recognizers, st.language }
+func (st *statsTranscriber) DroppedFinals() int64 { return st.droppedFinals }
+
+func TestRoomStats(t *testing.T) {
+	cfg := &appapi.Config{}
+	app := NewApplication(cfg, nil)
+	for token, st := range map[string]*statsTranscriber{
+		"room1": {recognizers: 2, language: "de", droppedFinals: 3},
+		"room2": {recognizers: 0, language: "en"},
+	} {
+		client := signaling.NewSpreedClient(token, &signaling.HPBSettings{}, st.language, cfg, nil)
+		app.rooms[token] = &roomState{client: client, audioWorker: vosk.NewAudioWorker(client, st, false)}
+	}
+	app.rooms["room1"].client.AddTarget("nc1") // awaiting its HPB session
+
+	stats := app.Stats()
+	if len(stats.Rooms) != 2 {
+		t.Fatalf("stats of %d rooms, want 2", len(stats.Rooms))
+	}
+	room1 := stats.Rooms["room1"]
+	if room1.Recognizers != 2 || room1.Language != "de" || room1.DroppedFinals != 3 {
+		t.Errorf("room1 recognizers %d, language %q, dropped finals %d, want 2, de, 3",
+			room1.Recognizers, room1.Language, room1.DroppedFinals)
+	}
+	if room1.PendingTargets != 1 || room1.Targets != 0 || room1.PeerConnections != 0 {
+		t.Errorf("room1 client stats %+v, want one pending target", room1.ClientStats)
+	}
+	if room1.Translating || len(room1.Speakers) != 0 {
+		t.Errorf("room1 translating %v with %d speakers, want neither", room1.Translating, len(room1.Speakers))
+	}
+	if room2 := stats.Rooms["room2"]; room2.Recognizers != 0 || room2.Language != "en" || room2.PendingTargets != 0 {
+		t.Errorf("room2 stats %+v", room2)
+	}
+	if len(stats.Webhooks) != 0 {
+		t.Errorf("stats of webhooks %v, none configured", stats.Webhooks)
+	}
+}
+
+func TestProcessStats(t *testing.T) {
+	ps := processStats()
+	if ps.Goroutines <= 0 || ps.GoHeapBytes == 0 || ps.GoTotalBytes < ps.GoHeapBytes {
+		t.Errorf("goroutines %d, heap %d, total %d", ps.Goroutines, ps.GoHeapBytes, ps.GoTotalBytes)
+	}
+	if runtime.GOOS == "linux" && ps.RSSBytes == 0 {
+		t.Error("no RSS read from /proc")
+	}
+	if ps.LoadedModels == nil {
+		t.Error("loaded models missing")
+	}
+}
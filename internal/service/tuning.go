@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var ErrInvalidTuning = errors.New("invalid tuning parameter")
+
+// Accepted ranges of the RoomTuning parameters.
+const (
+	MinForceFinalizeChunks = 50   // 1s of 20ms audio chunks
+	MaxForceFinalizeChunks = 3000 // 60s
+	MaxMinPartialInterval  = 5 * time.Second
+)
+
+// RoomTuning overrides the segmentation parameters of one room, e.g. shorter
+// segments for a fast debate or fewer partials for a lecture. Nil fields
+// keep the global defaults.
+type RoomTuning struct {
+	// ForceFinalizeChunks is the number of audio chunks after which an
+	// utterance without a natural end is finalized.
+	ForceFinalizeChunks *int
+
+	// MinPartialInterval is the minimum time between partial transcripts
+	// of a speaker; 0 sends every partial.
+	MinPartialInterval *time.Duration
+}
+
+func (t RoomTuning) Validate() error {
+	if n := t.ForceFinalizeChunks; n != nil && (*n < MinForceFinalizeChunks || *n > MaxForceFinalizeChunks) {
+		return fmt.Errorf("%w: force finalize chunks must be between %d and %d, got %d",
+			ErrInvalidTuning, MinForceFinalizeChunks, MaxForceFinalizeChunks, *n)
+	}
+	if d := t.MinPartialInterval; d != nil && (*d < 0 || *d > MaxMinPartialInterval) {
+		return fmt.Errorf("%w: min partial interval must be between 0 and %s, got %s",
+			ErrInvalidTuning, MaxMinPartialInterval, *d)
+	}
+	return nil
+}
+
+// applyTuning sets the overrides of a participant on the room's recognizers
+// and sender, unless they did not start the call. Parameters not overridden
+// keep their current value. Callers hold app.mu once the room is registered.
+func (rs *roomState) applyTuning(ncSessionID string, t RoomTuning) {
+	if t == (RoomTuning{}) {
+		return
+	}
+	if ncSessionID != rs.tuningOwner {
+		slog.Info("ignoring tuning of a participant who did not start the call",
+			"nc_session_id", ncSessionID)
+		return
+	}
+	if t.ForceFinalizeChunks != nil {
+		rs.audioWorker.SetForceFinalizeChunks(*t.ForceFinalizeChunks)
+	}
+	if t.MinPartialInterval != nil {
+		rs.sender.SetMinPartialInterval(*t.MinPartialInterval)
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/asr"
+	"github.com/nextcloud/go_live_transcription/internal/transcript"
+	"github.com/nextcloud/go_live_transcription/internal/vosk"
+)
+
+// tuningTranscriber records the force finalize limit set on a room.
+type tuningTranscriber struct {
+	asr.Transcriber
+	forceFinalizeChunks int
+}
+
+func (tt *tuningTranscriber) SetForceFinalizeChunks(n int) { tt.forceFinalizeChunks = n }
+
+func tunedRoom(owner string) (*roomState, *tuningTranscriber) {
+	tt := &tuningTranscriber{}
+	return &roomState{
+		audioWorker: vosk.NewAudioWorker(nil, tt, false),
+		sender:      transcript.NewSender(nil, nil, nil, nil),
+		tuningOwner: owner,
+	}, tt
+}
+
+func TestTuningPerRoom(t *testing.T) {
+	chunks := func(n int) RoomTuning { return RoomTuning{ForceFinalizeChunks: &n} }
+
+	debate, debateASR := tunedRoom("alice")
+	lecture, lectureASR := tunedRoom("bob")
+	debate.applyTuning("alice", chunks(100))
+	lecture.applyTuning("bob", chunks(2000))
+	if debateASR.forceFinalizeChunks != 100 || lectureASR.forceFinalizeChunks != 2000 {
+		t.Fatalf("force finalize chunks %d and %d, want 100 and 2000",
+			debateASR.forceFinalizeChunks, lectureASR.forceFinalizeChunks)
+	}
+
+	// Another participant enabling transcripts cannot retune the room
+	debate.applyTuning("carol", chunks(3000))
+	if debateASR.forceFinalizeChunks != 100 {
+		t.Errorf("force finalize chunks %d after another participant's tuning, want 100",
+			debateASR.forceFinalizeChunks)
+	}
+
+	// The owner can, and omitted parameters keep their value
+	interval := time.Second
+	debate.applyTuning("alice", RoomTuning{MinPartialInterval: &interval})
+	debate.applyTuning("alice", chunks(150))
+	if debateASR.forceFinalizeChunks != 150 || lectureASR.forceFinalizeChunks != 2000 {
+		t.Errorf("force finalize chunks %d and %d after the owner's retune, want 150 and 2000",
+			debateASR.forceFinalizeChunks, lectureASR.forceFinalizeChunks)
+	}
+}
+
+func TestTuningValidate(t *testing.T) {
+	n := func(v int) *int { return &v }
+	d := func(v time.Duration) *time.Duration { return &v }
+	for _, tt := range []struct {
+		name   string
+		tuning RoomTuning
+		valid  bool
+	}{
+		{"none", RoomTuning{}, true},
+		{"bounds", RoomTuning{ForceFinalizeChunks: n(MinForceFinalizeChunks), MinPartialInterval: d(0)}, true},
+		{"upper bounds", RoomTuning{ForceFinalizeChunks: n(MaxForceFinalizeChunks), MinPartialInterval: d(MaxMinPartialInterval)}, true},
+		{"too few chunks", RoomTuning{ForceFinalizeChunks: n(MinForceFinalizeChunks - 1)}, false},
+		{"too many chunks", RoomTuning{ForceFinalizeChunks: n(MaxForceFinalizeChunks + 1)}, false},
+		{"negative interval", RoomTuning{MinPartialInterval: d(-time.Millisecond)}, false},
+		{"long interval", RoomTuning{MinPartialInterval: d(MaxMinPartialInterval + time.Millisecond)}, false},
+	} {
+		err := tt.tuning.Validate()
+		if tt.valid != (err == nil) || (err != nil && !errors.Is(err, ErrInvalidTuning)) {
+			t.Errorf("%s: error %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
@@ -14,9 +14,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"math"
+	"net"
+	"net/http"
 	"net/url"
-	"os"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -35,6 +38,22 @@ import (
 var (
 	ErrRateLimited = errors.New("rate limited by HPB")
 	ErrDefunct     = errors.New("spreed client is defunct")
+
+	ErrDuplicateSession = errors.New("duplicate signaling session")
+	ErrRoomJoinFailed   = errors.New("room join failed")
+	ErrReceivedBye      = errors.New("received bye from HPB")
+	ErrHandshakeTimeout = errors.New("signaling handshake timed out")
+	ErrSignaling        = errors.New("signaling error")
+
+	ErrUnsupportedAudioCodec = errors.New("unsupported audio codec")
+)
+
+// Reasons a client closed, see SpreedClient.CloseReason.
+const (
+	CloseReasonLeft     = "left"      // transcription was stopped by request
+	CloseReasonLastUser = "last_user" // the call ended or no one receives transcripts anymore
+	CloseReasonError    = "error"     // the signaling connection failed or was closed by the HPB
+	CloseReasonShutdown = "shutdown"
 )
 
 type SpreedClient struct {
@@ -45,22 +64,57 @@ type SpreedClient struct {
 	secret      string
 	wsURL       string
 	backendURL  string
-	hpbSettings *HPBSettings
-
-	conn      *websocket.Conn
-	msgID     atomic.Int64
-	sessionID string
-	resumeID  string
-	defunct   atomic.Bool
+	hpbSettings atomic.Pointer[HPBSettings] // replaced when TURN credentials rotate
+
+	conn   wsConn
+	dialer dialFunc // dialWebSocket, replaced in tests
+	// Keepalive of the connection, SignalingPingInterval and
+	// SignalingReadTimeout outside tests
+	pingInterval time.Duration
+	readTimeout  time.Duration
+	msgID        atomic.Int64
+	sessionID    string
+	resumeID     string
+	defunct      atomic.Bool
+	// closeReason is why the client closed, guarded by mu
+	closeReason string
+
+	audioStopped atomic.Bool // draining: no new peer connections
 
 	peerConns   map[string]*webrtc.PeerConnection
 	peerConnsMu sync.Mutex
-
-	targets        map[string]struct{} // HPB session IDs receiving transcripts
-	ncSidMap       map[string]string   // NC session ID → HPB session ID
-	ncSidWaitStash map[string]struct{} // deferred targets awaiting ID mapping
+	audioTracks atomic.Int32 // running readAudioTrack goroutines
+
+	trackStats map[string]*trackStats // speaker session ID → audio statistics, guarded by peerConnsMu
+
+	decoderFailures     atomic.Int64
+	concealedPackets    atomic.Int64
+	droppedAudio        atomic.Int64
+	decoderRenegotiated map[string]int // speaker session ID → offers re-requested, guarded by peerConnsMu
+	renegotiateOnFail   bool
+	relayOnly           bool
+	tlsConfig           *tls.Config // nil for the defaults
+	proxy               func(*http.Request) (*url.URL, error)
+	jitterDepth         int
+	latePackets         atomic.Int64
+
+	iceRestarts      atomic.Int64
+	iceFailureStreak map[string]int // speaker session ID → failures since last connected, guarded by peerConnsMu
+
+	targets        map[string]struct{}  // HPB session IDs receiving transcripts
+	ncSidMap       map[string]string    // NC session ID → HPB session ID
+	hpbSidMap      map[string]string    // the reverse of ncSidMap
+	ncSidWaitStash map[string]time.Time // deferred targets awaiting ID mapping → when requested
+	history        []historyEntry       // recent finals replayed to new targets
 	targetMu       sync.Mutex
 
+	skipReplay func(ncSessionID string) bool // targets getting no history, nil for none
+
+	historySize   int
+	historyMaxAge time.Duration
+
+	speakers *speakerNames // nil unless speaker names are enabled
+
 	TranscriptCh chan Transcript
 	PCMAudioCh   chan PCMAudio
 
@@ -76,12 +130,32 @@ type Transcript struct {
 	LangID           string
 	Message          string
 	SpeakerSessionID string
+	SpeakerName      string
+	StartMs          int64 // since the speaker's audio started
+	EndMs            int64
+	// Alternatives are the recognizer's best hypotheses of a final, the
+	// first being Message, when alternatives are enabled.
+	Alternatives []Alternative
+}
+
+// Alternative is one hypothesis of a final transcript. Confidence is the
+// recognizer's score, comparable only among the alternatives of a segment.
+type Alternative struct {
+	Message    string  `json:"message"`
+	Confidence float64 `json:"confidence"`
+}
+
+type historyEntry struct {
+	at         time.Time
+	transcript Transcript
 }
 
 type PCMAudio struct {
 	SessionID  string
 	Samples    []int16
 	SampleRate int
+	// Ended marks the end of the speaker's audio track, without samples.
+	Ended bool
 }
 
 func NewSpreedClient(
@@ -91,25 +165,39 @@ func NewSpreedClient(
 	cfg *appapi.Config,
 	leaveCallCb func(string),
 ) *SpreedClient {
-	wsURL := sanitizeWebSocketURL(cfg.HPBUrl)
-	backendURL := cfg.NextcloudURL + "/ocs/v2.php/apps/spreed/api/v3/signaling/backend"
-
-	return &SpreedClient{
-		roomToken:      roomToken,
-		roomLangID:     roomLangID,
-		secret:         cfg.InternalSecret,
-		wsURL:          wsURL,
-		backendURL:     backendURL,
-		hpbSettings:    hpbSettings,
-		peerConns:      make(map[string]*webrtc.PeerConnection),
-		targets:        make(map[string]struct{}),
-		ncSidMap:       make(map[string]string),
-		ncSidWaitStash: make(map[string]struct{}),
-		TranscriptCh:   make(chan Transcript, 1000),
-		PCMAudioCh:     make(chan PCMAudio, 100),
-		leaveCallCb:    leaveCallCb,
-		logger:         slog.With("room_token", roomToken),
+	// Validated by LoadConfig
+	wsURL, _ := appapi.HPBWebSocketURL(cfg.HPBUrl)
+
+	sc := &SpreedClient{
+		roomToken:           roomToken,
+		roomLangID:          roomLangID,
+		secret:              cfg.InternalSecret,
+		wsURL:               wsURL,
+		backendURL:          cfg.SignalingBackendURL,
+		peerConns:           make(map[string]*webrtc.PeerConnection),
+		decoderRenegotiated: make(map[string]int),
+		iceFailureStreak:    make(map[string]int),
+		renegotiateOnFail:   cfg.RenegotiateOnDecoderFailure,
+		relayOnly:           cfg.ICERelayOnly,
+		tlsConfig:           cfg.HPBTLSConfig(),
+		proxy:               cfg.ProxyFunc(),
+		jitterDepth:         cfg.JitterBufferPackets,
+		targets:             make(map[string]struct{}),
+		ncSidMap:            make(map[string]string),
+		hpbSidMap:           make(map[string]string),
+		ncSidWaitStash:      make(map[string]time.Time),
+		TranscriptCh:        make(chan Transcript, 1000),
+		PCMAudioCh:          make(chan PCMAudio, cfg.AudioBufferFrames),
+		historySize:         cfg.TranscriptHistorySize,
+		historyMaxAge:       cfg.TranscriptHistoryMaxAge,
+		leaveCallCb:         leaveCallCb,
+		logger:              slog.With("room_token", roomToken),
 	}
+	sc.dialer = sc.dialWebSocket
+	sc.pingInterval = constants.SignalingPingInterval
+	sc.readTimeout = constants.SignalingReadTimeout
+	sc.hpbSettings.Store(hpbSettings)
+	return sc
 }
 
 func (sc *SpreedClient) Connect(ctx context.Context, reconnect ReconnectMethod) (SigConnectResult, error) {
@@ -123,27 +211,15 @@ func (sc *SpreedClient) Connect(ctx context.Context, reconnect ReconnectMethod)
 
 	if reconnect == FullReconnect {
 		sc.logger.Info("performing full reconnect")
-		sc.closeInternal()
+		sc.closeInternal(CloseReasonError)
 		sc.resumeID = ""
 		sc.sessionID = ""
 	}
 
-	dialer := websocket.Dialer{
-		HandshakeTimeout: 30 * time.Second,
-	}
-
-	parsedURL, _ := url.Parse(sc.wsURL)
-	if parsedURL != nil && parsedURL.Scheme == "wss" {
-		skipCert := os.Getenv("SKIP_CERT_VERIFY")
-		if skipCert == "true" || skipCert == "1" {
-			dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-		}
-	}
-
-	conn, _, err := dialer.DialContext(ctx, sc.wsURL, nil)
+	conn, err := sc.dialer(ctx)
 	if err != nil {
 		sc.logger.Error("failed to connect to HPB", "error", err)
-		return SigConnectRetry, fmt.Errorf("websocket dial: %w", err)
+		return SigConnectRetry, err
 	}
 	sc.conn = conn
 
@@ -167,15 +243,44 @@ func (sc *SpreedClient) Connect(ctx context.Context, reconnect ReconnectMethod)
 		return SigConnectRetry, nil
 	}
 
+	if res, err := sc.handshakeRetryingLocked(ctx); err != nil {
+		return res, err
+	}
+	sc.defunct.Store(false)
+
+	monCtx, monCancel := context.WithCancel(ctx)
+	sc.cancel = monCancel
+	go sc.monitor(monCtx)
+	go sc.keepalive(monCtx)
+
+	sc.sendInCall()
+	sc.sendJoin()
+
+	sc.targetMu.Lock()
+	if len(sc.targets) == 0 {
+		sc.startDeferredClose()
+	}
+	sc.targetMu.Unlock()
+
+	sc.logger.Info("connected to signaling server")
+	return SigConnectSuccess, nil
+}
+
+// handshakeLocked sends hello on the freshly dialed connection and waits for
+// the new session. Must be called with sc.mu held.
+func (sc *SpreedClient) handshakeLocked() (SigConnectResult, error) {
 	if err := sc.sendHello(); err != nil {
 		sc.logger.Error("failed to send hello", "error", err)
 		return SigConnectFailure, err
 	}
 
-	for i := 0; i < 10; i++ {
-		msg, err := sc.receiveMessage(constants.MsgReceiveTimeout)
+	// Drive the loop off a deadline rather than a message count, so a server
+	// sending more than a welcome before the hello doesn't fail the handshake.
+	deadline := time.Now().Add(constants.MsgReceiveTimeout)
+	for {
+		msg, err := sc.receiveBefore(deadline)
 		if err != nil {
-			sc.logger.Error("no message during handshake", "error", err)
+			sc.logger.Error("no hello during handshake", "error", err)
 			return SigConnectFailure, err
 		}
 
@@ -186,17 +291,19 @@ func (sc *SpreedClient) Connect(ctx context.Context, reconnect ReconnectMethod)
 				code = msg.Error.Code
 			}
 			sc.logger.Error("signaling error during connect", "code", code)
-			if code == "duplicate_session" {
-				return SigConnectFailure, fmt.Errorf("duplicate session")
-			}
-			if code == "room_join_failed" {
-				return SigConnectRetry, fmt.Errorf("room join failed")
+			switch code {
+			case "duplicate_session":
+				return SigConnectFailure, ErrDuplicateSession
+			case "room_join_failed":
+				return SigConnectRetry, ErrRoomJoinFailed
+			case "too_many_requests":
+				return SigConnectRetry, ErrRateLimited
 			}
-			return SigConnectFailure, fmt.Errorf("signaling error: %s", code)
+			return SigConnectFailure, fmt.Errorf("%w: %s", ErrSignaling, code)
 
 		case "bye":
 			sc.logger.Info("received bye during connect")
-			return SigConnectFailure, fmt.Errorf("received bye")
+			return SigConnectFailure, ErrReceivedBye
 
 		case "welcome":
 			sc.logger.Debug("received welcome")
@@ -211,29 +318,176 @@ func (sc *SpreedClient) Connect(ctx context.Context, reconnect ReconnectMethod)
 					"resume_id", sc.resumeID,
 				)
 			}
-			goto connected
+			return SigConnectSuccess, nil
 		}
 	}
-	return SigConnectFailure, fmt.Errorf("did not receive hello response")
+}
 
-connected:
-	sc.defunct.Store(false)
+// handshakeRetryingLocked is handshakeLocked, retried on a new connection
+// with a fresh hello when the HPB reports a duplicate session, which happens
+// while it still holds the session of an instance that crashed mid-call.
+// Must be called with sc.mu held.
+func (sc *SpreedClient) handshakeRetryingLocked(ctx context.Context) (SigConnectResult, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := sc.handshakeLocked()
+		if !errors.Is(err, ErrDuplicateSession) || attempt > constants.DuplicateSessionRetries {
+			return res, err
+		}
+		sc.logger.Warn("duplicate session, retrying with a new hello",
+			"attempt", attempt,
+			"session_id", sc.sessionID,
+			"resume_id", sc.resumeID,
+		)
+		sc.dropConnLocked()
+
+		timer := time.NewTimer(constants.DuplicateSessionRetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return SigConnectFailure, ctx.Err()
+		case <-timer.C:
+		}
 
-	monCtx, monCancel := context.WithCancel(ctx)
-	sc.cancel = monCancel
-	go sc.monitor(monCtx)
+		conn, err := sc.dialer(ctx)
+		if err != nil {
+			sc.logger.Error("failed to connect to HPB", "error", err)
+			return SigConnectRetry, err
+		}
+		sc.conn = conn
+	}
+}
+
+// receiveBefore reads the next handshake message, failing with
+// ErrHandshakeTimeout once deadline has passed.
+func (sc *SpreedClient) receiveBefore(deadline time.Time) (*SignalingMessage, error) {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return nil, ErrHandshakeTimeout
+	}
+	msg, err := sc.receiveMessage(remaining)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, fmt.Errorf("%w: %w", ErrHandshakeTimeout, err)
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// reconnect re-establishes the websocket after a read error without leaving
+// the call, so targets, the NC session mapping and, when the HPB session can
+// be resumed, the peer connections survive. It returns false once
+// MaxReconnectTries attempts have failed or ctx is done.
+func (sc *SpreedClient) reconnect(ctx context.Context) bool {
+	sc.mu.Lock()
+	if sc.conn != nil {
+		_ = sc.conn.Close()
+		sc.conn = nil
+	}
+	sc.mu.Unlock()
+
+	delay := constants.ReconnectBaseDelay
+	for attempt := 1; attempt <= constants.MaxReconnectTries; attempt++ {
+		err := sc.redial(ctx)
+		if err == nil {
+			sc.logger.Info("reconnected to signaling server", "attempt", attempt)
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		// A duplicate session is retried by the handshake and may clear up
+		// by the next attempt; only a bye means the room is gone
+		if errors.Is(err, ErrReceivedBye) {
+			sc.logger.Warn("room ended during reconnect", "error", err)
+			return false
+		}
+		sc.logger.Warn("reconnect attempt failed", "attempt", attempt, "error", err)
+
+		wait := delay
+		if errors.Is(err, ErrRateLimited) {
+			wait = max(wait, constants.RateLimitedRetryDelay)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+	return false
+}
+
+// redial makes a single reconnect attempt. A short resume is tried first;
+// if the HPB no longer knows the session a new one is negotiated on the same
+// connection, and the peer connections of the old session are dropped so the
+// participant update after joining requests fresh offers.
+func (sc *SpreedClient) redial(ctx context.Context) error {
+	conn, err := sc.dialer(ctx)
+	if err != nil {
+		return err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if ctx.Err() != nil {
+		_ = conn.Close()
+		return ctx.Err()
+	}
+	sc.conn = conn
+
+	resumed := false
+	if sc.resumeID != "" {
+		if resumed, err = sc.resumeConnection(ctx); err != nil {
+			sc.dropConnLocked()
+			return err
+		}
+	}
+
+	if resumed {
+		sc.logger.Info("resumed connection")
+	} else {
+		sc.logger.Info("session not resumable, starting a new one")
+		sc.resumeID = ""
+		sc.sessionID = ""
+		if _, err := sc.handshakeRetryingLocked(ctx); err != nil {
+			sc.dropConnLocked()
+			return err
+		}
+		sc.closePeerConns()
+	}
 
 	sc.sendInCall()
 	sc.sendJoin()
+	return nil
+}
 
-	sc.targetMu.Lock()
-	if len(sc.targets) == 0 {
-		sc.startDeferredClose()
+// Must be called with sc.mu held.
+func (sc *SpreedClient) dropConnLocked() {
+	if sc.conn != nil {
+		_ = sc.conn.Close()
+		sc.conn = nil
 	}
-	sc.targetMu.Unlock()
+}
 
-	sc.logger.Info("connected to signaling server")
-	return SigConnectSuccess, nil
+func (sc *SpreedClient) closePeerConns() {
+	sc.peerConnsMu.Lock()
+	defer sc.peerConnsMu.Unlock()
+	for sid, pc := range sc.peerConns {
+		_ = pc.Close()
+		delete(sc.peerConns, sid)
+	}
+}
+
+// StopAudio closes the peer connections and refuses new ones, so no more
+// audio arrives while the room drains. Transcripts can still be sent.
+func (sc *SpreedClient) StopAudio() {
+	sc.audioStopped.Store(true)
+	sc.closePeerConns()
 }
 
 func (sc *SpreedClient) IsDefunct() bool {
@@ -246,22 +500,51 @@ func (sc *SpreedClient) SetRoomLangID(langID string) {
 	sc.roomLangID = langID
 }
 
+// SetHPBSettings replaces the STUN/TURN servers used for new peer
+// connections. Established connections keep theirs.
+func (sc *SpreedClient) SetHPBSettings(settings *HPBSettings) {
+	sc.hpbSettings.Store(settings)
+}
+
+// HPBSettings returns the settings new peer connections use.
+func (sc *SpreedClient) HPBSettings() *HPBSettings {
+	return sc.hpbSettings.Load()
+}
+
 func (sc *SpreedClient) RoomLangID() string {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 	return sc.roomLangID
 }
 
+// Close leaves the call, as requested.
 func (sc *SpreedClient) Close() {
+	sc.CloseWithReason(CloseReasonLeft)
+}
+
+// CloseWithReason leaves the call for one of the CloseReason constants. It
+// reports whether this call closed the client, false if it was already
+// defunct.
+func (sc *SpreedClient) CloseWithReason(reason string) bool {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
-	sc.closeInternal()
+	return sc.closeInternal(reason)
 }
 
-func (sc *SpreedClient) closeInternal() {
+// CloseReason returns why the client closed, or "" while it is open.
+func (sc *SpreedClient) CloseReason() string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.closeReason
+}
+
+// closeInternal runs under sc.mu, so only one caller finds the client
+// active and closes it.
+func (sc *SpreedClient) closeInternal(reason string) bool {
 	if sc.defunct.Load() {
-		return
+		return false
 	}
+	sc.closeReason = reason
 
 	if sc.cancel != nil {
 		sc.cancel()
@@ -270,23 +553,22 @@ func (sc *SpreedClient) closeInternal() {
 
 	sc.targetMu.Lock()
 	sc.cancelDeferredClose()
+	targets := slices.Collect(maps.Keys(sc.targets))
+	// Sessions still awaiting their HPB session ID never get transcripts now
+	clear(sc.ncSidWaitStash)
 	sc.targetMu.Unlock()
 
 	if sc.conn != nil {
+		// A dead connection must not hold up the teardown
+		_ = sc.conn.SetWriteDeadline(time.Now().Add(constants.CloseWriteTimeout))
+		for _, hpbSid := range targets {
+			sc.sendMessageLocked(transcriptionEndedMessage(hpbSid))
+		}
 		sc.sendMessageLocked(SignalingMessage{Type: "bye", Bye: &ByeMessage{}})
 	}
 
-	sc.peerConnsMu.Lock()
-	for sid, pc := range sc.peerConns {
-		_ = pc.Close()
-		delete(sc.peerConns, sid)
-	}
-	sc.peerConnsMu.Unlock()
-
-	if sc.conn != nil {
-		_ = sc.conn.Close()
-		sc.conn = nil
-	}
+	sc.closePeerConns()
+	sc.dropConnLocked()
 
 	sc.defunct.Store(true)
 	sc.logger.Info("client closed")
@@ -294,42 +576,159 @@ func (sc *SpreedClient) closeInternal() {
 	if sc.leaveCallCb != nil {
 		go sc.leaveCallCb(sc.roomToken)
 	}
+	return true
 }
 
-func (sc *SpreedClient) AddTarget(ncSessionID string) {
+// AddTarget starts sending transcripts to a Nextcloud session, deferred
+// until its HPB session ID is known. Adding a session again is a no-op. It
+// returns the number of sessions receiving or awaiting transcripts.
+func (sc *SpreedClient) AddTarget(ncSessionID string) int {
 	sc.targetMu.Lock()
-	defer sc.targetMu.Unlock()
-
-	sc.cancelDeferredClose()
 
 	hpbSid, ok := sc.ncSidMap[ncSessionID]
 	if !ok {
-		sc.ncSidWaitStash[ncSessionID] = struct{}{}
+		// The deferred close keeps running and waits for the session
+		// only until the entry expires
+		sc.ncSidWaitStash[ncSessionID] = time.Now()
+		count := sc.targetCountLocked()
+		sc.targetMu.Unlock()
 		sc.logger.Debug("HPB session ID not found, deferring target add", "nc_session_id", ncSessionID)
-		return
+		return count
 	}
 
+	sc.cancelDeferredClose()
 	delete(sc.ncSidWaitStash, ncSessionID)
-	sc.targets[hpbSid] = struct{}{}
+	history := sc.addTargetLocked(hpbSid)
+	count := sc.targetCountLocked()
+	sc.targetMu.Unlock()
+
 	sc.logger.Debug("added target", "session_id", hpbSid, "nc_session_id", ncSessionID)
+	sc.replayHistory(hpbSid, ncSessionID, history)
+	return count
 }
 
-func (sc *SpreedClient) RemoveTarget(ncSessionID string) {
+// Must be called with targetMu held.
+func (sc *SpreedClient) targetCountLocked() int {
+	sc.pruneWaitStashLocked()
+	return len(sc.targets) + len(sc.ncSidWaitStash)
+}
+
+// TargetNcSessionIDs returns the Nextcloud sessions receiving or awaiting
+// transcripts.
+func (sc *SpreedClient) TargetNcSessionIDs() []string {
 	sc.targetMu.Lock()
 	defer sc.targetMu.Unlock()
 
-	delete(sc.ncSidWaitStash, ncSessionID)
+	sc.pruneWaitStashLocked()
+	ids := slices.Collect(maps.Keys(sc.ncSidWaitStash))
+	for ncSid, hpbSid := range sc.ncSidMap {
+		if _, ok := sc.targets[hpbSid]; ok {
+			ids = append(ids, ncSid)
+		}
+	}
+	return ids
+}
 
-	hpbSid, ok := sc.ncSidMap[ncSessionID]
-	if !ok {
+// pruneWaitStashLocked gives up deferred targets whose session did not show
+// up in the call within DeferredTargetTTL.
+// Must be called with targetMu held.
+func (sc *SpreedClient) pruneWaitStashLocked() {
+	cutoff := time.Now().Add(-constants.DeferredTargetTTL)
+	for ncSid, requested := range sc.ncSidWaitStash {
+		if requested.Before(cutoff) {
+			delete(sc.ncSidWaitStash, ncSid)
+			sc.logger.Debug("deferred target expired", "nc_session_id", ncSid)
+		}
+	}
+}
+
+// addTargetLocked adds a target and returns the history it has not seen yet.
+// Targets that already receive transcripts get no replay. Taking the history
+// snapshot under targetMu, the same lock SendTranscript records finals under,
+// guarantees a final is either replayed or sent live, never both.
+// Must be called with targetMu held.
+func (sc *SpreedClient) addTargetLocked(hpbSid string) []Transcript {
+	if _, exists := sc.targets[hpbSid]; exists {
+		return nil
+	}
+	sc.targets[hpbSid] = struct{}{}
+
+	sc.pruneHistoryLocked()
+	history := make([]Transcript, 0, len(sc.history))
+	for _, e := range sc.history {
+		history = append(history, e.transcript)
+	}
+	return history
+}
+
+// Must be called with targetMu held.
+func (sc *SpreedClient) recordHistoryLocked(t Transcript) {
+	if sc.historySize <= 0 || !t.Final {
 		return
 	}
-	delete(sc.targets, hpbSid)
-	sc.logger.Debug("removed target", "session_id", hpbSid, "nc_session_id", ncSessionID)
+	sc.history = append(sc.history, historyEntry{at: time.Now(), transcript: t})
+	if len(sc.history) > sc.historySize {
+		sc.history = slices.Delete(sc.history, 0, len(sc.history)-sc.historySize)
+	}
+}
 
-	if len(sc.targets) == 0 {
+// Must be called with targetMu held.
+func (sc *SpreedClient) pruneHistoryLocked() {
+	if sc.historyMaxAge <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-sc.historyMaxAge)
+	i := 0
+	for i < len(sc.history) && sc.history[i].at.Before(cutoff) {
+		i++
+	}
+	sc.history = slices.Delete(sc.history, 0, i)
+}
+
+// SetReplaySkip makes new targets for which skip is true get no history,
+// such as translation targets, which would otherwise be sent finals in the
+// original language. Must be called before the client connects.
+func (sc *SpreedClient) SetReplaySkip(skip func(ncSessionID string) bool) {
+	sc.skipReplay = skip
+}
+
+func (sc *SpreedClient) replayHistory(hpbSid, ncSessionID string, history []Transcript) {
+	if len(history) == 0 {
+		return
+	}
+	if sc.skipReplay != nil && sc.skipReplay(ncSessionID) {
+		sc.logger.Debug("skipping transcript history", "session_id", hpbSid, "nc_session_id", ncSessionID)
+		return
+	}
+	sc.logger.Debug("replaying transcript history", "session_id", hpbSid, "count", len(history))
+	for _, t := range history {
+		sc.sendTranscriptTo(hpbSid, t, true)
+	}
+}
+
+// RemoveTarget stops sending transcripts to a Nextcloud session. Removing a
+// session that is no target is a no-op. It returns the number of sessions
+// receiving or awaiting transcripts.
+func (sc *SpreedClient) RemoveTarget(ncSessionID string) int {
+	sc.targetMu.Lock()
+	defer sc.targetMu.Unlock()
+
+	sc.pruneWaitStashLocked()
+	_, removed := sc.ncSidWaitStash[ncSessionID]
+	delete(sc.ncSidWaitStash, ncSessionID)
+
+	if hpbSid, ok := sc.ncSidMap[ncSessionID]; ok {
+		if _, isTarget := sc.targets[hpbSid]; isTarget {
+			delete(sc.targets, hpbSid)
+			removed = true
+			sc.logger.Debug("removed target", "session_id", hpbSid, "nc_session_id", ncSessionID)
+		}
+	}
+
+	if removed && len(sc.targets) == 0 {
 		sc.startDeferredClose()
 	}
+	return sc.targetCountLocked()
 }
 
 func (sc *SpreedClient) removeTargetByHPBSid(sessionID string) {
@@ -344,21 +743,53 @@ func (sc *SpreedClient) removeTargetByHPBSid(sessionID string) {
 
 // Must be called with targetMu held.
 func (sc *SpreedClient) startDeferredClose() {
+	sc.armDeferredClose(constants.CallLeaveTimeout)
+}
+
+// Must be called with targetMu held.
+func (sc *SpreedClient) armDeferredClose(timeout time.Duration) {
 	sc.cancelDeferredClose()
-	sc.logger.Debug("starting deferred close timer", "timeout", constants.CallLeaveTimeout)
-	sc.deferredCloseTimer = time.AfterFunc(constants.CallLeaveTimeout, func() {
-		if sc.defunct.Load() {
-			return
-		}
-		sc.targetMu.Lock()
-		noTargets := len(sc.targets) == 0
+	sc.logger.Debug("starting deferred close timer", "timeout", timeout)
+	sc.deferredCloseTimer = time.AfterFunc(timeout, sc.deferredClose)
+}
+
+// deferredClose leaves the call unless it has targets. A deferred target
+// that has not expired yet postpones the decision until it resolves or
+// expires, so its session is not lost by closing just before it joins.
+func (sc *SpreedClient) deferredClose() {
+	if sc.defunct.Load() {
+		return
+	}
+	sc.targetMu.Lock()
+	sc.pruneWaitStashLocked()
+	if len(sc.targets) > 0 {
+		sc.targetMu.Unlock()
+		return
+	}
+	if wait := sc.nextWaitStashExpiryLocked(); wait > 0 {
+		sc.logger.Debug("deferred targets pending, postponing close", "pending", len(sc.ncSidWaitStash))
+		sc.armDeferredClose(wait)
 		sc.targetMu.Unlock()
+		return
+	}
+	sc.targetMu.Unlock()
+
+	sc.logger.Info("no targets after deferred close timeout, leaving call")
+	sc.CloseWithReason(CloseReasonLastUser)
+}
 
-		if noTargets {
-			sc.logger.Info("no targets after deferred close timeout, leaving call")
-			sc.Close()
+// nextWaitStashExpiryLocked returns the time until the oldest deferred
+// target expires, or 0 without deferred targets.
+// Must be called with targetMu held.
+func (sc *SpreedClient) nextWaitStashExpiryLocked() time.Duration {
+	var wait time.Duration
+	for _, requested := range sc.ncSidWaitStash {
+		left := time.Until(requested.Add(constants.DeferredTargetTTL))
+		if wait == 0 || left < wait {
+			wait = max(left, time.Millisecond)
 		}
-	})
+	}
+	return wait
 }
 
 // Must be called with targetMu held.
@@ -380,14 +811,36 @@ func (sc *SpreedClient) monitor(ctx context.Context) {
 		default:
 		}
 
-		msg, err := sc.receiveMessage(0)
+		// The connection is replaced under sc.mu, which is not held while
+		// blocked reading
+		sc.mu.Lock()
+		conn := sc.conn
+		sc.mu.Unlock()
+
+		// Any message or pong proves the connection alive, so a read
+		// timing out means it is dead rather than the room ended
+		if conn != nil {
+			conn.SetPongHandler(func(string) error {
+				return conn.SetReadDeadline(time.Now().Add(sc.readTimeout))
+			})
+		}
+		msg, err := sc.readMessage(conn, sc.readTimeout)
 		if err != nil {
 			if ctx.Err() != nil {
 				return // context canceled
 			}
-			sc.logger.Error("websocket error in monitor, closing", "error", err)
-			sc.Close()
-			return
+			// A dead connection is not the end of the room: only a bye,
+			// a fatal signaling error or a failed reconnect close the client.
+			sc.logger.Warn("websocket error in monitor, reconnecting", "error", err)
+			if !sc.reconnect(ctx) {
+				if ctx.Err() != nil {
+					return
+				}
+				sc.logger.Error("reconnect failed, closing")
+				sc.CloseWithReason(CloseReasonError)
+				return
+			}
+			continue
 		}
 
 		switch msg.Type {
@@ -400,7 +853,7 @@ func (sc *SpreedClient) monitor(ctx context.Context) {
 			if code == "processing_failed" {
 				continue // recoverable
 			}
-			sc.Close()
+			sc.CloseWithReason(CloseReasonError)
 			return
 
 		case "event":
@@ -411,12 +864,36 @@ func (sc *SpreedClient) monitor(ctx context.Context) {
 
 		case "bye":
 			sc.logger.Info("received bye, closing")
-			sc.Close()
+			sc.CloseWithReason(CloseReasonError)
 			return
 		}
 	}
 }
 
+// keepalive pings the HPB, so the monitor's read deadline only passes on a
+// dead connection.
+func (sc *SpreedClient) keepalive(ctx context.Context) {
+	ticker := time.NewTicker(sc.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		sc.mu.Lock()
+		conn := sc.conn
+		sc.mu.Unlock()
+		if conn == nil {
+			continue // reconnecting
+		}
+		// WriteControl may be called concurrently with the other writes
+		if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(constants.CloseWriteTimeout)); err != nil {
+			sc.logger.Debug("signaling ping failed", "error", err)
+		}
+	}
+}
+
 func (sc *SpreedClient) handleEvent(msg *SignalingMessage) {
 	if msg.Event == nil || msg.Event.Target != "participants" || msg.Event.Type != "update" {
 		return
@@ -427,7 +904,7 @@ func (sc *SpreedClient) handleEvent(msg *SignalingMessage) {
 
 	if msg.Event.Update.All && msg.Event.Update.InCall == CallFlagDisconnected {
 		sc.logger.Info("call ended for everyone")
-		sc.Close()
+		sc.CloseWithReason(CloseReasonLastUser)
 		return
 	}
 
@@ -445,37 +922,51 @@ func (sc *SpreedClient) handleEvent(msg *SignalingMessage) {
 				_ = pc.Close()
 				delete(sc.peerConns, user.SessionID)
 			}
+			delete(sc.iceFailureStreak, user.SessionID)
 			sc.peerConnsMu.Unlock()
 
 			sc.targetMu.Lock()
 			if user.NextcloudSessionID != "" {
-				delete(sc.ncSidMap, user.NextcloudSessionID)
+				sc.unmapSessionLocked(user.NextcloudSessionID)
 			}
 			sc.targetMu.Unlock()
 			continue
 		}
 
 		if user.NextcloudSessionID != "" {
+			var history []Transcript
 			sc.targetMu.Lock()
-			sc.ncSidMap[user.NextcloudSessionID] = user.SessionID
+			sc.mapSessionLocked(user.NextcloudSessionID, user.SessionID)
 
-			if _, waiting := sc.ncSidWaitStash[user.NextcloudSessionID]; waiting {
+			sc.pruneWaitStashLocked()
+			_, waiting := sc.ncSidWaitStash[user.NextcloudSessionID]
+			if waiting {
 				delete(sc.ncSidWaitStash, user.NextcloudSessionID)
-				sc.targets[user.SessionID] = struct{}{}
+				history = sc.addTargetLocked(user.SessionID)
 				sc.logger.Debug("resolved deferred target",
 					"nc_session_id", user.NextcloudSessionID,
 					"session_id", user.SessionID,
 				)
 			}
 			sc.targetMu.Unlock()
+
+			if waiting {
+				sc.replayHistory(user.SessionID, user.NextcloudSessionID, history)
+			}
 		}
 
-		if user.InCall&CallFlagInCall != 0 && user.InCall&CallFlagWithAudio != 0 {
+		if sendsAudio(user.InCall) {
 			sc.peerConnsMu.Lock()
 			_, exists := sc.peerConns[user.SessionID]
 			sc.peerConnsMu.Unlock()
 
-			if !exists {
+			switch {
+			case exists:
+			case user.InCall&CallFlagWithAudio == 0:
+				// Dial-in audio is published by the SIP bridge
+				sc.logger.Info("phone participant joined, requesting offer", "session_id", user.SessionID)
+				sc.sendOfferRequest(user.SessionID)
+			default:
 				sc.logger.Debug("user joined with audio, requesting offer", "session_id", user.SessionID)
 				sc.sendOfferRequest(user.SessionID)
 			}
@@ -487,6 +978,12 @@ func (sc *SpreedClient) handleEvent(msg *SignalingMessage) {
 	}
 }
 
+// sendsAudio reports whether a participant in the call publishes audio:
+// with a microphone or, dialed in, through the SIP bridge.
+func sendsAudio(flags CallFlag) bool {
+	return flags&CallFlagInCall != 0 && flags&(CallFlagWithAudio|CallFlagWithPhone) != 0
+}
+
 func (sc *SpreedClient) checkLastUserLeft(users []UserUpdateEntry) {
 	var us, them *UserUpdateEntry
 	for i := range users {
@@ -501,7 +998,7 @@ func (sc *SpreedClient) checkLastUserLeft(users []UserUpdateEntry) {
 	}
 	if us.InCall&CallFlagInCall != 0 && them.InCall == CallFlagDisconnected {
 		sc.logger.Info("last user left the call, closing")
-		sc.Close()
+		sc.CloseWithReason(CloseReasonLastUser)
 	}
 }
 
@@ -519,7 +1016,7 @@ func (sc *SpreedClient) handleMessage(ctx context.Context, msg *SignalingMessage
 }
 
 func (sc *SpreedClient) handleOffer(ctx context.Context, msg *SignalingMessage) {
-	if msg.Message.Sender == nil || msg.Message.Data.Payload == nil {
+	if msg.Message.Sender == nil || msg.Message.Data.Payload == nil || sc.audioStopped.Load() {
 		return
 	}
 
@@ -536,19 +1033,7 @@ func (sc *SpreedClient) handleOffer(ctx context.Context, msg *SignalingMessage)
 	}
 	sc.peerConnsMu.Unlock()
 
-	var iceServers []webrtc.ICEServer
-	for _, stun := range sc.hpbSettings.StunServers {
-		iceServers = append(iceServers, webrtc.ICEServer{URLs: stun.URLs})
-	}
-	for _, turn := range sc.hpbSettings.TurnServers {
-		iceServers = append(iceServers, webrtc.ICEServer{
-			URLs:       turn.URLs,
-			Username:   turn.Username,
-			Credential: turn.Credential,
-		})
-	}
-
-	config := webrtc.Configuration{ICEServers: iceServers}
+	config := iceConfiguration(sc.hpbSettings.Load(), sc.relayOnly, sc.logger)
 	pc, err := webrtc.NewPeerConnection(config)
 	if err != nil {
 		sc.logger.Error("failed to create peer connection", "error", err)
@@ -566,10 +1051,18 @@ func (sc *SpreedClient) handleOffer(ctx context.Context, msg *SignalingMessage)
 	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
 		sc.logger.Debug("peer connection state changed",
 			"session_id", spkrSid, "state", state.String())
-		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
 			sc.peerConnsMu.Lock()
-			delete(sc.peerConns, spkrSid)
+			delete(sc.iceFailureStreak, spkrSid)
 			sc.peerConnsMu.Unlock()
+		case webrtc.PeerConnectionStateFailed:
+			if sc.forgetPeerConn(spkrSid, pc) {
+				_ = pc.Close()
+				sc.restartAfterICEFailure(spkrSid)
+			}
+		case webrtc.PeerConnectionStateClosed:
+			sc.forgetPeerConn(spkrSid, pc)
 		}
 	})
 
@@ -579,6 +1072,10 @@ func (sc *SpreedClient) handleOffer(ctx context.Context, msg *SignalingMessage)
 		}
 		sc.logger.Debug("receiving audio track", "session_id", spkrSid,
 			"codec", track.Codec().MimeType)
+		if err := checkAudioCodec(track.Codec().RTPCodecCapability); err != nil {
+			sc.logger.Error("not transcribing audio track", "error", err, "session_id", spkrSid)
+			return
+		}
 		go sc.readAudioTrack(ctx, spkrSid, track)
 	})
 
@@ -641,10 +1138,19 @@ func (sc *SpreedClient) handleCandidate(msg *SignalingMessage) {
 		return
 	}
 
-	iceCandidate := webrtc.ICECandidateInit{
-		Candidate:     candidate.Candidate,
-		SDPMid:        &candidate.SDPMid,
-		SDPMLineIndex: uint16Ptr(uint16(candidate.SDPMLineIndex)),
+	if err := checkCandidate(pc, candidate); err != nil {
+		sc.logger.Warn("ignoring malformed ICE candidate", "error", err, "session_id", senderSid)
+		return
+	}
+
+	// The mid resolves the media section if given, the index is left out
+	// as it may disagree
+	iceCandidate := webrtc.ICECandidateInit{Candidate: candidate.Candidate}
+	if candidate.SDPMid != "" {
+		mid := candidate.SDPMid
+		iceCandidate.SDPMid = &mid
+	} else {
+		iceCandidate.SDPMLineIndex = uint16Ptr(uint16(candidate.SDPMLineIndex))
 	}
 
 	if err := pc.AddICECandidate(iceCandidate); err != nil {
@@ -652,7 +1158,35 @@ func (sc *SpreedClient) handleCandidate(msg *SignalingMessage) {
 	}
 }
 
+// opusSampleRate is the RTP clock rate of Opus, whatever the sample rate of
+// the encoded audio, and the rate tracks are decoded at.
+const opusSampleRate = 48000
+
+// checkAudioCodec returns an error wrapping ErrUnsupportedAudioCodec unless
+// the track can be decoded: only Opus at its 48 kHz clock rate is. Other
+// codecs negotiated by default, such as G.711, are refused rather than
+// decoded as Opus.
+func checkAudioCodec(codec webrtc.RTPCodecCapability) error {
+	if !strings.EqualFold(codec.MimeType, webrtc.MimeTypeOpus) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedAudioCodec, codec.MimeType)
+	}
+	if codec.ClockRate != opusSampleRate {
+		return fmt.Errorf("%w: %s at a clock rate of %d Hz", ErrUnsupportedAudioCodec, codec.MimeType, codec.ClockRate)
+	}
+	return nil
+}
+
 func (sc *SpreedClient) readAudioTrack(ctx context.Context, sessionID string, track *webrtc.TrackRemote) {
+	sc.audioTracks.Add(1)
+	defer sc.audioTracks.Add(-1)
+	stats := sc.addTrackStats(sessionID)
+	defer func() {
+		// A track replaced by a renegotiation has not ended the audio
+		if sc.removeTrackStats(sessionID, stats) {
+			sc.endAudio(ctx, sessionID)
+		}
+	}()
+
 	sc.logger.Info("audio track reader started", "session_id", sessionID,
 		"codec", track.Codec().MimeType,
 		"sample_rate", track.Codec().ClockRate,
@@ -660,17 +1194,74 @@ func (sc *SpreedClient) readAudioTrack(ctx context.Context, sessionID string, tr
 	)
 	defer sc.logger.Info("audio track reader stopped", "session_id", sessionID)
 
-	const sampleRate = 48000
-	const channels = 1
-	dec, err := opus.NewDecoder(sampleRate, channels)
+	const sampleRate = opusSampleRate
+	// Decode as many channels as negotiated and downmix to the mono Vosk
+	// wants. Opus decodes to at most two channels; for anything else mono
+	// output makes the decoder do the downmix itself.
+	channels := int(track.Codec().Channels)
+	if channels < 1 || channels > 2 {
+		if channels > 2 {
+			sc.logger.Warn("unsupported opus channel count, decoding as mono",
+				"session_id", sessionID, "channels", channels)
+		}
+		channels = 1
+	}
+	dec, err := newOpusDecoder(ctx, sampleRate, channels)
 	if err != nil {
+		sc.decoderFailures.Add(1)
 		sc.logger.Error("failed to create opus decoder", "error", err, "session_id", sessionID)
+		sc.renegotiateAfterDecoderFailure(sessionID)
 		return
 	}
 
-	pcmBuf := make([]int16, 5760) // max 120ms at 48kHz
+	pcmBuf := make([]int16, 5760*channels) // max 120ms at 48kHz
+
+	// n is the number of samples per channel
+	var dropped int64
+	emit := func(n int) {
+		samples := downmixToMono(pcmBuf[:n*channels], channels)
+		stats.addAudio(samples)
 
+		select {
+		case sc.PCMAudioCh <- PCMAudio{
+			SessionID:  sessionID,
+			Samples:    samples,
+			SampleRate: sampleRate,
+		}:
+		default:
+			// Transcription can't keep up; make the lost audio visible
+			sc.droppedAudio.Add(1)
+			if dropped++; dropped%constants.AudioDropWarnEvery == 1 {
+				sc.logger.Warn("audio buffer full, dropping audio",
+					"session_id", sessionID, "dropped_frames", dropped)
+			}
+		}
+	}
+
+	var lastSeq uint16
+	haveSeq := false
+	decode := func(packet *rtp.Packet) {
+		if haveSeq {
+			if lost := lostPackets(lastSeq, packet.SequenceNumber); lost > 0 {
+				sc.concealLoss(dec, pcmBuf, channels, packet.Payload, lost, emit)
+			}
+		}
+		lastSeq, haveSeq = packet.SequenceNumber, true
+
+		samplesDecoded, err := dec.Decode(packet.Payload, pcmBuf)
+		if err != nil {
+			stats.decodeErrors.Add(1)
+			sc.logger.Debug("opus decode error", "error", err, "session_id", sessionID)
+			return
+		}
+		if samplesDecoded > 0 {
+			emit(samplesDecoded)
+		}
+	}
+
+	jitter := newJitterBuffer(sc.jitterDepth, track.Codec().ClockRate)
 	rtpBuf := make([]byte, 4096)
+	waiting := false
 
 	for {
 		select {
@@ -679,8 +1270,25 @@ func (sc *SpreedClient) readAudioTrack(ctx context.Context, sessionID string, tr
 		default:
 		}
 
+		// Held packets are released on time even if no further packet
+		// arrives, e.g. when the speaker is muted
+		at, holding := jitter.deadline()
+		if holding || waiting {
+			if err := track.SetReadDeadline(at); err != nil {
+				sc.logger.Debug("setting track read deadline", "session_id", sessionID, "error", err)
+			}
+			waiting = holding
+		}
+
 		n, _, readErr := track.Read(rtpBuf)
 		if readErr != nil {
+			var netErr net.Error
+			if errors.As(readErr, &netErr) && netErr.Timeout() {
+				for _, p := range jitter.expire(time.Now()) {
+					decode(p)
+				}
+				continue
+			}
 			if ctx.Err() != nil {
 				return
 			}
@@ -691,35 +1299,211 @@ func (sc *SpreedClient) readAudioTrack(ctx context.Context, sessionID string, tr
 			continue
 		}
 
+		// The payload may be held by the jitter buffer, so it must not
+		// alias rtpBuf
 		packet := &rtp.Packet{}
-		if err := packet.Unmarshal(rtpBuf[:n]); err != nil {
+		if err := packet.Unmarshal(slices.Clone(rtpBuf[:n])); err != nil {
 			continue
 		}
 		if len(packet.Payload) == 0 {
 			continue
 		}
+		stats.packets.Add(1)
 
-		samplesDecoded, err := dec.Decode(packet.Payload, pcmBuf)
-		if err != nil {
-			sc.logger.Debug("opus decode error", "error", err, "session_id", sessionID)
-			continue
+		due, late := jitter.push(packet, time.Now())
+		if late {
+			sc.latePackets.Add(1)
 		}
-		if samplesDecoded == 0 {
-			continue
+		for _, p := range due {
+			decode(p)
+		}
+	}
+}
+
+// endAudio tells the audio consumer that the speaker's audio ended, so the
+// speaker's transcription state can be released.
+func (sc *SpreedClient) endAudio(ctx context.Context, sessionID string) {
+	select {
+	case sc.PCMAudioCh <- PCMAudio{SessionID: sessionID, Ended: true}:
+	case <-ctx.Done():
+	}
+}
+
+// lostPackets returns how many packets are missing between the sequence
+// numbers of two consecutive packets, across the uint16 wrap-around.
+func lostPackets(last, seq uint16) int {
+	return int(seq - last - 1)
+}
+
+// lossConcealer is the part of the opus decoder concealLoss uses, replaced
+// in tests.
+type lossConcealer interface {
+	LastPacketDuration() (int, error)
+	DecodeFEC(data []byte, pcm []int16) error
+	DecodePLC(pcm []int16) error
+}
+
+// concealLoss fills the audio of lost packets before next is decoded: the
+// packet right before next is recovered from next's in-band FEC data (opus
+// falls back to PLC if there is none), earlier ones by PLC. Gaps longer than
+// MaxConcealedPackets are left as a gap, as concealment would only produce
+// noise the recognizer may turn into words.
+func (sc *SpreedClient) concealLoss(
+	dec lossConcealer,
+	pcmBuf []int16,
+	channels int,
+	next []byte,
+	lost int,
+	emit func(int),
+) {
+	if lost > constants.MaxConcealedPackets {
+		return
+	}
+	frame, err := dec.LastPacketDuration()
+	if err != nil || frame <= 0 || frame*channels > len(pcmBuf) {
+		return
+	}
+
+	// opus sizes the output by the buffer's capacity, which must be exactly
+	// the missing duration
+	buf := pcmBuf[: frame*channels : frame*channels]
+	for i := range lost {
+		if i == lost-1 {
+			err = dec.DecodeFEC(next, buf)
+		} else {
+			err = dec.DecodePLC(buf)
 		}
+		if err != nil {
+			return
+		}
+		sc.concealedPackets.Add(1)
+		emit(frame)
+	}
+}
 
-		samples := make([]int16, samplesDecoded)
-		copy(samples, pcmBuf[:samplesDecoded])
+// downmixToMono returns a copy of interleaved pcm with the channels
+// averaged into one.
+func downmixToMono(pcm []int16, channels int) []int16 {
+	out := make([]int16, len(pcm)/channels)
+	if channels == 1 {
+		copy(out, pcm)
+		return out
+	}
+	for i := range out {
+		var sum int32
+		for _, s := range pcm[i*channels : (i+1)*channels] {
+			sum += int32(s)
+		}
+		out[i] = int16(sum / int32(channels))
+	}
+	return out
+}
 
+// newOpusDecoder retries decoder creation, which mostly fails under
+// transient resource pressure.
+//
+// Unlike the Vosk recognizers, a decoder needs no explicit cleanup: the
+// binding keeps the libopus state in a Go byte slice, freed by the GC once
+// the track reader returns. Nor are decoders pooled across renegotiations,
+// as the binding cannot reset one and a stale state would conceal the new
+// stream's first losses with the old stream's audio.
+func newOpusDecoder(ctx context.Context, sampleRate, channels int) (*opus.Decoder, error) {
+	delay := constants.OpusDecoderRetryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		var dec *opus.Decoder
+		if dec, err = opusNewDecoder(sampleRate, channels); err == nil {
+			return dec, nil
+		}
+		if attempt >= constants.OpusDecoderRetries {
+			return nil, err
+		}
 		select {
-		case sc.PCMAudioCh <- PCMAudio{
-			SessionID:  sessionID,
-			Samples:    samples,
-			SampleRate: sampleRate,
-		}:
-		default:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// opusNewDecoder creates the decoders of newOpusDecoder, replaced in tests.
+var opusNewDecoder = opus.NewDecoder
+
+// forgetPeerConn removes pc from peerConns unless it has already been
+// replaced by a newer connection for the speaker. It reports whether pc was
+// the current one.
+func (sc *SpreedClient) forgetPeerConn(sessionID string, pc *webrtc.PeerConnection) bool {
+	sc.peerConnsMu.Lock()
+	defer sc.peerConnsMu.Unlock()
+	if sc.peerConns[sessionID] != pc {
+		return false
+	}
+	delete(sc.peerConns, sessionID)
+	return true
+}
+
+// restartAfterICEFailure requests a fresh offer from a speaker whose peer
+// connection failed, so audio resumes without waiting for their client to
+// renegotiate. Consecutive failures back off exponentially and are given up
+// after MaxICERestarts; reaching the connected state resets the count.
+func (sc *SpreedClient) restartAfterICEFailure(sessionID string) {
+	if sc.defunct.Load() {
+		return
+	}
+
+	sc.peerConnsMu.Lock()
+	streak := sc.iceFailureStreak[sessionID]
+	if streak >= constants.MaxICERestarts {
+		sc.peerConnsMu.Unlock()
+		sc.logger.Warn("peer connection keeps failing, giving up on speaker", "session_id", sessionID)
+		return
+	}
+	sc.iceFailureStreak[sessionID] = streak + 1
+	sc.peerConnsMu.Unlock()
+
+	delay := constants.ICERestartDelay << streak
+	sc.logger.Info("peer connection failed, re-requesting offer",
+		"session_id", sessionID, "attempt", streak+1, "delay", delay)
+
+	time.AfterFunc(delay, func() {
+		if sc.defunct.Load() {
+			return
+		}
+		sc.peerConnsMu.Lock()
+		_, exists := sc.peerConns[sessionID]
+		sc.peerConnsMu.Unlock()
+		if exists {
+			return // the speaker renegotiated in the meantime
 		}
+		sc.iceRestarts.Add(1)
+		sc.sendOfferRequest(sessionID)
+	})
+}
+
+// renegotiateAfterDecoderFailure tears down the speaker's peer connection and
+// requests a fresh offer, so a failed decoder does not leave the speaker
+// silent for the rest of the call. Bounded per speaker.
+func (sc *SpreedClient) renegotiateAfterDecoderFailure(sessionID string) {
+	if !sc.renegotiateOnFail || sc.defunct.Load() {
+		return
+	}
+
+	sc.peerConnsMu.Lock()
+	if sc.decoderRenegotiated[sessionID] >= constants.MaxDecoderRenegotiations {
+		sc.peerConnsMu.Unlock()
+		sc.logger.Warn("opus decoder keeps failing, giving up on speaker", "session_id", sessionID)
+		return
+	}
+	sc.decoderRenegotiated[sessionID]++
+	if pc, ok := sc.peerConns[sessionID]; ok {
+		_ = pc.Close()
+		delete(sc.peerConns, sessionID)
 	}
+	sc.peerConnsMu.Unlock()
+
+	sc.logger.Info("re-requesting offer after opus decoder failure", "session_id", sessionID)
+	sc.sendOfferRequest(sessionID)
 }
 
 func (sc *SpreedClient) SendMessage(msg SignalingMessage) {
@@ -743,20 +1527,30 @@ func (sc *SpreedClient) sendMessageLocked(msg SignalingMessage) {
 
 	if err := sc.conn.WriteMessage(websocket.TextMessage, data); err != nil {
 		sc.logger.Error("failed to send message", "error", err)
+		return
 	}
+	sc.traceMessage("out", &msg)
 }
 
+// receiveMessage reads the next message from the current connection. Must
+// be called with sc.mu held.
 func (sc *SpreedClient) receiveMessage(timeout time.Duration) (*SignalingMessage, error) {
-	if sc.conn == nil {
+	return sc.readMessage(sc.conn, timeout)
+}
+
+// readMessage reads the next message from conn, waiting up to timeout if
+// it is positive.
+func (sc *SpreedClient) readMessage(conn wsConn, timeout time.Duration) (*SignalingMessage, error) {
+	if conn == nil {
 		return nil, fmt.Errorf("no connection")
 	}
 
 	if timeout > 0 {
-		_ = sc.conn.SetReadDeadline(time.Now().Add(timeout))
-		defer func() { _ = sc.conn.SetReadDeadline(time.Time{}) }()
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+		defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
 	}
 
-	_, data, err := sc.conn.ReadMessage()
+	_, data, err := conn.ReadMessage()
 	if err != nil {
 		return nil, err
 	}
@@ -765,6 +1559,7 @@ func (sc *SpreedClient) receiveMessage(timeout time.Duration) (*SignalingMessage
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return nil, fmt.Errorf("unmarshal message: %w", err)
 	}
+	sc.traceMessage("in", &msg)
 
 	return &msg, nil
 }
@@ -778,8 +1573,9 @@ func (sc *SpreedClient) resumeConnection(ctx context.Context) (bool, error) {
 		},
 	})
 
-	for i := 0; i < 10; i++ {
-		msg, err := sc.receiveMessage(constants.MsgReceiveTimeout)
+	deadline := time.Now().Add(constants.MsgReceiveTimeout)
+	for {
+		msg, err := sc.receiveBefore(deadline)
 		if err != nil {
 			return false, err
 		}
@@ -803,8 +1599,6 @@ func (sc *SpreedClient) resumeConnection(ctx context.Context) (bool, error) {
 			return false, nil
 		}
 	}
-
-	return false, nil
 }
 
 func (sc *SpreedClient) sendHello() error {
@@ -849,6 +1643,9 @@ func (sc *SpreedClient) sendJoin() {
 }
 
 func (sc *SpreedClient) sendOfferRequest(publisherSessionID string) {
+	if sc.audioStopped.Load() {
+		return
+	}
 	sc.SendMessage(SignalingMessage{
 		Type: "message",
 		Message: &DataMessage{
@@ -907,54 +1704,141 @@ func (sc *SpreedClient) sendCandidate(sender, offerSid, candidateStr string) {
 // non-nil, targets whose Nextcloud session ID satisfies it are skipped
 // (used to suppress original-language finals for translation recipients).
 func (sc *SpreedClient) SendTranscript(t Transcript, excludeNcSid func(string) bool) {
+	t.SpeakerName = sc.SpeakerName(t.SpeakerSessionID)
+
 	sc.targetMu.Lock()
 	type target struct {
 		hpbSid string
 		ncSid  string
 	}
 	targets := make([]target, 0, len(sc.targets))
-	// Build reverse map only when we need to exclude
-	var hpbToNc map[string]string
-	if excludeNcSid != nil {
-		hpbToNc = make(map[string]string, len(sc.ncSidMap))
-		for nc, hpb := range sc.ncSidMap {
-			hpbToNc[hpb] = nc
-		}
-	}
 	for sid := range sc.targets {
-		nc := ""
-		if hpbToNc != nil {
-			nc = hpbToNc[sid]
-		}
-		targets = append(targets, target{hpbSid: sid, ncSid: nc})
+		targets = append(targets, target{hpbSid: sid, ncSid: sc.hpbSidMap[sid]})
 	}
+	sc.recordHistoryLocked(t)
 	sc.targetMu.Unlock()
 
 	if len(targets) == 0 {
 		return
 	}
 
-	finalVal := t.Final
 	for _, tgt := range targets {
 		if excludeNcSid != nil && tgt.ncSid != "" && excludeNcSid(tgt.ncSid) {
 			continue
 		}
+		sc.sendTranscriptTo(tgt.hpbSid, t, false)
+	}
+}
+
+func (sc *SpreedClient) sendTranscriptTo(hpbSid string, t Transcript, history bool) {
+	finalVal := t.Final
+	sc.SendMessage(SignalingMessage{
+		Type: "message",
+		Message: &DataMessage{
+			Recipient: &Recipient{Type: "session", SessionID: hpbSid},
+			Data: &MessagePayload{
+				Final:            &finalVal,
+				LangID:           t.LangID,
+				Message:          t.Message,
+				SpeakerSessionID: t.SpeakerSessionID,
+				SpeakerName:      t.SpeakerName,
+				StartMs:          t.StartMs,
+				EndMs:            t.EndMs,
+				Alternatives:     t.Alternatives,
+				Type:             "transcript",
+				History:          history,
+				Unattributed:     t.SpeakerSessionID == "",
+			},
+		},
+	})
+}
+
+// ClientStats is a point-in-time snapshot of the client's resource usage.
+type ClientStats struct {
+	PeerConnections  int   `json:"peer_connections"`
+	AudioTracks      int   `json:"audio_tracks"`
+	Targets          int   `json:"targets"`
+	PendingTargets   int   `json:"pending_targets"`
+	DecoderFailures  int64 `json:"decoder_failures"`
+	ICERestarts      int64 `json:"ice_restarts"`
+	ConcealedPackets int64 `json:"concealed_packets"`
+	LatePackets      int64 `json:"late_packets"`
+	DroppedAudio     int64 `json:"dropped_audio_frames"`
+	Defunct          bool  `json:"defunct"`
+}
+
+// transcriptionEndedMessage tells a target that no more transcripts follow
+// because transcription stopped, as opposed to captions pausing on a network
+// problem.
+func transcriptionEndedMessage(hpbSid string) SignalingMessage {
+	return SignalingMessage{
+		Type: "message",
+		Message: &DataMessage{
+			Recipient: &Recipient{Type: "session", SessionID: hpbSid},
+			Data:      &MessagePayload{Type: "transcriptionEnded"},
+		},
+	}
+}
+
+// SendLoadStatus tells all targets whether transcription is overloaded, in
+// which case transcripts may lag or partials pause.
+func (sc *SpreedClient) SendLoadStatus(overloaded bool) {
+	sc.targetMu.Lock()
+	targets := slices.Collect(maps.Keys(sc.targets))
+	sc.targetMu.Unlock()
+
+	for _, hpbSid := range targets {
 		sc.SendMessage(SignalingMessage{
 			Type: "message",
 			Message: &DataMessage{
-				Recipient: &Recipient{Type: "session", SessionID: tgt.hpbSid},
-				Data: &MessagePayload{
-					Final:            &finalVal,
-					LangID:           t.LangID,
-					Message:          t.Message,
-					SpeakerSessionID: t.SpeakerSessionID,
-					Type:             "transcript",
-				},
+				Recipient: &Recipient{Type: "session", SessionID: hpbSid},
+				Data:      &MessagePayload{Type: "transcriptionLoad", Overloaded: &overloaded},
 			},
 		})
 	}
 }
 
+func (sc *SpreedClient) Stats() ClientStats {
+	sc.peerConnsMu.Lock()
+	peerConns := len(sc.peerConns)
+	sc.peerConnsMu.Unlock()
+
+	sc.targetMu.Lock()
+	sc.pruneWaitStashLocked()
+	targets := len(sc.targets)
+	pending := len(sc.ncSidWaitStash)
+	sc.targetMu.Unlock()
+
+	return ClientStats{
+		PeerConnections:  peerConns,
+		AudioTracks:      int(sc.audioTracks.Load()),
+		Targets:          targets,
+		PendingTargets:   pending,
+		DecoderFailures:  sc.decoderFailures.Load(),
+		ICERestarts:      sc.iceRestarts.Load(),
+		ConcealedPackets: sc.concealedPackets.Load(),
+		LatePackets:      sc.latePackets.Load(),
+		DroppedAudio:     sc.droppedAudio.Load(),
+		Defunct:          sc.defunct.Load(),
+	}
+}
+
+// mapSessionLocked records the HPB session of a Nextcloud session. Must be
+// called with targetMu held.
+func (sc *SpreedClient) mapSessionLocked(ncSid, hpbSid string) {
+	sc.unmapSessionLocked(ncSid)
+	sc.ncSidMap[ncSid] = hpbSid
+	sc.hpbSidMap[hpbSid] = ncSid
+}
+
+// Must be called with targetMu held.
+func (sc *SpreedClient) unmapSessionLocked(ncSid string) {
+	if hpbSid, ok := sc.ncSidMap[ncSid]; ok {
+		delete(sc.ncSidMap, ncSid)
+		delete(sc.hpbSidMap, hpbSid)
+	}
+}
+
 // ResolveNcSessionID maps a Nextcloud session ID to the corresponding HPB session ID.
 // Returns empty string if not found.
 func (sc *SpreedClient) ResolveNcSessionID(ncSessionID string) string {
@@ -980,17 +1864,36 @@ func generateNonce() string {
 	return hex.EncodeToString(b)
 }
 
-var httpToWS = regexp.MustCompile(`^http://`)
-var httpsToWSS = regexp.MustCompile(`^https://`)
+// checkCandidate validates a remote candidate's media section against the
+// offer it belongs to. The mid takes precedence and must name a section,
+// the m-line index is only checked to exist without one.
+func checkCandidate(pc *webrtc.PeerConnection, c *CandidateInfo) error {
+	if c.SDPMid == "" && (c.SDPMLineIndex < 0 || c.SDPMLineIndex > math.MaxUint16) {
+		return fmt.Errorf("sdpMLineIndex %d out of range", c.SDPMLineIndex)
+	}
 
-func sanitizeWebSocketURL(wsURL string) string {
-	wsURL = httpToWS.ReplaceAllString(wsURL, "ws://")
-	wsURL = httpsToWSS.ReplaceAllString(wsURL, "wss://")
-	wsURL = strings.TrimRight(wsURL, "/")
-	if !strings.HasSuffix(wsURL, "/spreed") {
-		wsURL += "/spreed"
+	desc := pc.RemoteDescription()
+	if desc == nil {
+		return nil // nothing to check against yet
+	}
+	offer, err := desc.Unmarshal()
+	if err != nil {
+		return nil //nolint:nilerr // pion has accepted the offer, leave the candidate to it
 	}
-	return wsURL
+
+	if c.SDPMid != "" {
+		for _, media := range offer.MediaDescriptions {
+			if mid, ok := media.Attribute("mid"); ok && mid == c.SDPMid {
+				return nil
+			}
+		}
+		return fmt.Errorf("sdpMid %q names no media section of the offer", c.SDPMid)
+	}
+	if c.SDPMLineIndex >= len(offer.MediaDescriptions) {
+		return fmt.Errorf("sdpMLineIndex %d out of range, offer has %d media sections",
+			c.SDPMLineIndex, len(offer.MediaDescriptions))
+	}
+	return nil
 }
 
 func uint16Ptr(v uint16) *uint16 {
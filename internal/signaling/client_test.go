@@ -0,0 +1,475 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package signaling
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hraban/opus"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// residentBytes returns the resident set size of the process after a GC.
+func residentBytes(t *testing.T) int64 {
+	t.Helper()
+	runtime.GC()
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		t.Skipf("resident memory unavailable: %v", err)
+	}
+	fields := strings.Fields(string(data))
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pages * int64(os.Getpagesize())
+}
+
+// TestOpusDecoderCreateDestroy checks that decoders dropped by exiting
+// track readers are freed, whether their state is Go or C memory: a leaked
+// libopus decoder state is about 25 KiB, so 5000 of them would add over
+// 100 MiB.
+func TestOpusDecoderCreateDestroy(t *testing.T) {
+	ctx := context.Background()
+	pcm := make([]int16, 5760*2)
+	cycle := func(n int) {
+		for range n {
+			dec, err := newOpusDecoder(ctx, 48000, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec.Decode([]byte{0xfc, 0xff, 0xfe}, pcm)
+			dec.DecodePLC(pcm[:960*2])
+		}
+	}
+
+	cycle(500)
+	before := residentBytes(t)
+	cycle(5000)
+	if grown := residentBytes(t) - before; grown > 32<<20 {
+		t.Errorf("resident memory grew by %d MiB over 5000 decoders", grown>>20)
+	}
+}
+
+func TestCheckAudioCodec(t *testing.T) {
+	tests := []struct {
+		codec webrtc.RTPCodecCapability
+		ok    bool
+	}{
+		{webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, true},
+		{webrtc.RTPCodecCapability{MimeType: "audio/OPUS", ClockRate: 48000}, true},
+		{webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 16000}, false},
+		{webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMU, ClockRate: 8000}, false},
+		{webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMA, ClockRate: 48000}, false},
+		{webrtc.RTPCodecCapability{}, false},
+	}
+	for _, tt := range tests {
+		err := checkAudioCodec(tt.codec)
+		if tt.ok && err != nil {
+			t.Errorf("%s at %d Hz refused: %v", tt.codec.MimeType, tt.codec.ClockRate, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrUnsupportedAudioCodec) {
+			t.Errorf("%s at %d Hz: error %v, want ErrUnsupportedAudioCodec", tt.codec.MimeType, tt.codec.ClockRate, err)
+		}
+	}
+}
+
+func TestSendsAudio(t *testing.T) {
+	tests := []struct {
+		flags CallFlag
+		want  bool
+	}{
+		{CallFlagDisconnected, false},
+		{CallFlagInCall, false},
+		{CallFlagInCall | CallFlagWithVideo, false},
+		{CallFlagInCall | CallFlagWithAudio, true},
+		{CallFlagInCall | CallFlagWithAudio | CallFlagWithVideo, true},
+		{CallFlagInCall | CallFlagWithPhone, true},
+		{CallFlagWithAudio, false},
+		{CallFlagWithPhone, false},
+	}
+	for _, tt := range tests {
+		if got := sendsAudio(tt.flags); got != tt.want {
+			t.Errorf("sendsAudio(%d) = %v, want %v", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryReplaySkipsTranslationTargets(t *testing.T) {
+	sc, _ := newFakeHPBClient(t)
+	conn := newFakeConn(t)
+	sc.conn = conn
+	sc.historySize = 10
+	sc.mapSessionLocked("nc1", "hpb1")
+	sc.mapSessionLocked("nc2", "hpb2")
+	sc.SetReplaySkip(func(ncSessionID string) bool { return ncSessionID == "nc2" })
+
+	sc.SendTranscript(Transcript{Final: true, LangID: "en", Message: "hello"}, nil)
+
+	sc.AddTarget("nc1")
+	msg := conn.expect("message")
+	if data := msg.Message.Data; msg.Message.Recipient.SessionID != "hpb1" || !data.History || data.Message != "hello" {
+		t.Fatalf("replayed %+v to %s, want the history to hpb1", data, msg.Message.Recipient.SessionID)
+	}
+
+	sc.AddTarget("nc2")
+	if len(conn.out) != 0 {
+		t.Errorf("history replayed to a translation target: %+v", (<-conn.out).Message.Data)
+	}
+}
+
+func TestSpeakerNameBySession(t *testing.T) {
+	sc, _ := newFakeHPBClient(t)
+	sc.SetSpeakerNameResolver(func(context.Context, string) (map[string]string, error) {
+		return nil, errors.New("not fetched in this test")
+	})
+	sc.speakers.names = map[string]string{"nc1": "Alice", "nc2": "Bob"}
+	sc.speakers.fetchedAt = time.Now()
+
+	sc.targetMu.Lock()
+	sc.mapSessionLocked("nc1", "hpb1")
+	sc.mapSessionLocked("nc2", "hpb2")
+	// nc1 rejoined with a new HPB session
+	sc.mapSessionLocked("nc1", "hpb3")
+	sc.targetMu.Unlock()
+
+	for hpbSid, want := range map[string]string{"hpb1": "", "hpb2": "Bob", "hpb3": "Alice", "hpb4": ""} {
+		if got := sc.SpeakerName(hpbSid); got != want {
+			t.Errorf("SpeakerName(%s) = %q, want %q", hpbSid, got, want)
+		}
+	}
+}
+
+func TestCheckCandidate(t *testing.T) {
+	// An offer of an audio and a video section, mids "0" and "1"
+	offerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer offerer.Close()
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+		if _, err := offerer.AddTransceiverFromKind(kind); err != nil {
+			t.Fatal(err)
+		}
+	}
+	offer, err := offerer.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if err := pc.SetRemoteDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		index   int
+		mid     string
+		wantErr bool
+	}{
+		{"index", 1, "", false},
+		{"index out of range", 2, "", true},
+		{"negative index", -1, "", true},
+		{"index beyond uint16", math.MaxUint16 + 1, "", true},
+		{"mid", 1, "1", false},
+		{"mid wins over a disagreeing index", 0, "1", false},
+		{"mid wins over an out of range index", 7, "0", false},
+		{"unknown mid", 0, "2", true},
+	}
+	for _, tt := range tests {
+		c := &CandidateInfo{SDPMLineIndex: tt.index, SDPMid: tt.mid}
+		if err := checkCandidate(pc, c); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestOpusDecoderRetries(t *testing.T) {
+	errFail := errors.New("injected decoder failure")
+	fails := 0 // decoders failing before one is created
+	calls := 0
+	opusNewDecoder = func(sampleRate, channels int) (*opus.Decoder, error) {
+		calls++
+		if calls <= fails {
+			return nil, errFail
+		}
+		return opus.NewDecoder(sampleRate, channels)
+	}
+	t.Cleanup(func() { opusNewDecoder = opus.NewDecoder })
+
+	// Backoffs between the attempts only, none after the last one
+	var backoffs time.Duration
+	for i := range constants.OpusDecoderRetries - 1 {
+		backoffs += constants.OpusDecoderRetryDelay << i
+	}
+	tests := []struct {
+		fails   int
+		wantErr bool
+	}{
+		{0, false},
+		{constants.OpusDecoderRetries - 1, false},
+		{constants.OpusDecoderRetries, true},
+	}
+	for _, tt := range tests {
+		fails, calls = tt.fails, 0
+		start := time.Now()
+		dec, err := newOpusDecoder(context.Background(), 48000, 1)
+		elapsed := time.Since(start)
+		if (err != nil) != tt.wantErr || (dec == nil) != tt.wantErr {
+			t.Errorf("%d failures: decoder %v, error %v", tt.fails, dec, err)
+		}
+		if calls != min(tt.fails+1, constants.OpusDecoderRetries) {
+			t.Errorf("%d failures: %d attempts", tt.fails, calls)
+		}
+		if tt.wantErr && elapsed >= backoffs+constants.OpusDecoderRetryDelay<<(constants.OpusDecoderRetries-1) {
+			t.Errorf("gave up after %v, backed off after the last attempt", elapsed)
+		}
+	}
+
+	// A cancelled context ends the backoff
+	fails, calls = constants.OpusDecoderRetries, 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := newOpusDecoder(ctx, 48000, 1); !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("cancelled: error %v after %d attempts", err, calls)
+	}
+}
+
+// fakeConcealer records how concealLoss recovers each lost packet.
+type fakeConcealer struct {
+	frame int
+	calls []string
+}
+
+func (c *fakeConcealer) LastPacketDuration() (int, error) { return c.frame, nil }
+
+func (c *fakeConcealer) DecodeFEC(data []byte, pcm []int16) error {
+	c.calls = append(c.calls, "fec "+string(data))
+	return nil
+}
+
+func (c *fakeConcealer) DecodePLC(pcm []int16) error {
+	c.calls = append(c.calls, "plc")
+	return nil
+}
+
+func TestConcealLoss(t *testing.T) {
+	const frame = 960 // 20ms at 48kHz
+	dec := &fakeConcealer{frame: frame}
+	sc := &SpreedClient{}
+	pcmBuf := make([]int16, 5760)
+	var emitted []int
+
+	// A stream missing packets across the sequence number wrap-around, a
+	// single one, more than MaxConcealedPackets, and a late packet
+	maxGap := uint16(constants.MaxConcealedPackets)
+	seqs := []uint16{65533, 65534, 1, 2, 4, 5 + maxGap + 1, 5}
+	for i, seq := range seqs[1:] {
+		if lost := lostPackets(seqs[i], seq); lost > 0 {
+			sc.concealLoss(dec, pcmBuf, 1, []byte(strconv.Itoa(int(seq))), lost, func(n int) {
+				emitted = append(emitted, n)
+			})
+		}
+	}
+
+	// Only the packet right before the next one is recovered from its FEC
+	want := []string{"plc", "fec 1", "fec 4"}
+	if !slices.Equal(dec.calls, want) {
+		t.Errorf("concealed with %q, want %q", dec.calls, want)
+	}
+	if len(emitted) != len(want) || slices.ContainsFunc(emitted, func(n int) bool { return n != frame }) {
+		t.Errorf("emitted %v, want %d frames of %d samples", emitted, len(want), frame)
+	}
+	if got := sc.concealedPackets.Load(); got != int64(len(want)) {
+		t.Errorf("%d concealed packets, want %d", got, len(want))
+	}
+
+	// A frame the buffer cannot hold is not concealed
+	dec.calls = nil
+	sc.concealLoss(dec, pcmBuf[:frame], 2, nil, 1, func(int) { t.Error("emitted audio") })
+	if len(dec.calls) != 0 {
+		t.Errorf("concealed with %q into a short buffer", dec.calls)
+	}
+}
+
+func TestDownmixToMono(t *testing.T) {
+	tests := []struct {
+		name     string
+		pcm      []int16
+		channels int
+		want     []int16
+	}{
+		{"mono", []int16{1, -2, 3}, 1, []int16{1, -2, 3}},
+		{"stereo", []int16{100, 200, -100, -300, 7, 8}, 2, []int16{150, -200, 7}},
+		{"opposite phase", []int16{1000, -1000}, 2, []int16{0}},
+		{"full scale", []int16{math.MaxInt16, math.MaxInt16, math.MinInt16, math.MinInt16}, 2,
+			[]int16{math.MaxInt16, math.MinInt16}},
+		{"empty", nil, 2, []int16{}},
+	}
+	for _, tt := range tests {
+		got := downmixToMono(tt.pcm, tt.channels)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: downmixToMono = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// The mono result is a copy the decoder buffer can be reused after
+	pcm := []int16{1, 2}
+	if got := downmixToMono(pcm, 1); &got[0] == &pcm[0] {
+		t.Error("mono audio aliases the decoder buffer")
+	}
+}
+
+func TestTargetsIdempotent(t *testing.T) {
+	sc, _ := newFakeHPBClient(t)
+	conn := newFakeConn(t)
+	sc.conn = conn
+	sc.historySize = 10
+	sc.mapSessionLocked("nc2", "hpb2")
+	sc.SendTranscript(Transcript{Final: true, LangID: "en", Message: "hello"}, nil)
+
+	count := func(step string, got, want int) {
+		t.Helper()
+		if got != want {
+			t.Errorf("%s: %d targets, want %d", step, got, want)
+		}
+	}
+
+	// nc1 awaits its HPB session, nc2 gets transcripts
+	count("enable nc1", sc.AddTarget("nc1"), 1)
+	count("enable nc1 again", sc.AddTarget("nc1"), 1)
+	count("enable nc2", sc.AddTarget("nc2"), 2)
+	conn.expect("message")
+	count("enable nc2 again", sc.AddTarget("nc2"), 2)
+	if len(conn.out) != 0 {
+		t.Errorf("history replayed again to an existing target: %+v", (<-conn.out).Message.Data)
+	}
+
+	count("disable a session never enabled", sc.RemoveTarget("nc3"), 2)
+	sc.targetMu.Lock()
+	armed := sc.deferredCloseTimer != nil
+	sc.targetMu.Unlock()
+	if armed {
+		t.Error("disabling a session never enabled armed the deferred close")
+	}
+
+	count("disable nc2", sc.RemoveTarget("nc2"), 1)
+	count("disable nc2 again", sc.RemoveTarget("nc2"), 1)
+	count("disable nc1", sc.RemoveTarget("nc1"), 0)
+	count("disable nc1 again", sc.RemoveTarget("nc1"), 0)
+
+	// Closing forgets the sessions awaiting their HPB session
+	sc.AddTarget("nc4")
+	sc.Close()
+	if ids := sc.TargetNcSessionIDs(); len(ids) != 0 {
+		t.Errorf("targets %v after closing", ids)
+	}
+}
+
+func TestDeferredTargetExpires(t *testing.T) {
+	sc, _ := newFakeHPBClient(t)
+	conn := newFakeConn(t)
+	sc.conn = conn
+	sc.historySize = 10
+	sc.SendTranscript(Transcript{Final: true, LangID: "en", Message: "hello"}, nil)
+
+	sc.AddTarget("nc1")
+	if n := sc.AddTarget("nc2"); n != 2 {
+		t.Fatalf("%d targets, want both deferred", n)
+	}
+	sc.targetMu.Lock()
+	sc.ncSidWaitStash["nc1"] = time.Now().Add(-constants.DeferredTargetTTL - time.Second)
+	sc.targetMu.Unlock()
+
+	if ids := sc.TargetNcSessionIDs(); !slices.Equal(ids, []string{"nc2"}) {
+		t.Errorf("targets %v, want only nc2 after nc1 expired", ids)
+	}
+	if stats := sc.Stats(); stats.PendingTargets != 1 || stats.Targets != 0 {
+		t.Errorf("stats %d targets %d pending, want 0 and 1", stats.Targets, stats.PendingTargets)
+	}
+
+	// The expired session joining late gets no transcripts, the other does
+	sc.handleEvent(&SignalingMessage{Event: &EventMessage{
+		Target: "participants",
+		Type:   "update",
+		Update: &EventUpdate{Users: []UserUpdateEntry{
+			{SessionID: "hpb1", NextcloudSessionID: "nc1", InCall: CallFlagInCall},
+			{SessionID: "hpb2", NextcloudSessionID: "nc2", InCall: CallFlagInCall},
+		}},
+	}})
+	if msg := conn.expect("message"); msg.Message.Recipient.SessionID != "hpb2" {
+		t.Errorf("history replayed to %s, want hpb2", msg.Message.Recipient.SessionID)
+	}
+	if len(conn.out) != 0 {
+		t.Errorf("history replayed to the expired target: %+v", (<-conn.out).Message.Recipient)
+	}
+	if stats := sc.Stats(); stats.Targets != 1 || stats.PendingTargets != 0 {
+		t.Errorf("stats %d targets %d pending, want 1 and 0", stats.Targets, stats.PendingTargets)
+	}
+}
+
+func TestDeferredClosePostponedByPendingTarget(t *testing.T) {
+	sc, _ := newFakeHPBClient(t)
+	sc.AddTarget("nc1")
+
+	// The close timeout runs out just before nc1 joins the call
+	sc.deferredClose()
+	if reason := sc.CloseReason(); reason != "" {
+		t.Fatalf("closed with %q while a deferred target is pending", reason)
+	}
+	sc.targetMu.Lock()
+	armed := sc.deferredCloseTimer != nil
+	wait := sc.nextWaitStashExpiryLocked()
+	sc.targetMu.Unlock()
+	if !armed || wait <= constants.DeferredTargetTTL-time.Minute || wait > constants.DeferredTargetTTL {
+		t.Fatalf("close rearmed %v for %s, want until the deferred target expires", armed, wait)
+	}
+
+	sc.handleEvent(&SignalingMessage{Event: &EventMessage{
+		Target: "participants",
+		Type:   "update",
+		Update: &EventUpdate{Users: []UserUpdateEntry{
+			{SessionID: "hpb1", NextcloudSessionID: "nc1", InCall: CallFlagInCall},
+		}},
+	}})
+	sc.deferredClose()
+	if reason := sc.CloseReason(); reason != "" {
+		t.Fatalf("closed with %q after the deferred target resolved", reason)
+	}
+
+	// Without targets the next timeout closes the client
+	sc.RemoveTarget("nc1")
+	sc.deferredClose()
+	if reason := sc.CloseReason(); reason != CloseReasonLastUser {
+		t.Errorf("close reason %q, want %q", reason, CloseReasonLastUser)
+	}
+}
+
+func TestDeferredCloseAfterPendingTargetExpires(t *testing.T) {
+	sc, _ := newFakeHPBClient(t)
+	sc.AddTarget("nc1")
+	sc.targetMu.Lock()
+	sc.ncSidWaitStash["nc1"] = time.Now().Add(-constants.DeferredTargetTTL - time.Second)
+	sc.targetMu.Unlock()
+
+	sc.deferredClose()
+	if reason := sc.CloseReason(); reason != CloseReasonLastUser {
+		t.Errorf("close reason %q, want %q once the deferred target expired", reason, CloseReasonLastUser)
+	}
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package signaling
+
+import (
+	"log/slog"
+
+	"github.com/pion/stun/v3"
+	"github.com/pion/webrtc/v4"
+)
+
+// iceConfiguration builds the peer connection configuration from Talk's
+// STUN/TURN settings. URLs are passed on verbatim, so turns: schemes and
+// ?transport= parameters reach the ICE agent as configured in Talk; URLs
+// the agent could not parse are skipped instead of failing the connection.
+// With relayOnly only TURN candidates are used, for networks where nothing
+// but the TURN server is reachable.
+func iceConfiguration(settings *HPBSettings, relayOnly bool, logger *slog.Logger) webrtc.Configuration {
+	var iceServers []webrtc.ICEServer
+	var turnURLs int
+	for _, s := range settings.StunServers {
+		if urls := validICEURLs(s.URLs, logger); len(urls) > 0 {
+			iceServers = append(iceServers, webrtc.ICEServer{URLs: urls})
+		}
+	}
+	for _, s := range settings.TurnServers {
+		urls := validICEURLs(s.URLs, logger)
+		if len(urls) == 0 {
+			continue
+		}
+		turnURLs += len(urls)
+		iceServers = append(iceServers, webrtc.ICEServer{
+			URLs:       urls,
+			Username:   s.Username,
+			Credential: s.Credential,
+		})
+	}
+
+	config := webrtc.Configuration{ICEServers: iceServers}
+	if relayOnly {
+		config.ICETransportPolicy = webrtc.ICETransportPolicyRelay
+		if turnURLs == 0 {
+			logger.Warn("relay-only ICE is enabled but Talk provides no TURN server, connections will fail")
+		}
+	}
+	return config
+}
+
+func validICEURLs(urls []string, logger *slog.Logger) []string {
+	valid := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if _, err := stun.ParseURI(u); err != nil {
+			logger.Warn("skipping invalid ICE server URL", "url", u, "error", err)
+			continue
+		}
+		valid = append(valid, u)
+	}
+	return valid
+}
@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package signaling
+
+import (
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestICEConfiguration(t *testing.T) {
+	settings := &HPBSettings{
+		StunServers: []StunServer{{URLs: []string{"stun:stun.example:3478"}}},
+		TurnServers: []TurnServer{
+			{
+				URLs: []string{
+					"turns:turn.example:443?transport=tcp",
+					"turn:turn.example:3478?transport=udp",
+					"https://turn.example", // not an ICE URL
+				},
+				Username:   "user",
+				Credential: "pass",
+			},
+			{URLs: []string{"turn:"}}, // nothing valid left
+		},
+	}
+
+	config := iceConfiguration(settings, false, slog.Default())
+	if len(config.ICEServers) != 2 {
+		t.Fatalf("%d ICE servers, want the STUN and one TURN server", len(config.ICEServers))
+	}
+	turn := config.ICEServers[1]
+	want := []string{"turns:turn.example:443?transport=tcp", "turn:turn.example:3478?transport=udp"}
+	if !slices.Equal(turn.URLs, want) {
+		t.Errorf("TURN URLs %q, want %q unmodified", turn.URLs, want)
+	}
+	if turn.Username != "user" || turn.Credential != "pass" {
+		t.Errorf("TURN credentials %q/%v", turn.Username, turn.Credential)
+	}
+	if config.ICETransportPolicy != webrtc.ICETransportPolicyAll {
+		t.Errorf("transport policy %v, want all", config.ICETransportPolicy)
+	}
+
+	// The configuration is accepted by a peer connection as is
+	pc, err := webrtc.NewPeerConnection(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if got := pc.GetConfiguration().ICEServers[1].URLs; !slices.Equal(got, want) {
+		t.Errorf("peer connection TURN URLs %q, want %q", got, want)
+	}
+
+	if relay := iceConfiguration(settings, true, slog.Default()); relay.ICETransportPolicy != webrtc.ICETransportPolicyRelay {
+		t.Errorf("relay-only transport policy %v", relay.ICETransportPolicy)
+	}
+}
@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package signaling
+
+import (
+	"slices"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// jitterBuffer restores the sequence order of an audio track's RTP packets
+// before decoding. Packets in order pass straight through; after a gap the
+// following packets are held until the missing one arrives, more than depth
+// packets are waiting, they span more than depth frames of audio or they
+// waited for longer than that. Packets arriving after their turn are
+// dropped, the decoder has concealed them.
+type jitterBuffer struct {
+	depth   int
+	maxSpan uint32 // in RTP timestamp units
+	maxWait time.Duration
+
+	packets   []*rtp.Packet // held packets, sorted by sequence number
+	heldSince time.Time     // when the head of packets started waiting
+	next      uint16        // sequence number due next
+	started   bool
+}
+
+// newJitterBuffer returns a buffer holding up to depth packets of 20ms
+// frames at the given RTP clock rate. A depth of 0 only drops late packets.
+func newJitterBuffer(depth int, clockRate uint32) *jitterBuffer {
+	return &jitterBuffer{
+		depth:   depth,
+		maxSpan: uint32(depth) * clockRate / 50,
+		maxWait: time.Duration(depth) * 20 * time.Millisecond,
+	}
+}
+
+// seqCmp orders sequence numbers with serial number arithmetic, so the
+// order survives the 16-bit wraparound.
+func seqCmp(a, b uint16) int {
+	return int(int16(a - b))
+}
+
+// push adds a packet arriving at now and returns the packets now due for
+// decoding, in order. late reports whether p was dropped for arriving too
+// late or twice.
+func (jb *jitterBuffer) push(p *rtp.Packet, now time.Time) (due []*rtp.Packet, late bool) {
+	if jb.started && seqCmp(p.SequenceNumber, jb.next) < 0 {
+		return nil, true
+	}
+
+	i, found := slices.BinarySearchFunc(jb.packets, p, func(a, b *rtp.Packet) int {
+		return seqCmp(a.SequenceNumber, b.SequenceNumber)
+	})
+	if found {
+		return nil, true
+	}
+	jb.packets = slices.Insert(jb.packets, i, p)
+
+	for len(jb.packets) > 0 {
+		head, newest := jb.packets[0], jb.packets[len(jb.packets)-1]
+		inOrder := jb.started && head.SequenceNumber == jb.next
+		if !inOrder && len(jb.packets) <= jb.depth && newest.Timestamp-head.Timestamp < jb.maxSpan {
+			break // wait for the gap to fill
+		}
+		due = append(due, head)
+		jb.packets = jb.packets[1:]
+		jb.next = head.SequenceNumber + 1
+		jb.started = true
+	}
+
+	switch {
+	case len(jb.packets) == 0:
+		jb.heldSince = time.Time{}
+	case len(due) > 0 || jb.heldSince.IsZero():
+		jb.heldSince = now
+	}
+	return due, false
+}
+
+// deadline returns when the held packets are released if the gap before
+// them does not fill; ok is false if no packets are held.
+func (jb *jitterBuffer) deadline() (at time.Time, ok bool) {
+	if len(jb.packets) == 0 {
+		return time.Time{}, false
+	}
+	return jb.heldSince.Add(jb.maxWait), true
+}
+
+// expire gives up on the gap once the held packets waited past their
+// deadline, and returns them for decoding in order. The missing packets
+// are dropped if they still arrive.
+func (jb *jitterBuffer) expire(now time.Time) []*rtp.Packet {
+	at, ok := jb.deadline()
+	if !ok || now.Before(at) {
+		return nil
+	}
+	due := jb.packets
+	jb.packets = nil
+	jb.heldSince = time.Time{}
+	jb.next = due[len(due)-1].SequenceNumber + 1
+	jb.started = true
+	return due
+}
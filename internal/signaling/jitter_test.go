@@ -0,0 +1,91 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package signaling
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+func packetSeq(seq uint16) *rtp.Packet {
+	return &rtp.Packet{Header: rtp.Header{SequenceNumber: seq, Timestamp: uint32(seq) * 960}}
+}
+
+func seqs(packets []*rtp.Packet) []uint16 {
+	var out []uint16
+	for _, p := range packets {
+		out = append(out, p.SequenceNumber)
+	}
+	return out
+}
+
+func TestJitterBuffer(t *testing.T) {
+	start := time.Unix(0, 0)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	jb := newJitterBuffer(3, 48000)
+	var released []uint16
+	push := func(seq uint16, ms int) bool {
+		due, late := jb.push(packetSeq(seq), at(ms))
+		released = append(released, seqs(due)...)
+		return late
+	}
+
+	// The first packet waits for the ones that may precede it
+	push(100, 0)
+	if len(released) != 0 {
+		t.Fatalf("released %v before the first deadline", released)
+	}
+	released = append(released, seqs(jb.expire(at(60)))...)
+	if !slices.Equal(released, []uint16{100}) {
+		t.Fatalf("released %v after the first deadline, want [100]", released)
+	}
+
+	// In order packets pass straight through, reordered ones are sorted
+	push(101, 80)
+	push(103, 120)
+	push(102, 121)
+	if want := []uint16{100, 101, 102, 103}; !slices.Equal(released, want) {
+		t.Fatalf("released %v, want %v", released, want)
+	}
+
+	// A gap is given up when the held packets waited for long enough, even
+	// if no further packet arrives
+	push(105, 160)
+	if got := jb.expire(at(200)); got != nil {
+		t.Fatalf("expired %v before the deadline", seqs(got))
+	}
+	if when, ok := jb.deadline(); !ok || !when.Equal(at(220)) {
+		t.Fatalf("deadline = %v, %v, want %v", when, ok, at(220))
+	}
+	released = append(released, seqs(jb.expire(at(220)))...)
+	if want := []uint16{100, 101, 102, 103, 105}; !slices.Equal(released, want) {
+		t.Fatalf("released %v, want %v", released, want)
+	}
+	if _, ok := jb.deadline(); ok {
+		t.Fatal("deadline set with no packets held")
+	}
+
+	// The missing packet is dropped when it arrives after all
+	if !push(104, 240) {
+		t.Error("packet after its turn not reported late")
+	}
+	if !push(105, 241) {
+		t.Error("duplicate packet not reported late")
+	}
+
+	// Sequence numbers wrap around
+	jb = newJitterBuffer(3, 48000)
+	released = nil
+	push(65535, 0)
+	jb.expire(at(60))
+	push(1, 80)
+	push(0, 81)
+	if want := []uint16{0, 1}; !slices.Equal(released, want) {
+		t.Fatalf("released %v across the wraparound, want %v", released, want)
+	}
+}
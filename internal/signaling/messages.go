@@ -109,6 +109,24 @@ type MessagePayload struct {
 	LangID           string `json:"langId,omitempty"`
 	Message          string `json:"message,omitempty"`
 	SpeakerSessionID string `json:"speakerSessionId,omitempty"`
+	SpeakerName      string `json:"speakerName,omitempty"`
+	StartMs          int64  `json:"startMs,omitempty"`
+	EndMs            int64  `json:"endMs,omitempty"`
+	// Alternatives of a final transcript, see Transcript.Alternatives.
+	Alternatives []Alternative `json:"alternatives,omitempty"`
+	// History marks transcripts replayed to a late-joining target.
+	History bool `json:"history,omitempty"`
+	// Unattributed marks transcripts of the mixed room audio, which have
+	// no speaker.
+	Unattributed bool `json:"unattributed,omitempty"`
+	// TranslationPending marks a placeholder shown while a final is being
+	// translated, replaced by the translation or an empty partial.
+	TranslationPending bool `json:"translationPending,omitempty"`
+	// Reason is set on translationError messages: unsupported_language_pair
+	// or backend_error.
+	Reason string `json:"reason,omitempty"`
+	// Overloaded is set on transcriptionLoad messages.
+	Overloaded *bool `json:"overloaded,omitempty"`
 }
 
 type SDPPayload struct {
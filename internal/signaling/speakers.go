@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package signaling
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// SpeakerNameResolver fetches the display names of a room's participants,
+// keyed by Nextcloud session ID.
+type SpeakerNameResolver func(ctx context.Context, roomToken string) (map[string]string, error)
+
+// speakerNames caches the display names of a room. Lookups never block on
+// the backend: a miss or an expired cache triggers a background refresh and
+// the transcript goes out with whatever name is known at that moment.
+type speakerNames struct {
+	mu          sync.Mutex
+	resolve     SpeakerNameResolver
+	roomToken   string
+	logger      *slog.Logger
+	names       map[string]string // NC session ID → display name
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	fetching    bool
+}
+
+// SetSpeakerNameResolver enables speaker names on transcript messages.
+// Must be called before the client connects.
+func (sc *SpreedClient) SetSpeakerNameResolver(resolve SpeakerNameResolver) {
+	sc.speakers = &speakerNames{resolve: resolve, roomToken: sc.roomToken, logger: sc.logger}
+}
+
+// SpeakerName returns the display name of the speaker with the given HPB
+// session ID, or "" if unknown or speaker names are disabled.
+func (sc *SpreedClient) SpeakerName(hpbSid string) string {
+	if sc.speakers == nil {
+		return ""
+	}
+
+	sc.targetMu.Lock()
+	ncSid := sc.hpbSidMap[hpbSid]
+	sc.targetMu.Unlock()
+	if ncSid == "" {
+		return ""
+	}
+
+	return sc.speakers.lookup(ncSid)
+}
+
+func (sn *speakerNames) lookup(ncSid string) string {
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+
+	name, ok := sn.names[ncSid]
+	// Participants join and rename themselves, so expired names are refreshed
+	stale := time.Since(sn.fetchedAt) > constants.SpeakerNameCacheTTL
+	if (!ok || stale) && !sn.fetching && time.Since(sn.lastAttempt) > constants.SpeakerNameRefreshInterval {
+		sn.fetching = true
+		go sn.refresh()
+	}
+	return name
+}
+
+func (sn *speakerNames) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), constants.SpeakerNameFetchTimeout)
+	defer cancel()
+
+	names, err := sn.resolve(ctx, sn.roomToken)
+
+	sn.mu.Lock()
+	defer sn.mu.Unlock()
+	sn.fetching = false
+	sn.lastAttempt = time.Now()
+	if err != nil {
+		sn.logger.Warn("failed to fetch speaker names", "error", err)
+		return
+	}
+	sn.names = names
+	sn.fetchedAt = sn.lastAttempt
+}
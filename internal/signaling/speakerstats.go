@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package signaling
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// SpeakerAudioStats describe the audio received from one speaker, to tell
+// a silent or very quiet speaker, or one whose audio fails to decode, apart
+// from a transcription problem.
+type SpeakerAudioStats struct {
+	Packets      int64     `json:"packets"`
+	DecodeErrors int64     `json:"decode_errors"`
+	LastAudio    time.Time `json:"last_audio,omitzero"`
+	// RMS is a moving average of the audio level, from 0 for silence to
+	// 1 for full scale. Speech is usually above 0.01.
+	RMS float64 `json:"rms"`
+}
+
+// trackStats counts the audio of a track. Only its reader writes them, so
+// plain atomic stores suffice.
+type trackStats struct {
+	packets      atomic.Int64
+	decodeErrors atomic.Int64
+	lastAudio    atomic.Int64  // unix nanoseconds
+	rms          atomic.Uint64 // math.Float64bits of the moving average
+}
+
+// addAudio records decoded mono samples.
+func (ts *trackStats) addAudio(samples []int16) {
+	if len(samples) == 0 {
+		return
+	}
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	rms := math.Sqrt(sum/float64(len(samples))) / 32768
+
+	prev := math.Float64frombits(ts.rms.Load())
+	ts.rms.Store(math.Float64bits(prev + constants.AudioLevelSmoothing*(rms-prev)))
+	ts.lastAudio.Store(time.Now().UnixNano())
+}
+
+func (ts *trackStats) snapshot() SpeakerAudioStats {
+	s := SpeakerAudioStats{
+		Packets:      ts.packets.Load(),
+		DecodeErrors: ts.decodeErrors.Load(),
+		RMS:          math.Float64frombits(ts.rms.Load()),
+	}
+	if last := ts.lastAudio.Load(); last > 0 {
+		s.LastAudio = time.Unix(0, last)
+	}
+	return s
+}
+
+// addTrackStats registers the statistics of a speaker's new track, which
+// replace those of an earlier one.
+func (sc *SpreedClient) addTrackStats(sessionID string) *trackStats {
+	ts := &trackStats{}
+	sc.peerConnsMu.Lock()
+	defer sc.peerConnsMu.Unlock()
+	if sc.trackStats == nil {
+		sc.trackStats = make(map[string]*trackStats)
+	}
+	sc.trackStats[sessionID] = ts
+	return ts
+}
+
+// removeTrackStats drops the statistics of a stopped track, unless a newer
+// track of the speaker replaced them. It reports whether the track was the
+// speaker's current one.
+func (sc *SpreedClient) removeTrackStats(sessionID string, ts *trackStats) (current bool) {
+	sc.peerConnsMu.Lock()
+	defer sc.peerConnsMu.Unlock()
+	if sc.trackStats[sessionID] != ts {
+		return false
+	}
+	delete(sc.trackStats, sessionID)
+	return true
+}
+
+// SpeakerStats returns the audio statistics of the speakers with a running
+// audio track, keyed by session ID.
+func (sc *SpreedClient) SpeakerStats() map[string]SpeakerAudioStats {
+	sc.peerConnsMu.Lock()
+	defer sc.peerConnsMu.Unlock()
+	stats := make(map[string]SpeakerAudioStats, len(sc.trackStats))
+	for sid, ts := range sc.trackStats {
+		stats[sid] = ts.snapshot()
+	}
+	return stats
+}
@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package signaling
+
+import (
+	"context"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// traceMessage logs the type and ID of a signaling message at trace level.
+// Only the kind of the message is logged, never its payload, so hello auth
+// tokens, SDPs and transcripts stay out of the logs.
+func (sc *SpreedClient) traceMessage(direction string, msg *SignalingMessage) {
+	if !sc.logger.Enabled(context.Background(), constants.LogLevelTrace) {
+		return
+	}
+	sc.logger.Log(context.Background(), constants.LogLevelTrace, "signaling message",
+		"direction", direction,
+		"type", msg.Type,
+		"subtype", messageSubtype(msg),
+		"id", msg.ID,
+	)
+}
+
+// messageSubtype returns what the message carries within its type, e.g. the
+// event type or error code.
+func messageSubtype(msg *SignalingMessage) string {
+	switch {
+	case msg.Message != nil && msg.Message.Data != nil:
+		return msg.Message.Data.Type
+	case msg.Event != nil:
+		return msg.Event.Target + "/" + msg.Event.Type
+	case msg.Internal != nil:
+		return msg.Internal.Type
+	case msg.Error != nil:
+		return msg.Error.Code
+	case msg.Hello != nil && msg.Hello.ResumeID != "" && msg.Hello.Auth == nil:
+		return "resume"
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package signaling
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsConn is the part of a websocket connection the client uses. Production
+// uses *websocket.Conn; tests can substitute a fake transport through
+// SpreedClient.dialer to drive handshake, resume, bye and error flows
+// without an HPB.
+type wsConn interface {
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+	Close() error
+}
+
+// dialFunc opens a websocket connection to the HPB.
+type dialFunc func(ctx context.Context) (wsConn, error)
+
+// dialWebSocket connects to the HPB with gorilla/websocket, through the
+// configured proxy and with the HPB's TLS settings.
+func (sc *SpreedClient) dialWebSocket(ctx context.Context) (wsConn, error) {
+	dialer := websocket.Dialer{
+		Proxy:            sc.proxy,
+		HandshakeTimeout: 30 * time.Second,
+	}
+
+	parsedURL, _ := url.Parse(sc.wsURL)
+	if parsedURL != nil && parsedURL.Scheme == "wss" {
+		dialer.TLSClientConfig = sc.tlsConfig
+	}
+
+	conn, _, err := dialer.DialContext(ctx, sc.wsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("websocket dial: %w", err)
+	}
+	return conn, nil
+}
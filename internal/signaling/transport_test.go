@@ -0,0 +1,455 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+)
+
+// fakeConn is one websocket connection to a fake HPB: the client reads
+// what the HPB sends on in and writes to out.
+type fakeConn struct {
+	t      *testing.T
+	in     chan []byte
+	out    chan SignalingMessage
+	closed chan struct{}
+	once   sync.Once
+
+	mu       sync.Mutex
+	deadline time.Time
+	pong     func(string) error
+	pings    int
+	answer   bool // answer pings with pongs
+}
+
+func newFakeConn(t *testing.T) *fakeConn {
+	return &fakeConn{
+		t:      t,
+		in:     make(chan []byte, 10),
+		out:    make(chan SignalingMessage, 100),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *fakeConn) ReadMessage() (int, []byte, error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case data := <-c.in:
+		return websocket.TextMessage, data, nil
+	case <-c.closed:
+		return 0, nil, net.ErrClosed
+	case <-timeout:
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+func (c *fakeConn) WriteMessage(_ int, data []byte) error {
+	select {
+	case <-c.closed:
+		return net.ErrClosed
+	default:
+	}
+	var msg SignalingMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	c.out <- msg
+	return nil
+}
+
+func (c *fakeConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (c *fakeConn) SetPongHandler(h func(string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pong = h
+}
+
+func (c *fakeConn) WriteControl(messageType int, _ []byte, _ time.Time) error {
+	if messageType != websocket.PingMessage {
+		return nil
+	}
+	c.mu.Lock()
+	c.pings++
+	pong, answer := c.pong, c.answer
+	c.mu.Unlock()
+	if answer && pong != nil {
+		return pong("")
+	}
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+// send delivers a message from the HPB.
+func (c *fakeConn) send(msg SignalingMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Error(err)
+		return
+	}
+	c.in <- data
+}
+
+// expect reads the client's next message and checks its type.
+func (c *fakeConn) expect(typ string) SignalingMessage {
+	select {
+	case msg := <-c.out:
+		if msg.Type != typ {
+			c.t.Errorf("client sent %s, want %s", msg.Type, typ)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Errorf("client sent no %s", typ)
+		return SignalingMessage{}
+	}
+}
+
+// fakeHPB answers the connections of a client, each with the next script.
+type fakeHPB struct {
+	mu      sync.Mutex
+	scripts []func(*fakeConn)
+	dials   int
+}
+
+func (h *fakeHPB) dial(t *testing.T) dialFunc {
+	return func(context.Context) (wsConn, error) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.dials >= len(h.scripts) {
+			return nil, errors.New("no more connections")
+		}
+		conn := newFakeConn(t)
+		go h.scripts[h.dials](conn)
+		h.dials++
+		return conn, nil
+	}
+}
+
+func newFakeHPBClient(t *testing.T, scripts ...func(*fakeConn)) (*SpreedClient, *fakeHPB) {
+	t.Helper()
+	cfg := &appapi.Config{
+		HPBUrl:              "https://hpb.example",
+		InternalSecret:      "secret",
+		SignalingBackendURL: "https://nc.example",
+		AudioBufferFrames:   1,
+	}
+	sc := NewSpreedClient("room", &HPBSettings{}, "en", cfg, nil)
+	hpb := &fakeHPB{scripts: scripts}
+	sc.dialer = hpb.dial(t)
+	t.Cleanup(sc.Close)
+	return sc, hpb
+}
+
+// welcome answers the client's hello with a welcome and a new session.
+func welcome(c *fakeConn) {
+	c.expect("hello")
+	c.send(SignalingMessage{Type: "welcome"})
+	c.send(SignalingMessage{Type: "hello", Hello: &HelloMessage{SessionID: "sid", ResumeID: "rid"}})
+}
+
+func answerError(code string) func(*fakeConn) {
+	return func(c *fakeConn) {
+		c.expect("hello")
+		c.send(SignalingMessage{Type: "error", Error: &ErrorMessage{Code: code}})
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	hello := make(chan SignalingMessage, 1)
+	joined := make(chan struct{})
+	sc, _ := newFakeHPBClient(t, func(c *fakeConn) {
+		msg := c.expect("hello")
+		hello <- msg
+		c.send(SignalingMessage{Type: "welcome"})
+		c.send(SignalingMessage{Type: "hello", Hello: &HelloMessage{SessionID: "sid", ResumeID: "rid"}})
+		if msg := c.expect("internal"); msg.Internal == nil || msg.Internal.Type != "incall" {
+			t.Errorf("client sent %+v, want incall", msg.Internal)
+		}
+		c.expect("room")
+		close(joined)
+	})
+
+	res, err := sc.Connect(context.Background(), NoReconnect)
+	if res != SigConnectSuccess || err != nil {
+		t.Fatalf("Connect = %v, %v", res, err)
+	}
+	<-joined
+
+	msg := <-hello
+	auth := msg.Hello.Auth
+	if auth == nil || auth.Type != "internal" || auth.Params == nil {
+		t.Fatalf("hello auth = %+v, want internal", auth)
+	}
+	if auth.Params.Token != hmacSHA256("secret", auth.Params.Random) || auth.Params.Backend != "https://nc.example" {
+		t.Errorf("hello auth params = %+v", auth.Params)
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.sessionID != "sid" || sc.resumeID != "rid" {
+		t.Errorf("session %q resume %q, want sid and rid", sc.sessionID, sc.resumeID)
+	}
+}
+
+func TestHandshakeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  func(*fakeConn)
+		wantRes SigConnectResult
+		wantErr error
+	}{
+		{"bye", func(c *fakeConn) {
+			c.expect("hello")
+			c.send(SignalingMessage{Type: "bye", Bye: &ByeMessage{}})
+		}, SigConnectFailure, ErrReceivedBye},
+		{"room join failed", answerError("room_join_failed"), SigConnectRetry, ErrRoomJoinFailed},
+		{"rate limited", answerError("too_many_requests"), SigConnectRetry, ErrRateLimited},
+		{"other error", answerError("invalid_token"), SigConnectFailure, ErrSignaling},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, _ := newFakeHPBClient(t, tt.script)
+			res, err := sc.Connect(context.Background(), NoReconnect)
+			if res != tt.wantRes || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Connect = %v, %v, want %v, %v", res, err, tt.wantRes, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandshakeRetriesDuplicateSession(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for DuplicateSessionRetryDelay")
+	}
+	sc, hpb := newFakeHPBClient(t, answerError("duplicate_session"), welcome)
+	res, err := sc.Connect(context.Background(), NoReconnect)
+	if res != SigConnectSuccess || err != nil {
+		t.Fatalf("Connect = %v, %v", res, err)
+	}
+	if hpb.dials != 2 {
+		t.Errorf("%d connections, want a new one for the retry", hpb.dials)
+	}
+}
+
+func TestShortResume(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  SignalingMessage
+		wantRes SigConnectResult
+		wantErr error
+	}{
+		{"resumed", SignalingMessage{Type: "hello", Hello: &HelloMessage{SessionID: "sid2"}}, SigConnectSuccess, nil},
+		{"session gone", SignalingMessage{Type: "error", Error: &ErrorMessage{Code: "no_such_session"}}, SigConnectRetry, nil},
+		{"rate limited", SignalingMessage{Type: "error", Error: &ErrorMessage{Code: "too_many_requests"}}, SigConnectFailure, ErrRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, _ := newFakeHPBClient(t, func(c *fakeConn) {
+				msg := c.expect("hello")
+				if msg.Hello == nil || msg.Hello.ResumeID != "rid" || msg.Hello.Auth != nil {
+					t.Errorf("hello = %+v, want a resume of rid", msg.Hello)
+				}
+				c.send(tt.answer)
+			})
+			sc.resumeID = "rid"
+			res, err := sc.Connect(context.Background(), ShortResume)
+			if res != tt.wantRes || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Connect = %v, %v, want %v, %v", res, err, tt.wantRes, tt.wantErr)
+			}
+		})
+	}
+}
+
+// joined answers the hello of a new session and the join after it.
+func joined(c *fakeConn) {
+	welcome(c)
+	c.expect("internal")
+	c.expect("room")
+}
+
+// resumed answers the short resume of session rid and the join after it.
+func resumed(done chan<- struct{}) func(*fakeConn) {
+	return func(c *fakeConn) {
+		msg := c.expect("hello")
+		if msg.Hello == nil || msg.Hello.ResumeID != "rid" {
+			c.t.Errorf("hello = %+v, want a resume of rid", msg.Hello)
+		}
+		c.send(SignalingMessage{Type: "hello", Hello: &HelloMessage{SessionID: "sid2"}})
+		c.expect("internal")
+		c.expect("room")
+		close(done)
+	}
+}
+
+func waitReconnected(t *testing.T, sc *SpreedClient, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("client did not reconnect")
+	}
+	if sc.IsDefunct() {
+		t.Error("client is defunct after reconnecting")
+	}
+}
+
+func TestReconnectResumes(t *testing.T) {
+	done := make(chan struct{})
+	sc, _ := newFakeHPBClient(t, func(c *fakeConn) {
+		joined(c)
+		_ = c.Close() // the HPB drops the connection
+	}, resumed(done))
+	if res, err := sc.Connect(context.Background(), NoReconnect); res != SigConnectSuccess || err != nil {
+		t.Fatalf("Connect = %v, %v", res, err)
+	}
+	waitReconnected(t, sc, done)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.sessionID != "sid2" {
+		t.Errorf("session %q, want the resumed sid2", sc.sessionID)
+	}
+}
+
+func TestReconnectRetriesDuplicateSession(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for DuplicateSessionRetryDelay")
+	}
+	done := make(chan struct{})
+	sc, hpb := newFakeHPBClient(t, func(c *fakeConn) {
+		joined(c)
+		_ = c.Close()
+	}, func(c *fakeConn) {
+		c.expect("hello")
+		c.send(SignalingMessage{Type: "error", Error: &ErrorMessage{Code: "no_such_session"}})
+		c.expect("hello")
+		c.send(SignalingMessage{Type: "error", Error: &ErrorMessage{Code: "duplicate_session"}})
+	}, func(c *fakeConn) {
+		joined(c)
+		close(done)
+	})
+	if res, err := sc.Connect(context.Background(), NoReconnect); res != SigConnectSuccess || err != nil {
+		t.Fatalf("Connect = %v, %v", res, err)
+	}
+	waitReconnected(t, sc, done)
+
+	hpb.mu.Lock()
+	defer hpb.mu.Unlock()
+	if hpb.dials != 3 {
+		t.Errorf("%d connections, want a new one for the duplicate session", hpb.dials)
+	}
+}
+
+func TestKeepaliveTimeoutReconnects(t *testing.T) {
+	first := make(chan *fakeConn, 1)
+	done := make(chan struct{})
+	sc, _ := newFakeHPBClient(t, func(c *fakeConn) {
+		joined(c)
+		first <- c // then the connection goes silent, not even answering pings
+	}, resumed(done))
+	sc.pingInterval = 10 * time.Millisecond
+	sc.readTimeout = 200 * time.Millisecond
+	if res, err := sc.Connect(context.Background(), NoReconnect); res != SigConnectSuccess || err != nil {
+		t.Fatalf("Connect = %v, %v", res, err)
+	}
+	waitReconnected(t, sc, done)
+
+	c := <-first
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pings == 0 {
+		t.Error("client sent no pings")
+	}
+}
+
+func TestCloseEndsTranscription(t *testing.T) {
+	conns := make(chan *fakeConn, 1)
+	sc, _ := newFakeHPBClient(t, func(c *fakeConn) {
+		joined(c)
+		conns <- c
+	})
+	if res, err := sc.Connect(context.Background(), NoReconnect); res != SigConnectSuccess || err != nil {
+		t.Fatalf("Connect = %v, %v", res, err)
+	}
+	c := <-conns
+	sc.targetMu.Lock()
+	sc.addTargetLocked("hpb1")
+	sc.addTargetLocked("hpb2")
+	sc.targetMu.Unlock()
+
+	if !sc.CloseWithReason(CloseReasonLeft) {
+		t.Fatal("CloseWithReason did not close the client")
+	}
+	ended := make(map[string]bool)
+	for range 2 {
+		msg := c.expect("message")
+		if msg.Message == nil || msg.Message.Recipient == nil || msg.Message.Data == nil ||
+			msg.Message.Data.Type != "transcriptionEnded" {
+			t.Fatalf("client sent %+v, want transcriptionEnded", msg.Message)
+		}
+		ended[msg.Message.Recipient.SessionID] = true
+	}
+	if !ended["hpb1"] || !ended["hpb2"] {
+		t.Errorf("transcriptionEnded sent to %v, want hpb1 and hpb2", ended)
+	}
+	c.expect("bye")
+}
+
+func TestDialThroughProxy(t *testing.T) {
+	connects := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			connects <- r.Host
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(proxy.Close)
+
+	sc, _ := newFakeHPBClient(t)
+	proxyURL, _ := url.Parse(proxy.URL)
+	sc.proxy = http.ProxyURL(proxyURL)
+	if _, err := sc.dialWebSocket(context.Background()); err == nil {
+		t.Fatal("dialed past a proxy refusing the tunnel")
+	}
+	select {
+	case host := <-connects:
+		if host != "hpb.example:443" {
+			t.Errorf("proxy tunnel to %s, want hpb.example:443", host)
+		}
+	default:
+		t.Error("HPB dialed without the proxy")
+	}
+}
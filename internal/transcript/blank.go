@@ -0,0 +1,19 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package transcript
+
+import "unicode"
+
+// IsBlank reports whether text has nothing to caption: it is empty or
+// consists only of whitespace, punctuation and symbols. The Unicode classes
+// cover the full-width spaces and punctuation of languages written without
+// word separators (e.g. "。" or U+3000), so no per-language table is needed.
+func IsBlank(text string) bool {
+	for _, r := range text {
+		if !unicode.IsSpace(r) && !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+			return false
+		}
+	}
+	return true
+}
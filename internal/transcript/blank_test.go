@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package transcript
+
+import "testing"
+
+func TestIsBlank(t *testing.T) {
+	tests := []struct {
+		lang, text string
+		want       bool
+	}{
+		{"en", "", true},
+		{"en", " \t\n", true},
+		{"en", "...", true},
+		{"en", "- ?!", true},
+		{"en", "ok.", false},
+		{"de", "„…“", true},
+		{"de", "Ähm.", false},
+		{"fr", " « » ", true},
+		{"es", "¿¡!?", true},
+		{"ru", "—", true},
+		{"ru", "да", false},
+		{"ar", "؟،", true},
+		{"ja", "　。、", true},
+		{"ja", "「」", true},
+		{"ja", "はい。", false},
+		{"zh", "，。！", true},
+		{"zh", "好", false},
+		{"hi", "।", true},
+		{"en", "€ + $", true},
+		{"en", "42", false},
+	}
+	for _, tt := range tests {
+		if got := IsBlank(tt.text); got != tt.want {
+			t.Errorf("%s: IsBlank(%q) = %v, want %v", tt.lang, tt.text, got, tt.want)
+		}
+	}
+}
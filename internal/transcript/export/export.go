@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+// Package export converts recorded transcripts into subtitle formats.
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/transcript"
+)
+
+// minCueDuration keeps cues readable when an utterance has no usable start
+// time, e.g. a single final without preceding partials.
+const minCueDuration = time.Second
+
+type cue struct {
+	start, end time.Duration // relative to the first segment
+	speaker    string
+	text       string
+}
+
+// ReadSegments parses a recorded JSONL transcript.
+func ReadSegments(r io.Reader) ([]transcript.RecordedSegment, error) {
+	var segments []transcript.RecordedSegment
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var seg transcript.RecordedSegment
+		if err := json.Unmarshal(scanner.Bytes(), &seg); err != nil {
+			return nil, fmt.Errorf("parse line %d: %w", line, err)
+		}
+		segments = append(segments, seg)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+	return segments, nil
+}
+
+// buildCues orders segments by start time, so that utterances of speakers
+// talking over each other interleave, and makes the times relative to the
+// earliest one.
+func buildCues(segments []transcript.RecordedSegment) []cue {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	segs := slices.Clone(segments)
+	for i := range segs {
+		if segs[i].Start.IsZero() || segs[i].Start.After(segs[i].Time) {
+			segs[i].Start = segs[i].Time.Add(-minCueDuration)
+		}
+	}
+	slices.SortStableFunc(segs, func(a, b transcript.RecordedSegment) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	origin := segs[0].Start
+	cues := make([]cue, 0, len(segs))
+	for _, seg := range segs {
+		start := seg.Start.Sub(origin)
+		end := max(seg.Time.Sub(origin), start+minCueDuration)
+		speaker := seg.SpeakerName
+		if speaker == "" {
+			speaker = seg.SpeakerSessionID
+		}
+		cues = append(cues, cue{start: start, end: end, speaker: speaker, text: seg.Text})
+	}
+	return cues
+}
+
+// WriteVTT writes the segments as WebVTT, with the speaker as voice span.
+func WriteVTT(w io.Writer, segments []transcript.RecordedSegment) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, "WEBVTT\n")
+	for i, c := range buildCues(segments) {
+		fmt.Fprintf(bw, "\n%d\n%s --> %s\n<v %s>%s\n",
+			i+1,
+			formatTimestamp(c.start, '.'),
+			formatTimestamp(c.end, '.'),
+			escapeVTT(c.speaker),
+			escapeVTT(c.text),
+		)
+	}
+	return bw.Flush()
+}
+
+// WriteSRT writes the segments as SubRip, prefixing the text with the speaker.
+func WriteSRT(w io.Writer, segments []transcript.RecordedSegment) error {
+	bw := bufio.NewWriter(w)
+	for i, c := range buildCues(segments) {
+		if i > 0 {
+			fmt.Fprint(bw, "\n")
+		}
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s: %s\n",
+			i+1,
+			formatTimestamp(c.start, ','),
+			formatTimestamp(c.end, ','),
+			c.speaker,
+			c.text,
+		)
+	}
+	return bw.Flush()
+}
+
+// formatTimestamp renders hh:mm:ss followed by sep and milliseconds. WebVTT
+// uses '.', SRT ','.
+func formatTimestamp(d time.Duration, sep byte) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\n", " ")
+
+func escapeVTT(s string) string {
+	return vttEscaper.Replace(s)
+}
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package export
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/transcript"
+)
+
+func readFixture(t *testing.T) []transcript.RecordedSegment {
+	t.Helper()
+	f, err := os.Open("testdata/meeting.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	segments, err := ReadSegments(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return segments
+}
+
+// The fixture has overlapping speakers recorded out of order, a final
+// without start time, cues shorter than minCueDuration, a speaker without
+// name, text to escape and a cue past the first hour.
+func TestExportFixture(t *testing.T) {
+	segments := readFixture(t)
+	if len(segments) != 5 {
+		t.Fatalf("read %d segments, want 5 without the blank line", len(segments))
+	}
+
+	for _, tt := range []struct {
+		golden string
+		write  func(io.Writer, []transcript.RecordedSegment) error
+	}{
+		{"testdata/meeting.vtt", WriteVTT},
+		{"testdata/meeting.srt", WriteSRT},
+	} {
+		want, err := os.ReadFile(tt.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got bytes.Buffer
+		if err := tt.write(&got, segments); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != string(want) {
+			t.Errorf("%s mismatch:\n%s\nwant:\n%s", tt.golden, got.String(), want)
+		}
+	}
+}
+
+var cueTimes = regexp.MustCompile(`(\d\d):(\d\d):(\d\d)[.,](\d{3}) --> (\d\d):(\d\d):(\d\d)[.,](\d{3})`)
+
+func TestCueTimesMonotonic(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteSRT(&out, readFixture(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	parse := func(h, m, s, ms string) time.Duration {
+		d, _ := time.ParseDuration(h + "h" + m + "m" + s + "s" + ms + "ms")
+		return d
+	}
+	var prev time.Duration
+	matches := cueTimes.FindAllStringSubmatch(out.String(), -1)
+	for i, m := range matches {
+		start, end := parse(m[1], m[2], m[3], m[4]), parse(m[5], m[6], m[7], m[8])
+		if start < prev {
+			t.Errorf("cue %d starts at %s, before the previous cue at %s", i+1, start, prev)
+		}
+		if end-start < minCueDuration {
+			t.Errorf("cue %d lasts only %s", i+1, end-start)
+		}
+		prev = start
+	}
+	if len(matches) != 5 {
+		t.Errorf("%d cues, want 5", len(matches))
+	}
+}
+
+func TestReadSegmentsRejectsMalformedLine(t *testing.T) {
+	_, err := ReadSegments(bytes.NewBufferString("{\"text\":\"ok\"}\nnot json\n"))
+	if err == nil || !regexp.MustCompile(`line 2`).MatchString(err.Error()) {
+		t.Errorf("error %v, want one naming line 2", err)
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	d := 2*time.Hour + 3*time.Minute + 4*time.Second + 5*time.Millisecond
+	if got := formatTimestamp(d, ','); got != "02:03:04,005" {
+		t.Errorf("formatTimestamp = %q, want 02:03:04,005", got)
+	}
+	if got := formatTimestamp(0, '.'); got != "00:00:00.000" {
+		t.Errorf("formatTimestamp(0) = %q", got)
+	}
+}
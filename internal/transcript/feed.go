@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package transcript
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// FeedEvent is a transcript or translation as pushed to feed subscribers.
+type FeedEvent struct {
+	Type             string `json:"type"` // "transcript", "translation" or "call_ended"
+	LangID           string `json:"langId"`
+	Message          string `json:"message"`
+	Final            bool   `json:"final"`
+	SpeakerSessionID string `json:"speakerSessionId,omitempty"`
+	SpeakerName      string `json:"speakerName,omitempty"`
+	OriginLangID     string `json:"originLangId,omitempty"` // translations only
+	StartMs          int64  `json:"startMs,omitempty"`
+	EndMs            int64  `json:"endMs,omitempty"`
+	Reason           string `json:"reason,omitempty"` // call_ended only
+}
+
+// Feed fans a room's transcripts and translations out to subscribers that
+// consume them directly instead of through the HPB. Publishing never
+// blocks: a subscriber too slow to keep up loses partials, and is closed
+// when even a final does not fit, so it can reconnect rather than silently
+// miss text.
+type Feed struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription receives the events of a feed on C until it is closed by
+// Unsubscribe, the feed closing, or falling behind.
+type Subscription struct {
+	C    chan FeedEvent
+	feed *Feed
+	once sync.Once
+}
+
+func NewFeed() *Feed {
+	return &Feed{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a new subscription, or nil if the feed is closed.
+func (f *Feed) Subscribe() *Subscription {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	sub := &Subscription{C: make(chan FeedEvent, constants.FeedBufferSize), feed: f}
+	f.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe stops the subscription and closes C.
+func (s *Subscription) Unsubscribe() {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	delete(s.feed.subs, s)
+	s.close()
+}
+
+// HasSubscribers reports whether anyone consumes the feed, so publishers
+// can skip building events nobody reads.
+func (f *Feed) HasSubscribers() bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subs) > 0
+}
+
+// Publish pushes the event to all subscribers without blocking.
+func (f *Feed) Publish(ev FeedEvent) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sub := range f.subs {
+		select {
+		case sub.C <- ev:
+		default:
+			if ev.Final {
+				slog.Warn("transcript feed subscriber too slow, disconnecting")
+				delete(f.subs, sub)
+				sub.close()
+			}
+		}
+	}
+}
+
+// Close closes all subscriptions and rejects new ones.
+func (f *Feed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for sub := range f.subs {
+		sub.close()
+	}
+	clear(f.subs)
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() { close(s.C) })
+}
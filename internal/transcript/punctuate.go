@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package transcript
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nextcloud/go_live_transcription/internal/languages"
+)
+
+// Punctuator restores the capitalization and sentence punctuation of a
+// final segment, which Vosk transcribes lowercase and unpunctuated. It must
+// fall back to the text's heuristic punctuation rather than fail. Except for
+// HeuristicPunctuator the sender runs it in the background, delaying the
+// captions of the segment's speaker until it returns.
+type Punctuator interface {
+	Punctuate(ctx context.Context, langID, text string) string
+}
+
+// HeuristicPunctuator punctuates with Punctuate, without any provider.
+type HeuristicPunctuator struct{}
+
+func (HeuristicPunctuator) Punctuate(_ context.Context, langID, text string) string {
+	return Punctuate(langID, text)
+}
+
+// englishQuestionWords start the English segments ended with a question
+// mark instead of a full stop.
+var englishQuestionWords = []string{
+	"are", "can", "could", "did", "do", "does", "how", "is", "shall",
+	"should", "what", "when", "where", "which", "who", "whom", "whose",
+	"why", "will", "would",
+}
+
+// Punctuate capitalizes the first letter of a segment, in English also the
+// pronoun "I", and ends it with a full stop unless it already ends a
+// sentence. English questions get a question mark and languages written
+// without spaces an ideographic full stop.
+func Punctuate(langID, text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return text
+	}
+
+	base, _, _ := strings.Cut(langID, "_")
+	words := strings.Fields(text)
+	if base == "en" {
+		for i, w := range words {
+			if w == "i" || strings.HasPrefix(w, "i'") {
+				words[i] = "I" + w[1:]
+			}
+		}
+		text = strings.Join(words, " ")
+	}
+
+	first, size := utf8.DecodeRuneInString(text)
+	text = string(unicode.ToUpper(first)) + text[size:]
+
+	last, _ := utf8.DecodeLastRuneInString(text)
+	switch {
+	case unicode.Is(unicode.Sentence_Terminal, last):
+	case base == "en" && slices.Contains(englishQuestionWords, strings.ToLower(words[0])):
+		text += "?"
+	case languages.Separator(langID) == "":
+		text += "。"
+	default:
+		text += "."
+	}
+	return text
+}
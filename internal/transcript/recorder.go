@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package transcript
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+)
+
+var ErrInvalidRoomToken = errors.New("invalid room token")
+
+// Talk room tokens are alphanumeric; anything else must not reach the
+// file system.
+var roomTokenRe = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+// RecordedSegment is one line of a recorded transcript file. Start is when
+// the first partial of the utterance arrived, Time when it was finalized.
+type RecordedSegment struct {
+	Start            time.Time `json:"start,omitzero"`
+	Time             time.Time `json:"time"`
+	SpeakerSessionID string    `json:"speaker_session_id"`
+	SpeakerName      string    `json:"speaker_name,omitempty"`
+	LangID           string    `json:"lang"`
+	Text             string    `json:"text"`
+}
+
+// WebhookSegment is a final transcript posted to the transcript webhook.
+// StartMs and EndMs are the recognizer's offsets in the speaker's audio.
+type WebhookSegment struct {
+	RoomToken string `json:"room_token"`
+	RecordedSegment
+	StartMs int64 `json:"start_ms"`
+	EndMs   int64 `json:"end_ms"`
+}
+
+// Recorder appends the final transcripts of a room to a JSONL file.
+type Recorder struct {
+	mu     sync.Mutex
+	f      *os.File
+	enc    *json.Encoder
+	logger *slog.Logger
+}
+
+func transcriptDir(storageDir string) string {
+	return filepath.Join(storageDir, "transcripts")
+}
+
+// TranscriptPath returns the file a room's transcript is recorded to in
+// the persistent storage.
+func TranscriptPath(storageDir, roomToken string) (string, error) {
+	if !roomTokenRe.MatchString(roomToken) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidRoomToken, roomToken)
+	}
+	return filepath.Join(transcriptDir(storageDir), roomToken+".jsonl"), nil
+}
+
+// OpenRecorder opens the room's transcript file for appending, removing
+// transcripts older than constants.TranscriptRetention first. A call that
+// is recorded again continues the existing file.
+func OpenRecorder(storageDir, roomToken string) (*Recorder, error) {
+	path, err := TranscriptPath(storageDir, roomToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(transcriptDir(storageDir), 0o755); err != nil {
+		return nil, fmt.Errorf("create transcript dir: %w", err)
+	}
+	cleanupTranscripts(transcriptDir(storageDir), constants.TranscriptRetention)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open transcript file: %w", err)
+	}
+	return &Recorder{
+		f:      f,
+		enc:    json.NewEncoder(f),
+		logger: slog.With("component", "transcript_recorder", "room_token", roomToken),
+	}, nil
+}
+
+// Record appends a final transcript that started at start. Partials and
+// empty segments are ignored.
+func (r *Recorder) Record(t signaling.Transcript, start time.Time) {
+	if !t.Final || t.Message == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return
+	}
+	err := r.enc.Encode(RecordedSegment{
+		Start:            start.UTC(),
+		Time:             time.Now().UTC(),
+		SpeakerSessionID: t.SpeakerSessionID,
+		SpeakerName:      t.SpeakerName,
+		LangID:           t.LangID,
+		Text:             t.Message,
+	})
+	if err != nil {
+		r.logger.Error("failed to record transcript", "error", err)
+	}
+}
+
+// Close flushes the file to disk and closes it. It is safe to call twice.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Sync()
+	if closeErr := r.f.Close(); err == nil {
+		err = closeErr
+	}
+	r.f = nil
+	return err
+}
+
+func cleanupTranscripts(dir string, retention time.Duration) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		slog.Warn("failed to list transcripts", "error", err, "dir", dir)
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() || time.Since(info.ModTime()) < retention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			slog.Warn("failed to remove old transcript", "error", err, "file", e.Name())
+			continue
+		}
+		slog.Info("removed old transcript", "file", e.Name())
+	}
+}
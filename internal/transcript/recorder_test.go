@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+)
+
+func readRecorded(t *testing.T, path string) []RecordedSegment {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var segments []RecordedSegment
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var seg RecordedSegment
+		if err := json.Unmarshal(scanner.Bytes(), &seg); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		segments = append(segments, seg)
+	}
+	return segments
+}
+
+func TestTranscriptPath(t *testing.T) {
+	if path, err := TranscriptPath("/data", "abc123XYZ"); err != nil || path != "/data/transcripts/abc123XYZ.jsonl" {
+		t.Errorf("TranscriptPath = %q, %v", path, err)
+	}
+	for _, token := range []string{"", "../abc", "a/b", "abc.jsonl", "abc def"} {
+		if _, err := TranscriptPath("/data", token); !errors.Is(err, ErrInvalidRoomToken) {
+			t.Errorf("TranscriptPath(%q): error %v, want ErrInvalidRoomToken", token, err)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := OpenRecorder(dir, "room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-2 * time.Second)
+	rec.Record(signaling.Transcript{Final: true, LangID: "en", Message: "hello",
+		SpeakerSessionID: "s1", SpeakerName: "Alice"}, start)
+	rec.Record(signaling.Transcript{LangID: "en", Message: "partial", SpeakerSessionID: "s1"}, start)
+	rec.Record(signaling.Transcript{Final: true, LangID: "en", SpeakerSessionID: "s1"}, start)
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Errorf("second close: %v", err)
+	}
+	rec.Record(signaling.Transcript{Final: true, Message: "after close"}, start)
+
+	// Recording the call again continues the file
+	rec, err = OpenRecorder(dir, "room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Record(signaling.Transcript{Final: true, LangID: "de", Message: "hallo", SpeakerSessionID: "s2"}, time.Time{})
+	rec.Close()
+
+	path, _ := TranscriptPath(dir, "room")
+	segments := readRecorded(t, path)
+	if len(segments) != 2 {
+		t.Fatalf("recorded %d segments, want the 2 non-empty finals: %+v", len(segments), segments)
+	}
+	first := segments[0]
+	if first.Text != "hello" || first.SpeakerName != "Alice" || first.LangID != "en" ||
+		!first.Start.Equal(start.UTC()) || first.Time.Before(first.Start) {
+		t.Errorf("first segment %+v", first)
+	}
+	if second := segments[1]; second.Text != "hallo" || !second.Start.IsZero() {
+		t.Errorf("second segment %+v", second)
+	}
+}
+
+func TestRecorderRemovesOldTranscripts(t *testing.T) {
+	dir := t.TempDir()
+	old, _ := TranscriptPath(dir, "old")
+	recent, _ := TranscriptPath(dir, "recent")
+	os.MkdirAll(filepath.Dir(old), 0o755)
+	for _, path := range []string{old, recent} {
+		if err := os.WriteFile(path, []byte("{}\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expired := time.Now().Add(-constants.TranscriptRetention - time.Hour)
+	if err := os.Chtimes(old, expired, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := OpenRecorder(dir, "room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Close()
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("expired transcript kept")
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("recent transcript removed: %v", err)
+	}
+}
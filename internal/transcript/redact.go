@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package transcript
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// spelledDigit is a digit as speech recognition writes it out in English.
+const spelledDigit = `(?:zero|oh|one|two|three|four|five|six|seven|eight|nine)`
+
+// DefaultRedactPatterns match e-mail addresses and phone numbers, both as
+// written, which punctuation and remote backends produce, and as spelled
+// out in English, which is what Vosk transcribes: "jane at example dot com"
+// and seven or more digit words in a row. Any run of seven or more digits
+// is taken for a phone number, so long amounts and codes are masked too.
+// They are always applied, LT_REDACT_PATTERNS adds to them.
+var DefaultRedactPatterns = []string{
+	`[\p{L}\p{N}._%+-]+@[\p{L}\p{N}-]+(?:\.[\p{L}\p{N}-]+)*\.\p{L}{2,}`,
+	`\+?\(?\d{1,4}\)?(?:[\s./-]?\d){6,14}`,
+	`(?i)\b(?:[\p{L}\p{N}_-]+ dot )*[\p{L}\p{N}._-]+ at [\p{L}\p{N}-]+(?: dot [\p{L}\p{N}-]+)+\b`,
+	`(?i)\b(?:(?:plus|double|triple) )?` + spelledDigit + `(?: (?:double |triple )?` + spelledDigit + `){6,14}\b`,
+}
+
+// DefaultRedactWords is the profanity masked unless LT_REDACT_WORDS gives a
+// list of its own.
+var DefaultRedactWords = []string{
+	"asshole", "bastard", "bitch", "bullshit", "cunt", "fuck", "fucked",
+	"fucker", "fucking", "motherfucker", "shit", "shitty", "wanker",
+}
+
+// Redactor masks profanity and personal data in transcripts. Patterns are
+// matched anywhere, words only as whole words, case-insensitively and
+// regardless of the transcript language. It is safe for concurrent use.
+type Redactor struct {
+	patterns []*regexp.Regexp
+	words    *regexp.Regexp // nil without words
+	mask     string
+}
+
+// NewRedactor compiles the default patterns plus the given ones and the
+// word list. Every match is replaced by mask.
+func NewRedactor(words, patterns []string, mask string) (*Redactor, error) {
+	r := &Redactor{mask: mask}
+
+	for _, p := range slices.Concat(DefaultRedactPatterns, patterns) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	if len(words) > 0 {
+		// Longest first, so a word wins over another one it starts with
+		quoted := make([]string, 0, len(words))
+		for _, w := range words {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+		slices.SortFunc(quoted, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+		r.words = regexp.MustCompile(`(?i)(?:` + strings.Join(quoted, "|") + `)`)
+	}
+	return r, nil
+}
+
+// Redact returns the text with every match replaced by the mask.
+func (r *Redactor) Redact(text string) string {
+	for _, re := range r.patterns {
+		text = re.ReplaceAllLiteralString(text, r.mask)
+	}
+	if r.words == nil {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range r.words.FindAllStringIndex(text, -1) {
+		if !isWordBoundary(text, m[0], m[1]) {
+			continue
+		}
+		b.WriteString(text[last:m[0]])
+		b.WriteString(r.mask)
+		last = m[1]
+	}
+	if b.Len() == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// isWordBoundary reports whether text[start:end] is not part of a longer
+// word. Scripts written without spaces have a boundary between any two
+// characters.
+func isWordBoundary(text string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	first, _ := utf8.DecodeRuneInString(text[start:])
+	last, _ := utf8.DecodeLastRuneInString(text[:end])
+	return (start == 0 || !continuesWord(before, first)) &&
+		(end == len(text) || !continuesWord(after, last))
+}
+
+func continuesWord(neighbour, edge rune) bool {
+	if !unicode.In(neighbour, unicode.L, unicode.M, unicode.N) {
+		return false
+	}
+	return !isUnspacedScript(neighbour) && !isUnspacedScript(edge)
+}
+
+func isUnspacedScript(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana,
+		unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar)
+}
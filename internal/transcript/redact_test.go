@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package transcript
+
+import "testing"
+
+func TestRedactDefaultPatterns(t *testing.T) {
+	r, err := NewRedactor(nil, nil, "***")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"write to jane.doe@example.com today", "write to *** today"},
+		{"call +49 30 1234567 now", "call *** now"},
+		{"call 555-123-4567", "call ***"},
+		{"the total is 1234567", "the total is ***"},
+		{"mail jane at example dot com please", "mail *** please"},
+		{"jane dot doe at mail dot example dot org", "***"},
+		{"my number is five five five one two three four five six seven", "my number is ***"},
+		{"dial plus four nine three oh double one two three four", "dial ***"},
+		{"we meet at nine", "we meet at nine"},
+		{"one two three four five six", "one two three four five six"},
+		{"look at the dot", "look at the dot"},
+		{"twenty people attended in 2026", "twenty people attended in 2026"},
+	}
+	for _, tt := range tests {
+		if got := r.Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactWords(t *testing.T) {
+	r, err := NewRedactor([]string{"darn", "darned"}, []string{`secret\sproject`}, "#")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{"darn it", "# it"},
+		{"Darned thing", "# thing"},
+		{"darning socks", "darning socks"},
+		{"the secret project", "the #"},
+		{"这是darn", "这是#"},
+	}
+	for _, tt := range tests {
+		if got := r.Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := NewRedactor(nil, []string{"("}, "#"); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
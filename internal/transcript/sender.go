@@ -6,10 +6,15 @@ package transcript
 import (
 	"context"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/languages"
 	"github.com/nextcloud/go_live_transcription/internal/signaling"
+	"github.com/nextcloud/go_live_transcription/internal/webhook"
 )
 
 type TranslationForwarder interface {
@@ -23,6 +28,63 @@ type Sender struct {
 	translateIn chan TranslateInputOutput
 	translator  TranslationForwarder
 	logger      *slog.Logger
+
+	partialTranslation bool
+	partialDebounce    time.Duration
+	partials           map[string]*partialState // key: speaker session ID
+
+	mergeGap time.Duration
+	merging  map[string]*mergingFinal // key: speaker session ID, nil without merging
+	merged   atomic.Int32             // len(merging), for Idle
+
+	recorder atomic.Pointer[Recorder]
+	redactor atomic.Pointer[Redactor]
+	feed     *Feed // nil without a transcript feed
+
+	webhook   *webhook.Poster // nil without a transcript webhook
+	roomToken string
+
+	punctuator atomic.Pointer[Punctuator]
+
+	// Finals punctuated in the background, queued per speaker in order,
+	// the first one being punctuated. Only accessed from Run.
+	punctuating  map[string][]pendingFinal
+	punctuated   chan pendingFinal
+	unpunctuated atomic.Int32 // finals in punctuating, for Idle
+
+	// Only accessed from Run, keyed by speaker session ID
+	started       map[string]time.Time // utterance start for the recorder
+	lastPartialAt map[string]time.Time // when the last partial was sent
+	heldPartials  map[string]signaling.Transcript
+
+	timeout      time.Duration // current send timeout, adapts to slow sends
+	timeoutCount int
+
+	minPartialInterval atomic.Int64 // time.Duration
+	busy               atomic.Bool  // a transcript is being handled
+}
+
+// mergingFinal is a final being joined with the next finals of its speaker.
+// Only accessed from Run.
+type mergingFinal struct {
+	t       signaling.Transcript
+	due     time.Time // when it is sent unless another final arrives
+	started time.Time // when its first final arrived
+}
+
+// pendingFinal is a final waiting for its punctuation. start is when its
+// utterance began, for the recorder.
+type pendingFinal struct {
+	t     signaling.Transcript
+	start time.Time
+}
+
+// partialState tracks the partial transcript of one speaker for the
+// partial translation mode. Only accessed from Run.
+type partialState struct {
+	prev   string    // last partial seen
+	sent   string    // last stable prefix forwarded for translation
+	sentAt time.Time // when sent was forwarded
 }
 
 func NewSender(
@@ -31,81 +93,520 @@ func NewSender(
 	translateIn chan TranslateInputOutput,
 	translator TranslationForwarder,
 ) *Sender {
-	return &Sender{
-		client:      client,
-		ch:          ch,
-		translateIn: translateIn,
-		translator:  translator,
-		logger:      slog.With("component", "transcript_sender"),
+	s := &Sender{
+		client:        client,
+		ch:            ch,
+		translateIn:   translateIn,
+		translator:    translator,
+		started:       make(map[string]time.Time),
+		lastPartialAt: make(map[string]time.Time),
+		heldPartials:  make(map[string]signaling.Transcript),
+		punctuating:   make(map[string][]pendingFinal),
+		punctuated:    make(chan pendingFinal),
+		timeout:       constants.SendTimeout,
+		logger:        slog.With("component", "transcript_sender"),
+	}
+	s.minPartialInterval.Store(int64(constants.MinTranscriptSendInterval))
+	return s
+}
+
+// SetMinPartialInterval overrides MinTranscriptSendInterval, the minimum
+// time between partials of a speaker. 0 sends every partial.
+func (s *Sender) SetMinPartialInterval(d time.Duration) {
+	s.minPartialInterval.Store(int64(d))
+}
+
+// EnablePartialTranslation makes the sender also forward the stable prefix of
+// partial transcripts for translation, at most once per debounce per speaker.
+// Must be called before Run.
+func (s *Sender) EnablePartialTranslation(debounce time.Duration) {
+	s.partialTranslation = true
+	s.partialDebounce = debounce
+	s.partials = make(map[string]*partialState)
+}
+
+// EnableFinalMerging makes the sender join the finals of a speaker that
+// follow each other within gap into one, sent once the speaker paused for gap
+// or after MaxMergedFinalDuration, so a sentence split at short pauses is
+// shown, recorded and translated whole. Partials in between are sent after
+// the text merged so far. Must be called before Run.
+func (s *Sender) EnableFinalMerging(gap time.Duration) {
+	s.mergeGap = gap
+	s.merging = make(map[string]*mergingFinal)
+}
+
+// SetFeed makes the sender publish the transcripts it sends to the feed.
+// Must be called before Run.
+func (s *Sender) SetFeed(f *Feed) {
+	s.feed = f
+}
+
+// SetWebhook makes the sender post the final transcripts of the room to p.
+// Must be called before Run.
+func (s *Sender) SetWebhook(p *webhook.Poster, roomToken string) {
+	s.webhook = p
+	s.roomToken = roomToken
+}
+
+// SetRecorder makes the sender record final transcripts, nil stops it.
+func (s *Sender) SetRecorder(r *Recorder) {
+	s.recorder.Store(r)
+}
+
+// SetRedactor makes the sender redact transcripts before they are recorded,
+// sent or forwarded for translation, nil stops it.
+func (s *Sender) SetRedactor(r *Redactor) {
+	s.redactor.Store(r)
+}
+
+// SetPunctuator makes the sender punctuate finals, after redaction and
+// before they are recorded, sent or forwarded for translation. nil stops it.
+func (s *Sender) SetPunctuator(p Punctuator) {
+	if p == nil {
+		s.punctuator.Store(nil)
+		return
 	}
+	s.punctuator.Store(&p)
+}
+
+// Idle reports whether no transcripts are queued, being handled, merged or
+// punctuated.
+func (s *Sender) Idle() bool {
+	return len(s.ch) == 0 && !s.busy.Load() && s.merged.Load() == 0 && s.unpunctuated.Load() == 0
 }
 
 func (s *Sender) Run(ctx context.Context) {
 	s.logger.Debug("transcript sender started")
 	defer s.logger.Debug("transcript sender stopped")
 
-	timeout := constants.SendTimeout
-	timeoutCount := 0
+	var flushC, mergeC <-chan time.Time
 
 	for {
+		s.busy.Store(false)
 		select {
 		case <-ctx.Done():
 			return
 		case t := <-s.ch:
+			s.busy.Store(true)
 			if s.client.IsDefunct() {
 				time.Sleep(2 * time.Second)
 				continue
 			}
 
-			// Forward final transcripts to the translation pipeline
-			if t.Final && s.translator.ShouldTranslate() {
-				select {
-				case s.translateIn <- TranslateInputOutput{
-					OriginLanguage:   t.LangID,
-					Message:          t.Message,
-					SpeakerSessionID: t.SpeakerSessionID,
-				}:
-				default:
-					s.logger.Warn("translate input channel full, dropping")
+			if r := s.redactor.Load(); r != nil {
+				t.Message = r.Redact(t.Message)
+				t.Alternatives = slices.Clone(t.Alternatives)
+				for i := range t.Alternatives {
+					t.Alternatives[i].Message = r.Redact(t.Alternatives[i].Message)
+				}
+				// A segment masked entirely may be left with nothing
+				// to caption
+				if IsBlank(t.Message) {
+					continue
+				}
+			}
+
+			if t.Final && s.merging != nil {
+				for _, f := range s.mergeFinal(t) {
+					if !s.sendFinal(ctx, f) {
+						return
+					}
 				}
+				if mergeC == nil && len(s.merging) > 0 {
+					mergeC = time.After(s.mergeGap)
+				}
+				continue
+			}
+			if t.Final {
+				if !s.sendFinal(ctx, t) {
+					return
+				}
+				continue
 			}
 
-			// For final transcripts, skip translation targets — they
-			// will receive the translated version instead.
-			var exclude func(string) bool
-			if t.Final && s.translator.ShouldTranslate() {
-				exclude = s.translator.IsTranslationTarget
+			if m := s.merging[t.SpeakerSessionID]; m != nil {
+				t.Message = joinSegments(t.LangID, m.t.Message, t.Message)
+				t.StartMs = m.t.StartMs
 			}
+			s.record(t)
 
-			done := make(chan struct{})
-			go func() {
-				s.client.SendTranscript(t, exclude)
-				close(done)
-			}()
+			// Partials replace each other, so only the latest one per
+			// speaker is sent within the min partial interval, or once
+			// the speaker's previous finals are punctuated. Finals are
+			// never held back and supersede a held partial.
+			interval := time.Duration(s.minPartialInterval.Load())
+			if len(s.punctuating[t.SpeakerSessionID]) > 0 {
+				s.heldPartials[t.SpeakerSessionID] = t
+				continue
+			}
+			if time.Since(s.lastPartialAt[t.SpeakerSessionID]) < interval {
+				s.heldPartials[t.SpeakerSessionID] = t
+				if flushC == nil {
+					flushC = time.After(interval)
+				}
+				continue
+			}
+			s.lastPartialAt[t.SpeakerSessionID] = time.Now()
 
-			select {
-			case <-done:
-				if timeoutCount > 0 {
-					timeoutCount--
+			if !s.process(ctx, t) {
+				return
+			}
+		case <-flushC:
+			flushC = nil
+			interval := time.Duration(s.minPartialInterval.Load())
+			for sid, t := range s.heldPartials {
+				if time.Since(s.lastPartialAt[sid]) < interval || len(s.punctuating[sid]) > 0 {
+					continue
 				}
-				if timeoutCount == 0 && timeout > constants.SendTimeout {
-					timeout = max(constants.SendTimeout, time.Duration(float64(timeout)/constants.TimeoutIncreaseFactor))
+				delete(s.heldPartials, sid)
+				s.lastPartialAt[sid] = time.Now()
+				if !s.process(ctx, t) {
+					return
 				}
-			case <-time.After(timeout):
-				s.logger.Error("timeout sending transcript",
-					"speaker_session_id", t.SpeakerSessionID,
-					"timeout", timeout,
-				)
-				if timeout <= constants.MaxTranscriptSendTimeout {
-					timeoutCount++
-					if timeoutCount >= 5 {
-						timeout = time.Duration(float64(timeout) * constants.TimeoutIncreaseFactor)
-						timeoutCount = 0
-					}
+			}
+			if len(s.heldPartials) > 0 {
+				flushC = time.After(interval / 2)
+			}
+		case <-mergeC:
+			mergeC = nil
+			for sid, m := range s.merging {
+				if time.Now().Before(m.due) {
+					continue
 				}
-			case <-ctx.Done():
+				delete(s.merging, sid)
+				s.merged.Store(int32(len(s.merging)))
+				if !s.sendFinal(ctx, m.t) {
+					return
+				}
+			}
+			if next, ok := s.nextMergeDue(); ok {
+				mergeC = time.After(time.Until(next))
+			}
+		case f := <-s.punctuated:
+			s.busy.Store(true)
+			if !s.punctuatedFinal(ctx, f) {
 				return
 			}
 		}
 	}
 }
+
+// sendFinal punctuates, records and sends a final, which supersedes a held
+// partial of its speaker. Punctuators other than the heuristic one may take
+// a while, so they run in the background: only the speaker's captions wait
+// for them, not the other speakers'.
+func (s *Sender) sendFinal(ctx context.Context, t signaling.Transcript) bool {
+	f := pendingFinal{t: t, start: s.utteranceStart(t)}
+	delete(s.heldPartials, t.SpeakerSessionID)
+
+	p := s.punctuator.Load()
+	if p == nil {
+		return s.finishFinal(ctx, f)
+	}
+	if _, ok := (*p).(HeuristicPunctuator); ok {
+		f.t.Message = (*p).Punctuate(ctx, t.LangID, t.Message)
+		return s.finishFinal(ctx, f)
+	}
+
+	sid := t.SpeakerSessionID
+	s.punctuating[sid] = append(s.punctuating[sid], f)
+	s.unpunctuated.Add(1)
+	if len(s.punctuating[sid]) == 1 {
+		s.punctuate(ctx, *p, f)
+	}
+	return true
+}
+
+// punctuate punctuates a final in the background and hands it back to Run.
+func (s *Sender) punctuate(ctx context.Context, p Punctuator, f pendingFinal) {
+	go func() {
+		f.t.Message = p.Punctuate(ctx, f.t.LangID, f.t.Message)
+		select {
+		case s.punctuated <- f:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// punctuatedFinal sends a final whose punctuation finished, then starts
+// punctuating the speaker's next final or sends their held partial.
+func (s *Sender) punctuatedFinal(ctx context.Context, f pendingFinal) bool {
+	sid := f.t.SpeakerSessionID
+	queue := s.punctuating[sid][1:]
+	if len(queue) > 0 {
+		s.punctuating[sid] = queue
+		if p := s.punctuator.Load(); p != nil {
+			s.punctuate(ctx, *p, queue[0])
+		} else {
+			s.punctuate(ctx, HeuristicPunctuator{}, queue[0])
+		}
+	} else {
+		delete(s.punctuating, sid)
+	}
+	s.unpunctuated.Add(-1)
+
+	if !s.finishFinal(ctx, f) {
+		return false
+	}
+	if t, ok := s.heldPartials[sid]; ok && len(queue) == 0 {
+		delete(s.heldPartials, sid)
+		s.lastPartialAt[sid] = time.Now()
+		return s.process(ctx, t)
+	}
+	return true
+}
+
+// finishFinal records and sends a punctuated final, unless a punctuator
+// left nothing to caption.
+func (s *Sender) finishFinal(ctx context.Context, f pendingFinal) bool {
+	delete(s.lastPartialAt, f.t.SpeakerSessionID)
+	if IsBlank(f.t.Message) {
+		return true
+	}
+	s.recordFinal(f.t, f.start)
+	return s.process(ctx, f.t)
+}
+
+// mergeFinal joins a final into the one merging for its speaker, or starts
+// merging it. It returns the finals to send right away: a merged one in
+// another language, or one merged for MaxMergedFinalDuration.
+func (s *Sender) mergeFinal(t signaling.Transcript) []signaling.Transcript {
+	defer func() { s.merged.Store(int32(len(s.merging))) }()
+
+	sid := t.SpeakerSessionID
+	// The final supersedes a held partial, which would be sent with the
+	// text merged before it only
+	delete(s.heldPartials, sid)
+	now := time.Now()
+
+	m := s.merging[sid]
+	if m != nil && m.t.LangID == t.LangID {
+		m.t.Message = joinSegments(t.LangID, m.t.Message, t.Message)
+		m.t.EndMs = t.EndMs
+		m.t.Alternatives = nil
+		m.due = now.Add(s.mergeGap)
+		if now.Sub(m.started) < constants.MaxMergedFinalDuration {
+			return nil
+		}
+		delete(s.merging, sid)
+		return []signaling.Transcript{m.t}
+	}
+
+	var send []signaling.Transcript
+	if m != nil {
+		send = append(send, m.t)
+	}
+	s.merging[sid] = &mergingFinal{t: t, due: now.Add(s.mergeGap), started: now}
+	return send
+}
+
+// joinSegments joins two segments with the word separator of the language.
+func joinSegments(langID, a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + languages.Separator(langID) + b
+}
+
+// nextMergeDue returns when the next merged final is due, if any.
+func (s *Sender) nextMergeDue() (time.Time, bool) {
+	var next time.Time
+	for _, m := range s.merging {
+		if next.IsZero() || m.due.Before(next) {
+			next = m.due
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// record tracks when the utterance of a partial started. Partials that are
+// held back still count for the start.
+func (s *Sender) record(t signaling.Transcript) {
+	s.utteranceStart(t)
+}
+
+// utteranceStart returns when the utterance of a transcript started, which
+// a final ends, if the transcripts are recorded or posted.
+func (s *Sender) utteranceStart(t signaling.Transcript) time.Time {
+	if s.recorder.Load() == nil && s.webhook == nil {
+		return time.Time{}
+	}
+	start, ok := s.started[t.SpeakerSessionID]
+	if !ok {
+		start = time.Now()
+		s.started[t.SpeakerSessionID] = start
+	}
+	if t.Final {
+		delete(s.started, t.SpeakerSessionID)
+	}
+	return start
+}
+
+// recordFinal appends a final to the recorder and posts it to the webhook,
+// if any.
+func (s *Sender) recordFinal(t signaling.Transcript, start time.Time) {
+	rec := s.recorder.Load()
+	if rec == nil && s.webhook == nil {
+		return
+	}
+	if start.IsZero() {
+		start = time.Now()
+	}
+	t.SpeakerName = s.client.SpeakerName(t.SpeakerSessionID)
+	if rec != nil {
+		rec.Record(t, start)
+	}
+	s.postFinal(t, start)
+}
+
+// postFinal posts a final transcript to the webhook, if any. Empty
+// segments are ignored, as by the recorder.
+func (s *Sender) postFinal(t signaling.Transcript, start time.Time) {
+	if s.webhook == nil || t.Message == "" {
+		return
+	}
+	s.webhook.Post(WebhookSegment{
+		RoomToken: s.roomToken,
+		RecordedSegment: RecordedSegment{
+			Start:            start.UTC(),
+			Time:             time.Now().UTC(),
+			SpeakerSessionID: t.SpeakerSessionID,
+			SpeakerName:      t.SpeakerName,
+			LangID:           t.LangID,
+			Text:             t.Message,
+		},
+		StartMs: t.StartMs,
+		EndMs:   t.EndMs,
+	})
+}
+
+// process forwards a transcript for translation and sends it to the
+// targets. It returns false if ctx was cancelled while sending.
+func (s *Sender) process(ctx context.Context, t signaling.Transcript) bool {
+	// Forward final transcripts (and, in partial translation mode,
+	// stable partial prefixes) to the translation pipeline
+	shouldTranslate := s.translator.ShouldTranslate()
+	if shouldTranslate {
+		if t.Final {
+			if s.partials != nil {
+				delete(s.partials, t.SpeakerSessionID)
+			}
+			s.forwardForTranslation(t.LangID, t.Message, t.SpeakerSessionID, true)
+		} else if s.partialTranslation {
+			s.forwardPartial(t)
+		}
+	}
+
+	// For final transcripts, skip translation targets — they
+	// will receive the translated version instead. The same goes
+	// for partials when those are translated too.
+	var exclude func(string) bool
+	if shouldTranslate && (t.Final || s.partialTranslation) {
+		exclude = s.translator.IsTranslationTarget
+	}
+
+	if s.feed.HasSubscribers() {
+		s.feed.Publish(FeedEvent{
+			Type:             "transcript",
+			LangID:           t.LangID,
+			Message:          t.Message,
+			Final:            t.Final,
+			SpeakerSessionID: t.SpeakerSessionID,
+			SpeakerName:      s.client.SpeakerName(t.SpeakerSessionID),
+			StartMs:          t.StartMs,
+			EndMs:            t.EndMs,
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.client.SendTranscript(t, exclude)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		if s.timeoutCount > 0 {
+			s.timeoutCount--
+		}
+		if s.timeoutCount == 0 && s.timeout > constants.SendTimeout {
+			s.timeout = max(constants.SendTimeout, time.Duration(float64(s.timeout)/constants.TimeoutIncreaseFactor))
+		}
+	case <-time.After(s.timeout):
+		s.logger.Error("timeout sending transcript",
+			"speaker_session_id", t.SpeakerSessionID,
+			"timeout", s.timeout,
+		)
+		if s.timeout <= constants.MaxTranscriptSendTimeout {
+			s.timeoutCount++
+			if s.timeoutCount >= 5 {
+				s.timeout = time.Duration(float64(s.timeout) * constants.TimeoutIncreaseFactor)
+				s.timeoutCount = 0
+			}
+		}
+	case <-ctx.Done():
+		return false
+	}
+	return true
+}
+
+func (s *Sender) forwardForTranslation(langID, message, speakerSessionID string, final bool) {
+	select {
+	case s.translateIn <- TranslateInputOutput{
+		OriginLanguage:   langID,
+		Message:          message,
+		SpeakerSessionID: speakerSessionID,
+		Final:            final,
+	}:
+	default:
+		s.logger.Warn("translate input channel full, dropping")
+	}
+}
+
+// forwardPartial forwards the part of a partial transcript that did not change
+// since the previous partial, if it grew and the debounce interval has passed.
+func (s *Sender) forwardPartial(t signaling.Transcript) {
+	st, ok := s.partials[t.SpeakerSessionID]
+	if !ok {
+		st = &partialState{}
+		s.partials[t.SpeakerSessionID] = st
+	}
+
+	prefix := stablePrefix(st.prev, t.Message, languages.Separator(t.LangID))
+	st.prev = t.Message
+
+	if len(prefix) <= len(st.sent) || time.Since(st.sentAt) < s.partialDebounce {
+		return
+	}
+
+	st.sent = prefix
+	st.sentAt = time.Now()
+	s.forwardForTranslation(t.LangID, prefix, t.SpeakerSessionID, false)
+}
+
+// stablePrefix returns the common prefix of two consecutive partials. With a
+// word separator the prefix is cut back to a whole word; languages without
+// one have no word boundaries in the text, so any character prefix is kept.
+func stablePrefix(prev, cur, sep string) string {
+	a, b := []rune(prev), []rune(cur)
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	prefix := string(b[:n])
+	if sep == "" {
+		return strings.TrimSpace(prefix)
+	}
+
+	atBoundary := (n == len(a) || strings.HasPrefix(string(a[n:]), sep)) &&
+		(n == len(b) || strings.HasPrefix(string(b[n:]), sep))
+	if !atBoundary {
+		if i := strings.LastIndex(prefix, sep); i >= 0 {
+			prefix = prefix[:i]
+		} else if strings.Contains(cur, sep) {
+			prefix = ""
+		}
+	}
+	return strings.TrimSpace(prefix)
+}
@@ -0,0 +1,163 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package transcript
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+)
+
+type noTranslation struct{}
+
+func (noTranslation) ShouldTranslate() bool           { return false }
+func (noTranslation) IsTranslationTarget(string) bool { return false }
+
+// newTestSender runs a sender of a client without targets, whose output is
+// observed on its feed.
+func newTestSender(t *testing.T) (*Sender, chan signaling.Transcript, *Subscription) {
+	t.Helper()
+	cfg := &appapi.Config{}
+	client := signaling.NewSpreedClient("room", &signaling.HPBSettings{}, "en", cfg, nil)
+	ch := make(chan signaling.Transcript, 10)
+	s := NewSender(client, ch, make(chan TranslateInputOutput, 10), noTranslation{})
+	feed := NewFeed()
+	s.SetFeed(feed)
+	sub := feed.Subscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.Run(ctx)
+	return s, ch, sub
+}
+
+// expectEvent returns the next event published, failing after a second.
+func expectEvent(t *testing.T, sub *Subscription) FeedEvent {
+	t.Helper()
+	select {
+	case ev := <-sub.C:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("nothing published")
+		return FeedEvent{}
+	}
+}
+
+// blankPunctuator punctuates away everything but "keep".
+type blankPunctuator struct{}
+
+func (blankPunctuator) Punctuate(_ context.Context, _, text string) string {
+	if text == "keep" {
+		return "Keep."
+	}
+	return "。"
+}
+
+func TestSenderDropsBlankSegments(t *testing.T) {
+	s, ch, sub := newTestSender(t)
+	r, err := NewRedactor([]string{"darn"}, nil, "***")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRedactor(r)
+	s.SetPunctuator(blankPunctuator{})
+
+	// Left blank by the redaction, a partial and a final are dropped
+	ch <- signaling.Transcript{LangID: "en", Message: "darn", SpeakerSessionID: "a"}
+	ch <- signaling.Transcript{Final: true, LangID: "en", Message: "darn darn", SpeakerSessionID: "a"}
+	// Left blank by the punctuation, a final is dropped
+	ch <- signaling.Transcript{Final: true, LangID: "ja", Message: "えー", SpeakerSessionID: "b"}
+	ch <- signaling.Transcript{Final: true, LangID: "en", Message: "keep", SpeakerSessionID: "b"}
+
+	if ev := expectEvent(t, sub); ev.Message != "Keep." || !ev.Final {
+		t.Errorf("published %+v, want only the final left to caption", ev)
+	}
+}
+
+func TestPartialsRateLimitedFinalsNot(t *testing.T) {
+	const interval = 500 * time.Millisecond
+	s, ch, sub := newTestSender(t)
+	s.SetMinPartialInterval(interval)
+
+	start := time.Now()
+	for _, msg := range []string{"one", "one two", "one two three"} {
+		ch <- signaling.Transcript{LangID: "en", Message: msg, SpeakerSessionID: "a"}
+	}
+	if ev := expectEvent(t, sub); ev.Message != "one" || ev.Final {
+		t.Fatalf("published %+v, want the first partial at once", ev)
+	}
+
+	// Another speaker has a rate limit of their own
+	ch <- signaling.Transcript{LangID: "en", Message: "hi", SpeakerSessionID: "b"}
+	if ev := expectEvent(t, sub); ev.Message != "hi" || ev.SpeakerSessionID != "b" {
+		t.Fatalf("published %+v, want the other speaker's partial at once", ev)
+	}
+
+	// The final passes at once and supersedes the held partials
+	ch <- signaling.Transcript{Final: true, LangID: "en", Message: "one two three four", SpeakerSessionID: "a"}
+	if ev := expectEvent(t, sub); ev.Message != "one two three four" || !ev.Final {
+		t.Fatalf("published %+v, want the final", ev)
+	}
+	if elapsed := time.Since(start); elapsed >= interval {
+		t.Errorf("final published after %s, held back by the partial interval", elapsed)
+	}
+	select {
+	case ev := <-sub.C:
+		t.Errorf("published %+v after the final, want the held partials dropped", ev)
+	case <-time.After(interval + 100*time.Millisecond):
+	}
+
+	// Once the interval passed, a partial is sent right away again
+	ch <- signaling.Transcript{LangID: "en", Message: "five", SpeakerSessionID: "a"}
+	if ev := expectEvent(t, sub); ev.Message != "five" {
+		t.Errorf("published %+v, want the next partial", ev)
+	}
+}
+
+func TestHeldPartialFlushed(t *testing.T) {
+	const interval = 200 * time.Millisecond
+	s, ch, sub := newTestSender(t)
+	s.SetMinPartialInterval(interval)
+
+	start := time.Now()
+	for _, msg := range []string{"one", "one two", "one two three"} {
+		ch <- signaling.Transcript{LangID: "en", Message: msg, SpeakerSessionID: "a"}
+	}
+	expectEvent(t, sub)
+
+	// Without a final, only the latest held partial follows, after the
+	// interval
+	ev := expectEvent(t, sub)
+	if ev.Message != "one two three" || ev.Final {
+		t.Errorf("published %+v, want the latest partial", ev)
+	}
+	if elapsed := time.Since(start); elapsed < interval {
+		t.Errorf("held partial published after %s, before the interval", elapsed)
+	}
+}
+
+func TestStablePrefix(t *testing.T) {
+	tests := []struct {
+		prev, cur, sep, want string
+	}{
+		{"hello wor", "hello world", " ", "hello"},
+		{"hello world", "hello world again", " ", "hello world"},
+		{"hello world", "hello word", " ", "hello"},
+		{"hel", "help", " ", "hel"},
+		{"hel", "help me", " ", ""},
+		{"", "hello", " ", ""},
+		// Without a separator any character prefix is stable
+		{"你好世", "你好世界", "", "你好世"},
+		{"你好世界", "你好时间", "", "你好"},
+		{"你好 ", "你好世界", "", "你好"},
+	}
+	for _, tt := range tests {
+		if got := stablePrefix(tt.prev, tt.cur, tt.sep); got != tt.want {
+			t.Errorf("stablePrefix(%q, %q, %q) = %q, want %q", tt.prev, tt.cur, tt.sep, got, tt.want)
+		}
+	}
+}
@@ -9,4 +9,13 @@ type TranslateInputOutput struct {
 	Message            string
 	SpeakerSessionID   string
 	TargetNcSessionIDs map[string]struct{}
+	// Final is false for translations of partial transcripts, which clients
+	// should overwrite with the next segment of the same speaker.
+	Final bool
+	// Pending marks the placeholder partial of a final still being
+	// translated. An empty partial clears it if the translation failed.
+	Pending bool
+	// Failure is set on notices telling the targets that translating
+	// failed, to the reason; such notices carry no message.
+	Failure string
 }
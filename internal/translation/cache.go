@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// translationCache is a size-bounded LRU of source text → translated text
+// with an optional TTL. Short phrases repeat a lot in meetings, a hit skips
+// the OCP schedule/poll round-trip entirely.
+type translationCache struct {
+	mu     sync.Mutex
+	size   int
+	ttl    time.Duration
+	ll     *list.List
+	items  map[string]*list.Element
+	hits   uint64
+	misses uint64
+}
+
+type cacheItem struct {
+	key   string
+	value string
+	at    time.Time
+}
+
+func newTranslationCache(size int, ttl time.Duration) *translationCache {
+	return &translationCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *translationCache) get(text string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := normalizeCacheKey(text)
+	el, ok := c.items[key]
+	if ok && c.ttl > 0 && time.Since(el.Value.(*cacheItem).at) > c.ttl {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return "", false
+	}
+
+	c.hits++
+	c.ll.MoveToFront(el)
+	return el.Value.(*cacheItem).value, true
+}
+
+func (c *translationCache) put(text, translated string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := normalizeCacheKey(text)
+	if el, ok := c.items[key]; ok {
+		item := el.Value.(*cacheItem)
+		item.value = translated
+		item.at = time.Now()
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheItem{key: key, value: translated, at: time.Now()})
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheItem).key)
+	}
+}
+
+func (c *translationCache) stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// normalizeCacheKey collapses whitespace only: case is kept, as punctuated
+// sources differ in it and their translations do too.
+func normalizeCacheKey(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTranslationCacheKeys(t *testing.T) {
+	c := newTranslationCache(10, time.Minute)
+	c.put("good morning", "guten Morgen")
+	c.put("Good morning.", "Guten Morgen.")
+
+	tests := []struct {
+		text, want string
+		wantOK     bool
+	}{
+		{"good morning", "guten Morgen", true},
+		{"  good \t morning\n", "guten Morgen", true},
+		{"Good morning.", "Guten Morgen.", true},
+		{"Good  morning.", "Guten Morgen.", true},
+		{"GOOD MORNING", "", false},
+		{"good morning.", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := c.get(tt.text)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("get(%q) = %q, %v, want %q, %v", tt.text, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// segmentFlights shares the translation tasks of identical segments in
+// flight across all translators and rooms, keyed by flightKey.
+var segmentFlights flightGroup
+
+// flightKey identifies the translation of a segment: the same text in the
+// same language pair.
+func flightKey(originLangID, targetLangID, message string) string {
+	return originLangID + "|" + targetLangID + "|" + message
+}
+
+// flightGroup runs one call per key at a time and shares its result with
+// the callers asking for the same key meanwhile, like
+// golang.org/x/sync/singleflight. The zero value is ready to use.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	val  string
+	err  error
+}
+
+// do runs fn for the key unless a call for it is in flight, then it waits
+// for that call's result instead, or until ctx is done.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() (string, error)) (string, error) {
+	for {
+		c, leader := g.join(key)
+		if leader {
+			val, err := fn()
+			g.finish(key, c, val, err)
+			return val, err
+		}
+		val, retry, err := c.wait(ctx)
+		if !retry {
+			return val, err
+		}
+	}
+}
+
+// join returns the call in flight for the key, or starts one led by the
+// caller, who must finish it.
+func (g *flightGroup) join(key string) (c *flightCall, leader bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.calls[key]; ok {
+		return c, false
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	c = &flightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	return c, true
+}
+
+// finish hands the result of a call to its waiters.
+func (g *flightGroup) finish(key string, c *flightCall, val string, err error) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	c.val, c.err = val, err
+	close(c.done)
+}
+
+// wait returns the call's result, or ctx.Err() once ctx is done. retry
+// reports that the call was cancelled by its leader, such as a room torn
+// down, or dropped for lack of a task slot, while the caller still wants
+// the result.
+func (c *flightCall) wait(ctx context.Context) (val string, retry bool, err error) {
+	select {
+	case <-c.done:
+		if errors.Is(c.err, context.Canceled) || errors.Is(c.err, context.DeadlineExceeded) ||
+			errors.Is(c.err, errNoTaskSlot) {
+			return "", ctx.Err() == nil, c.err
+		}
+		return c.val, false, c.err
+	case <-ctx.Done():
+		return "", false, ctx.Err()
+	}
+}
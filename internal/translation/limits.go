@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// errNoTaskSlot is returned for a translation dropped because no task slot
+// was free.
+var errNoTaskSlot = errors.New("no translation task slot free")
+
+// globalTaskSlots bounds the translation tasks in flight process wide, so
+// busy calls with many target languages don't saturate the task processing
+// queue of Nextcloud, which other apps share. nil means no limit.
+var globalTaskSlots *taskSlots
+
+// SetGlobalTaskLimit sets the maximum translation tasks in flight across all
+// rooms, 0 meaning no limit. Must be called before rooms are created.
+func SetGlobalTaskLimit(n int) {
+	globalTaskSlots = newTaskSlots(n)
+}
+
+// taskSlots is a semaphore of translation tasks, nil without a limit.
+type taskSlots struct {
+	slots   chan struct{}
+	waiting atomic.Int32
+}
+
+func newTaskSlots(n int) *taskSlots {
+	if n <= 0 {
+		return nil
+	}
+	return &taskSlots{slots: make(chan struct{}, n)}
+}
+
+// acquire waits for a free slot, or takes one only if free when wait is
+// false. At most MaxTranslationTaskWaiters callers wait, others give up
+// at once. It reports whether a slot was taken.
+func (s *taskSlots) acquire(ctx context.Context, wait bool) bool {
+	if s == nil {
+		return true
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if !wait {
+		return false
+	}
+	if s.waiting.Add(1) > constants.MaxTranslationTaskWaiters {
+		s.waiting.Add(-1)
+		return false
+	}
+	defer s.waiting.Add(-1)
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *taskSlots) release() {
+	if s != nil {
+		<-s.slots
+	}
+}
+
+type taskSlotsKey struct{}
+
+// roomTaskSlots is how the backend tasks of a translation take slots.
+type roomTaskSlots struct {
+	room *taskSlots
+	wait bool
+}
+
+// withTaskSlots makes the backend tasks run under ctx take a slot of room
+// and of the process, waiting for them if wait is set. Translations
+// answered from a cache or by another room's task take none.
+func withTaskSlots(ctx context.Context, room *taskSlots, wait bool) context.Context {
+	return context.WithValue(ctx, taskSlotsKey{}, roomTaskSlots{room: room, wait: wait})
+}
+
+// acquireTaskSlot takes the slots of a backend task run under ctx, as set
+// by withTaskSlots; without, only a process wide slot is waited for. It
+// fails with errNoTaskSlot if no slot could be taken.
+func acquireTaskSlot(ctx context.Context) (release func(), err error) {
+	rs, ok := ctx.Value(taskSlotsKey{}).(roomTaskSlots)
+	if !ok {
+		rs.wait = true
+	}
+	if !rs.room.acquire(ctx, rs.wait) {
+		return nil, noTaskSlot(ctx)
+	}
+	if !globalTaskSlots.acquire(ctx, rs.wait) {
+		rs.room.release()
+		return nil, noTaskSlot(ctx)
+	}
+	return func() {
+		globalTaskSlots.release()
+		rs.room.release()
+	}, nil
+}
+
+func noTaskSlot(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errNoTaskSlot
+}
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+func TestTaskSlotsBoundWaiters(t *testing.T) {
+	s := newTaskSlots(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if !s.acquire(ctx, false) {
+		t.Fatal("free slot not taken")
+	}
+	if s.acquire(ctx, false) {
+		t.Fatal("slot taken beyond the limit without waiting")
+	}
+
+	done := make(chan bool, constants.MaxTranslationTaskWaiters)
+	for range constants.MaxTranslationTaskWaiters {
+		go func() { done <- s.acquire(ctx, true) }()
+	}
+	for s.waiting.Load() < constants.MaxTranslationTaskWaiters {
+		time.Sleep(time.Millisecond)
+	}
+	if s.acquire(ctx, true) {
+		t.Fatal("slot taken beyond the waiters limit")
+	}
+
+	// Released slots go to the waiters, who give up once ctx is done
+	s.release()
+	if !<-done {
+		t.Fatal("waiter did not get the released slot")
+	}
+	cancel()
+	for range constants.MaxTranslationTaskWaiters - 1 {
+		if <-done {
+			t.Error("waiter got a slot after ctx was done")
+		}
+	}
+}
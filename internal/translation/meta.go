@@ -5,7 +5,12 @@ package translation
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,17 +22,51 @@ import (
 
 type MetaTranslator struct {
 	mu              sync.Mutex
-	translators     map[string]*OCPTranslator // key: target language
-	sidLangMap      map[string]string         // NC session ID → target language
+	translators     map[string]*targetTranslator // key: target language
+	originTrans     map[string]Translator        // key: origin + "|" + target language, for speakers not in the room language
+	sidLangMap      map[string]string            // NC session ID → target language
 	client          *appapi.Client
+	cfg             *appapi.Config
 	roomToken       string
 	roomLangID      string
 	shouldTranslate atomic.Bool
 	translateIn     chan transcript.TranslateInputOutput
 	translateOut    chan transcript.TranslateInputOutput
 	langsCache      *langsCache
+	policy          *LangPolicy
 	cancel          context.CancelFunc
 	logger          *slog.Logger
+
+	// Partial translations can finish after a newer segment of the same
+	// speaker; only the latest dispatched segment per speaker and target
+	// language may be emitted unless it is final.
+	dispatchSeq uint64
+	latestSeq   map[string]uint64 // key: speaker session ID + "|" + target language
+
+	batched  atomic.Int32 // segments waiting for the batch window
+	inFlight atomic.Int32 // running handleTranslation calls
+
+	taskSlots *taskSlots // bounds the room's translation tasks in flight
+
+	failures map[string]*failureNotice // key: origin + "|" + target language
+	degraded map[string]string         // NC session ID → failing language pair, gets originals
+}
+
+// targetTranslator is the room language translator into a target language,
+// with the sessions receiving its translations. Guarded by MetaTranslator.mu.
+type targetTranslator struct {
+	Translator
+	ncSessionIDs map[string]struct{}
+}
+
+func newTargetTranslator(tr Translator) *targetTranslator {
+	return &targetTranslator{Translator: tr, ncSessionIDs: make(map[string]struct{})}
+}
+
+// SessionIDs returns a copy of the sessions, segments keep it after mt.mu
+// is released.
+func (t *targetTranslator) SessionIDs() map[string]struct{} {
+	return maps.Clone(t.ncSessionIDs)
 }
 
 type langsCache struct {
@@ -37,18 +76,26 @@ type langsCache struct {
 
 func NewMetaTranslator(
 	client *appapi.Client,
+	cfg *appapi.Config,
 	roomToken, roomLangID string,
 	translateIn chan transcript.TranslateInputOutput,
 	translateOut chan transcript.TranslateInputOutput,
 ) *MetaTranslator {
 	return &MetaTranslator{
-		translators:  make(map[string]*OCPTranslator),
+		translators:  make(map[string]*targetTranslator),
+		originTrans:  make(map[string]Translator),
 		sidLangMap:   make(map[string]string),
 		client:       client,
+		cfg:          cfg,
 		roomToken:    roomToken,
 		roomLangID:   roomLangID,
 		translateIn:  translateIn,
 		translateOut: translateOut,
+		latestSeq:    make(map[string]uint64),
+		taskSlots:    newTaskSlots(cfg.MaxTranslationTasksPerRoom),
+		failures:     make(map[string]*failureNotice),
+		degraded:     make(map[string]string),
+		policy:       NewLangPolicy(cfg),
 		logger:       slog.With("component", "meta_translator", "room_token", roomToken),
 	}
 }
@@ -57,10 +104,18 @@ func (mt *MetaTranslator) ShouldTranslate() bool {
 	return mt.shouldTranslate.Load()
 }
 
-func (mt *MetaTranslator) AddTranslator(targetLangID, ncSessionID string) error {
+func (mt *MetaTranslator) AddTranslator(ctx context.Context, targetLangID, ncSessionID string) error {
 	mt.mu.Lock()
 	defer mt.mu.Unlock()
 
+	if err := mt.policy.CheckPair(mt.roomLangID, targetLangID); err != nil {
+		return err
+	}
+
+	if err := mt.checkTargetCapLocked(targetLangID, ncSessionID); err != nil {
+		return err
+	}
+
 	if existingLang, ok := mt.sidLangMap[ncSessionID]; ok {
 		if existingLang == targetLangID {
 			return nil
@@ -68,17 +123,23 @@ func (mt *MetaTranslator) AddTranslator(targetLangID, ncSessionID string) error
 		mt.removeTranslatorLocked(existingLang, ncSessionID)
 	}
 	mt.sidLangMap[ncSessionID] = targetLangID
+	delete(mt.degraded, ncSessionID)
 
 	if _, ok := mt.translators[targetLangID]; !ok {
-		translator := NewOCPTranslator(mt.client, mt.roomLangID, targetLangID, mt.roomToken)
-		if err := translator.IsLanguagePairSupported(); err != nil {
-			delete(mt.sidLangMap, ncSessionID)
-			return err
+		translator := mt.newTranslator(mt.roomLangID, targetLangID)
+		if err := translator.IsLanguagePairSupported(ctx); err != nil {
+			// An unreachable backend may recover, until then the session
+			// gets the original transcripts
+			if !mt.cfg.TranslationFallback || errors.Is(err, ErrTranslateLangPair) {
+				delete(mt.sidLangMap, ncSessionID)
+				return err
+			}
+			mt.degradeLocked(ncSessionID, mt.roomLangID+"|"+targetLangID)
 		}
-		mt.translators[targetLangID] = translator
+		mt.translators[targetLangID] = newTargetTranslator(translator)
 	}
 
-	mt.translators[targetLangID].AddSessionID(ncSessionID)
+	mt.translators[targetLangID].ncSessionIDs[ncSessionID] = struct{}{}
 	mt.shouldTranslate.Store(true)
 
 	mt.ensureRunning()
@@ -90,11 +151,43 @@ func (mt *MetaTranslator) AddTranslator(targetLangID, ncSessionID string) error
 	return nil
 }
 
+// checkTargetCapLocked enforces the per-room limit on distinct target
+// languages, each of which costs one OCP task per translated segment. A
+// session switching away from a language it alone uses frees that slot.
+func (mt *MetaTranslator) checkTargetCapLocked(targetLangID, ncSessionID string) error {
+	limit := mt.cfg.MaxTranslationTargetLangs
+	if limit <= 0 {
+		return nil
+	}
+	if _, ok := mt.translators[targetLangID]; ok {
+		return nil
+	}
+
+	inUse := len(mt.translators)
+	if existingLang, ok := mt.sidLangMap[ncSessionID]; ok {
+		if t, ok := mt.translators[existingLang]; ok && len(t.ncSessionIDs) == 1 {
+			inUse--
+		}
+	}
+	if inUse >= limit {
+		return fmt.Errorf("%w: room already uses %d of %d", ErrTooManyTargetLangs, inUse, limit)
+	}
+	return nil
+}
+
+func (mt *MetaTranslator) newTranslator(originLangID, targetLangID string) Translator {
+	return NewTranslator(mt.client, mt.cfg, mt.roomToken, originLangID, targetLangID)
+}
+
+// IsTranslationTarget reports whether the session gets translations instead
+// of the original transcripts. Sessions whose translations keep failing get
+// the originals again with the translation fallback.
 func (mt *MetaTranslator) IsTranslationTarget(ncSessionID string) bool {
 	mt.mu.Lock()
 	defer mt.mu.Unlock()
 	_, ok := mt.sidLangMap[ncSessionID]
-	return ok
+	_, degraded := mt.degraded[ncSessionID]
+	return ok && !degraded
 }
 
 func (mt *MetaTranslator) IsTranslating() bool {
@@ -103,9 +196,12 @@ func (mt *MetaTranslator) IsTranslating() bool {
 	return len(mt.sidLangMap) > 0
 }
 
-func (mt *MetaTranslator) IsTargetLangSupported(targetLangID string) (bool, error) {
-	tmp := NewOCPTranslator(mt.client, mt.roomLangID, targetLangID, mt.roomToken)
-	err := tmp.IsLanguagePairSupported()
+func (mt *MetaTranslator) IsTargetLangSupported(ctx context.Context, targetLangID string) (bool, error) {
+	if err := mt.policy.CheckPair(mt.roomLangID, targetLangID); err != nil {
+		return false, err
+	}
+	tmp := mt.newTranslator(mt.roomLangID, targetLangID)
+	err := tmp.IsLanguagePairSupported(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -122,6 +218,7 @@ func (mt *MetaTranslator) RemoveTranslator(ncSessionID string) {
 	}
 	mt.removeTranslatorLocked(langID, ncSessionID)
 	delete(mt.sidLangMap, ncSessionID)
+	delete(mt.degraded, ncSessionID)
 
 	if len(mt.sidLangMap) == 0 {
 		mt.shouldTranslate.Store(false)
@@ -134,13 +231,19 @@ func (mt *MetaTranslator) removeTranslatorLocked(targetLangID, ncSessionID strin
 	if !ok {
 		return
 	}
-	translator.RemoveSessionID(ncSessionID)
-	if !translator.HasSessions() {
+	delete(translator.ncSessionIDs, ncSessionID)
+	if len(translator.ncSessionIDs) == 0 {
 		delete(mt.translators, targetLangID)
+		for key := range mt.originTrans {
+			// Unsupported pairs are kept as nil translators
+			if _, target, _ := strings.Cut(key, "|"); target == targetLangID {
+				delete(mt.originTrans, key)
+			}
+		}
 	}
 }
 
-func (mt *MetaTranslator) GetTranslationLanguages() (*SupportedTranslationLanguages, error) {
+func (mt *MetaTranslator) GetTranslationLanguages(ctx context.Context) (*SupportedTranslationLanguages, error) {
 	mt.mu.Lock()
 	defer mt.mu.Unlock()
 
@@ -148,11 +251,12 @@ func (mt *MetaTranslator) GetTranslationLanguages() (*SupportedTranslationLangua
 		return mt.langsCache.langs, nil
 	}
 
-	tmp := NewOCPTranslator(mt.client, mt.roomLangID, "en", mt.roomToken)
-	langs, err := tmp.GetTranslationLanguages()
+	tmp := mt.newTranslator(mt.roomLangID, "en")
+	langs, err := tmp.GetTranslationLanguages(ctx)
 	if err != nil {
 		return nil, err
 	}
+	langs = mt.policy.Filter(langs)
 
 	mt.langsCache = &langsCache{time: time.Now(), langs: langs}
 	return langs, nil
@@ -169,17 +273,46 @@ func (mt *MetaTranslator) SetRoomLangID(langID string) {
 	mt.roomLangID = langID
 	mt.langsCache = nil // invalidate cache
 
-	for targetLang, oldTranslator := range mt.translators {
-		newTranslator := NewOCPTranslator(mt.client, langID, targetLang, mt.roomToken)
-		for sid := range oldTranslator.SessionIDs() {
-			newTranslator.AddSessionID(sid)
+	// The policy may not allow translating from the new language; those
+	// sessions get the originals again
+	for targetLang, tt := range mt.translators {
+		if err := mt.policy.CheckPair(langID, targetLang); err != nil {
+			mt.logger.Info("stopping translation not allowed from the new room language",
+				"lang_id", langID, "target_lang", targetLang, "sessions", len(tt.ncSessionIDs), "error", err)
+			for ncSid := range tt.ncSessionIDs {
+				mt.removeTranslatorLocked(targetLang, ncSid)
+				delete(mt.sidLangMap, ncSid)
+				delete(mt.degraded, ncSid)
+			}
 		}
-		mt.translators[targetLang] = newTranslator
+	}
+	if len(mt.sidLangMap) == 0 {
+		mt.shouldTranslate.Store(false)
+		mt.stopRunning()
+	}
+
+	for targetLang, oldTranslator := range mt.translators {
+		oldTranslator.Translator = mt.newTranslator(langID, targetLang)
+	}
+	// The failures were of the old language pairs. Degraded sessions keep
+	// the originals until the new pair translates for them.
+	clear(mt.failures)
+	for ncSid := range mt.degraded {
+		mt.degraded[ncSid] = langID + "|" + mt.sidLangMap[ncSid]
 	}
 
 	mt.logger.Info("room language updated", "lang_id", langID)
 }
 
+// Idle reports whether no segments are waiting for or in translation. A
+// stopped translator is idle, as nothing would consume its input.
+func (mt *MetaTranslator) Idle() bool {
+	mt.mu.Lock()
+	running := mt.cancel != nil
+	mt.mu.Unlock()
+	return !running || len(mt.translateIn) == 0 && mt.batched.Load() == 0 && mt.inFlight.Load() == 0
+}
+
 func (mt *MetaTranslator) Shutdown() {
 	mt.mu.Lock()
 	defer mt.mu.Unlock()
@@ -203,43 +336,247 @@ func (mt *MetaTranslator) stopRunning() {
 	}
 }
 
+// runTranslation collects segments arriving within the batch window and
+// translates them as one task per target language, which keeps the number of
+// OCP tasks down in busy rooms.
 func (mt *MetaTranslator) runTranslation(ctx context.Context) {
 	mt.logger.Debug("translation goroutine started")
 	defer mt.logger.Debug("translation goroutine stopped")
 
+	window := mt.cfg.TranslationBatchWindow
+	var pending []transcript.TranslateInputOutput
+	var flushC <-chan time.Time
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case segment := <-mt.translateIn:
-			mt.mu.Lock()
-			for _, translator := range mt.translators {
-				seg := segment
-				seg.TargetLanguage = translator.targetLanguage
-				seg.TargetNcSessionIDs = translator.SessionIDs()
+			pending = append(pending, segment)
+			if window <= 0 || len(pending) >= constants.TranslationMaxBatchSize {
+				mt.dispatch(ctx, pending)
+				pending, flushC = nil, nil
+			} else if flushC == nil {
+				flushC = time.After(window)
+			}
+		case <-flushC:
+			mt.dispatch(ctx, pending)
+			pending, flushC = nil, nil
+		}
+		mt.batched.Store(int32(len(pending)))
+	}
+}
 
-				go mt.handleTranslation(translator, seg)
+func (mt *MetaTranslator) dispatch(ctx context.Context, segments []transcript.TranslateInputOutput) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	type pending struct {
+		batch []transcript.TranslateInputOutput
+		seqs  []uint64
+	}
+
+	for _, translator := range mt.translators {
+		sessionIDs := translator.SessionIDs()
+		// Speakers may have a language of their own, so each origin
+		// language is translated as a batch of its own
+		byTranslator := make(map[Translator]*pending)
+		for _, segment := range segments {
+			seg := segment
+			seg.TargetLanguage = translator.TargetLanguage()
+			seg.TargetNcSessionIDs = sessionIDs
+
+			if seg.OriginLanguage == seg.TargetLanguage {
+				// Already in the target language
+				select {
+				case mt.translateOut <- seg:
+				default:
+					mt.logger.Warn("translate output channel full")
+				}
+				continue
+			}
+			tr := mt.translatorForLocked(ctx, translator, seg.OriginLanguage)
+			if tr == nil {
+				continue
 			}
-			mt.mu.Unlock()
+
+			mt.dispatchSeq++
+			mt.latestSeq[seqKey(seg)] = mt.dispatchSeq
+
+			p, ok := byTranslator[tr]
+			if !ok {
+				p = &pending{}
+				byTranslator[tr] = p
+			}
+			p.batch = append(p.batch, seg)
+			p.seqs = append(p.seqs, mt.dispatchSeq)
+		}
+
+		for tr, p := range byTranslator {
+			mt.inFlight.Add(1)
+			go mt.handleTranslation(ctx, tr, p.batch, p.seqs)
+		}
+	}
+}
+
+// translatorForLocked returns the translator for segments in the origin
+// language into the room translator's target language, or nil if the pair
+// is not allowed or the backend cannot translate it, not even by detecting
+// the origin language. Must be called with mt.mu held.
+func (mt *MetaTranslator) translatorForLocked(ctx context.Context, roomTranslator *targetTranslator, originLangID string) Translator {
+	if originLangID == "" || originLangID == roomTranslator.OriginLanguage() {
+		return roomTranslator.Translator
+	}
+
+	targetLangID := roomTranslator.TargetLanguage()
+	key := originLangID + "|" + targetLangID
+	if tr, ok := mt.originTrans[key]; ok {
+		return tr
+	}
+	if err := mt.policy.CheckPair(originLangID, targetLangID); err != nil {
+		mt.logger.Debug("not translating speaker language",
+			"origin_lang", originLangID, "target_lang", targetLangID, "error", err)
+		return nil
+	}
+	tr := mt.newTranslator(originLangID, targetLangID)
+	if err := tr.IsLanguagePairSupported(ctx); err != nil {
+		if !errors.Is(err, ErrTranslateLangPair) {
+			// The backend may be back for the next segment
+			mt.logger.Warn("cannot check speaker language pair",
+				"origin_lang", originLangID, "target_lang", targetLangID, "error", err)
+			return nil
 		}
+		mt.logger.Info("not translating speaker language, pair not supported",
+			"origin_lang", originLangID, "target_lang", targetLangID, "error", err)
+		tr = nil
 	}
+	mt.originTrans[key] = tr
+	return tr
 }
 
-func (mt *MetaTranslator) handleTranslation(translator *OCPTranslator, seg transcript.TranslateInputOutput) {
-	translated, err := translator.Translate(seg.Message)
+func (mt *MetaTranslator) handleTranslation(
+	ctx context.Context,
+	translator Translator,
+	batch []transcript.TranslateInputOutput,
+	seqs []uint64,
+) {
+	defer mt.inFlight.Add(-1)
+
+	ph := mt.startPlaceholder(batch)
+
+	// Backend tasks of finals wait for a task slot, partials are dropped
+	// at the limit as a newer partial or the final follows anyway
+	wait := slices.ContainsFunc(batch, func(seg transcript.TranslateInputOutput) bool { return seg.Final })
+	taskCtx := withTaskSlots(ctx, mt.taskSlots, wait)
+
+	messages := make([]string, len(batch))
+	for i, seg := range batch {
+		messages[i] = seg.Message
+	}
+
+	translated, failed, err := mt.translateBatch(taskCtx, translator, messages)
+	placeholderShown := ph.stop(ctx, err != nil || slices.Contains(failed, true))
 	if err != nil {
+		if ctx.Err() != nil {
+			mt.logger.Debug("translation cancelled", "target_lang", batch[0].TargetLanguage)
+			return
+		}
+		if errors.Is(err, errNoTaskSlot) {
+			level := slog.LevelDebug
+			if wait {
+				level = slog.LevelWarn
+			}
+			mt.logger.Log(ctx, level, "dropping translation, too many translation tasks",
+				"target_lang", batch[0].TargetLanguage, "final", wait)
+			return
+		}
 		mt.logger.Error("translation failed",
 			"error", err,
-			"origin_lang", seg.OriginLanguage,
-			"target_lang", seg.TargetLanguage,
+			"origin_lang", batch[0].OriginLanguage,
+			"target_lang", batch[0].TargetLanguage,
+			"segments", len(batch),
 		)
+		mt.notifyFailure(ctx, translator, batch, err)
 		return
 	}
+	mt.clearFailure(translator, batch)
+
+	for i, seg := range batch {
+		mt.mu.Lock()
+		key := seqKey(seg)
+		latest := mt.latestSeq[key] == seqs[i]
+		if latest {
+			delete(mt.latestSeq, key)
+		}
+		mt.mu.Unlock()
+		if failed[i] {
+			continue
+		}
+		if !seg.Final && !latest {
+			mt.logger.Debug("dropping stale partial translation", "target_lang", seg.TargetLanguage)
+			continue
+		}
+
+		seg.Message = translated[i]
+		if seg.Final && placeholderShown {
+			mt.sendOut(ctx, seg)
+			continue
+		}
+		select {
+		case mt.translateOut <- seg:
+		default:
+			mt.logger.Warn("translate output channel full")
+		}
+	}
+}
+
+// translateBatch translates the messages of a batch. If the batch fails as
+// a whole or comes back with a different number of translations, the
+// messages are translated one by one, so one failing segment does not cost
+// the others their translation and a backend merging segments does not
+// shift them. failed marks the messages left untranslated; err is only set
+// if none was translated.
+func (mt *MetaTranslator) translateBatch(
+	ctx context.Context,
+	translator Translator,
+	messages []string,
+) (translated []string, failed []bool, err error) {
+	translated, err = translator.TranslateBatch(ctx, messages)
+	if err == nil && len(translated) != len(messages) {
+		err = fmt.Errorf("%d translations for %d segments", len(translated), len(messages))
+	}
+	if err == nil {
+		return translated, make([]bool, len(messages)), nil
+	}
+	if len(messages) == 1 || ctx.Err() != nil || errors.Is(err, errNoTaskSlot) {
+		return nil, nil, err
+	}
 
-	seg.Message = translated
-	select {
-	case mt.translateOut <- seg:
-	default:
-		mt.logger.Warn("translate output channel full")
+	mt.logger.Warn("batch translation failed, translating segments individually",
+		"error", err, "target_lang", translator.TargetLanguage(), "segments", len(messages))
+	translated = make([]string, len(messages))
+	failed = make([]bool, len(messages))
+	var lastErr error
+	untranslated := 0
+	for i, msg := range messages {
+		if translated[i], err = translator.Translate(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, err
+			}
+			failed[i], lastErr = true, err
+			untranslated++
+		}
 	}
+	switch {
+	case untranslated == len(messages):
+		return nil, nil, lastErr
+	case untranslated > 0:
+		mt.logger.Warn("segments left untranslated",
+			"error", lastErr, "target_lang", translator.TargetLanguage(), "failed", untranslated)
+	}
+	return translated, failed, nil
+}
+
+func seqKey(seg transcript.TranslateInputOutput) string {
+	return seg.SpeakerSessionID + "|" + seg.TargetLanguage
 }
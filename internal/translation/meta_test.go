@@ -0,0 +1,314 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/languages"
+	"github.com/nextcloud/go_live_transcription/internal/transcript"
+)
+
+var errFake = errors.New("translation task failed")
+
+func init() {
+	RegisterBackend("fake", func(_ *appapi.Client, _ *appapi.Config, _, origin, target string) Translator {
+		return &fakeTranslator{origin: origin, target: target}
+	})
+}
+
+// fakeTranslator translates "x" into "T(x)", from en into de unless set
+// otherwise, but not from unsupportedLangID. Messages listed in fail fail; failBatch fails batches of
+// several messages and merge joins their translations into one, as a
+// backend dropping separators does.
+type fakeTranslator struct {
+	origin, target string
+
+	mu        sync.Mutex
+	fail      []string
+	failBatch bool
+	merge     bool
+	calls     []string
+}
+
+func (f *fakeTranslator) OriginLanguage() string { return cmp.Or(f.origin, "en") }
+func (f *fakeTranslator) TargetLanguage() string { return cmp.Or(f.target, "de") }
+
+func (f *fakeTranslator) Translate(_ context.Context, message string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, message)
+	if slices.Contains(f.fail, message) {
+		return "", errFake
+	}
+	return "T(" + message + ")", nil
+}
+
+func (f *fakeTranslator) TranslateBatch(ctx context.Context, messages []string) ([]string, error) {
+	f.mu.Lock()
+	failBatch, merge := f.failBatch, f.merge
+	f.mu.Unlock()
+	if failBatch && len(messages) > 1 {
+		return nil, errFake
+	}
+	var out []string
+	for _, msg := range messages {
+		translated, err := f.Translate(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, translated)
+	}
+	if merge {
+		out = []string{strings.Join(out, " ")}
+	}
+	return out, nil
+}
+
+const unsupportedLangID = "xx"
+
+func (f *fakeTranslator) IsLanguagePairSupported(context.Context) error {
+	if f.origin == unsupportedLangID {
+		return ErrTranslateLangPair
+	}
+	return nil
+}
+
+func (f *fakeTranslator) GetTranslationLanguages(context.Context) (*SupportedTranslationLanguages, error) {
+	return &SupportedTranslationLanguages{}, nil
+}
+
+func newTestMetaTranslator(cfg *appapi.Config) (*MetaTranslator, chan transcript.TranslateInputOutput) {
+	out := make(chan transcript.TranslateInputOutput, 100)
+	return NewMetaTranslator(nil, cfg, "room", "en", make(chan transcript.TranslateInputOutput), out), out
+}
+
+func TestTranslateBatchFallsBackToSegments(t *testing.T) {
+	mt, _ := newTestMetaTranslator(&appapi.Config{})
+	messages := []string{"one", "two", "three"}
+	tests := []struct {
+		name       string
+		tr         *fakeTranslator
+		want       []string
+		wantFailed []bool
+		wantErr    bool
+	}{
+		{"batch works", &fakeTranslator{}, []string{"T(one)", "T(two)", "T(three)"}, []bool{false, false, false}, false},
+		{"one segment fails", &fakeTranslator{fail: []string{"two"}, failBatch: true},
+			[]string{"T(one)", "", "T(three)"}, []bool{false, true, false}, false},
+		{"segments merged", &fakeTranslator{merge: true},
+			[]string{"T(one)", "T(two)", "T(three)"}, []bool{false, false, false}, false},
+		{"all fail", &fakeTranslator{fail: messages}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		translated, failed, err := mt.translateBatch(context.Background(), tt.tr, messages)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: error %v", tt.name, err)
+		}
+		if !slices.Equal(translated, tt.want) || !slices.Equal(failed, tt.wantFailed) {
+			t.Errorf("%s: translated %q failed %v, want %q %v", tt.name, translated, failed, tt.want, tt.wantFailed)
+		}
+	}
+}
+
+func TestFallbackDegradesAndRecovers(t *testing.T) {
+	mt, out := newTestMetaTranslator(&appapi.Config{TranslationBackend: "fake", TranslationFallback: true})
+	t.Cleanup(mt.Shutdown)
+	ctx := context.Background()
+	if err := mt.AddTranslator(ctx, "de", "s1"); err != nil {
+		t.Fatal(err)
+	}
+	batch := []transcript.TranslateInputOutput{{TargetNcSessionIDs: map[string]struct{}{"s1": {}}}}
+	tr := &fakeTranslator{}
+
+	degrade := func() {
+		t.Helper()
+		for i := range constants.TranslationFailuresBeforeFallback {
+			if !mt.IsTranslationTarget("s1") {
+				t.Fatalf("degraded after %d failures", i)
+			}
+			mt.notifyFailure(ctx, tr, batch, errFake)
+		}
+		if mt.IsTranslationTarget("s1") {
+			t.Fatalf("still a translation target after %d failures", constants.TranslationFailuresBeforeFallback)
+		}
+	}
+
+	degrade()
+	if notice := <-out; notice.Failure != FailureBackend {
+		t.Errorf("notice failure = %q, want %q", notice.Failure, FailureBackend)
+	}
+	if len(out) != 0 {
+		t.Errorf("%d more notices within the backoff", len(out))
+	}
+	mt.clearFailure(tr, batch)
+	if !mt.IsTranslationTarget("s1") {
+		t.Fatal("not a translation target again after the pair recovered")
+	}
+
+	// A new room language does not end the fallback by itself, only the
+	// new pair translating does
+	degrade()
+	mt.SetRoomLangID("fr")
+	if mt.IsTranslationTarget("s1") {
+		t.Fatal("fallback ended by the room language change")
+	}
+	mt.clearFailure(tr, batch)
+	if mt.IsTranslationTarget("s1") {
+		t.Fatal("fallback ended by the old pair recovering")
+	}
+	mt.clearFailure(&fakeTranslator{origin: "fr"}, batch)
+	if !mt.IsTranslationTarget("s1") {
+		t.Fatal("not a translation target again after the new pair translated")
+	}
+}
+
+func TestSpeakerLanguagePairUnsupported(t *testing.T) {
+	mt, _ := newTestMetaTranslator(&appapi.Config{TranslationBackend: "fake"})
+	t.Cleanup(mt.Shutdown)
+	ctx := context.Background()
+	if err := mt.AddTranslator(ctx, "de", "s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	room := mt.translators["de"]
+	if tr := mt.translatorForLocked(ctx, room, "fr"); tr == nil || tr.OriginLanguage() != "fr" {
+		t.Errorf("translator for a supported speaker language = %v", tr)
+	}
+	if tr := mt.translatorForLocked(ctx, room, unsupportedLangID); tr != nil {
+		t.Errorf("translator for an unsupported speaker language = %v, want none", tr)
+	}
+	if tr, ok := mt.originTrans[unsupportedLangID+"|de"]; !ok || tr != nil {
+		t.Error("unsupported pair not remembered")
+	}
+
+	mt.removeTranslatorLocked("de", "s1")
+	if len(mt.originTrans) != 0 {
+		t.Errorf("%d speaker translators left after the target was removed", len(mt.originTrans))
+	}
+}
+
+func TestLangPolicy(t *testing.T) {
+	p := NewLangPolicy(&appapi.Config{
+		TranslationOriginDeny:  []string{"fr"},
+		TranslationTargetAllow: []string{"de", "es"},
+		TranslationTargetDeny:  []string{"es"},
+	})
+	for _, tt := range []struct {
+		origin, target string
+		allowed        bool
+	}{
+		{"en", "de", true},
+		{"fr", "de", false}, // denied origin
+		{"en", "it", false}, // not in the target allow list
+		{"en", "es", false}, // the deny list wins
+	} {
+		err := p.CheckPair(tt.origin, tt.target)
+		if tt.allowed != (err == nil) || (err != nil && !errors.Is(err, ErrTranslateLangNotAllowed)) {
+			t.Errorf("CheckPair(%s, %s) = %v, want allowed %v", tt.origin, tt.target, err, tt.allowed)
+		}
+	}
+
+	filtered := p.Filter(&SupportedTranslationLanguages{
+		OriginLanguages: map[string]languages.LanguageModel{"en": {}, "fr": {}},
+		TargetLanguages: map[string]languages.LanguageModel{"de": {}, "es": {}, "it": {}},
+	})
+	if len(filtered.OriginLanguages) != 1 || len(filtered.TargetLanguages) != 1 {
+		t.Errorf("filtered to %v and %v, want en into de", filtered.OriginLanguages, filtered.TargetLanguages)
+	}
+}
+
+func TestPolicyEnforced(t *testing.T) {
+	mt, _ := newTestMetaTranslator(&appapi.Config{
+		TranslationBackend:     "fake",
+		TranslationOriginDeny:  []string{"fr"},
+		TranslationTargetAllow: []string{"de", "it"},
+	})
+	t.Cleanup(mt.Shutdown)
+	ctx := context.Background()
+
+	if err := mt.AddTranslator(ctx, "es", "s1"); !errors.Is(err, ErrTranslateLangNotAllowed) {
+		t.Fatalf("adding a target outside the allow list: error %v", err)
+	}
+	if ok, err := mt.IsTargetLangSupported(ctx, "es"); ok || !errors.Is(err, ErrTranslateLangNotAllowed) {
+		t.Errorf("target outside the allow list supported %v, %v", ok, err)
+	}
+	if err := mt.AddTranslator(ctx, "de", "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mt.AddTranslator(ctx, "it", "s2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Switching to an allowed language keeps the targets
+	mt.SetRoomLangID("nl")
+	if !mt.IsTranslationTarget("s1") || !mt.IsTranslationTarget("s2") {
+		t.Fatal("targets dropped on an allowed room language")
+	}
+
+	// A denied room language stops the translations, the sessions get the
+	// originals, and adding them again is rejected
+	mt.SetRoomLangID("fr")
+	if mt.IsTranslationTarget("s1") || mt.IsTranslationTarget("s2") || mt.ShouldTranslate() {
+		t.Error("still translating from a denied room language")
+	}
+	if len(mt.translators) != 0 {
+		t.Errorf("%d translators left", len(mt.translators))
+	}
+	if err := mt.AddTranslator(ctx, "de", "s1"); !errors.Is(err, ErrTranslateLangNotAllowed) {
+		t.Errorf("adding a target from a denied room language: error %v", err)
+	}
+}
+
+func TestTargetLangCap(t *testing.T) {
+	mt, _ := newTestMetaTranslator(&appapi.Config{TranslationBackend: "fake", MaxTranslationTargetLangs: 2})
+	t.Cleanup(mt.Shutdown)
+	ctx := context.Background()
+	add := func(lang, ncSid string) error { return mt.AddTranslator(ctx, lang, ncSid) }
+
+	if err := add("de", "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := add("it", "s2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := add("es", "s3"); !errors.Is(err, ErrTooManyTargetLangs) {
+		t.Fatalf("third target language: error %v, want ErrTooManyTargetLangs", err)
+	}
+	if mt.IsTranslationTarget("s3") {
+		t.Error("rejected session is a translation target")
+	}
+
+	// Languages in use take no further slot
+	if err := add("de", "s3"); err != nil {
+		t.Errorf("joining a target language in use: %v", err)
+	}
+	// Switching away from a shared language still needs a slot, from one
+	// the session alone uses not
+	if err := add("es", "s1"); !errors.Is(err, ErrTooManyTargetLangs) {
+		t.Errorf("switching away from a shared language: error %v, want ErrTooManyTargetLangs", err)
+	}
+	if err := add("es", "s2"); err != nil {
+		t.Errorf("switching the only session of a language: %v", err)
+	}
+
+	// Removing the last session of a language frees its slot
+	mt.RemoveTranslator("s2")
+	if err := add("fr", "s4"); err != nil {
+		t.Errorf("target language after a slot was freed: %v", err)
+	}
+	if n := len(mt.translators); n != 2 {
+		t.Errorf("%d target languages, want 2", n)
+	}
+}
@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/transcript"
+)
+
+// Reasons of the translationError notices sent to translation targets.
+const (
+	FailureUnsupportedPair = "unsupported_language_pair"
+	FailureBackend         = "backend_error"
+)
+
+// failureNotice backs off the translationError notices of a language pair,
+// so the targets of a broken translator are told now and then rather than
+// for every segment, and counts its failures in a row. Guarded by
+// MetaTranslator.mu.
+type failureNotice struct {
+	next        time.Time
+	delay       time.Duration
+	consecutive int
+}
+
+// notifyFailure tells the targets of a batch that it could not be
+// translated, unless they were told within the backoff of the language
+// pair, which doubles with every notice up to
+// TranslationFailureNoticeMaxInterval.
+func (mt *MetaTranslator) notifyFailure(
+	ctx context.Context,
+	translator Translator,
+	batch []transcript.TranslateInputOutput,
+	err error,
+) {
+	key := translator.OriginLanguage() + "|" + translator.TargetLanguage()
+	now := time.Now()
+
+	mt.mu.Lock()
+	f, ok := mt.failures[key]
+	if !ok {
+		f = &failureNotice{delay: constants.TranslationFailureNoticeInterval}
+		mt.failures[key] = f
+	}
+	due := !now.Before(f.next)
+	if due {
+		f.next = now.Add(f.delay)
+		f.delay = min(2*f.delay, constants.TranslationFailureNoticeMaxInterval)
+	}
+	f.consecutive++
+	if mt.cfg.TranslationFallback && f.consecutive >= constants.TranslationFailuresBeforeFallback {
+		for ncSid := range batch[0].TargetNcSessionIDs {
+			mt.degradeLocked(ncSid, key)
+		}
+	}
+	mt.mu.Unlock()
+	if !due {
+		return
+	}
+
+	// Translation tasks only fail, so ask the backend whether the pair is
+	// supported at all
+	reason := FailureBackend
+	if errors.Is(err, ErrTranslateLangPair) ||
+		errors.Is(translator.IsLanguagePairSupported(ctx), ErrTranslateLangPair) {
+		reason = FailureUnsupportedPair
+	}
+
+	notice := transcript.TranslateInputOutput{
+		OriginLanguage:     translator.OriginLanguage(),
+		TargetLanguage:     translator.TargetLanguage(),
+		TargetNcSessionIDs: batch[0].TargetNcSessionIDs,
+		Failure:            reason,
+	}
+	select {
+	case mt.translateOut <- notice:
+	default:
+		mt.logger.Warn("translate output channel full")
+	}
+}
+
+// clearFailure resets the notice backoff of a language pair once it
+// translates again, and recovers the sessions it degraded among the batch's
+// targets.
+func (mt *MetaTranslator) clearFailure(translator Translator, batch []transcript.TranslateInputOutput) {
+	key := translator.OriginLanguage() + "|" + translator.TargetLanguage()
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	delete(mt.failures, key)
+	for ncSid := range batch[0].TargetNcSessionIDs {
+		if mt.degraded[ncSid] == key {
+			delete(mt.degraded, ncSid)
+			mt.logger.Info("translation recovered, stopping original transcripts",
+				"nc_session_id", ncSid, "target_lang", translator.TargetLanguage())
+		}
+	}
+}
+
+// degradeLocked makes a translation target receive the original transcripts
+// until the language pair translates again. Must be called with mt.mu held.
+func (mt *MetaTranslator) degradeLocked(ncSessionID, pairKey string) {
+	if _, ok := mt.degraded[ncSessionID]; ok {
+		return
+	}
+	mt.degraded[ncSessionID] = pairKey
+	mt.logger.Warn("translation failing, falling back to original transcripts",
+		"nc_session_id", ncSessionID, "pair", pairKey)
+}
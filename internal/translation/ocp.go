@@ -4,11 +4,13 @@
 package translation
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"sync"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/nextcloud/go_live_transcription/internal/appapi"
@@ -19,10 +21,19 @@ import (
 const translateTaskType = "core:text2text:translate"
 const autoDetectOriginLangID = "detect_language"
 
+// batchSeparator joins segments of a batch into a single task input. Line
+// breaks survive translation with every backend we know of; the output is
+// only trusted if it splits back into exactly as many parts.
+const batchSeparator = "\n"
+
 var (
 	ErrTranslateFatal    = errors.New("translation fatal error")
 	ErrTranslateLangPair = errors.New("unsupported language pair")
 	ErrTranslate         = errors.New("translation error")
+
+	ErrTranslateLangNotAllowed = errors.New("language not allowed for translation")
+	ErrTooManyTargetLangs      = errors.New("too many translation target languages")
+	ErrProviderMalformed       = errors.New("translation provider returned unexpected data")
 )
 
 type SupportedTranslationLanguages struct {
@@ -53,15 +64,39 @@ type TaskTypesResponse struct {
 	Types map[string]TaskType `json:"types"`
 }
 
+// PollOptions controls how OCP tasks are scheduled and polled. The poll
+// interval starts at InitialInterval and doubles up to MaxInterval; once a
+// task has been pending for SlowAfter it is polled every SlowInterval until
+// Deadline.
+type PollOptions struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	SlowAfter       time.Duration
+	SlowInterval    time.Duration
+	Deadline        time.Duration
+	RetryDelay      time.Duration // between failed schedule attempts
+}
+
+func DefaultPollOptions() PollOptions {
+	return PollOptions{
+		InitialInterval: constants.OCPPollInitialInterval,
+		MaxInterval:     constants.OCPPollMaxInterval,
+		SlowAfter:       constants.OCPPollSlowAfter,
+		SlowInterval:    constants.OCPPollSlowInterval,
+		Deadline:        constants.OCPPollDeadline,
+		RetryDelay:      constants.OCPTaskSchedRetryDelay,
+	}
+}
+
 type OCPTranslator struct {
-	mu              sync.Mutex
 	client          *appapi.Client
+	poll            PollOptions
 	originLanguage  string
 	targetLanguage  string
 	roomToken       string
 	ocpOriginLangID string
-	ncSessionIDs    map[string]struct{} // NC session IDs receiving this translation
 	taskTypesCache  *taskTypesCache
+	cache           *translationCache // nil when disabled
 	logger          *slog.Logger
 }
 
@@ -70,14 +105,14 @@ type taskTypesCache struct {
 	types TaskTypesResponse
 }
 
-func NewOCPTranslator(client *appapi.Client, originLang, targetLang, roomToken string) *OCPTranslator {
+func NewOCPTranslator(client *appapi.Client, originLang, targetLang, roomToken string, poll PollOptions) *OCPTranslator {
 	return &OCPTranslator{
 		client:          client,
+		poll:            poll,
 		originLanguage:  originLang,
 		targetLanguage:  targetLang,
 		roomToken:       roomToken,
 		ocpOriginLangID: originLang,
-		ncSessionIDs:    make(map[string]struct{}),
 		logger: slog.With(
 			"component", "ocp_translator",
 			"origin_lang", originLang,
@@ -86,35 +121,187 @@ func NewOCPTranslator(client *appapi.Client, originLang, targetLang, roomToken s
 	}
 }
 
-func (t *OCPTranslator) AddSessionID(ncSessionID string) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	t.ncSessionIDs[ncSessionID] = struct{}{}
+func (t *OCPTranslator) OriginLanguage() string { return t.originLanguage }
+func (t *OCPTranslator) TargetLanguage() string { return t.targetLanguage }
+
+// enableCache attaches an LRU of recent translations. Must be called before
+// the translator is used.
+func (t *OCPTranslator) enableCache(size int, ttl time.Duration) {
+	if size > 0 {
+		t.cache = newTranslationCache(size, ttl)
+	}
 }
 
-func (t *OCPTranslator) RemoveSessionID(ncSessionID string) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	delete(t.ncSessionIDs, ncSessionID)
+func (t *OCPTranslator) Translate(ctx context.Context, message string) (string, error) {
+	if t.cache == nil {
+		return t.translate(ctx, message)
+	}
+
+	if translated, ok := t.cache.get(message); ok {
+		t.logCacheStats()
+		return translated, nil
+	}
+
+	translated, err := t.translate(ctx, message)
+	if err != nil {
+		return "", err
+	}
+	t.cache.put(message, translated)
+	t.logCacheStats()
+	return translated, nil
 }
 
-func (t *OCPTranslator) SessionIDs() map[string]struct{} {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	result := make(map[string]struct{}, len(t.ncSessionIDs))
-	for k, v := range t.ncSessionIDs {
-		result[k] = v
+// TranslateBatch translates several segments with a single OCP task where
+// possible. Cached segments are answered directly. If the backend merges or
+// drops separators, the segments are translated one by one instead.
+func (t *OCPTranslator) TranslateBatch(ctx context.Context, messages []string) ([]string, error) {
+	if len(messages) == 1 {
+		translated, err := t.Translate(ctx, messages[0])
+		if err != nil {
+			return nil, err
+		}
+		return []string{translated}, nil
 	}
-	return result
+
+	// Every segment missing from the cache is translated once: by the task
+	// of this batch, or by the one already in flight for it elsewhere
+	translated := make(map[string]string)
+	var led []string
+	leading := make(map[string]*flightCall)
+	waiting := make(map[string]*flightCall)
+	for _, msg := range messages {
+		if _, ok := translated[msg]; ok || leading[msg] != nil || waiting[msg] != nil {
+			continue
+		}
+		if t.cache != nil {
+			if cached, ok := t.cache.get(msg); ok {
+				translated[msg] = cached
+				continue
+			}
+		}
+		c, leader := segmentFlights.join(t.flightKey(msg))
+		if leader {
+			led = append(led, msg)
+			leading[msg] = c
+		} else {
+			waiting[msg] = c
+		}
+	}
+
+	// The segments led here are finished before waiting for the others,
+	// whose leaders may be waiting for them in turn
+	results, err := t.translateSegments(ctx, led)
+	for i, msg := range led {
+		var val string
+		if err == nil {
+			val = results[i]
+			translated[msg] = val
+			t.storeCached(msg, val)
+		}
+		segmentFlights.finish(t.flightKey(msg), leading[msg], val, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for msg, c := range waiting {
+		val, retry, err := c.wait(ctx)
+		if retry {
+			val, err = t.translate(ctx, msg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		translated[msg] = val
+		t.storeCached(msg, val)
+	}
+
+	out := make([]string, len(messages))
+	for i, msg := range messages {
+		out[i] = translated[msg]
+	}
+	return out, nil
 }
 
-func (t *OCPTranslator) HasSessions() bool {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	return len(t.ncSessionIDs) > 0
+// translateSegments translates distinct segments with a single OCP task,
+// or one by one if the backend merges or drops separators. The caller
+// leads their flights, so the tasks run directly.
+func (t *OCPTranslator) translateSegments(ctx context.Context, messages []string) ([]string, error) {
+	switch len(messages) {
+	case 0:
+		return nil, nil
+	case 1:
+		translated, err := t.runTranslateTask(ctx, messages[0])
+		if err != nil {
+			return nil, err
+		}
+		return []string{translated}, nil
+	}
+
+	// The separator must not occur inside a segment
+	texts := make([]string, len(messages))
+	for i, msg := range messages {
+		texts[i] = strings.ReplaceAll(msg, batchSeparator, languages.Separator(t.originLanguage))
+	}
+	joined, err := t.runTranslateTask(ctx, strings.Join(texts, batchSeparator))
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(strings.Trim(joined, batchSeparator), batchSeparator)
+	if len(parts) != len(messages) {
+		t.logger.Warn("batch translation lost segment boundaries, translating individually",
+			"segments", len(messages),
+			"parts", len(parts),
+		)
+		parts = make([]string, len(messages))
+		for i, msg := range messages {
+			if parts[i], err = t.runTranslateTask(ctx, msg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts, nil
+}
+
+func (t *OCPTranslator) storeCached(message, translated string) {
+	if t.cache != nil {
+		t.cache.put(message, translated)
+	}
 }
 
-func (t *OCPTranslator) Translate(message string) (string, error) {
+func (t *OCPTranslator) logCacheStats() {
+	hits, misses := t.cache.stats()
+	if total := hits + misses; total%100 == 0 {
+		t.logger.Info("translation cache stats",
+			"hits", hits,
+			"misses", misses,
+			"hit_rate", float64(hits)/float64(total),
+		)
+	}
+}
+
+// flightKey identifies the segment's translation among all translators.
+func (t *OCPTranslator) flightKey(message string) string {
+	return flightKey(t.originLanguage, t.targetLanguage, message)
+}
+
+func (t *OCPTranslator) translate(ctx context.Context, message string) (string, error) {
+	return segmentFlights.do(ctx, t.flightKey(message), func() (string, error) {
+		return t.runTranslateTask(ctx, message)
+	})
+}
+
+func (t *OCPTranslator) runTranslateTask(ctx context.Context, message string) (string, error) {
+	release, err := acquireTaskSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	schedBody := map[string]any{
 		"type":     translateTaskType,
 		"appId":    "live_transcription",
@@ -125,18 +312,31 @@ func (t *OCPTranslator) Translate(message string) (string, error) {
 			"target_language": t.targetLanguage,
 		},
 	}
+	return runTask(ctx, t.client, t.poll, t.logger, schedBody)
+}
 
+// runTask schedules an OCP task, retrying failed attempts, and waits for
+// its output.
+func runTask(ctx context.Context, client *appapi.Client, poll PollOptions, logger *slog.Logger,
+	schedBody map[string]any,
+) (string, error) {
 	var lastErr error
 	for tries := constants.OCPTaskProcSchedRetries; tries > 0; tries-- {
-		data, err := t.client.OCSPost(
+		data, err := client.OCSPost(
+			ctx,
 			"/ocs/v2.php/taskprocessing/tasks_consumer/schedule",
 			"admin",
 			schedBody,
 		)
 		if err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
 			lastErr = err
-			t.logger.Warn("task scheduling failed, retrying", "error", err, "tries_left", tries-1)
-			time.Sleep(2 * time.Second)
+			logger.Warn("task scheduling failed, retrying", "error", err, "tries_left", tries-1)
+			if err := sleepCtx(ctx, poll.RetryDelay); err != nil {
+				return "", err
+			}
 			continue
 		}
 
@@ -145,8 +345,11 @@ func (t *OCPTranslator) Translate(message string) (string, error) {
 			return "", fmt.Errorf("%w: parse schedule response: %v", ErrTranslate, err)
 		}
 
-		result, err := t.pollTask(resp.Task.ID)
+		result, err := pollTask(ctx, client, poll, logger, resp.Task.ID)
 		if err != nil {
+			if ctx.Err() != nil {
+				go deleteTask(client, logger, resp.Task.ID)
+			}
 			return "", err
 		}
 		return result, nil
@@ -155,21 +358,34 @@ func (t *OCPTranslator) Translate(message string) (string, error) {
 	return "", fmt.Errorf("%w: failed after retries: %v", ErrTranslate, lastErr)
 }
 
-func (t *OCPTranslator) pollTask(taskID int) (string, error) {
+// pollTask waits for the task to finish. It returns ctx.Err() as soon as the
+// context is cancelled, e.g. when the room is torn down.
+func pollTask(ctx context.Context, client *appapi.Client, poll PollOptions, logger *slog.Logger,
+	taskID int,
+) (string, error) {
 	path := fmt.Sprintf("/ocs/v1.php/taskprocessing/tasks_consumer/task/%d", taskID)
 
-	for i := 0; i < 360; i++ { // up to ~30 minutes
-		if i < 180 {
-			waitTime := min(1<<i, 5) // 1,2,4,5,5,5,...
-			time.Sleep(time.Duration(waitTime) * time.Second)
-		} else {
-			time.Sleep(10 * time.Second)
+	start := time.Now()
+	interval := poll.InitialInterval
+	for i := 0; time.Since(start) < poll.Deadline; i++ {
+		wait := poll.SlowInterval
+		if time.Since(start) < poll.SlowAfter {
+			wait = interval
+			interval = min(interval*2, poll.MaxInterval)
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			return "", err
 		}
 
-		data, err := t.client.OCSGet(path, "admin")
+		data, err := client.OCSGet(ctx, path, "admin")
 		if err != nil {
-			t.logger.Warn("task poll error", "error", err, "poll_count", i)
-			time.Sleep(5 * time.Second)
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			logger.Warn("task poll error", "error", err, "poll_count", i)
+			if err := sleepCtx(ctx, poll.MaxInterval); err != nil {
+				return "", err
+			}
 			continue
 		}
 
@@ -196,15 +412,57 @@ func (t *OCPTranslator) pollTask(taskID int) (string, error) {
 	return "", fmt.Errorf("%w: task timed out", ErrTranslate)
 }
 
-func (t *OCPTranslator) IsLanguagePairSupported() error {
-	taskTypes, err := t.getTaskTypes()
-	if err != nil {
-		return err
+// deleteTask deletes a task whose result is no longer waited for, such as
+// one that timed out, so it doesn't keep occupying the task processing.
+func deleteTask(client *appapi.Client, logger *slog.Logger, taskID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), constants.OCPTaskDeleteTimeout)
+	defer cancel()
+	path := fmt.Sprintf("/ocs/v2.php/taskprocessing/tasks_consumer/task/%d", taskID)
+	if _, err := client.OCSDelete(ctx, path, "admin"); err != nil {
+		logger.Debug("failed to delete abandoned task", "task_id", taskID, "error", err)
 	}
+}
 
+// sleepCtx waits for d or until ctx is done, whichever comes first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// translateTaskTypeOf returns the translate task type, checking that it
+// declares the language enums the rest of the code relies on.
+func translateTaskTypeOf(taskTypes *TaskTypesResponse) (TaskType, error) {
 	tt, ok := taskTypes.Types[translateTaskType]
 	if !ok {
-		return fmt.Errorf("%w: no text2text translate task type available", ErrTranslateFatal)
+		return TaskType{}, fmt.Errorf("%w: no text2text translate task type available", ErrTranslateFatal)
+	}
+	for _, key := range []string{"origin_language", "target_language"} {
+		values, ok := tt.InputShapeEnumValues[key]
+		if !ok {
+			return TaskType{}, fmt.Errorf("%w: missing '%s' in input shape enum values", ErrProviderMalformed, key)
+		}
+		if !slices.ContainsFunc(values, func(v InputShapeEnum) bool { return v.Value != "" }) {
+			return TaskType{}, fmt.Errorf("%w: no values for '%s'", ErrProviderMalformed, key)
+		}
+	}
+	return tt, nil
+}
+
+func (t *OCPTranslator) IsLanguagePairSupported(ctx context.Context) error {
+	taskTypes, err := t.getTaskTypes(ctx)
+	if err != nil {
+		return err
+	}
+
+	tt, err := translateTaskTypeOf(taskTypes)
+	if err != nil {
+		return err
 	}
 
 	originSupported := false
@@ -240,19 +498,22 @@ func (t *OCPTranslator) IsLanguagePairSupported() error {
 	return nil
 }
 
-func (t *OCPTranslator) GetTranslationLanguages() (*SupportedTranslationLanguages, error) {
-	taskTypes, err := t.getTaskTypes()
+func (t *OCPTranslator) GetTranslationLanguages(ctx context.Context) (*SupportedTranslationLanguages, error) {
+	taskTypes, err := t.getTaskTypes(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	tt, ok := taskTypes.Types[translateTaskType]
-	if !ok {
-		return nil, fmt.Errorf("%w: no text2text translate task type", ErrTranslateFatal)
+	tt, err := translateTaskTypeOf(taskTypes)
+	if err != nil {
+		return nil, err
 	}
 
 	olangs := make(map[string]languages.LanguageModel)
 	for _, v := range tt.InputShapeEnumValues["origin_language"] {
+		if v.Value == "" {
+			continue
+		}
 		if lm, ok := languages.VoskSupportedLanguageMap[v.Value]; ok {
 			olangs[v.Value] = lm
 		} else {
@@ -265,6 +526,9 @@ func (t *OCPTranslator) GetTranslationLanguages() (*SupportedTranslationLanguage
 
 	tlangs := make(map[string]languages.LanguageModel)
 	for _, v := range tt.InputShapeEnumValues["target_language"] {
+		if v.Value == "" {
+			continue
+		}
 		if lm, ok := languages.LanguageMap[v.Value]; ok {
 			tlangs[v.Value] = lm
 		} else {
@@ -281,19 +545,19 @@ func (t *OCPTranslator) GetTranslationLanguages() (*SupportedTranslationLanguage
 	}, nil
 }
 
-func (t *OCPTranslator) getTaskTypes() (*TaskTypesResponse, error) {
+func (t *OCPTranslator) getTaskTypes(ctx context.Context) (*TaskTypesResponse, error) {
 	if t.taskTypesCache != nil && time.Since(t.taskTypesCache.time) < constants.CacheTranslationTaskTypes {
 		return &t.taskTypesCache.types, nil
 	}
 
-	data, err := t.client.OCSGet("/ocs/v2.php/taskprocessing/tasks_consumer/tasktypes", "admin")
+	data, err := t.client.OCSGet(ctx, "/ocs/v2.php/taskprocessing/tasks_consumer/tasktypes", "admin")
 	if err != nil {
 		return nil, fmt.Errorf("%w: fetch task types: %v", ErrTranslateFatal, err)
 	}
 
 	var resp TaskTypesResponse
 	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, fmt.Errorf("%w: parse task types: %v", ErrTranslate, err)
+		return nil, fmt.Errorf("%w: parse task types: %v", ErrProviderMalformed, err)
 	}
 
 	if _, ok := resp.Types[translateTaskType]; !ok {
@@ -0,0 +1,299 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+)
+
+// fakeOCP is a task processing API translating every line "x" of a task
+// into "T(x)". Tasks keep running until release is closed or, if
+// pendingPolls is set, for that many polls.
+type fakeOCP struct {
+	release      chan struct{}
+	scheduled    chan string // inputs of the scheduled tasks
+	pendingPolls int
+
+	mu     sync.Mutex
+	inputs []string
+	polls  int
+}
+
+func newFakeOCP(t *testing.T) (*fakeOCP, *appapi.Client) {
+	f := &fakeOCP{release: make(chan struct{}), scheduled: make(chan string, 100)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, appapi.NewClient(&appapi.Config{NextcloudURL: srv.URL})
+}
+
+func (f *fakeOCP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var task Task
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/schedule"):
+		var body struct {
+			Input struct {
+				Input string `json:"input"`
+			} `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.inputs = append(f.inputs, body.Input.Input)
+		task = Task{ID: len(f.inputs), Status: "STATUS_SCHEDULED"}
+		f.mu.Unlock()
+		f.scheduled <- body.Input.Input
+
+	default:
+		var id int
+		if _, err := fmt.Sscanf(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], "%d", &id); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		task = Task{ID: id, Status: "STATUS_RUNNING"}
+		f.mu.Lock()
+		f.polls++
+		done := f.pendingPolls > 0 && f.polls > f.pendingPolls
+		f.mu.Unlock()
+		if done {
+			close(f.release)
+		}
+		select {
+		case <-f.release:
+			f.mu.Lock()
+			lines := strings.Split(f.inputs[id-1], batchSeparator)
+			f.mu.Unlock()
+			for i, line := range lines {
+				lines[i] = "T(" + line + ")"
+			}
+			task.Status = "STATUS_SUCCESSFUL"
+			task.Output = map[string]string{"output": strings.Join(lines, batchSeparator)}
+		default:
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"ocs": map[string]any{"data": TaskResponse{Task: task}}})
+}
+
+// waitScheduled returns the input of the next task scheduled.
+func (f *fakeOCP) waitScheduled(t *testing.T) string {
+	t.Helper()
+	select {
+	case input := <-f.scheduled:
+		return input
+	case <-time.After(5 * time.Second):
+		t.Fatal("no task scheduled")
+		return ""
+	}
+}
+
+func testPollOptions() PollOptions {
+	return PollOptions{
+		InitialInterval: time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		SlowAfter:       time.Minute,
+		SlowInterval:    5 * time.Millisecond,
+		Deadline:        5 * time.Second,
+		RetryDelay:      time.Millisecond,
+	}
+}
+
+func TestSegmentsShareTasks(t *testing.T) {
+	ocp, client := newFakeOCP(t)
+	room1 := NewOCPTranslator(client, "en", "de", "room1", testPollOptions())
+	room2 := NewOCPTranslator(client, "en", "de", "room2", testPollOptions())
+	other := NewOCPTranslator(client, "en", "fr", "room2", testPollOptions())
+	ctx := context.Background()
+
+	type result struct {
+		out []string
+		err error
+	}
+	batch := func(tr *OCPTranslator, messages ...string) chan result {
+		ch := make(chan result, 1)
+		go func() {
+			out, err := tr.TranslateBatch(ctx, messages)
+			ch <- result{out, err}
+		}()
+		return ch
+	}
+
+	// A segment repeated within a batch is translated once
+	first := batch(room1, "a", "b", "a")
+	if input := ocp.waitScheduled(t); input != "a\nb" {
+		t.Fatalf("first task input %q, want a and b once", input)
+	}
+
+	// Another room's batch only asks for the segment not in flight yet,
+	// another language pair for all of its segments
+	second := batch(room2, "b", "c")
+	if input := ocp.waitScheduled(t); input != "c" {
+		t.Fatalf("second task input %q, want only c", input)
+	}
+	third := batch(other, "a", "b")
+	if input := ocp.waitScheduled(t); input != "a\nb" {
+		t.Fatalf("task input %q for another pair, want a and b", input)
+	}
+	close(ocp.release)
+
+	for _, tt := range []struct {
+		ch   chan result
+		want []string
+	}{
+		{first, []string{"T(a)", "T(b)", "T(a)"}},
+		{second, []string{"T(b)", "T(c)"}},
+		{third, []string{"T(a)", "T(b)"}},
+	} {
+		res := <-tt.ch
+		if res.err != nil || !slices.Equal(res.out, tt.want) {
+			t.Errorf("translated %q, %v, want %q", res.out, res.err, tt.want)
+		}
+	}
+	if n := len(ocp.inputs); n != 3 {
+		t.Errorf("%d tasks scheduled, want 3", n)
+	}
+}
+
+func TestTaskSlotsOnlyForBackendTasks(t *testing.T) {
+	ocp, client := newFakeOCP(t)
+	tr := NewOCPTranslator(client, "en", "de", "room", testPollOptions())
+	tr.enableCache(10, time.Minute)
+	tr.cache.put("cached", "T(cached)")
+	slots := newTaskSlots(2)
+	ctx := withTaskSlots(context.Background(), slots, true)
+
+	errs := make(chan error, 5)
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		go func() {
+			_, err := tr.Translate(ctx, msg)
+			errs <- err
+		}()
+	}
+	ocp.waitScheduled(t)
+	ocp.waitScheduled(t)
+	select {
+	case input := <-ocp.scheduled:
+		t.Fatalf("task for %q scheduled beyond the limit", input)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Cache hits need no slot, partials get none at the limit
+	if got, err := tr.Translate(ctx, "cached"); err != nil || got != "T(cached)" {
+		t.Errorf("cached translation = %q, %v while the slots are taken", got, err)
+	}
+	if _, err := tr.Translate(withTaskSlots(context.Background(), slots, false), "f"); !errors.Is(err, errNoTaskSlot) {
+		t.Errorf("partial translation at the limit: error %v, want errNoTaskSlot", err)
+	}
+
+	close(ocp.release)
+	for range 5 {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if n := len(ocp.inputs); n != 5 {
+		t.Errorf("%d tasks scheduled, want 5", n)
+	}
+}
+
+func TestSuccessAfterPendingPolls(t *testing.T) {
+	ocp, client := newFakeOCP(t)
+	ocp.pendingPolls = 2
+	tr := NewOCPTranslator(client, "en", "de", "room", testPollOptions())
+
+	got, err := tr.Translate(context.Background(), "hello")
+	if err != nil || got != "T(hello)" {
+		t.Fatalf("translated %q, %v, want T(hello)", got, err)
+	}
+	if ocp.polls != 3 {
+		t.Errorf("polled %d times, want 2 pending polls and the successful one", ocp.polls)
+	}
+}
+
+func TestCancelStopsPolling(t *testing.T) {
+	ocp, client := newFakeOCP(t)
+	opts := testPollOptions()
+	opts.InitialInterval, opts.MaxInterval = time.Minute, time.Minute
+	tr := NewOCPTranslator(client, "en", "de", "room", opts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := tr.Translate(ctx, "never finished")
+		done <- err
+	}()
+	ocp.waitScheduled(t)
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("polling went on after the context was cancelled")
+	}
+}
+
+func TestMalformedTaskTypes(t *testing.T) {
+	const valid = `{"origin_language": [{"name": "English", "value": "en"}, {"name": "Detect", "value": "detect_language"}],
+		"target_language": [{"name": "German", "value": "de"}]}`
+	for _, tt := range []struct {
+		name       string
+		enumValues string // inputShapeEnumValues of the translate task type, "" for no such type
+		wantErr    error
+	}{
+		{"valid", valid, nil},
+		{"no translate task type", "", ErrTranslateFatal},
+		{"missing target languages", `{"origin_language": [{"name": "English", "value": "en"}]}`, ErrProviderMalformed},
+		{"missing enum values", `null`, ErrProviderMalformed},
+		{"no origin values", `{"origin_language": [], "target_language": [{"name": "German", "value": "de"}]}`,
+			ErrProviderMalformed},
+		{"only empty values", `{"origin_language": [{"name": "", "value": ""}],
+			"target_language": [{"name": "German", "value": "de"}]}`, ErrProviderMalformed},
+		{"wrong shape", `{"origin_language": "en", "target_language": "de"}`, ErrProviderMalformed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			types := `{}`
+			if tt.enumValues != "" {
+				types = `{"` + translateTaskType + `": {"inputShapeEnumValues": ` + tt.enumValues + `}}`
+			}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"ocs": {"data": {"types": %s}}}`, types)
+			}))
+			t.Cleanup(srv.Close)
+			tr := NewOCPTranslator(appapi.NewClient(&appapi.Config{NextcloudURL: srv.URL}), "en", "de", "room",
+				testPollOptions())
+
+			langs, err := tr.GetTranslationLanguages(context.Background())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error %v, want %v", err, tt.wantErr)
+				}
+				if err := tr.IsLanguagePairSupported(context.Background()); !errors.Is(err, tt.wantErr) {
+					t.Errorf("language pair check: error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := langs.OriginLanguages["en"]; !ok || len(langs.TargetLanguages) != 1 {
+				t.Errorf("languages %+v, want en into de", langs)
+			}
+		})
+	}
+}
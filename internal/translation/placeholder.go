@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/transcript"
+)
+
+// placeholder shows the targets of a batch that its finals are being
+// translated, once the translation took longer than
+// TranslationPlaceholderDelay. Placeholders are partials, so the
+// translations replace them; a failed translation clears them. Whatever
+// replaces a shown placeholder is never dropped at a full output channel,
+// or the placeholder would stay on screen.
+type placeholder struct {
+	mt    *MetaTranslator
+	segs  []transcript.TranslateInputOutput // one per speaker
+	timer *time.Timer
+
+	mu      sync.Mutex
+	sent    bool
+	stopped bool
+}
+
+// startPlaceholder arms the placeholder of the batch's finals, or returns
+// nil if placeholders are disabled or the batch has no final.
+func (mt *MetaTranslator) startPlaceholder(batch []transcript.TranslateInputOutput) *placeholder {
+	if !mt.cfg.TranslationPlaceholder {
+		return nil
+	}
+	p := &placeholder{mt: mt}
+	speakers := make(map[string]struct{})
+	for _, seg := range batch {
+		if _, ok := speakers[seg.SpeakerSessionID]; ok || !seg.Final {
+			continue
+		}
+		speakers[seg.SpeakerSessionID] = struct{}{}
+		seg.Message = constants.TranslationPlaceholder
+		seg.Final = false
+		seg.Pending = true
+		p.segs = append(p.segs, seg)
+	}
+	if len(p.segs) == 0 {
+		return nil
+	}
+	p.timer = time.AfterFunc(constants.TranslationPlaceholderDelay, p.show)
+	return p
+}
+
+func (p *placeholder) show() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	p.sent = true
+	p.send(p.segs)
+}
+
+// stop disarms the placeholder before the translations are emitted, and
+// clears it if shown and the translation failed. It reports whether the
+// placeholder was shown. Safe on nil.
+func (p *placeholder) stop(ctx context.Context, failed bool) (shown bool) {
+	if p == nil {
+		return false
+	}
+	p.timer.Stop()
+	p.mu.Lock()
+	p.stopped = true
+	shown = p.sent
+	p.mu.Unlock()
+	if !shown || !failed {
+		return shown
+	}
+	for _, seg := range p.segs {
+		seg.Message = ""
+		seg.Pending = false
+		p.mt.sendOut(ctx, seg)
+	}
+	return shown
+}
+
+// sendOut sends a segment that must not be dropped, waiting for room in
+// the output channel until ctx ends.
+func (mt *MetaTranslator) sendOut(ctx context.Context, seg transcript.TranslateInputOutput) {
+	select {
+	case mt.translateOut <- seg:
+	case <-ctx.Done():
+	}
+}
+
+func (p *placeholder) send(segs []transcript.TranslateInputOutput) {
+	for _, seg := range segs {
+		select {
+		case p.mt.translateOut <- seg:
+		default:
+			p.mt.logger.Warn("translate output channel full")
+		}
+	}
+}
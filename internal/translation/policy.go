@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"fmt"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/languages"
+)
+
+// LangPolicy restricts the translation matrix offered to clients. An empty
+// allow list allows every language; the deny list always wins.
+type LangPolicy struct {
+	originAllow, originDeny map[string]struct{}
+	targetAllow, targetDeny map[string]struct{}
+}
+
+func NewLangPolicy(cfg *appapi.Config) *LangPolicy {
+	return &LangPolicy{
+		originAllow: toSet(cfg.TranslationOriginAllow),
+		originDeny:  toSet(cfg.TranslationOriginDeny),
+		targetAllow: toSet(cfg.TranslationTargetAllow),
+		targetDeny:  toSet(cfg.TranslationTargetDeny),
+	}
+}
+
+func toSet(values []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+func allowed(lang string, allow, deny map[string]struct{}) bool {
+	if _, ok := deny[lang]; ok {
+		return false
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	_, ok := allow[lang]
+	return ok
+}
+
+func (p *LangPolicy) OriginAllowed(lang string) bool {
+	return allowed(lang, p.originAllow, p.originDeny)
+}
+
+func (p *LangPolicy) TargetAllowed(lang string) bool {
+	return allowed(lang, p.targetAllow, p.targetDeny)
+}
+
+// CheckPair returns an ErrTranslateLangNotAllowed error if either side of
+// the pair is excluded by the policy.
+func (p *LangPolicy) CheckPair(originLang, targetLang string) error {
+	if !p.OriginAllowed(originLang) {
+		return fmt.Errorf("%w: origin language '%s'", ErrTranslateLangNotAllowed, originLang)
+	}
+	if !p.TargetAllowed(targetLang) {
+		return fmt.Errorf("%w: target language '%s'", ErrTranslateLangNotAllowed, targetLang)
+	}
+	return nil
+}
+
+// Filter returns a copy of langs without the excluded languages.
+func (p *LangPolicy) Filter(langs *SupportedTranslationLanguages) *SupportedTranslationLanguages {
+	filtered := &SupportedTranslationLanguages{
+		OriginLanguages: make(map[string]languages.LanguageModel, len(langs.OriginLanguages)),
+		TargetLanguages: make(map[string]languages.LanguageModel, len(langs.TargetLanguages)),
+	}
+	for id, lm := range langs.OriginLanguages {
+		if p.OriginAllowed(id) {
+			filtered.OriginLanguages[id] = lm
+		}
+	}
+	for id, lm := range langs.TargetLanguages {
+		if p.TargetAllowed(id) {
+			filtered.TargetLanguages[id] = lm
+		}
+	}
+	return filtered
+}
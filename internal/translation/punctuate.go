@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/languages"
+	"github.com/nextcloud/go_live_transcription/internal/transcript"
+)
+
+const punctuateTaskType = "core:text2text"
+
+// OCPPunctuator punctuates final segments with a free text2text task of the
+// Nextcloud task processing, usually backed by an LLM. Short segments, and
+// all of them while no provider is installed or every translation task slot
+// is taken, are punctuated heuristically right away; so is a segment whose
+// task fails, takes longer than the timeout or changes its words.
+type OCPPunctuator struct {
+	client  *appapi.Client
+	poll    PollOptions
+	timeout time.Duration
+	cache   *translationCache
+	logger  *slog.Logger
+
+	mu        sync.Mutex
+	available bool
+	checkedAt time.Time
+}
+
+func NewOCPPunctuator(client *appapi.Client, timeout time.Duration) *OCPPunctuator {
+	return &OCPPunctuator{
+		client:  client,
+		poll:    DefaultPollOptions(),
+		timeout: timeout,
+		cache:   newTranslationCache(constants.TranslationCacheSize, constants.TranslationCacheTTL),
+		logger:  slog.With("component", "ocp_punctuator"),
+	}
+}
+
+func (p *OCPPunctuator) Punctuate(ctx context.Context, langID, text string) string {
+	if len(strings.Fields(text)) < constants.PunctuationMinWords {
+		return transcript.Punctuate(langID, text)
+	}
+
+	key := langID + ":" + text
+	if cached, ok := p.cache.get(key); ok {
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	if !p.providerAvailable(ctx) || !globalTaskSlots.acquire(ctx, false) {
+		return transcript.Punctuate(langID, text)
+	}
+	defer globalTaskSlots.release()
+
+	output, err := runTask(ctx, p.client, p.poll, p.logger, map[string]any{
+		"type":  punctuateTaskType,
+		"appId": "live_transcription",
+		"input": map[string]any{"input": punctuatePrompt(langID, text)},
+	})
+	output = strings.TrimSpace(output)
+	switch {
+	case err != nil:
+		p.logger.Debug("punctuation task failed, using heuristic", "error", err)
+		return transcript.Punctuate(langID, text)
+	case bareText(output) != bareText(text):
+		p.logger.Debug("punctuation task changed the words, using heuristic")
+		return transcript.Punctuate(langID, text)
+	}
+	p.cache.put(key, output)
+	return output
+}
+
+func punctuatePrompt(langID, text string) string {
+	language := langID
+	if lm, ok := languages.LanguageMap[langID]; ok {
+		language = lm.Name
+	}
+	return fmt.Sprintf("Add punctuation and capitalization to this %s speech transcript. "+
+		"Do not add, remove or change any words. Reply with the corrected text only.\n\n%s", language, text)
+}
+
+// providerAvailable reports whether a text2text provider is installed,
+// checked at most every TranslationProviderCheckTTL. Other rooms go on with
+// the previous answer while it is checked.
+func (p *OCPPunctuator) providerAvailable(ctx context.Context) bool {
+	p.mu.Lock()
+	if time.Since(p.checkedAt) < constants.TranslationProviderCheckTTL {
+		defer p.mu.Unlock()
+		return p.available
+	}
+	p.checkedAt = time.Now()
+	p.mu.Unlock()
+
+	available := p.checkProvider(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.available = available
+	return available
+}
+
+// checkProvider fetches the task types. Failures count as no provider until
+// the next check, so an unreachable Nextcloud doesn't delay every segment.
+func (p *OCPPunctuator) checkProvider(ctx context.Context) bool {
+	data, err := p.client.OCSGet(ctx, "/ocs/v2.php/taskprocessing/tasks_consumer/tasktypes", "admin")
+	if err != nil {
+		p.logger.Warn("fetching task types failed, punctuating heuristically", "error", err)
+		return false
+	}
+	var resp TaskTypesResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		p.logger.Warn("parsing task types failed, punctuating heuristically", "error", err)
+		return false
+	}
+
+	_, available := resp.Types[punctuateTaskType]
+	if !available {
+		p.logger.Info("no text2text provider installed, punctuating heuristically")
+	}
+	return available
+}
+
+// bareText keeps the lowercased letters and digits of text, so texts
+// differing only in case, punctuation and spacing compare equal.
+func bareText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.In(r, unicode.L, unicode.M, unicode.N) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, text)
+}
@@ -6,6 +6,7 @@ package translation
 import (
 	"context"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/nextcloud/go_live_transcription/internal/constants"
@@ -14,9 +15,12 @@ import (
 )
 
 type TranslatedSender struct {
-	client *signaling.SpreedClient
-	ch     chan transcript.TranslateInputOutput
-	logger *slog.Logger
+	client   *signaling.SpreedClient
+	ch       chan transcript.TranslateInputOutput
+	busy     atomic.Bool      // a translation is being sent
+	feed     *transcript.Feed // nil without a transcript feed
+	redactor atomic.Pointer[transcript.Redactor]
+	logger   *slog.Logger
 }
 
 func NewTranslatedSender(client *signaling.SpreedClient, ch chan transcript.TranslateInputOutput) *TranslatedSender {
@@ -27,6 +31,24 @@ func NewTranslatedSender(client *signaling.SpreedClient, ch chan transcript.Tran
 	}
 }
 
+// SetFeed makes the sender publish the translations to the feed. Must be
+// called before Run.
+func (s *TranslatedSender) SetFeed(f *transcript.Feed) {
+	s.feed = f
+}
+
+// SetRedactor makes the sender redact translations, which are made from
+// redacted transcripts already but may bring matches of their own. nil
+// stops it.
+func (s *TranslatedSender) SetRedactor(r *transcript.Redactor) {
+	s.redactor.Store(r)
+}
+
+// Idle reports whether no translations are queued or being sent.
+func (s *TranslatedSender) Idle() bool {
+	return len(s.ch) == 0 && !s.busy.Load()
+}
+
 func (s *TranslatedSender) Run(ctx context.Context) {
 	s.logger.Debug("translated text sender started")
 	defer s.logger.Debug("translated text sender stopped")
@@ -35,10 +57,12 @@ func (s *TranslatedSender) Run(ctx context.Context) {
 	timeoutCount := 0
 
 	for {
+		s.busy.Store(false)
 		select {
 		case <-ctx.Done():
 			return
 		case seg := <-s.ch:
+			s.busy.Store(true)
 			done := make(chan struct{})
 			go func() {
 				s.sendTranslatedText(seg)
@@ -78,6 +102,26 @@ func (s *TranslatedSender) Run(ctx context.Context) {
 }
 
 func (s *TranslatedSender) sendTranslatedText(seg transcript.TranslateInputOutput) {
+	if r := s.redactor.Load(); r != nil {
+		seg.Message = r.Redact(seg.Message)
+	}
+	if seg.Failure != "" {
+		s.sendFailure(seg)
+		return
+	}
+	speakerName := s.client.SpeakerName(seg.SpeakerSessionID)
+	// Placeholders and their clearing are for the call participants only
+	if s.feed.HasSubscribers() && !seg.Pending && seg.Message != "" {
+		s.feed.Publish(transcript.FeedEvent{
+			Type:             "translation",
+			LangID:           seg.TargetLanguage,
+			Message:          seg.Message,
+			Final:            seg.Final,
+			SpeakerSessionID: seg.SpeakerSessionID,
+			SpeakerName:      speakerName,
+			OriginLangID:     seg.OriginLanguage,
+		})
+	}
 	for ncSid := range seg.TargetNcSessionIDs {
 		hpbSid := s.client.ResolveNcSessionID(ncSid)
 		if hpbSid == "" {
@@ -85,7 +129,7 @@ func (s *TranslatedSender) sendTranslatedText(seg transcript.TranslateInputOutpu
 				"nc_session_id", ncSid)
 			continue
 		}
-		finalVal := true
+		finalVal := seg.Final
 		s.client.SendMessage(signaling.SignalingMessage{
 			Type: "message",
 			Message: &signaling.DataMessage{
@@ -94,8 +138,37 @@ func (s *TranslatedSender) sendTranslatedText(seg transcript.TranslateInputOutpu
 					LangID:           seg.TargetLanguage,
 					Message:          seg.Message,
 					SpeakerSessionID: seg.SpeakerSessionID,
+					SpeakerName:      speakerName,
 					Final:            &finalVal,
 					Type:             "transcript",
+					Unattributed:     seg.SpeakerSessionID == "",
+
+					TranslationPending: seg.Pending,
+				},
+			},
+		})
+	}
+}
+
+// sendFailure tells the targets that their language could not be translated
+// into, so clients can explain the missing captions or fall back to the
+// original language.
+func (s *TranslatedSender) sendFailure(seg transcript.TranslateInputOutput) {
+	s.logger.Info("notifying targets of failed translation",
+		"target_lang", seg.TargetLanguage, "reason", seg.Failure, "targets", len(seg.TargetNcSessionIDs))
+	for ncSid := range seg.TargetNcSessionIDs {
+		hpbSid := s.client.ResolveNcSessionID(ncSid)
+		if hpbSid == "" {
+			continue
+		}
+		s.client.SendMessage(signaling.SignalingMessage{
+			Type: "message",
+			Message: &signaling.DataMessage{
+				Recipient: &signaling.Recipient{Type: "session", SessionID: hpbSid},
+				Data: &signaling.MessagePayload{
+					Type:   "translationError",
+					LangID: seg.TargetLanguage,
+					Reason: seg.Failure,
 				},
 			},
 		})
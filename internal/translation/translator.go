@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package translation
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+)
+
+// Translator translates text from one origin into one target language.
+// OCPTranslator, using Nextcloud's task processing, is the default; other
+// backends such as a local MT service plug in through RegisterBackend.
+type Translator interface {
+	OriginLanguage() string
+	TargetLanguage() string
+
+	Translate(ctx context.Context, message string) (string, error)
+	// TranslateBatch returns one translation per message, in order.
+	TranslateBatch(ctx context.Context, messages []string) ([]string, error)
+
+	// IsLanguagePairSupported returns an error wrapping
+	// ErrTranslateLangPair if the pair cannot be translated.
+	IsLanguagePairSupported(ctx context.Context) error
+	// GetTranslationLanguages returns every language the backend offers,
+	// regardless of the translator's own pair.
+	GetTranslationLanguages(ctx context.Context) (*SupportedTranslationLanguages, error)
+}
+
+// Backend creates the translator of a language pair for a room.
+type Backend func(client *appapi.Client, cfg *appapi.Config, roomToken, originLangID, targetLangID string) Translator
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]Backend{
+		"ocp": newOCPBackend,
+	}
+)
+
+// RegisterBackend makes a translation backend selectable with
+// LT_TRANSLATION_BACKEND. It is meant to be called from an init function.
+func RegisterBackend(name string, backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = backend
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// HasBackend reports whether a backend of that name is registered.
+func HasBackend(name string) bool {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	_, ok := backends[name]
+	return ok
+}
+
+// NewTranslator creates a translator with the backend selected in cfg,
+// which main has checked with HasBackend.
+func NewTranslator(client *appapi.Client, cfg *appapi.Config, roomToken, originLangID, targetLangID string) Translator {
+	backendsMu.RLock()
+	backend := backends[cfg.TranslationBackend]
+	backendsMu.RUnlock()
+	return backend(client, cfg, roomToken, originLangID, targetLangID)
+}
+
+func newOCPBackend(client *appapi.Client, cfg *appapi.Config, roomToken, originLangID, targetLangID string) Translator {
+	poll := DefaultPollOptions()
+	poll.InitialInterval = cfg.TranslationPollInitialInterval
+	poll.Deadline = cfg.TranslationPollDeadline
+	translator := NewOCPTranslator(client, originLangID, targetLangID, roomToken, poll)
+	translator.enableCache(cfg.TranslationCacheSize, cfg.TranslationCacheTTL)
+	return translator
+}
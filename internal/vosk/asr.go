@@ -0,0 +1,27 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/asr"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+)
+
+var _ asr.Transcriber = (*TranscriberManager)(nil)
+
+func init() {
+	asr.RegisterBackend("vosk", newBackend, asr.Capabilities{LocalModels: true})
+}
+
+func newBackend(cfg *appapi.Config, languageID string, transcriptCh chan signaling.Transcript) (asr.Transcriber, error) {
+	return NewTranscriberManager(languageID, asr.SampleRate, RecognizerOptions{
+		RecreateOnForceFinalize: cfg.RecreateRecognizerOnForceFinalize,
+		WordTimings:             cfg.WordTimings,
+		StopTokenMaxConfidence:  cfg.StopTokenMaxConfidence,
+		MaxAlternatives:         cfg.MaxAlternatives,
+		MinFinalWords:           cfg.MinFinalWords,
+		MinFinalChars:           cfg.MinFinalChars,
+	}, transcriptCh), nil
+}
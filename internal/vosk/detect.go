@@ -0,0 +1,269 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"encoding/json"
+
+	vosk "github.com/alphacep/vosk-api/go"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// languageDetector identifies the language of a new speaker. Vosk has no
+// language identification, so the speaker's first seconds of audio are fed
+// to a recognizer per candidate language, and the language whose model
+// recognizes the most words with the most confidence wins. The audio is
+// kept to be replayed into the speaker's real recognizer.
+//
+// The candidates are loaded and fed on the detector's own goroutine, off
+// the room's audio path, which only hands the audio over and picks up the
+// result. Audio the goroutine is too busy to take is not scored.
+type languageDetector struct {
+	buffered []byte        // guarded by the manager's mu
+	in       chan []byte   // audio to score
+	stop     chan struct{} // closed to abandon the detection
+	result   chan string   // the detected language, "" for none, once
+}
+
+// detectorQueue bounds the audio chunks waiting to be scored.
+const detectorQueue = 64
+
+// startDetector starts detecting a speaker's language among candidates.
+func (tm *TranscriberManager) startDetector(sessionID string, candidates []string) *languageDetector {
+	d := &languageDetector{
+		in:     make(chan []byte, detectorQueue),
+		stop:   make(chan struct{}),
+		result: make(chan string, 1),
+	}
+	go tm.runDetector(sessionID, d, candidates)
+	return d
+}
+
+// runDetector scores the speaker's audio until a language is recognized,
+// or none is within LanguageDetectionMaxWindow. Words are scored as the
+// candidates finalize utterances, so a decision covers all the audio seen;
+// only the last decision forces the running utterances to finalize.
+func (tm *TranscriberManager) runDetector(sessionID string, d *languageDetector, candidates []string) {
+	var lang string
+	defer func() { d.result <- lang }()
+
+	recs := tm.loadDetectorRecognizers(candidates)
+	defer tm.freeDetectorRecognizers(recs)
+	if len(recs) == 0 {
+		tm.logger.Warn("no candidate model for language detection available, using the room language",
+			"session_id", sessionID, "candidates", candidates)
+		return
+	}
+
+	bytesPerSecond := tm.sampleRate * 2 // 16-bit mono
+	window := int(bytesPerSecond * constants.LanguageDetectionWindow.Seconds())
+	maxWindow := int(bytesPerSecond * constants.LanguageDetectionMaxWindow.Seconds())
+
+	scores := make(map[string]float64, len(recs))
+	var fed int
+	for {
+		select {
+		case <-d.stop:
+			return
+		case pcm := <-d.in:
+			fed += len(pcm)
+			for l, rec := range recs {
+				if rec.AcceptWaveform(pcm) != 0 {
+					scores[l] += wordConfidence(rec.Result())
+				}
+			}
+		}
+		if fed < window {
+			continue
+		}
+		if lang = bestLanguage(scores); lang != "" {
+			return
+		}
+		if fed >= maxWindow {
+			for l, rec := range recs {
+				scores[l] += wordConfidence(rec.FinalResult())
+			}
+			lang = bestLanguage(scores)
+			return
+		}
+	}
+}
+
+// loadDetectorRecognizers creates a recognizer per installed candidate, as
+// far as the recognizer limits allow: detectors count towards them but are
+// never evicted, so they only take free places.
+func (tm *TranscriberManager) loadDetectorRecognizers(candidates []string) map[string]*vosk.VoskRecognizer {
+	recs := make(map[string]*vosk.VoskRecognizer, len(candidates))
+	for _, lang := range candidates {
+		if !tm.reserveDetectorRecognizer() {
+			tm.logger.Info("recognizer limit reached, detecting among fewer candidates",
+				"loaded", len(recs), "candidates", len(candidates))
+			break
+		}
+		rec, err := newDetectorRecognizer(lang, tm.sampleRate)
+		if err != nil {
+			tm.releaseDetectorRecognizer()
+			continue
+		}
+		recs[lang] = rec
+	}
+	return recs
+}
+
+func newDetectorRecognizer(lang string, sampleRate float64) (*vosk.VoskRecognizer, error) {
+	model, err := GetModelManager().GetModel(lang)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := vosk.NewRecognizer(model, sampleRate)
+	if err != nil {
+		GetModelManager().ReleaseModel(lang)
+		return nil, err
+	}
+	rec.SetWords(1)
+	return rec, nil
+}
+
+// reserveDetectorRecognizer counts a detector recognizer if both limits
+// leave room for it.
+func (tm *TranscriberManager) reserveDetectorRecognizer() bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if limit := perRoomLimit(); limit > 0 && len(tm.recognizers)+tm.detectorRecs >= limit {
+		return false
+	}
+	if limit := globalLimit(); limit > 0 && liveRecognizers.Load() >= int64(limit) {
+		return false
+	}
+	tm.detectorRecs++
+	liveRecognizers.Add(1)
+	return true
+}
+
+func (tm *TranscriberManager) releaseDetectorRecognizer() {
+	tm.mu.Lock()
+	tm.detectorRecs--
+	tm.mu.Unlock()
+	liveRecognizers.Add(-1)
+}
+
+// freeDetectorRecognizers frees the candidate recognizers and releases
+// their models and places.
+func (tm *TranscriberManager) freeDetectorRecognizers(recs map[string]*vosk.VoskRecognizer) {
+	for lang, rec := range recs {
+		rec.Free()
+		GetModelManager().ReleaseModel(lang)
+		tm.releaseDetectorRecognizer()
+	}
+}
+
+// wordConfidence sums the word confidences of a Vosk result.
+func wordConfidence(resultJSON string) float64 {
+	var result voskResult
+	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil {
+		return 0
+	}
+	var score float64
+	for _, w := range result.Result {
+		score += w.Conf
+	}
+	return score
+}
+
+// bestLanguage returns the language with the highest score, or "" if none
+// recognized a word.
+func bestLanguage(scores map[string]float64) string {
+	best, bestScore := "", 0.0
+	for lang, score := range scores {
+		if score > bestScore {
+			best, bestScore = lang, score
+		}
+	}
+	return best
+}
+
+// SetLanguageDetection detects the language of speakers without an assigned
+// language among the candidates, or with no candidates stops detecting.
+// Speakers already transcribed keep their language.
+func (tm *TranscriberManager) SetLanguageDetection(candidates []string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.detectCandidates = candidates
+}
+
+// Feed passes a speaker's audio to their recognizer, detecting the
+// speaker's language first if language detection is enabled.
+func (tm *TranscriberManager) Feed(sessionID string, pcm []byte) error {
+	if pcm = tm.detect(sessionID, pcm); len(pcm) == 0 {
+		return nil
+	}
+	r, err := tm.GetOrCreate(sessionID)
+	if err != nil {
+		return err
+	}
+	r.FeedAudio(pcm)
+	return nil
+}
+
+// detect runs the speaker's language detection and returns the audio to
+// feed to their recognizer: nothing while detecting, then all the audio
+// buffered during the detection.
+func (tm *TranscriberManager) detect(sessionID string, pcm []byte) []byte {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	d := tm.detectors[sessionID]
+	_, assigned := tm.speakerLangs[sessionID]
+	_, detected := tm.detectedLangs[sessionID]
+	_, running := tm.recognizers[sessionID]
+	if len(tm.detectCandidates) == 0 || assigned || detected || (running && d == nil) {
+		if d != nil {
+			// Detection was disabled or a language assigned meanwhile
+			pcm = append(d.buffered, pcm...)
+			tm.stopDetectorLocked(sessionID)
+		}
+		return pcm
+	}
+
+	if d == nil {
+		d = tm.startDetector(sessionID, tm.detectCandidates)
+		tm.detectors[sessionID] = d
+	}
+	d.buffered = append(d.buffered, pcm...)
+
+	select {
+	case lang := <-d.result:
+		delete(tm.detectors, sessionID)
+		// Empty falls back to the room language
+		tm.detectedLangs[sessionID] = lang
+		tm.logger.Info("detected speaker language", "session_id", sessionID, "language", lang,
+			"fallback", lang == "")
+		return d.buffered
+	default:
+	}
+
+	bytesPerSecond := tm.sampleRate * 2
+	if len(d.buffered) > int(2*bytesPerSecond*constants.LanguageDetectionMaxWindow.Seconds()) {
+		// The detector falls too far behind the audio
+		tm.stopDetectorLocked(sessionID)
+		tm.detectedLangs[sessionID] = ""
+		tm.logger.Warn("language detection too slow, using the room language", "session_id", sessionID)
+		return d.buffered
+	}
+	select {
+	case d.in <- pcm:
+	default:
+	}
+	return nil
+}
+
+// stopDetectorLocked abandons the speaker's detection, if any. Its
+// goroutine frees the candidates. Must be called with tm.mu held.
+func (tm *TranscriberManager) stopDetectorLocked(sessionID string) {
+	if d, ok := tm.detectors[sessionID]; ok {
+		close(d.stop)
+		delete(tm.detectors, sessionID)
+	}
+}
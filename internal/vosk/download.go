@@ -10,18 +10,66 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 
 	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/languages"
 )
 
-const (
-	hfRepo     = "Nextcloud-AI/vosk-models"
-	hfRevision = "06f2f156dcd79092400891afb6cf8101e54f6ba2"
-	hfAPIBase  = "https://huggingface.co/api/models"
-	hfResolve  = "https://huggingface.co"
-)
+// modelSource describes where models are fetched from. The base URL must
+// expose the same API layout as huggingface.co (/api/models/<repo>/tree/<rev>
+// and /<repo>/resolve/<rev>/<path>), which allows pointing at an internal mirror.
+type modelSource struct {
+	baseURL  string
+	repo     string
+	revision string
+}
+
+var currentModelSource atomic.Pointer[modelSource]
+
+// SetModelSource sets where models are downloaded from, as validated by
+// appapi.LoadConfig.
+func SetModelSource(baseURL, repo, revision string) {
+	currentModelSource.Store(&modelSource{baseURL: baseURL, repo: repo, revision: revision})
+}
+
+func (s *modelSource) treeURL(prefix string) string {
+	u := fmt.Sprintf("%s/api/models/%s/tree/%s", s.baseURL, s.repo, s.revision)
+	if prefix != "" {
+		u += "/" + prefix
+	}
+	return u
+}
+
+func (s *modelSource) resolveURL(filePath string) string {
+	return fmt.Sprintf("%s/%s/resolve/%s/%s", s.baseURL, s.repo, s.revision, filePath)
+}
+
+// downloadClient fetches the models. It has no timeout, as model files
+// take long to download; requests are bounded by their context.
+var downloadClient atomic.Pointer[http.Client]
+
+func init() {
+	downloadClient.Store(http.DefaultClient)
+	currentModelSource.Store(&modelSource{
+		baseURL:  constants.ModelsBaseURL,
+		repo:     constants.ModelsRepo,
+		revision: constants.ModelsRevision,
+	})
+}
+
+// SetDownloadProxy routes the model downloads through the proxy selected
+// by proxy, see appapi.Config.ProxyFunc.
+func SetDownloadProxy(proxy func(*http.Request) (*url.URL, error)) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	downloadClient.Store(&http.Client{Transport: transport})
+}
 
 type hfEntry struct {
 	Type string `json:"type"`
@@ -29,31 +77,56 @@ type hfEntry struct {
 	Size int64  `json:"size"`
 }
 
-func DownloadModels(client *appapi.Client, storageDir string) error {
-	slog.Info("starting model download", "repo", hfRepo, "dest", storageDir)
+// DownloadModels downloads the models not yet in storageDir, reporting the
+// progress to AppAPI and in CurrentInitStatus.
+func DownloadModels(ctx context.Context, client *appapi.Client, storageDir string) (err error) {
+	updateInitStatus(func(s *InitStatus) {
+		*s = InitStatus{Phase: InitListing}
+	})
+	defer func() {
+		updateInitStatus(func(s *InitStatus) {
+			if err != nil {
+				s.Phase = InitFailed
+				s.LastError = err.Error()
+				return
+			}
+			s.Phase, s.Percent, s.CurrentFile = InitDone, 100, ""
+			s.FilesDone = s.FilesTotal
+		})
+	}()
+
+	src := currentModelSource.Load()
+
+	slog.Info("starting model download",
+		"base_url", src.baseURL,
+		"repo", src.repo,
+		"revision", src.revision,
+		"dest", storageDir,
+	)
 
 	if err := os.MkdirAll(storageDir, 0o755); err != nil {
 		return fmt.Errorf("create storage dir: %w", err)
 	}
+	// Even a failed download may leave complete model directories behind
+	defer GetModelManager().InvalidateAvailableModels()
 
-	files, err := listAllFiles("")
+	if files, ok := verifiedManifestFiles(storageDir, src, ""); ok {
+		slog.Info("all models already downloaded, verified against the manifest", "files", len(files))
+		return nil
+	}
+
+	files, err := listAllFiles(ctx, src, "")
 	if err != nil {
 		return fmt.Errorf("list repo files: %w", err)
 	}
 
 	slog.Info("found files to download", "total", len(files))
 
-	var toDownload []hfEntry
-	for _, f := range files {
-		localPath := filepath.Join(storageDir, f.Path)
-		if info, err := os.Stat(localPath); err == nil && info.Size() == f.Size {
-			continue // already downloaded
-		}
-		toDownload = append(toDownload, f)
-	}
+	toDownload := pendingFiles(storageDir, files)
 
 	if len(toDownload) == 0 {
 		slog.Info("all models already downloaded")
+		saveManifest(storageDir, src, files)
 		return nil
 	}
 
@@ -61,11 +134,18 @@ func DownloadModels(client *appapi.Client, storageDir string) error {
 
 	for i, f := range toDownload {
 		progress := int(float64(i) / float64(len(toDownload)) * 99)
-		if err := client.SetInitStatus(progress); err != nil {
+		updateInitStatus(func(s *InitStatus) {
+			s.Phase = InitDownloading
+			s.Percent = progress
+			s.CurrentFile = f.Path
+			s.FilesDone = i
+			s.FilesTotal = len(toDownload)
+		})
+		if err := client.SetInitStatus(ctx, progress); err != nil {
 			slog.Warn("failed to report init progress", "error", err, "progress", progress)
 		}
 
-		if err := downloadFile(storageDir, f.Path); err != nil {
+		if err := downloadFile(ctx, src, storageDir, f.Path); err != nil {
 			return fmt.Errorf("download %s: %w", f.Path, err)
 		}
 
@@ -75,32 +155,97 @@ func DownloadModels(client *appapi.Client, storageDir string) error {
 	}
 
 	slog.Info("model download complete", "files", len(toDownload))
+	saveManifest(storageDir, src, files)
 	return nil
 }
 
-func listAllFiles(prefix string) ([]hfEntry, error) {
-	url := fmt.Sprintf("%s/%s/tree/%s", hfAPIBase, hfRepo, hfRevision)
-	if prefix != "" {
-		url += "/" + prefix
+// saveManifest writes the manifest of a complete download. Without it the
+// next start lists the repository again, so failing is only logged.
+func saveManifest(storageDir string, src *modelSource, files []hfEntry) {
+	if err := writeManifest(storageDir, src, files); err != nil {
+		slog.Warn("failed to write model manifest", "error", err)
 	}
+}
 
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, http.NoBody)
-	if err != nil {
-		return nil, fmt.Errorf("create request %s: %w", url, err)
+// DownloadModel downloads only the model directory of a single language.
+func DownloadModel(ctx context.Context, storageDir, lang string) error {
+	modelDir, ok := languages.ModelsList[lang]
+	if !ok {
+		return fmt.Errorf("no model available for language: %s", lang)
 	}
-	resp, err := http.DefaultClient.Do(req)
+
+	src := currentModelSource.Load()
+
+	slog.Info("starting single model download", "lang", lang, "model", modelDir, "dest", storageDir)
+
+	if err := os.MkdirAll(storageDir, 0o755); err != nil {
+		return fmt.Errorf("create storage dir: %w", err)
+	}
+	defer GetModelManager().InvalidateAvailableModels()
+
+	if _, ok := verifiedManifestFiles(storageDir, src, modelDir); ok {
+		slog.Info("model already downloaded", "lang", lang)
+		return nil
+	}
+
+	files, err := listAllFiles(ctx, src, modelDir)
 	if err != nil {
-		return nil, fmt.Errorf("GET %s: %w", url, err)
+		return fmt.Errorf("list model files: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	toDownload := pendingFiles(storageDir, files)
+	if len(toDownload) == 0 {
+		slog.Info("model already downloaded", "lang", lang)
+		return nil
+	}
+
+	for _, f := range toDownload {
+		if err := downloadFile(ctx, src, storageDir, f.Path); err != nil {
+			return fmt.Errorf("download %s: %w", f.Path, err)
+		}
+	}
+
+	slog.Info("single model download complete", "lang", lang, "files", len(toDownload))
+	return nil
+}
+
+// pendingFiles returns the entries that are missing locally or whose size differs.
+func pendingFiles(storageDir string, files []hfEntry) []hfEntry {
+	var toDownload []hfEntry
+	for _, f := range files {
+		localPath := filepath.Join(storageDir, f.Path)
+		if info, err := os.Stat(localPath); err == nil && info.Size() == f.Size {
+			continue // already downloaded
+		}
+		toDownload = append(toDownload, f)
 	}
+	return toDownload
+}
+
+// maxTreePages bounds the number of pages followed for a single directory
+// listing, protecting against a mirror that keeps returning a next link.
+const maxTreePages = 1000
 
+func listAllFiles(ctx context.Context, src *modelSource, prefix string) ([]hfEntry, error) {
 	var entries []hfEntry
-	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
-		return nil, fmt.Errorf("decode response: %w", err)
+	pageURL := src.treeURL(prefix)
+	seen := make(map[string]struct{})
+
+	for page := 0; pageURL != ""; page++ {
+		if page >= maxTreePages {
+			return nil, fmt.Errorf("list %s: more than %d pages", src.treeURL(prefix), maxTreePages)
+		}
+		if _, ok := seen[pageURL]; ok {
+			return nil, fmt.Errorf("list %s: pagination loop at %s", src.treeURL(prefix), pageURL)
+		}
+		seen[pageURL] = struct{}{}
+
+		pageEntries, next, err := listTreePage(ctx, pageURL)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, pageEntries...)
+		pageURL = next
 	}
 
 	var files []hfEntry
@@ -109,7 +254,7 @@ func listAllFiles(prefix string) ([]hfEntry, error) {
 		case "file":
 			files = append(files, e)
 		case "directory":
-			subFiles, err := listAllFiles(e.Path)
+			subFiles, err := listAllFiles(ctx, src, e.Path)
 			if err != nil {
 				return nil, err
 			}
@@ -120,19 +265,85 @@ func listAllFiles(prefix string) ([]hfEntry, error) {
 	return files, nil
 }
 
-func downloadFile(storageDir, filePath string) error {
-	url := fmt.Sprintf("%s/%s/resolve/%s/%s", hfResolve, hfRepo, hfRevision, filePath)
+// listTreePage fetches one page of a tree listing and returns the URL of the
+// next page, taken from the rel="next" Link header, or "" on the last page.
+func listTreePage(ctx context.Context, pageURL string) ([]hfEntry, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, http.NoBody)
+	if err != nil {
+		return nil, "", fmt.Errorf("create request %s: %w", pageURL, err)
+	}
+	resp, err := downloadClient.Load().Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("GET %s: %w", pageURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GET %s: status %d", pageURL, resp.StatusCode)
+	}
+
+	var entries []hfEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, "", fmt.Errorf("decode response: %w", err)
+	}
+
+	next, err := nextPageURL(pageURL, resp.Header.Values("Link"))
+	if err != nil {
+		return nil, "", err
+	}
+	return entries, next, nil
+}
+
+// nextPageURL extracts the rel="next" target from Link headers, resolving it
+// relative to the current page URL.
+func nextPageURL(pageURL string, linkHeaders []string) (string, error) {
+	for _, header := range linkHeaders {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			isNext := false
+			for _, param := range parts[1:] {
+				param = strings.ReplaceAll(strings.TrimSpace(param), `"`, "")
+				if strings.EqualFold(param, "rel=next") {
+					isNext = true
+					break
+				}
+			}
+			if !isNext {
+				continue
+			}
+
+			base, err := url.Parse(pageURL)
+			if err != nil {
+				return "", fmt.Errorf("parse page URL %s: %w", pageURL, err)
+			}
+			ref, err := url.Parse(target[1 : len(target)-1])
+			if err != nil {
+				return "", fmt.Errorf("parse next link %s: %w", target, err)
+			}
+			return base.ResolveReference(ref).String(), nil
+		}
+	}
+	return "", nil
+}
+
+func downloadFile(ctx context.Context, src *modelSource, storageDir, filePath string) error {
+	url := src.resolveURL(filePath)
 	localPath := filepath.Join(storageDir, filePath)
 
 	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
 		return fmt.Errorf("mkdir: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, http.NoBody)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, http.NoBody)
 	if err != nil {
 		return fmt.Errorf("create request %s: %w", url, err)
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := downloadClient.Load().Do(req)
 	if err != nil {
 		return fmt.Errorf("GET %s: %w", url, err)
 	}
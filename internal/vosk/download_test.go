@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNextPageURL(t *testing.T) {
+	const page = "https://hf.example/api/models/repo/tree/main?cursor=1"
+	for _, tt := range []struct {
+		name  string
+		links []string
+		want  string
+	}{
+		{"none", nil, ""},
+		{"absolute", []string{`<https://hf.example/api/models/repo/tree/main?cursor=2>; rel="next"`},
+			"https://hf.example/api/models/repo/tree/main?cursor=2"},
+		{"relative", []string{`</api/models/repo/tree/main?cursor=2>; rel="next"`},
+			"https://hf.example/api/models/repo/tree/main?cursor=2"},
+		{"unquoted", []string{`<?cursor=2>; rel=next`},
+			"https://hf.example/api/models/repo/tree/main?cursor=2"},
+		{"among others", []string{`<?cursor=0>; rel="prev", <?cursor=2>; rel="next"`},
+			"https://hf.example/api/models/repo/tree/main?cursor=2"},
+		{"second header", []string{`<?cursor=0>; rel="prev"`, `<?cursor=2>; rel="next"`},
+			"https://hf.example/api/models/repo/tree/main?cursor=2"},
+		{"last page", []string{`<?cursor=0>; rel="prev"`}, ""},
+		{"malformed target", []string{`?cursor=2; rel="next"`}, ""},
+	} {
+		got, err := nextPageURL(page, tt.links)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: nextPageURL = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// fakeHub serves tree listings of the pages by path and cursor, linking
+// each page to the next one, and every file with the content "model".
+func fakeHub(t *testing.T, pages map[string][][]hfEntry) *modelSource {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/repo/resolve/main/") {
+			fmt.Fprint(w, "model")
+			return
+		}
+		prefix := strings.TrimPrefix(r.URL.Path, "/api/models/repo/tree/main")
+		dirPages, ok := pages[strings.TrimPrefix(prefix, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		cursor, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		if cursor+1 < len(dirPages) {
+			w.Header().Set("Link", fmt.Sprintf(`<?cursor=%d>; rel="next"`, cursor+1))
+		}
+		json.NewEncoder(w).Encode(dirPages[cursor])
+	}))
+	t.Cleanup(srv.Close)
+	return &modelSource{baseURL: srv.URL, repo: "repo", revision: "main"}
+}
+
+func TestListAllFilesPaginated(t *testing.T) {
+	src := fakeHub(t, map[string][][]hfEntry{
+		"": {
+			{{Type: "file", Path: "a"}, {Type: "directory", Path: "sub"}},
+			{{Type: "file", Path: "b"}},
+		},
+		"sub": {
+			{{Type: "file", Path: "sub/c"}},
+			{},
+			{{Type: "file", Path: "sub/d"}},
+		},
+	})
+
+	files, err := listAllFiles(context.Background(), src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	if want := []string{"a", "sub/c", "sub/d", "b"}; !slices.Equal(paths, want) {
+		t.Errorf("listed %q, want %q", paths, want)
+	}
+}
+
+func TestListAllFilesPaginationLoop(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<?cursor=1>; rel="next"`)
+		json.NewEncoder(w).Encode([]hfEntry{{Type: "file", Path: "a"}})
+	}))
+	t.Cleanup(srv.Close)
+	src := &modelSource{baseURL: srv.URL, repo: "repo", revision: "main"}
+
+	_, err := listAllFiles(context.Background(), src, "")
+	if err == nil || !strings.Contains(err.Error(), "pagination loop") {
+		t.Errorf("error %v, want a pagination loop", err)
+	}
+}
@@ -0,0 +1,87 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+
+	vosk "github.com/alphacep/vosk-api/go"
+)
+
+// unknownWord is what Vosk recognizes speech outside a grammar as.
+const unknownWord = "[unk]"
+
+// SetGrammar constrains the room's recognizers to the phrases. Vosk
+// applies phrases only as a grammar, so speech outside them is not
+// transcribed; it suits rooms with a fixed set of terms, not open
+// conversation. Running recognizers finish their utterance and are
+// recreated with the grammar on the speakers' next audio. No phrases
+// return to free recognition.
+func (tm *TranscriberManager) SetGrammar(phrases []string) {
+	tm.mu.Lock()
+	tm.grammar = slices.Clone(phrases)
+	old := make([]*Recognizer, 0, len(tm.recognizers))
+	for sid, r := range tm.recognizers {
+		old = append(old, r)
+		delete(tm.recognizers, sid)
+	}
+	tm.mu.Unlock()
+
+	finishRecognizers(old...)
+	tm.logger.Info("grammar set", "phrases", len(phrases))
+}
+
+// grammarLocked returns the grammar of the room's phrases for a model,
+// or "" to recognize freely: without phrases, or if the model has no
+// runtime graph to apply a grammar to. Must be called with tm.mu held.
+func (tm *TranscriberManager) grammarLocked(language string, model *vosk.VoskModel) string {
+	if len(tm.grammar) == 0 {
+		return ""
+	}
+	if !GetModelManager().SupportsGrammar(language) {
+		tm.logger.Warn("model does not support a grammar, recognizing freely", "language", language)
+		return ""
+	}
+	return buildGrammar(model, tm.grammar, tm.logger)
+}
+
+// buildGrammar returns the Vosk grammar of the phrases the model knows all
+// words of. Speech outside them is let through as unknown words, which are
+// dropped from the transcripts, rather than forced onto a phrase.
+func buildGrammar(model *vosk.VoskModel, phrases []string, logger *slog.Logger) string {
+	grammar := make([]string, 0, len(phrases)+1)
+	for _, phrase := range phrases {
+		words := strings.Fields(strings.ToLower(phrase))
+		if len(words) == 0 {
+			continue
+		}
+		if i := slices.IndexFunc(words, func(w string) bool { return model.FindWord(w) < 0 }); i >= 0 {
+			logger.Warn("skipping grammar phrase, the model does not know a word", "word", words[i])
+			continue
+		}
+		grammar = append(grammar, strings.Join(words, " "))
+	}
+	if len(grammar) == 0 {
+		return ""
+	}
+
+	grammar = append(grammar, unknownWord)
+	data, err := json.Marshal(grammar)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// stripUnknownWords removes the unknown words of grammar recognition.
+func stripUnknownWords(text string) string {
+	if !strings.Contains(text, unknownWord) {
+		return text
+	}
+	words := slices.DeleteFunc(strings.Fields(text), func(w string) bool { return w == unknownWord })
+	return strings.Join(words, " ")
+}
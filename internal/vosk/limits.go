@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// liveRecognizers counts the open recognizers of the process.
+var liveRecognizers atomic.Int64
+
+// recognizerLimits bounds the recognizers per room and process wide. Each
+// one holds C memory, so large calls where many people speak would grow
+// without bound. When a limit is hit, the least recently fed recognizer is
+// finalized and closed to make room, if it has been idle for
+// RecognizerEvictMinIdle; its speaker gets a new one on demand. Without an
+// idle one the new speaker is not transcribed until one is.
+var recognizerLimits = struct {
+	mu       sync.Mutex
+	perRoom  int // 0 = no limit
+	global   int // 0 = no limit
+	managers map[*TranscriberManager]struct{}
+}{managers: make(map[*TranscriberManager]struct{})}
+
+// SetRecognizerLimits sets the maximum recognizers per room and in total,
+// 0 meaning no limit. Must be called before rooms are created.
+func SetRecognizerLimits(perRoom, global int) {
+	recognizerLimits.mu.Lock()
+	defer recognizerLimits.mu.Unlock()
+	recognizerLimits.perRoom = perRoom
+	recognizerLimits.global = global
+}
+
+// LiveRecognizers returns the number of open recognizers of the process.
+func LiveRecognizers() int64 {
+	return liveRecognizers.Load()
+}
+
+func registerManager(tm *TranscriberManager) {
+	recognizerLimits.mu.Lock()
+	defer recognizerLimits.mu.Unlock()
+	recognizerLimits.managers[tm] = struct{}{}
+}
+
+func unregisterManager(tm *TranscriberManager) {
+	recognizerLimits.mu.Lock()
+	defer recognizerLimits.mu.Unlock()
+	delete(recognizerLimits.managers, tm)
+}
+
+func perRoomLimit() int {
+	recognizerLimits.mu.Lock()
+	defer recognizerLimits.mu.Unlock()
+	return recognizerLimits.perRoom
+}
+
+func globalLimit() int {
+	recognizerLimits.mu.Lock()
+	defer recognizerLimits.mu.Unlock()
+	return recognizerLimits.global
+}
+
+// makeRoomGlobally evicts the least recently fed recognizer of all rooms if
+// the process wide limit is reached, or fails with ErrRecognizerLimit if
+// none is idle. Concurrent creations may overshoot the limit briefly; the
+// next creation evicts again.
+func makeRoomGlobally() error {
+	recognizerLimits.mu.Lock()
+	limit := recognizerLimits.global
+	managers := make([]*TranscriberManager, 0, len(recognizerLimits.managers))
+	for tm := range recognizerLimits.managers {
+		managers = append(managers, tm)
+	}
+	recognizerLimits.mu.Unlock()
+
+	if limit <= 0 || liveRecognizers.Load() < int64(limit) {
+		return nil
+	}
+
+	var oldestTM *TranscriberManager
+	var oldestSid string
+	var oldest time.Time
+	for _, tm := range managers {
+		tm.mu.Lock()
+		sid, lastFeed, ok := tm.leastRecentLocked()
+		tm.mu.Unlock()
+		if ok && (oldestTM == nil || lastFeed.Before(oldest)) {
+			oldestTM, oldestSid, oldest = tm, sid, lastFeed
+		}
+	}
+	if oldestTM == nil || time.Since(oldest) < constants.RecognizerEvictMinIdle {
+		return ErrRecognizerLimit
+	}
+
+	oldestTM.mu.Lock()
+	r := oldestTM.detachIdleLocked(oldestSid)
+	oldestTM.mu.Unlock()
+	if r == nil {
+		// Fed meanwhile, the next creation tries again
+		return ErrRecognizerLimit
+	}
+	slog.Info("recognizer limit reached, evicting least recently active",
+		"session_id", oldestSid, "max_recognizers", limit)
+	finishRecognizers(r)
+	return nil
+}
+
+// leastRecentLocked returns the recognizer of the room fed least recently.
+// Must be called with tm.mu held.
+func (tm *TranscriberManager) leastRecentLocked() (sessionID string, lastFeed time.Time, ok bool) {
+	for sid, r := range tm.recognizers {
+		t := r.LastFeed()
+		if !ok || t.Before(lastFeed) {
+			sessionID, lastFeed, ok = sid, t, true
+		}
+	}
+	return sessionID, lastFeed, ok
+}
+
+// detachIdleLocked removes the speaker's recognizer if it has been idle for
+// RecognizerEvictMinIdle and returns it, for the caller to finish without
+// the lock. Must be called with tm.mu held.
+func (tm *TranscriberManager) detachIdleLocked(sessionID string) *Recognizer {
+	r, ok := tm.recognizers[sessionID]
+	if !ok || time.Since(r.LastFeed()) < constants.RecognizerEvictMinIdle {
+		return nil
+	}
+	delete(tm.recognizers, sessionID)
+	return r
+}
+
+// finishRecognizers finalizes the current utterances of detached
+// recognizers and closes them. Flushing waits for the recognizers, so it
+// runs without tm.mu.
+func finishRecognizers(rs ...*Recognizer) {
+	for _, r := range rs {
+		r.Flush()
+		r.Close()
+	}
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+func testRecognizer(lastFeed time.Time) *Recognizer {
+	return &Recognizer{lastFeed: lastFeed, logger: slog.Default()}
+}
+
+func TestRoomLimitEvictsOnlyIdle(t *testing.T) {
+	SetRecognizerLimits(1, 0)
+	t.Cleanup(func() { SetRecognizerLimits(0, 0) })
+
+	tm := NewTranscriberManager("en", 16000, RecognizerOptions{}, nil)
+	t.Cleanup(tm.CloseAll)
+
+	// A speaker mid-utterance keeps their recognizer
+	busy := testRecognizer(time.Now())
+	tm.recognizers["busy"] = busy
+	if _, evicted, err := tm.create("new"); !errors.Is(err, ErrRecognizerLimit) || evicted != nil {
+		t.Fatalf("create at the limit: evicted %v, error %v, want ErrRecognizerLimit", evicted, err)
+	}
+	if tm.recognizers["busy"] != busy {
+		t.Fatal("busy recognizer evicted")
+	}
+
+	// Once idle long enough it makes room, detached for the caller to
+	// finish without the lock
+	busy.lastFeed = time.Now().Add(-constants.RecognizerEvictMinIdle)
+	_, evicted, _ := tm.create("new")
+	if evicted != busy {
+		t.Fatalf("evicted %v, want the idle recognizer", evicted)
+	}
+	if _, ok := tm.recognizers["busy"]; ok {
+		t.Error("evicted recognizer still in the room")
+	}
+	finishRecognizers(evicted)
+}
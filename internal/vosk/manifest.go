@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// manifestName is the file in the storage directory that lists the model
+// files of the last complete download.
+const manifestName = ".models-manifest.json"
+
+// modelManifest records the files of a complete model download, so later
+// starts verify them locally instead of listing the whole repository again.
+// It only applies to the source it was written for; another revision, such
+// as after an update of constants.ModelsRevision, lists the repository.
+type modelManifest struct {
+	BaseURL  string    `json:"base_url"`
+	Repo     string    `json:"repo"`
+	Revision string    `json:"revision"`
+	Files    []hfEntry `json:"files"`
+}
+
+// verifiedManifestFiles returns the manifest's files under prefix ("" for
+// all) if the manifest was written for src and the files are all present
+// with their sizes.
+func verifiedManifestFiles(storageDir string, src *modelSource, prefix string) ([]hfEntry, bool) {
+	data, err := os.ReadFile(filepath.Join(storageDir, manifestName))
+	if err != nil {
+		return nil, false
+	}
+	var m modelManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, false
+	}
+	if m.BaseURL != src.baseURL || m.Repo != src.repo || m.Revision != src.revision {
+		return nil, false
+	}
+
+	var files []hfEntry
+	for _, f := range m.Files {
+		if prefix == "" || strings.HasPrefix(f.Path, prefix+"/") {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 || len(pendingFiles(storageDir, files)) > 0 {
+		return nil, false
+	}
+	return files, true
+}
+
+// writeManifest records the files of a complete download from src.
+func writeManifest(storageDir string, src *modelSource, files []hfEntry) error {
+	data, err := json.Marshal(modelManifest{
+		BaseURL:  src.baseURL,
+		Repo:     src.repo,
+		Revision: src.revision,
+		Files:    files,
+	})
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+
+	path := filepath.Join(storageDir, manifestName)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		_ = os.Remove(path + ".tmp")
+		return fmt.Errorf("rename manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	src := &modelSource{baseURL: "https://huggingface.co", repo: "org/models", revision: "r1"}
+	files := []hfEntry{
+		{Type: "file", Path: "vosk-model-en/am/final.mdl", Size: 3},
+		{Type: "file", Path: "vosk-model-de/am/final.mdl", Size: 5},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, f.Size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, ok := verifiedManifestFiles(dir, src, ""); ok {
+		t.Fatal("verified without a manifest")
+	}
+	if err := writeManifest(dir, src, files); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, ok := verifiedManifestFiles(dir, src, ""); !ok || len(got) != 2 {
+		t.Fatalf("all files: %v, %v, want both verified", got, ok)
+	}
+	if got, ok := verifiedManifestFiles(dir, src, "vosk-model-de"); !ok || len(got) != 1 || got[0] != files[1] {
+		t.Fatalf("one model: %v, %v, want its file verified", got, ok)
+	}
+	if _, ok := verifiedManifestFiles(dir, src, "vosk-model-fr"); ok {
+		t.Error("verified a model the manifest does not list")
+	}
+
+	// Another revision or source lists the repository again
+	for _, other := range []*modelSource{
+		{baseURL: src.baseURL, repo: src.repo, revision: "r2"},
+		{baseURL: src.baseURL, repo: "fork/models", revision: src.revision},
+		{baseURL: "https://mirror.example", repo: src.repo, revision: src.revision},
+	} {
+		if _, ok := verifiedManifestFiles(dir, other, ""); ok {
+			t.Errorf("manifest of %+v verified for %+v", src, other)
+		}
+	}
+
+	// So does a file changed or removed since
+	if err := os.WriteFile(filepath.Join(dir, files[0].Path), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := verifiedManifestFiles(dir, src, ""); ok {
+		t.Error("verified with a file of the wrong size")
+	}
+	if _, ok := verifiedManifestFiles(dir, src, "vosk-model-de"); !ok {
+		t.Error("a model not verified for a file of another one")
+	}
+	if err := os.Remove(filepath.Join(dir, files[1].Path)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := verifiedManifestFiles(dir, src, "vosk-model-de"); ok {
+		t.Error("verified with a file missing")
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"math"
+	"time"
+)
+
+// mixedSessionID is the recognizer key of a room in mixed audio mode. Its
+// transcripts carry no speaker session and are sent as unattributed.
+const mixedSessionID = ""
+
+// mixFrame is the mixing granularity: 20ms at 16kHz.
+const mixFrame = 320
+
+// mixIdleTimeout is how long a speaker who sends no audio, such as a muted
+// one, holds back the mix before the others are mixed without them.
+const mixIdleTimeout = 100 * time.Millisecond
+
+// mixMaxPending bounds the audio buffered per speaker, 1s. A speaker whose
+// stream runs ahead of the others loses its oldest audio beyond it.
+const mixMaxPending = 50 * mixFrame
+
+// mixer downmixes the 16kHz audio of all speakers into a single stream. The
+// HPB forwards every speaker separately, so the mix advances at the pace of
+// the streams themselves: a frame is mixed once every active speaker has
+// one buffered, speakers silent for mixIdleTimeout not counting, and the
+// frames are summed with clipping.
+type mixer struct {
+	speakers map[string]*mixSpeaker
+	mixed    map[string]int // samples per speaker mixed since takeMixed
+}
+
+type mixSpeaker struct {
+	pending  []int16
+	lastSeen time.Time
+}
+
+func newMixer() *mixer {
+	return &mixer{speakers: make(map[string]*mixSpeaker), mixed: make(map[string]int)}
+}
+
+// add queues a speaker's samples received at now and returns the mixed
+// audio that became complete, if any.
+func (m *mixer) add(sessionID string, samples []int16, now time.Time) []int16 {
+	sp := m.speakers[sessionID]
+	if sp == nil {
+		sp = &mixSpeaker{}
+		m.speakers[sessionID] = sp
+	}
+	sp.pending = append(sp.pending, samples...)
+	if over := len(sp.pending) - mixMaxPending; over > 0 {
+		sp.pending = sp.pending[over:]
+	}
+	sp.lastSeen = now
+
+	var out []int16
+	for m.ready(now) {
+		out = m.mixFrame(out)
+	}
+	m.prune(now)
+	return out
+}
+
+// ready reports whether every active speaker has a full frame buffered,
+// and at least one does.
+func (m *mixer) ready(now time.Time) bool {
+	full := false
+	for _, sp := range m.speakers {
+		switch {
+		case len(sp.pending) >= mixFrame:
+			full = true
+		case now.Sub(sp.lastSeen) < mixIdleTimeout:
+			return false
+		}
+	}
+	return full
+}
+
+// mixFrame appends one frame summed from the head of every speaker's
+// audio, short ones padded with silence.
+func (m *mixer) mixFrame(out []int16) []int16 {
+	var frame [mixFrame]int32
+	for sid, sp := range m.speakers {
+		n := min(len(sp.pending), mixFrame)
+		for i, s := range sp.pending[:n] {
+			frame[i] += int32(s)
+		}
+		sp.pending = sp.pending[n:]
+		if n > 0 {
+			m.mixed[sid] += n
+		}
+	}
+	for _, v := range frame {
+		out = append(out, int16(min(max(v, math.MinInt16), math.MaxInt16)))
+	}
+	return out
+}
+
+// flush mixes all the audio still buffered, without waiting for the
+// speakers that are behind.
+func (m *mixer) flush() []int16 {
+	var out []int16
+	for m.pending() {
+		out = m.mixFrame(out)
+	}
+	return out
+}
+
+// pending reports whether any speaker has audio buffered.
+func (m *mixer) pending() bool {
+	for _, sp := range m.speakers {
+		if len(sp.pending) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// takeMixed returns the samples of every speaker mixed since the last call.
+func (m *mixer) takeMixed() map[string]int {
+	mixed := m.mixed
+	m.mixed = make(map[string]int)
+	return mixed
+}
+
+// prune forgets idle speakers whose audio has been mixed.
+func (m *mixer) prune(now time.Time) {
+	for sid, sp := range m.speakers {
+		if len(sp.pending) == 0 && now.Sub(sp.lastSeen) >= mixIdleTimeout {
+			delete(m.speakers, sid)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func constFrame(v int16, n int) []int16 {
+	s := make([]int16, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+func TestMixerOverlappingSpeakers(t *testing.T) {
+	m := newMixer()
+	start := time.Now()
+
+	// Two speakers talking at once, each sending 20ms every 20ms
+	var out []int16
+	for i := range 50 {
+		now := start.Add(time.Duration(i) * 20 * time.Millisecond)
+		out = append(out, m.add("a", constFrame(100, mixFrame), now)...)
+		out = append(out, m.add("b", constFrame(-30, mixFrame), now)...)
+	}
+
+	// The mix runs in real time, not twice as fast. a's first frame is
+	// mixed alone, before b is heard from.
+	if len(out) != 50*mixFrame {
+		t.Fatalf("mixed %d samples, want %d", len(out), 50*mixFrame)
+	}
+	for i, s := range out[mixFrame:] {
+		if s != 70 {
+			t.Fatalf("sample %d = %d, want the sum 70", mixFrame+i, s)
+		}
+	}
+}
+
+func TestMixerUnevenArrival(t *testing.T) {
+	m := newMixer()
+	now := time.Now()
+
+	// a sends 60ms in one burst while b lags behind: nothing is mixed
+	// until b catches up
+	m.add("b", constFrame(2, mixFrame/2), now)
+	if out := m.add("a", constFrame(1, 3*mixFrame), now); len(out) != 0 {
+		t.Fatalf("mixed %d samples before b caught up", len(out))
+	}
+	out := m.add("b", constFrame(2, 3*mixFrame-mixFrame/2), now)
+	if len(out) != 3*mixFrame {
+		t.Fatalf("mixed %d samples, want %d", len(out), 3*mixFrame)
+	}
+	for i, s := range out {
+		if s != 3 {
+			t.Fatalf("sample %d = %d, want 3", i, s)
+		}
+	}
+}
+
+func TestMixerIdleSpeaker(t *testing.T) {
+	m := newMixer()
+	start := time.Now()
+	m.add("a", nil, start)
+	m.add("b", constFrame(7, mixFrame), start)
+	if out := m.add("a", constFrame(5, mixFrame), start); len(out) != mixFrame || out[0] != 12 {
+		t.Fatalf("mixed %d samples, want %d of 12", len(out), mixFrame)
+	}
+
+	// b falls silent: a is held back for mixIdleTimeout, then mixed alone
+	if out := m.add("a", constFrame(5, mixFrame), start.Add(20*time.Millisecond)); len(out) != 0 {
+		t.Fatalf("mixed %d samples while b was active", len(out))
+	}
+	out := m.add("a", constFrame(5, mixFrame), start.Add(mixIdleTimeout+20*time.Millisecond))
+	if len(out) != 2*mixFrame {
+		t.Fatalf("mixed %d samples, want %d", len(out), 2*mixFrame)
+	}
+	if _, ok := m.speakers["b"]; ok {
+		t.Error("idle speaker b not forgotten")
+	}
+}
+
+func TestMixerClipping(t *testing.T) {
+	m := newMixer()
+	now := time.Now()
+	for _, sid := range []string{"a", "b", "c"} {
+		m.add(sid, nil, now)
+	}
+	m.add("a", constFrame(30000, mixFrame), now)
+	m.add("c", constFrame(-30000, mixFrame), now)
+	out := m.add("b", constFrame(30000, mixFrame), now)
+	if len(out) != mixFrame || out[0] != 30000 {
+		t.Fatalf("mixed %d samples starting with %v, want %d of 30000", len(out), out[:1], mixFrame)
+	}
+
+	m = newMixer()
+	m.add("b", nil, now)
+	m.add("a", constFrame(30000, mixFrame), now)
+	out = m.add("b", constFrame(30000, mixFrame), now)
+	if out[0] != math.MaxInt16 {
+		t.Errorf("sample = %d, want clipped to %d", out[0], math.MaxInt16)
+	}
+}
+
+func TestMixerTakeMixed(t *testing.T) {
+	m := newMixer()
+	now := time.Now()
+	m.add("b", nil, now)
+	m.add("a", constFrame(1, 2*mixFrame), now)
+	if mixed := m.takeMixed(); len(mixed) != 0 {
+		t.Fatalf("mixed %v before b sent audio", mixed)
+	}
+	m.add("b", constFrame(1, mixFrame+mixFrame/2), now)
+	mixed := m.takeMixed()
+	if mixed["a"] != mixFrame || mixed["b"] != mixFrame {
+		t.Fatalf("mixed %v, want a frame of each", mixed)
+	}
+	if mixed := m.takeMixed(); len(mixed) != 0 {
+		t.Fatalf("mixed %v taken twice", mixed)
+	}
+}
+
+func TestMixerFlush(t *testing.T) {
+	m := newMixer()
+	now := time.Now()
+
+	// a is ahead of b, so nothing can be mixed yet
+	m.add("b", constFrame(2, mixFrame/2), now)
+	if out := m.add("a", constFrame(1, 2*mixFrame), now); len(out) != 0 {
+		t.Fatalf("mixed %d samples while b is behind", len(out))
+	}
+
+	// Draining mixes everything buffered, b padded with silence
+	out := m.flush()
+	if len(out) != 2*mixFrame {
+		t.Fatalf("flushed %d samples, want %d", len(out), 2*mixFrame)
+	}
+	if out[0] != 3 || out[mixFrame/2] != 1 || out[mixFrame] != 1 {
+		t.Errorf("flushed %d, %d, %d, want 3, 1, 1", out[0], out[mixFrame/2], out[mixFrame])
+	}
+	if mixed := m.takeMixed(); mixed["a"] != 2*mixFrame || mixed["b"] != mixFrame/2 {
+		t.Errorf("mixed %v, want a %d and b %d", mixed, 2*mixFrame, mixFrame/2)
+	}
+	if out := m.flush(); len(out) != 0 {
+		t.Errorf("second flush returned %d samples", len(out))
+	}
+}
@@ -4,29 +4,62 @@
 package vosk
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	vosk "github.com/alphacep/vosk-api/go"
 
-	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
 	"github.com/nextcloud/go_live_transcription/internal/languages"
 )
 
+var (
+	ErrModelInUse       = errors.New("model is currently in use")
+	ErrModelNotFound    = errors.New("model is not installed")
+	ErrModelUnsupported = errors.New("no model available for language")
+	ErrRecognizerLimit  = errors.New("recognizer limit reached and no recognizer idle")
+)
+
 type ModelManager struct {
 	mu     sync.Mutex
 	models map[string]*modelEntry
 	logger *slog.Logger
+
+	// Installed languages, rescanned from disk once older than availableTTL
+	// or after InvalidateAvailableModels. Guarded by availMu.
+	availMu      sync.Mutex
+	available    []string
+	availableAt  time.Time
+	availableTTL time.Duration
+
+	storageDir atomic.Pointer[string] // where the models are installed
+
+	// Models no recognizer references stay loaded, up to maxIdle of them,
+	// so a room restarting in the same language skips the reload. Beyond
+	// that the least recently released ones are freed. Guarded by mu.
+	maxIdle int
 }
 
 type modelEntry struct {
 	model    *vosk.VoskModel
 	refCount int
+	lastUsed time.Time // when the last reference was released
 }
 
+// loadModel and freeModel load and free the Vosk models, replaced in tests.
+var (
+	loadModel = vosk.NewModel
+	freeModel = (*vosk.VoskModel).Free
+)
+
 var globalModelManager *ModelManager
 var modelManagerOnce sync.Once
 
@@ -34,9 +67,12 @@ func GetModelManager() *ModelManager {
 	modelManagerOnce.Do(func() {
 		vosk.SetLogLevel(-1) // suppress vosk's own logs
 		globalModelManager = &ModelManager{
-			models: make(map[string]*modelEntry),
-			logger: slog.With("component", "model_manager"),
+			models:       make(map[string]*modelEntry),
+			logger:       slog.With("component", "model_manager"),
+			availableTTL: constants.ModelsRefreshInterval,
+			maxIdle:      constants.MaxIdleModels,
 		}
+		globalModelManager.SetStorageDir(constants.PersistentStorage)
 	})
 	return globalModelManager
 }
@@ -53,16 +89,16 @@ func (mm *ModelManager) GetModel(lang string) (*vosk.VoskModel, error) {
 
 	modelDir, ok := languages.ModelsList[lang]
 	if !ok {
-		return nil, fmt.Errorf("no model available for language: %s", lang)
+		return nil, fmt.Errorf("%w: %s", ErrModelUnsupported, lang)
 	}
 
-	modelPath := filepath.Join(appapi.PersistentStorage(), modelDir)
+	modelPath := mm.modelPath(modelDir)
 	if _, err := os.Stat(modelPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("model directory not found: %s", modelPath)
+		return nil, fmt.Errorf("%w: %s (%s)", ErrModelNotFound, lang, modelPath)
 	}
 
 	mm.logger.Info("loading vosk model", "lang", lang, "path", modelPath)
-	model, err := vosk.NewModel(modelPath)
+	model, err := loadModel(modelPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load vosk model for %s: %w", lang, err)
 	}
@@ -81,14 +117,83 @@ func (mm *ModelManager) ReleaseModel(lang string) {
 		return
 	}
 
+	if entry.refCount <= 0 {
+		mm.logger.Error("model released more often than acquired", "lang", lang)
+		return
+	}
 	entry.refCount--
 	mm.logger.Info("released model", "lang", lang, "ref_count", entry.refCount)
 
-	if entry.refCount <= 0 {
-		entry.model.Free()
-		delete(mm.models, lang)
-		mm.logger.Info("freed vosk model", "lang", lang)
+	// Only the last reference makes the model evictable; no recognizer can
+	// still use it
+	if entry.refCount == 0 {
+		entry.lastUsed = time.Now()
+		mm.evictLocked()
+	}
+}
+
+// SetMaxIdleModels sets how many models without recognizers stay loaded,
+// freeing the least recently used ones beyond that. 0 frees a model as soon
+// as its last recognizer is closed.
+func (mm *ModelManager) SetMaxIdleModels(n int) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.maxIdle = max(n, 0)
+	mm.evictLocked()
+}
+
+// evictLocked frees the least recently used idle models until at most
+// maxIdle remain. Models with a reference are never freed. mm.mu must be held.
+func (mm *ModelManager) evictLocked() {
+	var idle []string
+	for lang, entry := range mm.models {
+		if entry.refCount == 0 {
+			idle = append(idle, lang)
+		}
 	}
+	if len(idle) <= mm.maxIdle {
+		return
+	}
+
+	slices.SortFunc(idle, func(a, b string) int {
+		return mm.models[a].lastUsed.Compare(mm.models[b].lastUsed)
+	})
+	for _, lang := range idle[:len(idle)-mm.maxIdle] {
+		mm.freeLocked(lang)
+	}
+}
+
+// freeLocked frees the model of an idle entry. mm.mu must be held.
+func (mm *ModelManager) freeLocked(lang string) {
+	freeModel(mm.models[lang].model)
+	delete(mm.models, lang)
+	mm.logger.Info("freed vosk model", "lang", lang)
+}
+
+// LoadedModels returns the reference count of every loaded model by language,
+// 0 for idle models kept loaded.
+func (mm *ModelManager) LoadedModels() map[string]int {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	loaded := make(map[string]int, len(mm.models))
+	for lang, entry := range mm.models {
+		loaded[lang] = entry.refCount
+	}
+	return loaded
+}
+
+// CheckInstalled returns ErrModelUnsupported if no model exists for the
+// language and ErrModelNotFound if it is not downloaded.
+func (mm *ModelManager) CheckInstalled(lang string) error {
+	if _, ok := languages.ModelsList[lang]; !ok {
+		return fmt.Errorf("%w: %s", ErrModelUnsupported, lang)
+	}
+	if !mm.IsModelAvailable(lang) {
+		return fmt.Errorf("%w: %s", ErrModelNotFound, lang)
+	}
+	return nil
 }
 
 func (mm *ModelManager) IsModelAvailable(lang string) bool {
@@ -96,20 +201,123 @@ func (mm *ModelManager) IsModelAvailable(lang string) bool {
 	if !ok {
 		return false
 	}
-	modelPath := filepath.Join(appapi.PersistentStorage(), modelDir)
-	info, err := os.Stat(modelPath)
+	info, err := os.Stat(mm.modelPath(modelDir))
 	if err != nil {
 		return false
 	}
 	return info.IsDir()
 }
 
+// ListAvailableModels returns the installed languages from a cache that is
+// refreshed from disk periodically and whenever models are added or removed.
 func (mm *ModelManager) ListAvailableModels() []string {
-	var available []string
-	for lang := range languages.ModelsList {
-		if mm.IsModelAvailable(lang) {
-			available = append(available, lang)
+	mm.availMu.Lock()
+	defer mm.availMu.Unlock()
+
+	if mm.availableAt.IsZero() || time.Since(mm.availableAt) >= mm.availableTTL {
+		var available []string
+		for lang := range languages.ModelsList {
+			if mm.IsModelAvailable(lang) {
+				available = append(available, lang)
+			}
+		}
+		mm.available = available
+		mm.availableAt = time.Now()
+	}
+	return slices.Clone(mm.available)
+}
+
+// InvalidateAvailableModels makes the next ListAvailableModels rescan the disk.
+func (mm *ModelManager) InvalidateAvailableModels() {
+	mm.availMu.Lock()
+	mm.availableAt = time.Time{}
+	mm.availMu.Unlock()
+}
+
+// SupportsGrammar reports whether the installed model of a language has a
+// runtime graph, which recognizers need to be constrained by a grammar.
+// Models with a precompiled graph ignore grammars.
+func (mm *ModelManager) SupportsGrammar(lang string) bool {
+	modelDir, ok := languages.ModelsList[lang]
+	if !ok {
+		return false
+	}
+	for _, name := range []string{"HCLr.fst", "Gr.fst"} {
+		if _, err := os.Stat(filepath.Join(mm.modelPath(modelDir), "graph", name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// SetStorageDir sets the directory the models are installed in.
+func (mm *ModelManager) SetStorageDir(dir string) {
+	mm.storageDir.Store(&dir)
+	mm.InvalidateAvailableModels()
+}
+
+func (mm *ModelManager) modelPath(modelDir string) string {
+	return filepath.Join(*mm.storageDir.Load(), modelDir)
+}
+
+// SetAvailableModelsTTL sets how long the installed-models list is cached.
+// A TTL of 0 rescans on every call.
+func (mm *ModelManager) SetAvailableModelsTTL(ttl time.Duration) {
+	mm.availMu.Lock()
+	mm.availableTTL = ttl
+	mm.availMu.Unlock()
+}
+
+// DeleteModel removes the model directory of a language from the persistent
+// storage and returns the number of bytes reclaimed. The path is always taken
+// from languages.ModelsList, never from the caller. Deletion is refused while
+// the model is used by any recognizer; an idle loaded model is freed first.
+func (mm *ModelManager) DeleteModel(lang string) (int64, error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	modelDir, ok := languages.ModelsList[lang]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrModelUnsupported, lang)
+	}
+
+	if entry, ok := mm.models[lang]; ok {
+		if entry.refCount > 0 {
+			return 0, fmt.Errorf("%w: %s (ref_count %d)", ErrModelInUse, lang, entry.refCount)
+		}
+		mm.freeLocked(lang)
+	}
+
+	modelPath := mm.modelPath(modelDir)
+	info, err := os.Stat(modelPath)
+	if err != nil || !info.IsDir() {
+		return 0, fmt.Errorf("%w: %s", ErrModelNotFound, lang)
+	}
+
+	var size int64
+	err = filepath.WalkDir(modelPath, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
+		if d.Type().IsRegular() {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += fi.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("measuring model directory %s: %w", modelPath, err)
 	}
-	return available
+
+	err = os.RemoveAll(modelPath)
+	mm.InvalidateAvailableModels()
+	if err != nil {
+		return 0, fmt.Errorf("removing model directory %s: %w", modelPath, err)
+	}
+
+	mm.logger.Info("deleted vosk model", "lang", lang, "path", modelPath, "bytes", size)
+	return size, nil
 }
@@ -0,0 +1,269 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	vosk "github.com/alphacep/vosk-api/go"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/languages"
+)
+
+var testModelLangs = []string{"en", "de", "fr"}
+
+// fakeModels replaces the Vosk model loading and tracks which models are
+// held by a caller and which were freed.
+type fakeModels struct {
+	t     *testing.T
+	mu    sync.Mutex
+	lang  map[*vosk.VoskModel]string
+	held  map[*vosk.VoskModel]int
+	freed map[*vosk.VoskModel]bool
+	loads int
+}
+
+func newTestModelManager(t *testing.T) (*ModelManager, *fakeModels) {
+	dir := t.TempDir()
+	for _, lang := range testModelLangs {
+		if err := os.MkdirAll(filepath.Join(dir, languages.ModelsList[lang]), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mm := &ModelManager{models: make(map[string]*modelEntry), logger: slog.Default()}
+	mm.SetStorageDir(dir)
+
+	fm := &fakeModels{
+		t:     t,
+		lang:  make(map[*vosk.VoskModel]string),
+		held:  make(map[*vosk.VoskModel]int),
+		freed: make(map[*vosk.VoskModel]bool),
+	}
+	loadModel = func(path string) (*vosk.VoskModel, error) {
+		fm.mu.Lock()
+		defer fm.mu.Unlock()
+		m := new(vosk.VoskModel)
+		fm.lang[m] = filepath.Base(path)
+		fm.loads++
+		return m, nil
+	}
+	freeModel = func(m *vosk.VoskModel) {
+		fm.mu.Lock()
+		defer fm.mu.Unlock()
+		if fm.held[m] > 0 {
+			fm.t.Errorf("model %s freed while held %d times", fm.lang[m], fm.held[m])
+		}
+		if fm.freed[m] {
+			fm.t.Errorf("model %s freed twice", fm.lang[m])
+		}
+		fm.freed[m] = true
+	}
+	t.Cleanup(func() {
+		loadModel = vosk.NewModel
+		freeModel = (*vosk.VoskModel).Free
+	})
+	return mm, fm
+}
+
+// acquire gets a model the way a recognizer does and marks it held.
+func (fm *fakeModels) acquire(mm *ModelManager, lang string) (*vosk.VoskModel, error) {
+	m, err := mm.GetModel(lang)
+	if err != nil {
+		return nil, err
+	}
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if fm.freed[m] {
+		return nil, fmt.Errorf("got freed model for %s", lang)
+	}
+	fm.held[m]++
+	return m, nil
+}
+
+func (fm *fakeModels) release(mm *ModelManager, lang string, m *vosk.VoskModel) {
+	fm.mu.Lock()
+	fm.held[m]--
+	fm.mu.Unlock()
+	mm.ReleaseModel(lang)
+}
+
+func TestIdleModelsEvictedLeastRecentlyUsed(t *testing.T) {
+	mm, fm := newTestModelManager(t)
+	mm.SetMaxIdleModels(1)
+
+	en, _ := fm.acquire(mm, "en")
+	de, _ := fm.acquire(mm, "de")
+	fm.release(mm, "en", en)
+	if got := mm.LoadedModels(); got["en"] != 0 || got["de"] != 1 {
+		t.Fatalf("loaded models %v, want idle en and de in use", got)
+	}
+
+	// Releasing de makes two idle models, so the older en is freed
+	fm.release(mm, "de", de)
+	if got := mm.LoadedModels(); len(got) != 1 || got["de"] != 0 {
+		t.Fatalf("loaded models %v, want only idle de", got)
+	}
+	if !fm.freed[en] || fm.freed[de] {
+		t.Fatalf("freed en %v de %v, want only en", fm.freed[en], fm.freed[de])
+	}
+
+	// The idle model is reused without loading it again
+	if m, _ := fm.acquire(mm, "de"); m != de || fm.loads != 2 {
+		t.Fatalf("reacquired de loaded %d models, want the idle one reused", fm.loads)
+	}
+	fm.release(mm, "de", de)
+
+	mm.SetMaxIdleModels(0)
+	if got := mm.LoadedModels(); len(got) != 0 {
+		t.Fatalf("loaded models %v after disabling the idle cache", got)
+	}
+}
+
+func TestDeleteModelFreesIdleModel(t *testing.T) {
+	mm, fm := newTestModelManager(t)
+	mm.SetMaxIdleModels(1)
+
+	en, _ := fm.acquire(mm, "en")
+	if _, err := mm.DeleteModel("en"); err == nil {
+		t.Fatal("deleted a model in use")
+	}
+	fm.release(mm, "en", en)
+	if _, err := mm.DeleteModel("en"); err != nil {
+		t.Fatalf("deleting an idle model: %v", err)
+	}
+	if !fm.freed[en] || len(mm.LoadedModels()) != 0 {
+		t.Fatal("deleted model still loaded")
+	}
+}
+
+// TestEvictionWithActiveRecognition mixes recognizers acquiring and releasing
+// models with the idle cache shrinking and growing; fakeModels fails the test
+// if a model is freed while held.
+func TestEvictionWithActiveRecognition(t *testing.T) {
+	mm, fm := newTestModelManager(t)
+
+	const workers, rounds = 8, 300
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(workers)
+	for range workers {
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				lang := testModelLangs[rand.IntN(len(testModelLangs))]
+				m, err := fm.acquire(mm, lang)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				runtime.Gosched()
+				fm.release(mm, lang, m)
+			}
+		}()
+	}
+
+	evictDone := make(chan struct{})
+	go func() {
+		defer close(evictDone)
+		for n := 0; ; n++ {
+			select {
+			case <-stop:
+				return
+			default:
+				mm.SetMaxIdleModels(n % 3)
+				runtime.Gosched()
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	<-evictDone
+
+	mm.SetMaxIdleModels(0)
+	if got := mm.LoadedModels(); len(got) != 0 {
+		t.Fatalf("loaded models %v after every recognizer released", got)
+	}
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if len(fm.freed) != fm.loads {
+		t.Errorf("freed %d of %d loaded models", len(fm.freed), fm.loads)
+	}
+}
+
+func TestAvailableModelsCache(t *testing.T) {
+	mm, _ := newTestModelManager(t)
+	mm.SetAvailableModelsTTL(time.Hour)
+	want := slices.Sorted(slices.Values(testModelLangs))
+	if got := slices.Sorted(slices.Values(mm.ListAvailableModels())); !slices.Equal(got, want) {
+		t.Fatalf("available models %v, want %v", got, want)
+	}
+
+	// A model arriving on disk is only seen once the cache is invalidated
+	if err := os.MkdirAll(filepath.Join(*mm.storageDir.Load(), languages.ModelsList["it"]), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(mm.ListAvailableModels(), "it") {
+		t.Fatal("cached list rescanned before the TTL")
+	}
+	mm.InvalidateAvailableModels()
+	if !slices.Contains(mm.ListAvailableModels(), "it") {
+		t.Fatal("new model missing after invalidation")
+	}
+
+	// Deleting a model invalidates the cache itself
+	if _, err := mm.DeleteModel("fr"); err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(mm.ListAvailableModels(), "fr") {
+		t.Error("deleted model still listed")
+	}
+
+	// Without TTL every call rescans
+	mm.SetAvailableModelsTTL(0)
+	os.RemoveAll(filepath.Join(*mm.storageDir.Load(), languages.ModelsList["it"]))
+	if slices.Contains(mm.ListAvailableModels(), "it") {
+		t.Error("removed model listed without TTL")
+	}
+}
+
+func TestDownloadModelInvalidatesAvailableModels(t *testing.T) {
+	dir := t.TempDir()
+	mm := GetModelManager()
+	mm.SetStorageDir(dir)
+	mm.SetAvailableModelsTTL(time.Hour)
+	t.Cleanup(func() {
+		mm.SetStorageDir(constants.PersistentStorage)
+		mm.SetAvailableModelsTTL(constants.ModelsRefreshInterval)
+	})
+
+	modelDir := languages.ModelsList["it"]
+	src := fakeHub(t, map[string][][]hfEntry{
+		modelDir: {{{Type: "file", Path: modelDir + "/conf", Size: int64(len("model"))}}},
+	})
+	SetModelSource(src.baseURL, src.repo, src.revision)
+	t.Cleanup(func() {
+		SetModelSource(constants.ModelsBaseURL, constants.ModelsRepo, constants.ModelsRevision)
+	})
+
+	if slices.Contains(mm.ListAvailableModels(), "it") {
+		t.Fatal("model available before the download")
+	}
+	if err := DownloadModel(context.Background(), dir, "it"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(mm.ListAvailableModels(), "it") {
+		t.Error("downloaded model not listed until the cache expires")
+	}
+}
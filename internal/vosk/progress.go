@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"sync"
+	"time"
+)
+
+// Phases of the model download run by init.
+const (
+	InitIdle        = "idle" // no download since startup
+	InitListing     = "listing"
+	InitDownloading = "downloading"
+	InitDone        = "done"
+	InitFailed      = "failed"
+)
+
+// InitStatus is the progress of the latest model download, so admins can
+// see where init is stuck or why it failed.
+type InitStatus struct {
+	Phase       string    `json:"phase"`
+	Percent     int       `json:"percent"`
+	CurrentFile string    `json:"current_file,omitempty"`
+	FilesDone   int       `json:"files_done"`
+	FilesTotal  int       `json:"files_total"`
+	LastError   string    `json:"last_error,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitzero"`
+}
+
+var initStatus = struct {
+	mu sync.Mutex
+	s  InitStatus
+}{s: InitStatus{Phase: InitIdle}}
+
+// CurrentInitStatus returns the progress of the latest model download.
+func CurrentInitStatus() InitStatus {
+	initStatus.mu.Lock()
+	defer initStatus.mu.Unlock()
+	return initStatus.s
+}
+
+func updateInitStatus(update func(s *InitStatus)) {
+	initStatus.mu.Lock()
+	defer initStatus.mu.Unlock()
+	update(&initStatus.s)
+	initStatus.s.UpdatedAt = time.Now()
+}
@@ -12,15 +12,48 @@ import (
 	"encoding/json"
 	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	vosk "github.com/alphacep/vosk-api/go"
 
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/languages"
 	"github.com/nextcloud/go_live_transcription/internal/signaling"
+	"github.com/nextcloud/go_live_transcription/internal/transcript"
 )
 
 type voskResult struct {
-	Partial string `json:"partial,omitempty"`
-	Text    string `json:"text,omitempty"`
+	Partial string     `json:"partial,omitempty"`
+	Text    string     `json:"text,omitempty"`
+	Result  []voskWord `json:"result,omitempty"` // final results with word info, see wordsFlag
+
+	// Alternatives replace Text and Result of finals with max alternatives
+	// set, best first:
+	//
+	//	{"alternatives": [
+	//	  {"confidence": 231.8, "text": "hello world",
+	//	   "result": [{"start": 0.3, "end": 0.6, "word": "hello"}, ...]},
+	//	  {"confidence": 229.1, "text": "hello word", "result": [...]}
+	//	]}
+	//
+	// "result" is only present with word info and its words carry no
+	// confidence. Partials are unaffected.
+	Alternatives []voskAlternative `json:"alternatives,omitempty"`
+}
+
+type voskAlternative struct {
+	Confidence float64    `json:"confidence"`
+	Text       string     `json:"text"`
+	Result     []voskWord `json:"result,omitempty"`
+}
+
+// voskWord times are in seconds since the recognizer was created.
+type voskWord struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Word  string  `json:"word"`
+	Conf  float64 `json:"conf"`
 }
 
 // maxChunksBeforeForceFinalize forces a FinalResult() call after this many
@@ -28,25 +61,95 @@ type voskResult struct {
 // At 16kHz with 320-sample chunks (20ms each), 500 chunks = 10 seconds.
 const maxChunksBeforeForceFinalize = 500
 
+// RecognizerOptions tunes the recognizers of a room.
+type RecognizerOptions struct {
+	// RecreateOnForceFinalize resets the Vosk recognizer after a forced
+	// finalize and frees and recreates it once it has been fed
+	// RecognizerRecreateInterval of audio, returning its C memory at the
+	// cost of CPU and the decoder context. Without it FinalResult alone
+	// finalizes the utterance and memory held by the recognizer may keep
+	// growing over long calls.
+	RecreateOnForceFinalize bool
+
+	// WordTimings makes Vosk report per-word times, which gives finals
+	// exact start and end times instead of the utterance boundaries.
+	WordTimings bool
+
+	// NoPartials skips computing partial results, which costs a decoder
+	// pass per chunk. Finals are unaffected.
+	NoPartials bool
+
+	// ForceFinalizeChunks overrides maxChunksBeforeForceFinalize when set.
+	ForceFinalizeChunks int
+
+	// StopTokenMaxConfidence is the mean word confidence below which a
+	// segment consisting of a single stop token is dropped as a
+	// hallucination. Confidence is only known for finals in word timing
+	// mode without alternatives; without it such segments are kept, as a
+	// real one-word answer is worse to lose than an artifact to show.
+	StopTokenMaxConfidence float64
+
+	// MinFinalWords and MinFinalChars drop finals with fewer words, see
+	// languages.WordCount, or letters, such as a noise-triggered "uh".
+	// 0 disables either. Partials are never dropped.
+	MinFinalWords int
+	MinFinalChars int
+
+	// MaxAlternatives makes finals carry up to this many hypotheses with
+	// their confidence. 0 disables them, saving CPU and payload.
+	MaxAlternatives int
+
+	// Grammar is a Vosk grammar, a JSON list of phrases, constraining the
+	// recognizer to them. It is built per recognizer from the room's
+	// phrases, see TranscriberManager.SetGrammar.
+	Grammar string
+}
+
+// voskDecoder is the part of the Vosk recognizer a Recognizer feeds,
+// replaced in tests.
+type voskDecoder interface {
+	AcceptWaveform(buffer []byte) int
+	Result() string
+	PartialResult() string
+	FinalResult() string
+	Reset()
+	Free()
+}
+
 type Recognizer struct {
 	mu               sync.Mutex
-	rec              *vosk.VoskRecognizer
+	rec              voskDecoder
 	model            *vosk.VoskModel
 	sampleRate       float64
 	sessionID        string
 	language         string
+	opts             RecognizerOptions
 	feedCount        int64
 	chunksSinceFinal int
-	transcriptCh     chan signaling.Transcript
-	logger           *slog.Logger
+	lastFeed         time.Time
+
+	// Sample offsets in the speaker's audio stream, for segment times
+	samplesFed      int64 // total fed so far
+	utteranceStart  int64 // where the current utterance began
+	recognizerStart int64 // where the current Vosk recognizer began
+
+	transcriptCh  chan signaling.Transcript
+	droppedFinals *atomic.Int64 // shared by the room's recognizers, may be nil
+	logger        *slog.Logger
 }
 
-func NewRecognizer(model *vosk.VoskModel, sessionID, language string, sampleRate float64, transcriptCh chan signaling.Transcript) (*Recognizer, error) {
-	rec, err := vosk.NewRecognizer(model, sampleRate)
+func NewRecognizer(
+	model *vosk.VoskModel,
+	sessionID, language string,
+	sampleRate float64,
+	opts RecognizerOptions,
+	transcriptCh chan signaling.Transcript,
+) (*Recognizer, error) {
+	rec, err := newDecoder(model, sampleRate, language, opts)
 	if err != nil {
 		return nil, err
 	}
-	rec.SetWords(0) // no word-level timing
+	liveRecognizers.Add(1)
 
 	return &Recognizer{
 		rec:          rec,
@@ -54,48 +157,178 @@ func NewRecognizer(model *vosk.VoskModel, sessionID, language string, sampleRate
 		sampleRate:   sampleRate,
 		sessionID:    sessionID,
 		language:     language,
+		opts:         opts,
+		lastFeed:     time.Now(),
 		transcriptCh: transcriptCh,
 		logger:       slog.With("session_id", sessionID, "component", "vosk_recognizer"),
 	}, nil
 }
 
-func (r *Recognizer) FeedAudio(pcmData []byte) {
+// LastFeed returns when audio was last fed, or the creation time.
+func (r *Recognizer) LastFeed() time.Time {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	return r.lastFeed
+}
+
+// FeedAudio feeds a chunk of 16-bit mono audio and sends the transcript it
+// yields, if any. The transcript is sent without r.mu held, as a full queue
+// may hold up a final for FinalTranscriptQueueTimeout.
+func (r *Recognizer) FeedAudio(pcmData []byte) {
+	r.mu.Lock()
+	t, ok := r.feedLocked(pcmData)
+	r.mu.Unlock()
+	if ok {
+		r.send(t)
+	}
+}
 
+// Must be called with r.mu held.
+func (r *Recognizer) feedLocked(pcmData []byte) (t signaling.Transcript, ok bool) {
 	if r.rec == nil {
-		return
+		return t, false
 	}
 
 	r.feedCount++
 	r.chunksSinceFinal++
+	r.lastFeed = time.Now()
+	r.samplesFed += int64(len(pcmData) / 2) // 16-bit mono
 
 	switch {
 	case r.rec.AcceptWaveform(pcmData) != 0:
 		// Natural final result
 		resultJSON := r.rec.Result()
 		r.logger.Debug("vosk final result", "json", resultJSON)
-		r.emitTranscript(resultJSON, true)
+		t, ok = r.transcriptOf(resultJSON, true)
 		r.chunksSinceFinal = 0
-	case r.chunksSinceFinal >= maxChunksBeforeForceFinalize:
+	case r.chunksSinceFinal >= r.opts.forceFinalizeChunks():
 		// Force finalization to prevent unbounded C-side memory growth
 		resultJSON := r.rec.FinalResult()
 		r.logger.Debug("vosk forced final", "json", resultJSON, "chunks", r.chunksSinceFinal)
-		r.emitTranscript(resultJSON, true)
+		t, ok = r.transcriptOf(resultJSON, true)
 		r.chunksSinceFinal = 0
-		// Recreate the recognizer to fully release C memory
-		r.resetRecognizer()
+		switch r.forcedFinalReset() {
+		case recreateRecognizer:
+			r.resetRecognizer()
+		case resetRecognizerState:
+			start := time.Now()
+			r.rec.Reset()
+			r.logger.Debug("recognizer reset", "took", time.Since(start))
+		}
+	case r.opts.NoPartials:
+		// Partials are shed, wait for the final
 	default:
 		// Partial result
 		partialJSON := r.rec.PartialResult()
-		r.emitTranscript(partialJSON, false)
+		t, ok = r.transcriptOf(partialJSON, false)
 	}
+	return t, ok
 }
 
-func (r *Recognizer) emitTranscript(resultJSON string, isFinal bool) {
+type forcedReset int
+
+const (
+	keepRecognizer       forcedReset = iota // FinalResult alone finalized
+	resetRecognizerState                    // Reset clears the decoder
+	recreateRecognizer                      // free and create anew
+)
+
+// forcedFinalReset tells how the recognizer is renewed after a forced final:
+// with RecreateOnForceFinalize it is reset, and recreated now and then to
+// fully release C memory. r.mu must be held.
+func (r *Recognizer) forcedFinalReset() forcedReset {
+	switch {
+	case !r.opts.RecreateOnForceFinalize:
+		return keepRecognizer
+	case r.samplesFed-r.recognizerStart >= int64(constants.RecognizerRecreateInterval.Seconds()*r.sampleRate):
+		return recreateRecognizer
+	default:
+		return resetRecognizerState
+	}
+}
+
+func (o RecognizerOptions) forceFinalizeChunks() int {
+	if o.ForceFinalizeChunks > 0 {
+		return o.ForceFinalizeChunks
+	}
+	return maxChunksBeforeForceFinalize
+}
+
+// newDecoder creates the Vosk recognizers of a Recognizer, replaced in tests.
+var newDecoder = func(
+	model *vosk.VoskModel,
+	sampleRate float64,
+	language string,
+	opts RecognizerOptions,
+) (voskDecoder, error) {
+	return newVoskRecognizer(model, sampleRate, language, opts)
+}
+
+func newVoskRecognizer(
+	model *vosk.VoskModel,
+	sampleRate float64,
+	language string,
+	opts RecognizerOptions,
+) (*vosk.VoskRecognizer, error) {
+	var rec *vosk.VoskRecognizer
+	var err error
+	if opts.Grammar != "" {
+		rec, err = vosk.NewRecognizerGrm(model, sampleRate, opts.Grammar)
+	} else {
+		rec, err = vosk.NewRecognizer(model, sampleRate)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec.SetWords(wordsFlag(language, opts))
+	if opts.MaxAlternatives > 0 {
+		rec.SetMaxAlternatives(opts.MaxAlternatives)
+	}
+	return rec, nil
+}
+
+// wordsFlag enables the word info of finals for word timings, and for the
+// word confidence telling a stop token of the language from speech.
+func wordsFlag(language string, opts RecognizerOptions) int {
+	if opts.WordTimings || len(languages.StopTokens[language]) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// samplesToMs converts a sample offset into milliseconds of the stream.
+func (r *Recognizer) samplesToMs(samples int64) int64 {
+	return int64(float64(samples) * 1000 / r.sampleRate)
+}
+
+// segmentTimes returns the start and end of the current utterance in
+// milliseconds since the speaker's audio started. Finals in word timing
+// mode use the first and last word; otherwise the utterance spans from the
+// previous final to the audio fed so far.
+func (r *Recognizer) segmentTimes(result voskResult) (startMs, endMs int64) {
+	if r.opts.WordTimings && len(result.Result) > 0 {
+		base := r.samplesToMs(r.recognizerStart)
+		first, last := result.Result[0], result.Result[len(result.Result)-1]
+		return base + int64(first.Start*1000), base + int64(last.End*1000)
+	}
+	return r.samplesToMs(r.utteranceStart), r.samplesToMs(r.samplesFed)
+}
+
+// transcriptOf turns a Vosk result into the transcript to send, false if
+// there is nothing to send. Must be called with r.mu held.
+func (r *Recognizer) transcriptOf(resultJSON string, isFinal bool) (signaling.Transcript, bool) {
 	var result voskResult
 	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil { //nolint:gocritic // err is checked
-		return
+		return signaling.Transcript{}, false
+	}
+	if len(result.Alternatives) > 0 {
+		best := result.Alternatives[0]
+		result.Text, result.Result = best.Text, best.Result
+	}
+
+	startMs, endMs := r.segmentTimes(result)
+	if isFinal {
+		r.utteranceStart = r.samplesFed
 	}
 
 	var message string
@@ -104,42 +337,161 @@ func (r *Recognizer) emitTranscript(resultJSON string, isFinal bool) {
 	} else {
 		message = result.Partial
 	}
+	message = r.normalizeText(message)
 
-	if message == "" || message == "the" {
-		return
+	if transcript.IsBlank(message) || r.isHallucination(message, result) {
+		return signaling.Transcript{}, false
+	}
+	if isFinal && r.isTooShort(message) {
+		r.logger.Debug("dropping short final", "words", languages.WordCount(r.language, message))
+		return signaling.Transcript{}, false
 	}
 
-	select {
-	case r.transcriptCh <- signaling.Transcript{
+	var alternatives []signaling.Alternative
+	if isFinal && len(result.Alternatives) > 0 {
+		alternatives = make([]signaling.Alternative, 0, len(result.Alternatives))
+		for _, alt := range result.Alternatives {
+			alternatives = append(alternatives, signaling.Alternative{
+				Message:    r.normalizeText(alt.Text),
+				Confidence: alt.Confidence,
+			})
+		}
+	}
+
+	return signaling.Transcript{
 		Final:            isFinal,
 		LangID:           r.language,
 		Message:          message,
 		SpeakerSessionID: r.sessionID,
-	}:
+		StartMs:          startMs,
+		EndMs:            endMs,
+		Alternatives:     alternatives,
+	}, true
+}
+
+// normalizeText drops the unknown words of grammar recognition and joins
+// the words the way the language writes them.
+func (r *Recognizer) normalizeText(text string) string {
+	if r.opts.Grammar != "" {
+		text = stripUnknownWords(text)
+	}
+	return languages.JoinWords(r.language, text)
+}
+
+// send queues a transcript for the sender. Partials are superseded by the
+// next one anyway and are dropped when the queue is full. Finals wait up to
+// FinalTranscriptQueueTimeout, which also slows down the audio worker and
+// shifts the pressure to the audio queue, before they are dropped and
+// counted. Must be called without r.mu held, so the wait does not block
+// callers such as LastFeed, which the recognizer limit calls under the
+// lock of a room's TranscriberManager.
+func (r *Recognizer) send(t signaling.Transcript) {
+	select {
+	case r.transcriptCh <- t:
+		return
 	default:
-		r.logger.Warn("transcript channel full, dropping message")
+	}
+	if !t.Final {
+		r.logger.Debug("transcript channel full, dropping partial")
+		return
+	}
+
+	timer := time.NewTimer(constants.FinalTranscriptQueueTimeout)
+	defer timer.Stop()
+	select {
+	case r.transcriptCh <- t:
+	case <-timer.C:
+		var dropped int64
+		if r.droppedFinals != nil {
+			dropped = r.droppedFinals.Add(1)
+		}
+		r.logger.Warn("transcript channel full, dropped final transcript", "dropped_finals", dropped)
 	}
 }
 
-// Must be called with r.mu held.
+// isHallucination reports whether the segment is a known artifact of the
+// language's model rather than speech. The word confidence of finals tells
+// them apart, partials and alternatives have none and are kept.
+func (r *Recognizer) isHallucination(message string, result voskResult) bool {
+	if !languages.IsStopSegment(r.language, message) {
+		return false
+	}
+	if len(result.Result) == 0 || len(result.Alternatives) > 0 {
+		return false // confidence unknown
+	}
+	var sum float64
+	for _, w := range result.Result {
+		sum += w.Conf
+	}
+	return sum/float64(len(result.Result)) < r.opts.StopTokenMaxConfidence
+}
+
+// isTooShort reports whether a final falls below the minimum words or
+// letters of the options.
+func (r *Recognizer) isTooShort(message string) bool {
+	return languages.WordCount(r.language, message) < r.opts.MinFinalWords ||
+		languages.LetterCount(message) < r.opts.MinFinalChars
+}
+
+// Flush finalizes the current utterance, emitting what was said so far as
+// a final transcript.
+func (r *Recognizer) Flush() {
+	r.mu.Lock()
+	if r.rec == nil || r.chunksSinceFinal == 0 {
+		r.mu.Unlock()
+		return
+	}
+	resultJSON := r.rec.FinalResult()
+	r.logger.Debug("vosk flushed final", "json", resultJSON)
+	t, ok := r.transcriptOf(resultJSON, true)
+	r.chunksSinceFinal = 0
+	r.mu.Unlock()
+
+	if ok {
+		r.send(t)
+	}
+}
+
+// resetRecognizer frees and recreates the Vosk recognizer. Vosk's own
+// Reset is much cheaper, as the recreation re-attaches the model, but keeps
+// the recognizer's feature pipeline. Must be called with r.mu held.
 func (r *Recognizer) resetRecognizer() {
+	start := time.Now()
 	if r.rec != nil {
 		r.rec.Free()
 	}
-	// Force glibc to return freed pages to OS
-	C.malloc_trim(0)
+	mallocTrim()
 
-	newRec, err := vosk.NewRecognizer(r.model, r.sampleRate)
+	newRec, err := newDecoder(r.model, r.sampleRate, r.language, r.opts)
 	if err != nil {
 		r.logger.Error("failed to recreate recognizer", "error", err)
 		r.rec = nil
 		return
 	}
-	newRec.SetWords(0)
 	r.rec = newRec
-	r.logger.Debug("recognizer reset")
+	r.recognizerStart = r.samplesFed
+	r.logger.Debug("recognizer recreated", "took", time.Since(start))
 }
 
+// lastMallocTrim is when mallocTrim last ran, in Unix nanoseconds.
+var lastMallocTrim atomic.Int64
+
+// mallocTrim makes glibc return freed pages to the OS, at most once per
+// MallocTrimInterval across the process. It runs in the background, as
+// trimming a large heap takes a while and the caller holds a recognizer.
+func mallocTrim() {
+	now := time.Now().UnixNano()
+	last := lastMallocTrim.Load()
+	if now-last < int64(constants.MallocTrimInterval) || !lastMallocTrim.CompareAndSwap(last, now) {
+		return
+	}
+	go func() { C.malloc_trim(0) }()
+}
+
+// Close frees the recognizer and releases its model reference. The
+// reference is held from creation until here, across recognizer resets, so
+// the model can't be freed or deleted while it is in use. Closing twice is
+// harmless.
 func (r *Recognizer) Close() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -148,6 +500,11 @@ func (r *Recognizer) Close() {
 		r.rec.Free()
 		r.rec = nil
 	}
+	if r.model != nil {
+		r.model = nil
+		GetModelManager().ReleaseModel(r.language)
+		liveRecognizers.Add(-1)
+	}
 	r.logger.Debug("recognizer closed")
 }
 
@@ -155,91 +512,271 @@ type TranscriberManager struct {
 	mu           sync.Mutex
 	recognizers  map[string]*Recognizer
 	language     string
-	sampleRate   float64
-	transcriptCh chan signaling.Transcript
-	logger       *slog.Logger
+	speakerLangs map[string]string // session ID → language overriding the room's
+
+	// Language detection, enabled with candidate languages
+	detectCandidates []string
+	detectors        map[string]*languageDetector
+	detectedLangs    map[string]string // session ID → detected language, "" = the room's
+	detectorRecs     int               // recognizers of the running detections
+
+	grammar []string // phrases the recognizers are constrained to, see SetGrammar
+
+	sampleRate    float64
+	opts          RecognizerOptions
+	transcriptCh  chan signaling.Transcript
+	droppedFinals atomic.Int64
+	logger        *slog.Logger
 }
 
-func NewTranscriberManager(language string, sampleRate float64, transcriptCh chan signaling.Transcript) *TranscriberManager {
-	return &TranscriberManager{
-		recognizers:  make(map[string]*Recognizer),
-		language:     language,
-		sampleRate:   sampleRate,
-		transcriptCh: transcriptCh,
-		logger:       slog.With("component", "transcriber_manager"),
+func NewTranscriberManager(
+	language string,
+	sampleRate float64,
+	opts RecognizerOptions,
+	transcriptCh chan signaling.Transcript,
+) *TranscriberManager {
+	tm := &TranscriberManager{
+		recognizers:   make(map[string]*Recognizer),
+		language:      language,
+		speakerLangs:  make(map[string]string),
+		detectors:     make(map[string]*languageDetector),
+		detectedLangs: make(map[string]string),
+		sampleRate:    sampleRate,
+		opts:          opts,
+		transcriptCh:  transcriptCh,
+		logger:        slog.With("component", "transcriber_manager"),
 	}
+	registerManager(tm)
+	return tm
 }
 
 func (tm *TranscriberManager) GetOrCreate(sessionID string) (*Recognizer, error) {
+	tm.mu.Lock()
+	existing, ok := tm.recognizers[sessionID]
+	tm.mu.Unlock()
+	if ok {
+		return existing, nil
+	}
+
+	// Takes other rooms' locks, so it must run without tm.mu
+	if err := makeRoomGlobally(); err != nil {
+		return nil, err
+	}
+
+	r, evicted, err := tm.create(sessionID)
+	if evicted != nil {
+		finishRecognizers(evicted)
+	}
+	return r, err
+}
+
+// create creates the speaker's recognizer, detaching the room's least
+// recently fed one if the room is at its limit; the caller finishes that
+// one without the lock.
+func (tm *TranscriberManager) create(sessionID string) (r, evicted *Recognizer, err error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
 	if r, ok := tm.recognizers[sessionID]; ok {
-		return r, nil
+		return r, nil, nil
+	}
+	if limit := perRoomLimit(); limit > 0 && len(tm.recognizers)+tm.detectorRecs >= limit {
+		sid, _, ok := tm.leastRecentLocked()
+		if ok {
+			evicted = tm.detachIdleLocked(sid)
+		}
+		if evicted == nil {
+			return nil, nil, ErrRecognizerLimit
+		}
+		tm.logger.Info("room recognizer limit reached, evicting least recently active",
+			"session_id", sid, "max_recognizers", limit)
 	}
 
-	model, err := GetModelManager().GetModel(tm.language)
+	language := tm.languageForLocked(sessionID)
+	model, err := GetModelManager().GetModel(language)
 	if err != nil {
-		return nil, err
+		return nil, evicted, err
 	}
 
-	r, err := NewRecognizer(model, sessionID, tm.language, tm.sampleRate, tm.transcriptCh)
+	opts := tm.opts
+	opts.Grammar = tm.grammarLocked(language, model)
+
+	// The recognizer owns the model reference from here on
+	r, err = NewRecognizer(model, sessionID, language, tm.sampleRate, opts, tm.transcriptCh)
 	if err != nil {
-		GetModelManager().ReleaseModel(tm.language)
-		return nil, err
+		GetModelManager().ReleaseModel(language)
+		return nil, evicted, err
 	}
 
+	r.droppedFinals = &tm.droppedFinals
 	tm.recognizers[sessionID] = r
-	tm.logger.Info("created recognizer", "session_id", sessionID, "language", tm.language)
-	return r, nil
+	tm.logger.Info("created recognizer", "session_id", sessionID, "language", language)
+	return r, evicted, nil
 }
 
-func (tm *TranscriberManager) Remove(sessionID string) {
+// languageForLocked returns the speaker's assigned language, or the room's.
+// Must be called with tm.mu held.
+func (tm *TranscriberManager) languageForLocked(sessionID string) string {
+	if lang, ok := tm.speakerLangs[sessionID]; ok {
+		return lang
+	}
+	if lang := tm.detectedLangs[sessionID]; lang != "" {
+		return lang
+	}
+	return tm.language
+}
+
+// SetSpeakerLanguage assigns the speaker a language of their own, or with
+// an empty language makes them follow the room's again. A recognizer in
+// another language is finalized and replaced on the speaker's next audio.
+func (tm *TranscriberManager) SetSpeakerLanguage(sessionID, language string) error {
+	if language != "" {
+		if err := GetModelManager().CheckInstalled(language); err != nil {
+			return err
+		}
+	}
+
 	tm.mu.Lock()
-	defer tm.mu.Unlock()
+	if language == "" {
+		delete(tm.speakerLangs, sessionID)
+	} else {
+		tm.speakerLangs[sessionID] = language
+	}
 
-	if r, ok := tm.recognizers[sessionID]; ok {
-		r.Close()
-		GetModelManager().ReleaseModel(tm.language)
+	r, ok := tm.recognizers[sessionID]
+	if ok && r.language != tm.languageForLocked(sessionID) {
 		delete(tm.recognizers, sessionID)
+	} else {
+		r = nil
 	}
+	tm.logger.Info("speaker language set", "session_id", sessionID, "language", tm.languageForLocked(sessionID))
+	tm.mu.Unlock()
+
+	if r != nil {
+		finishRecognizers(r)
+	}
+	return nil
 }
 
-func (tm *TranscriberManager) SetLanguage(language string) error {
+// Remove finalizes the utterance of a speaker whose audio ended and forgets
+// them, including their detection. Their next audio starts afresh.
+func (tm *TranscriberManager) Remove(sessionID string) {
 	tm.mu.Lock()
-	defer tm.mu.Unlock()
+	r, ok := tm.recognizers[sessionID]
+	delete(tm.recognizers, sessionID)
+	tm.stopDetectorLocked(sessionID)
+	delete(tm.detectedLangs, sessionID)
+	tm.mu.Unlock()
+
+	if ok {
+		finishRecognizers(r)
+	}
+}
 
+func (tm *TranscriberManager) SetLanguage(language string) error {
+	tm.mu.Lock()
 	if language == tm.language {
+		tm.mu.Unlock()
 		return nil
 	}
 
-	newModel, err := GetModelManager().GetModel(language)
-	if err != nil {
+	// Only check the model is installed. The manager holds no model
+	// reference of its own: every recognizer acquires one for its language
+	// on creation and releases exactly that one on Close, so the counts stay
+	// balanced however many recognizers exist during the switch.
+	if err := GetModelManager().CheckInstalled(language); err != nil {
+		tm.mu.Unlock()
 		return err
 	}
 
+	// Finish every pending utterance in the old language so a switch
+	// mid-sentence doesn't lose it; the new language's recognizers are
+	// created on the speakers' next audio. Speakers with a language of
+	// their own, assigned or detected, are unaffected.
+	var old []*Recognizer
 	for sid, r := range tm.recognizers {
-		r.Close()
-		GetModelManager().ReleaseModel(tm.language)
+		if _, assigned := tm.speakerLangs[sid]; assigned || tm.detectedLangs[sid] != "" {
+			continue
+		}
+		old = append(old, r)
 		delete(tm.recognizers, sid)
 	}
 
-	// Release model ref; recognizers will re-acquire on demand
-	GetModelManager().ReleaseModel(language)
-	_ = newModel
-
 	tm.language = language
 	tm.logger.Info("language switched", "language", language)
+	tm.mu.Unlock()
+
+	finishRecognizers(old...)
 	return nil
 }
 
+// SetForceFinalizeChunks changes the force finalize limit of the current
+// and future recognizers.
+func (tm *TranscriberManager) SetForceFinalizeChunks(n int) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.opts.ForceFinalizeChunks = n
+	for _, r := range tm.recognizers {
+		r.mu.Lock()
+		r.opts.ForceFinalizeChunks = n
+		r.mu.Unlock()
+	}
+}
+
+// SetPartials enables or disables partial results of the current and future
+// recognizers.
+func (tm *TranscriberManager) SetPartials(enabled bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.opts.NoPartials = !enabled
+	for _, r := range tm.recognizers {
+		r.mu.Lock()
+		r.opts.NoPartials = !enabled
+		r.mu.Unlock()
+	}
+}
+
+// DroppedFinals returns the number of final transcripts lost because the
+// transcript queue stayed full.
+func (tm *TranscriberManager) DroppedFinals() int64 {
+	return tm.droppedFinals.Load()
+}
+
+// Stats returns the number of live recognizers and the language whose model
+// they reference.
+func (tm *TranscriberManager) Stats() (recognizers int, language string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return len(tm.recognizers), tm.language
+}
+
+// FlushAll finalizes the current utterance of every recognizer.
+func (tm *TranscriberManager) FlushAll() {
+	tm.mu.Lock()
+	recognizers := make([]*Recognizer, 0, len(tm.recognizers))
+	for _, r := range tm.recognizers {
+		recognizers = append(recognizers, r)
+	}
+	tm.mu.Unlock()
+
+	for _, r := range recognizers {
+		r.Flush()
+	}
+}
+
 func (tm *TranscriberManager) CloseAll() {
+	unregisterManager(tm)
+
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
 	for sid, r := range tm.recognizers {
 		r.Close()
-		GetModelManager().ReleaseModel(tm.language)
 		delete(tm.recognizers, sid)
 	}
+	for sid := range tm.detectors {
+		tm.stopDetectorLocked(sid)
+	}
 }
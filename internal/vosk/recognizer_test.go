@@ -0,0 +1,466 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package vosk
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	vosk "github.com/alphacep/vosk-api/go"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+	"github.com/nextcloud/go_live_transcription/internal/languages"
+	"github.com/nextcloud/go_live_transcription/internal/signaling"
+)
+
+func TestIsHallucination(t *testing.T) {
+	// German gets a stop token of its own for the test
+	languages.StopTokens["de"] = []string{"ja"}
+	t.Cleanup(func() { delete(languages.StopTokens, "de") })
+
+	words := func(confs ...float64) []voskWord {
+		ws := make([]voskWord, 0, len(confs))
+		for _, c := range confs {
+			ws = append(ws, voskWord{Word: "w", Conf: c})
+		}
+		return ws
+	}
+	tests := []struct {
+		name     string
+		language string
+		message  string
+		result   voskResult
+		want     bool
+	}{
+		{"low confidence stop token", "en", "the", voskResult{Result: words(0.4)}, true},
+		{"confident stop token", "en", "the", voskResult{Result: words(0.95)}, false},
+		{"unknown confidence", "en", "the", voskResult{}, false},
+		{"alternatives", "en", "the", voskResult{Result: words(0), Alternatives: []voskAlternative{{}}}, false},
+		{"not a stop token", "en", "yes", voskResult{Result: words(0.1)}, false},
+		{"more than a stop token", "en", "the end", voskResult{Result: words(0.1, 0.1)}, false},
+		{"stop token of another language", "en", "ja", voskResult{Result: words(0.1)}, false},
+		{"low confidence German stop token", "de", "Ja", voskResult{Result: words(0.4)}, true},
+		{"confident German stop token", "de", "ja", voskResult{Result: words(0.95)}, false},
+		{"English stop token in German", "de", "the", voskResult{Result: words(0.1)}, false},
+		{"language without stop tokens", "fr", "the", voskResult{Result: words(0.1)}, false},
+	}
+	for _, tt := range tests {
+		r := &Recognizer{language: tt.language, opts: RecognizerOptions{StopTokenMaxConfidence: 0.7}}
+		if got := r.isHallucination(tt.message, tt.result); got != tt.want {
+			t.Errorf("%s: isHallucination(%q) = %v, want %v", tt.name, tt.message, got, tt.want)
+		}
+	}
+
+	// The confidence is asked for in the default setup, without word
+	// timings, only for languages with stop tokens
+	for lang, want := range map[string]int{"en": 1, "de": 1, "fr": 0} {
+		if got := wordsFlag(lang, RecognizerOptions{}); got != want {
+			t.Errorf("wordsFlag(%s) = %d, want %d", lang, got, want)
+		}
+	}
+	if wordsFlag("fr", RecognizerOptions{WordTimings: true}) != 1 {
+		t.Error("no word info with word timings")
+	}
+}
+
+// BenchmarkRecognizerReset compares the Vosk Reset done on a forced final
+// with the recreation done every RecognizerRecreateInterval. It needs an
+// unpacked model in LT_TEST_MODEL_PATH.
+func BenchmarkRecognizerReset(b *testing.B) {
+	path := os.Getenv("LT_TEST_MODEL_PATH")
+	if path == "" {
+		b.Skip("LT_TEST_MODEL_PATH not set")
+	}
+	model, err := vosk.NewModel(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer model.Free()
+
+	silence := make([]byte, 16000*2) // 1s at 16kHz
+	newRec := func(b *testing.B) *vosk.VoskRecognizer {
+		rec, err := newVoskRecognizer(model, 16000, "en", RecognizerOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		return rec
+	}
+
+	b.Run("reset", func(b *testing.B) {
+		rec := newRec(b)
+		defer rec.Free()
+		for b.Loop() {
+			b.StopTimer()
+			rec.AcceptWaveform(silence)
+			b.StartTimer()
+			rec.Reset()
+		}
+	})
+	b.Run("recreate", func(b *testing.B) {
+		rec := newRec(b)
+		for b.Loop() {
+			b.StopTimer()
+			rec.AcceptWaveform(silence)
+			b.StartTimer()
+			rec.Free()
+			rec = newRec(b)
+		}
+		rec.Free()
+	})
+}
+
+func TestForcedFinalReset(t *testing.T) {
+	const sampleRate = 16000
+	recreateAfter := int64(constants.RecognizerRecreateInterval.Seconds() * sampleRate)
+	for _, tt := range []struct {
+		name     string
+		recreate bool
+		fed      int64 // samples since the recognizer was created
+		want     forcedReset
+	}{
+		{"kept without recreation", false, 0, keepRecognizer},
+		{"kept however long it runs", false, 10 * recreateAfter, keepRecognizer},
+		{"reset before the interval", true, recreateAfter - 1, resetRecognizerState},
+		{"recreated at the interval", true, recreateAfter, recreateRecognizer},
+	} {
+		r := &Recognizer{
+			sampleRate:      sampleRate,
+			opts:            RecognizerOptions{RecreateOnForceFinalize: tt.recreate},
+			samplesFed:      tt.fed + 1000,
+			recognizerStart: 1000,
+		}
+		if got := r.forcedFinalReset(); got != tt.want {
+			t.Errorf("%s: forcedFinalReset = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentTimes(t *testing.T) {
+	const sampleRate = 16000
+	emit := func(r *Recognizer, resultJSON string, final bool) signaling.Transcript {
+		t.Helper()
+		tr, ok := r.transcriptOf(resultJSON, final)
+		if !ok {
+			t.Fatalf("no transcript for %s", resultJSON)
+		}
+		return tr
+	}
+
+	// Utterances span from the previous final to the audio fed so far
+	r := &Recognizer{
+		language:       "en",
+		sampleRate:     sampleRate,
+		logger:         slog.Default(),
+		utteranceStart: 1 * sampleRate,
+		samplesFed:     2 * sampleRate,
+	}
+	if tr := emit(r, `{"partial": "hello"}`, false); tr.StartMs != 1000 || tr.EndMs != 2000 {
+		t.Errorf("partial at %d-%dms, want 1000-2000", tr.StartMs, tr.EndMs)
+	}
+	r.samplesFed = 3 * sampleRate
+	if tr := emit(r, `{"text": "hello world"}`, true); tr.StartMs != 1000 || tr.EndMs != 3000 {
+		t.Errorf("final at %d-%dms, want 1000-3000", tr.StartMs, tr.EndMs)
+	}
+	r.samplesFed = 4 * sampleRate
+	if tr := emit(r, `{"partial": "next"}`, false); tr.StartMs != 3000 || tr.EndMs != 4000 {
+		t.Errorf("partial after a final at %d-%dms, want 3000-4000", tr.StartMs, tr.EndMs)
+	}
+
+	// Word timings are relative to the recognizer's creation
+	r.opts.WordTimings = true
+	r.recognizerStart = 10 * sampleRate
+	words := `[{"start": 0.5, "end": 0.9, "word": "good", "conf": 1}, {"start": 1.0, "end": 1.25, "word": "morning", "conf": 1}]`
+	if tr := emit(r, `{"text": "good morning", "result": `+words+`}`, true); tr.StartMs != 10500 || tr.EndMs != 11250 {
+		t.Errorf("final with word timings at %d-%dms, want 10500-11250", tr.StartMs, tr.EndMs)
+	}
+	tr := emit(r, `{"alternatives": [{"confidence": 200, "text": "good morning", "result": `+words+`},
+		{"confidence": 150, "text": "could morning"}]}`, true)
+	if tr.StartMs != 10500 || tr.EndMs != 11250 || len(tr.Alternatives) != 2 {
+		t.Errorf("final with alternatives at %d-%dms with %d alternatives, want the best one's times",
+			tr.StartMs, tr.EndMs, len(tr.Alternatives))
+	}
+	// Partials have no words and keep the utterance span
+	if tr := emit(r, `{"partial": "good"}`, false); tr.StartMs != 4000 || tr.EndMs != 4000 {
+		t.Errorf("partial with word timings at %d-%dms, want 4000-4000", tr.StartMs, tr.EndMs)
+	}
+}
+
+func TestMinFinalLength(t *testing.T) {
+	for _, tt := range []struct {
+		name, lang, text string
+		words, chars     int
+		kept             bool
+	}{
+		{"no limits", "en", "uh", 0, 0, true},
+		{"too few words", "en", "uh", 2, 0, false},
+		{"enough words", "en", "see you", 2, 0, true},
+		{"too few letters", "en", "a", 0, 2, false},
+		{"punctuation is no letter", "en", "a.", 0, 2, false},
+		{"enough letters", "en", "ok", 0, 2, true},
+		{"both limits", "en", "okay", 2, 3, false},
+		// Without a separator every letter counts as a word
+		{"spaceless too short", "zh", "嗯", 2, 0, false},
+		{"spaceless long enough", "zh", "你 好", 2, 0, true},
+	} {
+		r := &Recognizer{
+			language:   tt.lang,
+			sampleRate: 16000,
+			logger:     slog.Default(),
+			opts:       RecognizerOptions{MinFinalWords: tt.words, MinFinalChars: tt.chars},
+		}
+		final, _ := json.Marshal(voskResult{Text: tt.text})
+		if _, kept := r.transcriptOf(string(final), true); kept != tt.kept {
+			t.Errorf("%s: final %q kept %v, want %v", tt.name, tt.text, kept, tt.kept)
+		}
+		// Partials are exempt
+		partial, _ := json.Marshal(voskResult{Partial: tt.text})
+		if _, kept := r.transcriptOf(string(partial), false); !kept {
+			t.Errorf("%s: partial %q dropped", tt.name, tt.text)
+		}
+	}
+}
+
+// The fixture is a final of Vosk with max alternatives set in word timing
+// mode, the last alternative with a word outside the grammar.
+func TestAlternativesFixture(t *testing.T) {
+	resultJSON, err := os.ReadFile("testdata/alternatives.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Recognizer{
+		language:        "en",
+		sampleRate:      16000,
+		logger:          slog.Default(),
+		recognizerStart: 16000,
+		opts:            RecognizerOptions{WordTimings: true, MaxAlternatives: 3, Grammar: `["meet at noon", "[unk]"]`},
+	}
+	tr, ok := r.transcriptOf(string(resultJSON), true)
+	if !ok {
+		t.Fatal("no transcript for the fixture")
+	}
+	if tr.Message != "meet at noon" || tr.StartMs != 1360 || tr.EndMs != 2590 {
+		t.Errorf("final %q at %d-%dms, want the best alternative at 1360-2590ms", tr.Message, tr.StartMs, tr.EndMs)
+	}
+	want := []signaling.Alternative{
+		{Message: "meet at noon", Confidence: 231.815979},
+		{Message: "meat at noon", Confidence: 229.104553},
+		{Message: "noon", Confidence: 226.500305},
+	}
+	if !slices.Equal(tr.Alternatives, want) {
+		t.Errorf("alternatives %+v, want %+v", tr.Alternatives, want)
+	}
+
+	// Partials never carry alternatives
+	if tr, _ := r.transcriptOf(`{"partial": "meet at"}`, false); tr.Alternatives != nil {
+		t.Errorf("partial with alternatives %+v", tr.Alternatives)
+	}
+}
+
+// finalDecoder yields a final for every chunk and signals each one.
+type finalDecoder struct {
+	voskDecoder
+	results chan struct{}
+}
+
+func (d *finalDecoder) AcceptWaveform([]byte) int { return 1 }
+
+func (d *finalDecoder) Result() string {
+	d.results <- struct{}{}
+	return `{"text": "hello"}`
+}
+
+func TestFinalQueueWaitReleasesLock(t *testing.T) {
+	dec := &finalDecoder{results: make(chan struct{}, 1)}
+	r := &Recognizer{
+		rec:           dec,
+		language:      "en",
+		sampleRate:    16000,
+		transcriptCh:  make(chan signaling.Transcript), // nobody reads yet
+		droppedFinals: new(atomic.Int64),
+		logger:        slog.Default(),
+	}
+
+	fed := make(chan struct{})
+	go func() {
+		r.FeedAudio(make([]byte, 640))
+		close(fed)
+	}()
+	<-dec.results
+
+	// The feed waits for the queue, but the recognizer stays available
+	locked := make(chan struct{})
+	go func() {
+		r.LastFeed()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(constants.FinalTranscriptQueueTimeout / 2):
+		t.Fatal("LastFeed blocked while a final waits for the queue")
+	}
+
+	if tr := <-r.transcriptCh; !tr.Final || tr.Message != "hello" {
+		t.Errorf("queued %+v, want the final", tr)
+	}
+	<-fed
+
+	if testing.Short() {
+		return
+	}
+	// Without a reader the final is dropped after the timeout
+	r.FeedAudio(make([]byte, 640))
+	if got := r.droppedFinals.Load(); got != 1 {
+		t.Errorf("%d dropped finals, want 1", got)
+	}
+}
+
+// nopDecoder recognizes nothing.
+type nopDecoder struct{}
+
+func (nopDecoder) AcceptWaveform([]byte) int { return 0 }
+func (nopDecoder) Result() string            { return "{}" }
+func (nopDecoder) PartialResult() string     { return "{}" }
+func (nopDecoder) FinalResult() string       { return "{}" }
+func (nopDecoder) Reset()                    {}
+func (nopDecoder) Free()                     {}
+
+// newTestTranscriberManager returns a room whose recognizers decode with
+// dec and take their models from a test model manager, installed as the
+// global one.
+func newTestTranscriberManager(t *testing.T, lang string, dec voskDecoder) (*TranscriberManager, *fakeModels) {
+	mm, fm := newTestModelManager(t)
+	prev, prevDecoder := GetModelManager(), newDecoder
+	globalModelManager = mm
+	newDecoder = func(*vosk.VoskModel, float64, string, RecognizerOptions) (voskDecoder, error) {
+		return dec, nil
+	}
+	t.Cleanup(func() {
+		globalModelManager, newDecoder = prev, prevDecoder
+	})
+
+	tm := NewTranscriberManager(lang, 16000, RecognizerOptions{}, make(chan signaling.Transcript, 10))
+	t.Cleanup(tm.CloseAll)
+	return tm, fm
+}
+
+func TestLanguageSwitchRefcounts(t *testing.T) {
+	tm, fm := newTestTranscriberManager(t, "en", nopDecoder{})
+	mm := GetModelManager()
+	create := func(sid string) {
+		t.Helper()
+		if _, err := tm.GetOrCreate(sid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wantLoaded := func(step string, want map[string]int) {
+		t.Helper()
+		if got := mm.LoadedModels(); !maps.Equal(got, want) {
+			t.Fatalf("%s: model references %v, want %v", step, got, want)
+		}
+	}
+
+	create("alice")
+	create("bob")
+	if err := tm.SetSpeakerLanguage("carol", "fr"); err != nil {
+		t.Fatal(err)
+	}
+	create("carol")
+	wantLoaded("created", map[string]int{"en": 2, "fr": 1})
+
+	// Switching releases the room language's references, not the speaker's
+	if err := tm.SetLanguage("de"); err != nil {
+		t.Fatal(err)
+	}
+	wantLoaded("switched", map[string]int{"fr": 1})
+	create("alice")
+	wantLoaded("recreated", map[string]int{"de": 1, "fr": 1})
+
+	// Failed switches take no reference
+	if err := tm.SetLanguage("it"); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("switch to a missing model: %v, want ErrModelNotFound", err)
+	}
+	if err := tm.SetLanguage("xx"); !errors.Is(err, ErrModelUnsupported) {
+		t.Errorf("switch to an unsupported language: %v, want ErrModelUnsupported", err)
+	}
+	if err := tm.SetLanguage("de"); err != nil {
+		t.Errorf("switch to the current language: %v", err)
+	}
+	wantLoaded("failed switches", map[string]int{"de": 1, "fr": 1})
+
+	tm.CloseAll()
+	wantLoaded("closed", map[string]int{})
+	if len(fm.freed) != fm.loads {
+		t.Errorf("freed %d of %d loaded models", len(fm.freed), fm.loads)
+	}
+}
+
+// utteranceDecoder has the same utterance pending for every chunk.
+type utteranceDecoder struct {
+	nopDecoder
+	partial, final string
+}
+
+func (d utteranceDecoder) PartialResult() string { return `{"partial": "` + d.partial + `"}` }
+func (d utteranceDecoder) FinalResult() string   { return `{"text": "` + d.final + `"}` }
+
+func TestLanguageSwitchFlushesUtterance(t *testing.T) {
+	tm, _ := newTestTranscriberManager(t, "en", utteranceDecoder{partial: "hello", final: "hello there"})
+	feed := func(sid string) {
+		t.Helper()
+		r, err := tm.GetOrCreate(sid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.FeedAudio(make([]byte, 640))
+	}
+	next := func() signaling.Transcript {
+		t.Helper()
+		select {
+		case tr := <-tm.transcriptCh:
+			return tr
+		default:
+			t.Fatal("no transcript")
+			return signaling.Transcript{}
+		}
+	}
+
+	if err := tm.SetSpeakerLanguage("carol", "fr"); err != nil {
+		t.Fatal(err)
+	}
+	feed("alice")
+	feed("carol")
+	next()
+	next()
+
+	// The pending partial is finalized in the language it was spoken in
+	if err := tm.SetLanguage("de"); err != nil {
+		t.Fatal(err)
+	}
+	tr := next()
+	if !tr.Final || tr.Message != "hello there" || tr.LangID != "en" || tr.SpeakerSessionID != "alice" {
+		t.Errorf("switch sent %+v, want alice's final in en", tr)
+	}
+	// A speaker with a language of their own keeps their utterance going
+	select {
+	case tr := <-tm.transcriptCh:
+		t.Errorf("switch also sent %+v", tr)
+	default:
+	}
+
+	// The new language's recognizer is only created with the next audio
+	if _, ok := tm.recognizers["alice"]; ok {
+		t.Error("recognizer recreated before the speaker's next audio")
+	}
+	feed("alice")
+	if tr := next(); tr.Final || tr.LangID != "de" {
+		t.Errorf("next audio sent %+v, want a partial in de", tr)
+	}
+}
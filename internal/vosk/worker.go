@@ -6,23 +6,42 @@ package vosk
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/nextcloud/go_live_transcription/internal/asr"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
 	"github.com/nextcloud/go_live_transcription/internal/signaling"
 )
 
 type AudioWorker struct {
 	client  *signaling.SpreedClient
-	manager *TranscriberManager
+	manager asr.Transcriber
+	mixer   *mixer // non-nil in mixed audio mode
+	drainCh chan chan struct{}
 	logger  *slog.Logger
+
+	fed     sync.Map // speaker session ID → *atomic.Int64, bytes of 16 kHz audio fed
+	limited int64    // chunks dropped at the recognizer limit
 }
 
-func NewAudioWorker(client *signaling.SpreedClient, manager *TranscriberManager) *AudioWorker {
-	return &AudioWorker{
+// NewAudioWorker creates the worker feeding a room's audio to its
+// transcriber. With mixed set, all speakers are downmixed into a single
+// recognizer, trading speaker attribution for one recognizer per room.
+func NewAudioWorker(client *signaling.SpreedClient, manager asr.Transcriber, mixed bool) *AudioWorker {
+	w := &AudioWorker{
 		client:  client,
 		manager: manager,
+		drainCh: make(chan chan struct{}),
 		logger:  slog.With("component", "audio_worker"),
 	}
+	if mixed {
+		w.mixer = newMixer()
+	}
+	return w
 }
 
 func (w *AudioWorker) Run(ctx context.Context) {
@@ -37,23 +56,85 @@ func (w *AudioWorker) Run(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case audio := <-w.client.PCMAudioCh:
-			if len(audio.Samples) == 0 {
-				continue
+			w.feed(audio)
+		case done := <-w.drainCh:
+			for len(w.client.PCMAudioCh) > 0 {
+				w.feed(<-w.client.PCMAudioCh)
 			}
-
-			rec, err := w.manager.GetOrCreate(audio.SessionID)
-			if err != nil {
-				w.logger.Error("failed to get/create recognizer",
-					"error", err,
-					"session_id", audio.SessionID,
-				)
-				continue
+			if w.mixer != nil {
+				w.feedRecognizer(mixedSessionID, w.mixer.flush(), w.mixer.takeMixed())
 			}
+			w.manager.FlushAll()
+			close(done)
+		}
+	}
+}
 
-			downsampled := downsample48to16(audio.Samples)
-			pcmBytes := int16ToBytes(downsampled)
-			rec.FeedAudio(pcmBytes)
+func (w *AudioWorker) feed(audio signaling.PCMAudio) {
+	if audio.Ended {
+		if w.mixer == nil {
+			w.manager.Remove(audio.SessionID)
+		}
+		w.fed.Delete(audio.SessionID)
+		return
+	}
+	if len(audio.Samples) == 0 {
+		return
+	}
+
+	sessionID := audio.SessionID
+	downsampled := downsample48to16(audio.Samples)
+	fed := map[string]int{sessionID: len(downsampled)}
+	if w.mixer != nil {
+		sessionID = mixedSessionID
+		if downsampled = w.mixer.add(audio.SessionID, downsampled, time.Now()); len(downsampled) == 0 {
+			return
 		}
+		fed = w.mixer.takeMixed()
+	}
+	w.feedRecognizer(sessionID, downsampled, fed)
+}
+
+// feedRecognizer feeds 16kHz samples to a session's recognizer and counts
+// fed as the samples per speaker in them.
+func (w *AudioWorker) feedRecognizer(sessionID string, samples []int16, fed map[string]int) {
+	if len(samples) == 0 {
+		return
+	}
+	err := w.manager.Feed(sessionID, int16ToBytes(samples))
+	if errors.Is(err, ErrRecognizerLimit) {
+		if w.limited++; w.limited%constants.AudioDropWarnEvery == 1 {
+			w.logger.Warn("recognizer limit reached, dropping audio",
+				"session_id", sessionID, "dropped_chunks", w.limited)
+		}
+		return
+	}
+	if err != nil {
+		w.logger.Error("failed to get/create recognizer",
+			"error", err,
+			"session_id", sessionID,
+		)
+		return
+	}
+	for sid, samples := range fed {
+		w.countFed(sid, samples*2)
+	}
+}
+
+// Drain feeds the audio still queued to the recognizers and finalizes their
+// current utterances. Stop the audio first, or new audio may follow.
+func (w *AudioWorker) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case w.drainCh <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -69,6 +150,52 @@ func (w *AudioWorker) SetLanguage(language string) error {
 	return w.manager.SetLanguage(language)
 }
 
+func (w *AudioWorker) SetSpeakerLanguage(sessionID, language string) error {
+	return w.manager.SetSpeakerLanguage(sessionID, language)
+}
+
+func (w *AudioWorker) SetLanguageDetection(candidates []string) {
+	w.manager.SetLanguageDetection(candidates)
+}
+
+func (w *AudioWorker) SetGrammar(phrases []string) {
+	w.manager.SetGrammar(phrases)
+}
+
+func (w *AudioWorker) SetForceFinalizeChunks(n int) {
+	w.manager.SetForceFinalizeChunks(n)
+}
+
+func (w *AudioWorker) SetPartials(enabled bool) {
+	w.manager.SetPartials(enabled)
+}
+
+func (w *AudioWorker) DroppedFinals() int64 {
+	return w.manager.DroppedFinals()
+}
+
+func (w *AudioWorker) Stats() (recognizers int, language string) {
+	return w.manager.Stats()
+}
+
+func (w *AudioWorker) countFed(sessionID string, n int) {
+	counter, ok := w.fed.Load(sessionID)
+	if !ok {
+		counter, _ = w.fed.LoadOrStore(sessionID, &atomic.Int64{})
+	}
+	counter.(*atomic.Int64).Add(int64(n))
+}
+
+// FedBytes returns the bytes of audio of a speaker fed for transcription,
+// in mixed audio mode those that went into the mix. It is forgotten when
+// the speaker's track ends.
+func (w *AudioWorker) FedBytes(sessionID string) int64 {
+	if counter, ok := w.fed.Load(sessionID); ok {
+		return counter.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
 func downsample48to16(samples []int16) []int16 {
 	const ratio = 3 // 48000 / 16000
 	outLen := len(samples) / ratio
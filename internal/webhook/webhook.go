@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/nextcloud/go_live_transcription/internal/constants"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the request body, as
+// "sha256=<hex>", when the poster has a secret.
+const SignatureHeader = "X-Signature-256"
+
+// Poster delivers JSON events to a webhook in the background, in order,
+// retrying failed deliveries with backoff. Events wait in a bounded queue
+// and are dropped when it is full, so posting never blocks the caller.
+type Poster struct {
+	url     string
+	secret  []byte // empty without signing
+	client  *http.Client
+	queue   chan []byte
+	pending atomic.Int64 // events queued or being delivered
+	dropped atomic.Int64
+	logger  *slog.Logger
+}
+
+// NewPoster creates the poster of a webhook URL queuing up to queueSize
+// events, reached through the proxy selected by proxy, see
+// appapi.Config.ProxyFunc.
+func NewPoster(webhookURL string, queueSize int, proxy func(*http.Request) (*url.URL, error)) *Poster {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	return &Poster{
+		url:    webhookURL,
+		client: &http.Client{Transport: transport, Timeout: constants.WebhookTimeout},
+		queue:  make(chan []byte, queueSize),
+		logger: slog.With("component", "webhook", "url", redactURL(webhookURL)),
+	}
+}
+
+// redactURL returns the scheme, host and path of a webhook URL, leaving out
+// the credentials and query that webhooks often authenticate with.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "invalid URL"
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+}
+
+// SetSecret makes the poster sign the events with secret, see
+// SignatureHeader. An empty secret leaves them unsigned. Must be called
+// before Run.
+func (p *Poster) SetSecret(secret string) {
+	p.secret = []byte(secret)
+}
+
+// Post queues an event, or drops it if the queue is full.
+func (p *Poster) Post(event any) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		p.logger.Error("failed to encode webhook event", "error", err)
+		return
+	}
+	p.pending.Add(1)
+	select {
+	case p.queue <- body:
+	default:
+		p.pending.Add(-1)
+		if p.dropped.Add(1)%constants.WebhookDropWarnEvery == 1 {
+			p.logger.Warn("webhook queue full, dropping events", "dropped", p.dropped.Load())
+		}
+	}
+}
+
+// Dropped returns the number of events dropped, on a full queue or after
+// the last delivery attempt failed.
+func (p *Poster) Dropped() int64 {
+	return p.dropped.Load()
+}
+
+// Pending returns the number of events queued or being delivered.
+func (p *Poster) Pending() int64 {
+	return p.pending.Load()
+}
+
+// Run delivers the queued events until ctx is done.
+func (p *Poster) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case body := <-p.queue:
+			if err := p.deliver(ctx, body); err != nil && ctx.Err() == nil {
+				p.dropped.Add(1)
+				p.logger.Warn("webhook delivery failed, dropping event", "error", err)
+			}
+			p.pending.Add(-1)
+		}
+	}
+}
+
+// Flush waits until the queued events are delivered or given up on, or
+// until ctx is done.
+func (p *Poster) Flush(ctx context.Context) {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for p.pending.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			p.logger.Warn("webhook events left undelivered", "pending", p.pending.Load())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliver posts an event, retrying up to WebhookMaxAttempts times. Client
+// errors other than timeouts and rate limits are not retried.
+func (p *Poster) deliver(ctx context.Context, body []byte) error {
+	delay := constants.WebhookRetryBaseDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		if retry, err = p.post(ctx, body); err == nil || !retry || attempt >= constants.WebhookMaxAttempts {
+			return err
+		}
+		p.logger.Debug("webhook delivery failed, retrying", "error", err, "attempt", attempt, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, constants.WebhookRetryMaxDelay)
+	}
+}
+
+func (p *Poster) post(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(p.secret) > 0 {
+		mac := hmac.New(sha256.New, p.secret)
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// *url.Error carries the full URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, fmt.Errorf("POST: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return true, fmt.Errorf("POST: status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("POST: status %d", resp.StatusCode)
+	}
+}
@@ -5,6 +5,9 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log/slog"
 	"net"
 	"net/http"
@@ -14,24 +17,52 @@ import (
 	"time"
 
 	"github.com/nextcloud/go_live_transcription/internal/appapi"
+	"github.com/nextcloud/go_live_transcription/internal/asr"
+	"github.com/nextcloud/go_live_transcription/internal/constants"
 	"github.com/nextcloud/go_live_transcription/internal/handlers"
 	"github.com/nextcloud/go_live_transcription/internal/service"
+	"github.com/nextcloud/go_live_transcription/internal/translation"
+	"github.com/nextcloud/go_live_transcription/internal/vosk"
 )
 
 func main() {
-	logLevel := slog.LevelInfo
-	if os.Getenv("LT_LOG_LEVEL") == "debug" {
-		logLevel = slog.LevelDebug
-	}
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
-	})))
+	slog.SetDefault(newLogger(slog.LevelInfo, true))
 
 	cfg, err := appapi.LoadConfig()
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
 		os.Exit(1)
 	}
+	slog.SetDefault(newLogger(cfg.LogLevel, !cfg.LogUnsafe))
+	if !translation.HasBackend(cfg.TranslationBackend) {
+		slog.Error("unknown LT_TRANSLATION_BACKEND",
+			"backend", cfg.TranslationBackend,
+			"available", translation.Backends(),
+		)
+		os.Exit(1)
+	}
+	if !asr.HasBackend(cfg.ASRBackend) {
+		slog.Error("unknown LT_ASR_BACKEND",
+			"backend", cfg.ASRBackend,
+			"available", asr.Backends(),
+		)
+		os.Exit(1)
+	}
+	if asr.NeedsEndpoint(cfg.ASRBackend) && cfg.ASREndpoint == "" {
+		slog.Error("LT_ASR_ENDPOINT is required with this LT_ASR_BACKEND", "backend", cfg.ASRBackend)
+		os.Exit(1)
+	}
+	if cfg.LogUnsafe {
+		slog.Warn("LT_LOG_UNSAFE is enabled, room tokens and session IDs are logged in full")
+	}
+
+	vosk.GetModelManager().SetStorageDir(cfg.PersistentStorage)
+	vosk.GetModelManager().SetAvailableModelsTTL(cfg.ModelsRefreshInterval)
+	vosk.GetModelManager().SetMaxIdleModels(cfg.MaxIdleModels)
+	vosk.SetModelSource(cfg.ModelsBaseURL, cfg.ModelsRepo, cfg.ModelsRevision)
+	vosk.SetRecognizerLimits(cfg.MaxRecognizersPerRoom, cfg.MaxRecognizers)
+	translation.SetGlobalTaskLimit(cfg.MaxTranslationTasks)
+	vosk.SetDownloadProxy(cfg.ProxyFunc())
 
 	slog.Info("starting go_live_transcription",
 		"app_id", cfg.AppID,
@@ -49,20 +80,24 @@ func main() {
 
 	skipAuth := map[string]bool{
 		"/heartbeat": true,
+		"/ready":     true,
 	}
 	authedHandler := appapi.AuthMiddleware(cfg, skipAuth, mux)
 
 	srv := &http.Server{
 		Handler:      authedHandler,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		ReadTimeout:  cfg.ServerReadTimeout,
+		WriteTimeout: cfg.ServerWriteTimeout,
+		IdleTimeout:  cfg.ServerIdleTimeout,
 	}
 
 	var ln net.Listener
-	if os.Getenv("HP_SHARED_KEY") != "" {
+	if cfg.HPSharedKey != "" {
 		sockPath := "/tmp/exapp.sock"
-		_ = os.Remove(sockPath) // clean up stale socket
+		if err := removeStaleSocket(sockPath); err != nil {
+			slog.Error("cannot take over unix socket", "path", sockPath, "error", err)
+			os.Exit(1)
+		}
 		ln, err = net.Listen("unix", sockPath)
 		if err != nil {
 			slog.Error("failed to listen on unix socket", "path", sockPath, "error", err)
@@ -82,6 +117,13 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
 
+	go svc.RunLoadMonitor(ctx)
+	// Webhooks outlive ctx to deliver the events of the shutdown
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	defer stopWebhooks()
+	go svc.RunWebhooks(webhookCtx)
+	go svc.RunHPBSettingsRefresh(ctx, cfg.HPBSettingsRefreshInterval)
+
 	go func() {
 		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
 			slog.Error("HTTP server error", "error", err)
@@ -92,6 +134,9 @@ func main() {
 	<-ctx.Done()
 	slog.Info("shutting down")
 
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.DrainTimeout)
+	svc.Drain(drainCtx)
+	cancelDrain()
 	svc.Shutdown()
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -100,6 +145,44 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		slog.Error("server shutdown error", "error", err)
 	}
+	svc.FlushWebhooks(shutdownCtx)
 
 	slog.Info("shutdown complete")
 }
+
+func newLogger(level slog.Level, redact bool) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if redact {
+				a = appapi.RedactLogAttr(groups, a)
+			}
+			return replaceLevelName(groups, a)
+		},
+	}))
+}
+
+// replaceLevelName logs the trace level as TRACE instead of DEBUG-4.
+func replaceLevelName(_ []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey {
+		if level, ok := a.Value.Any().(slog.Level); ok && level == constants.LogLevelTrace {
+			a.Value = slog.StringValue("TRACE")
+		}
+	}
+	return a
+}
+
+// removeStaleSocket removes the socket file left behind by a previous
+// instance. A socket that still accepts connections belongs to a running
+// instance and is left alone, so two instances can't clobber each other.
+func removeStaleSocket(path string) error {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("socket is in use, is another instance running?")
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing stale socket: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: 2026 Nextcloud GmbH and Nextcloud contributors
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	// Nothing to remove
+	if err := removeStaleSocket(path); err != nil {
+		t.Fatalf("missing socket: %v", err)
+	}
+
+	// A running instance keeps its socket
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := removeStaleSocket(path); err == nil {
+		t.Error("took over the socket of a running instance")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("socket in use removed: %v", err)
+	}
+
+	// The socket of an instance that exited is removed
+	ln.Close()
+	if err := removeStaleSocket(path); err != nil {
+		t.Fatalf("stale socket: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("stale socket left behind: %v", err)
+	}
+}